@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	logGo "log"
 	"strconv"
@@ -57,7 +58,8 @@ func main() {
 	helpers.InitReadBlackListEmail()
 
 	// Init Kafka Config
-	kafkaConfluent.InitKafkaConfig(configs.GetConfig().Kafka.KafkaUrl, configs.GetConfig().Kafka.KafkaUsername, configs.GetConfig().Kafka.KafkaPassword)
+	kafkaConfluent.InitKafkaConfig(configs.GetConfig().Kafka.KafkaUrl, configs.GetConfig().Kafka.KafkaUsername, configs.GetConfig().Kafka.KafkaPassword,
+		configs.GetConfig().Kafka.KafkaPartitioner)
 
 	// Init instance fiber
 	app := fiber.New(fiber.Config{
@@ -101,8 +103,7 @@ func main() {
 
 func setHttp(app *fiber.App, gs *graceful.GracefulShutdown) {
 	// Init Redis
-	redisClient := redis.InitConnection(configs.GetConfig().Redis.RedisDB, configs.GetConfig().Redis.RedisHost, configs.GetConfig().Redis.RedisPort,
-		configs.GetConfig().Redis.RedisPassword, configs.GetConfig().Redis.RedisAppConfig)
+	redisClient := redis.InitConnection(configs.GetConfig().Redis)
 	// Init Jwt
 	helperImpl := &helpers.JwtImpl{}
 	helperImpl.InitConfig(configs.GetConfig().Jwt.JwtPrivateKey, configs.GetConfig().Jwt.JwtPublicKey,
@@ -111,23 +112,62 @@ func setHttp(app *fiber.App, gs *graceful.GracefulShutdown) {
 	logger := log.GetLogger()
 	mongoMasterClient := mongodb.NewMongoDBLogger(mongodb.GetMasterConn(), mongodb.GetMasterDBName(), logger)
 	mongoSlaveClient := mongodb.NewMongoDBLogger(mongodb.GetSlaveConn(), mongodb.GetMasterDBName(), logger)
-	kafkaProducer, err := kafkaConfluent.NewProducer(kafkaConfluent.GetConfig().GetKafkaConfig(configs.GetConfig().ServiceName, true), logger)
+	kafkaProducer, err := kafkaConfluent.NewProducer(kafkaConfluent.GetConfig().GetProducerConfig(configs.GetConfig().ServiceName), logger)
 	if err != nil {
 		panic(err)
 	}
+
+	workerQueryMongodbRepo := workerRepoQuery.NewQueryMongodbRepository(mongoSlaveClient, logger)
+	workerQueryMongodbPrimary := workerRepoQuery.NewQueryMongodbRepository(mongoMasterClient, logger)
+	workerQueryMongodbCommand := workerRepoCommand.NewCommandMongodbRepository(mongoMasterClient, logger)
+	workerUsecaseCommand := workerUsecase.NewCommandUsecase(workerQueryMongodbRepo, workerQueryMongodbPrimary, workerQueryMongodbCommand, redisClient, logger)
+	workerUsecaseQuery := workerUsecase.NewQueryUsecase(workerQueryMongodbRepo, redisClient, logger)
+	gs.Register(workerUsecaseCommand)
+
+	// duplicated seats are rejected by the database even if two generations ever run at once
+	if resp := <-workerQueryMongodbCommand.CreateBankTicketIndex(context.Background()); resp.Error != nil {
+		logger.Error(context.Background(), "Failed create bank ticket index", resp.Error.Error())
+	}
+	if resp := <-workerQueryMongodbCommand.CreatePaymentHistoryIndex(context.Background()); resp.Error != nil {
+		logger.Error(context.Background(), "Failed create payment history index", resp.Error.Error())
+	}
+	if resp := <-workerQueryMongodbCommand.CreateOrderIndex(context.Background()); resp.Error != nil {
+		logger.Error(context.Background(), "Failed create order index", resp.Error.Error())
+	}
+	if resp := <-workerQueryMongodbCommand.CreateOutboxEventIndex(context.Background()); resp.Error != nil {
+		logger.Error(context.Background(), "Failed create outbox event index", resp.Error.Error())
+	}
+	if resp := <-workerQueryMongodbCommand.CreateOutboxEventSentIndex(context.Background(), workerHandler.OutboxSentRetention()); resp.Error != nil {
+		logger.Error(context.Background(), "Failed create outbox event sent index", resp.Error.Error())
+	}
+
+	// jobs are run in process, those left unfinished by a stopped instance are failed so they are not reported as pending forever
+	if failed, err := workerUsecaseCommand.FailAbandonedWorkerJob(context.Background()); err != nil {
+		logger.Error(context.Background(), "Failed mark abandoned worker job", err.Error())
+	} else if failed > 0 {
+		logger.Info(context.Background(), fmt.Sprintf("Marked %d abandoned worker job as failed", failed), "")
+	}
+
+	workerRetrier := workerHandler.NewWorkerRetrier(kafkaProducer, logger)
+
+	// set module
+	workerHandler.InitWorkerHttpHandler(app, workerUsecaseCommand, workerUsecaseQuery, logger, redisClient)
+	workerHandler.InitDeadLetterHttpHandler(app, workerRetrier, logger, redisClient)
+	cronScheduler := workerHandler.InitCronHandler(workerUsecaseCommand, workerUsecaseQuery, redisClient, logger)
+	workerHandler.InitCronAdminHttpHandler(app, cronScheduler, workerUsecaseQuery, logger, redisClient)
+	workerHandler.InitConsistencyHttpHandler(app, workerUsecaseCommand, workerUsecaseQuery, logger, redisClient)
+	gs.Register(cronScheduler)
+	if workerConsumer := workerHandler.InitWorkerEventConflHandler(workerUsecaseCommand, workerRetrier, logger); workerConsumer != nil {
+		gs.Register(workerConsumer)
+	}
+	// the outbox relay is closed after the jobs saving events and before the producer it waits delivery reports from
+	gs.Register(workerHandler.InitOutboxRelay(workerUsecaseCommand, workerUsecaseQuery, kafkaProducer, logger))
+
+	// closers run in order, so connections are closed after the jobs and consumers using them
 	gs.Register(
 		mongoMasterClient,
 		mongoSlaveClient,
 		graceful.FnWithError(redisClient.Close),
 		kafkaProducer,
 	)
-
-	workerQueryMongodbRepo := workerRepoQuery.NewQueryMongodbRepository(mongoSlaveClient, logger)
-	workerQueryMongodbCommand := workerRepoCommand.NewCommandMongodbRepository(mongoMasterClient, logger)
-	workerUsecaseCommand := workerUsecase.NewCommandUsecase(workerQueryMongodbRepo, workerQueryMongodbCommand, logger)
-
-	// set module
-	workerHandler.InitWorkerHttpHandler(app, workerUsecaseCommand, logger, redisClient)
-	workerHandler.InitCronHandler(workerUsecaseCommand, logger)
-	workerHandler.InitWorkerEventConflHandler(workerUsecaseCommand, logger)
 }
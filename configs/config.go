@@ -23,6 +23,7 @@ type Config struct {
 	Datadog           DatadogConfig    `envconfig:"datadog"`
 	Kafka             KafkaConfig      `envconfig:"kafka"`
 	Jwt               JwtConfig        `envconfig:"jwt"`
+	Worker            WorkerConfig     `envconfig:"worker"`
 	UsernameBasicAuth string           `envconfig:"username_basic_auth"`
 	PasswordBasicAuth string           `envconfig:"password_basic_auth"`
 	ShutDownDelay     string           `envconfig:"shutdown_delay"`
@@ -61,11 +62,13 @@ type MongoDBConfig struct {
 }
 
 type RedisConfig struct {
-	RedisDB        string `envconfig:"redis_db"`
-	RedisHost      string `envconfig:"redis_host"`
-	RedisPort      string `envconfig:"redis_port"`
-	RedisPassword  string `envconfig:"redis_password"`
-	RedisAppConfig string `envconfig:"redis_app_config"`
+	RedisDB                 string `envconfig:"redis_db"`
+	RedisHost               string `envconfig:"redis_host"`
+	RedisPort               string `envconfig:"redis_port"`
+	RedisPassword           string `envconfig:"redis_password"`
+	RedisAppConfig          string `envconfig:"redis_app_config"`
+	RedisSentinelMasterName string `envconfig:"redis_sentinel_master_name"`
+	RedisSentinelPassword   string `envconfig:"redis_sentinel_password"`
 }
 
 type APMElasticConfig struct {
@@ -82,9 +85,14 @@ type DatadogConfig struct {
 }
 
 type KafkaConfig struct {
-	KafkaUrl      string `envconfig:"kafka_url"`
-	KafkaUsername string `envconfig:"kafka_username"`
-	KafkaPassword string `envconfig:"kafka_password"`
+	KafkaUrl            string `envconfig:"kafka_url"`
+	KafkaUsername       string `envconfig:"kafka_username"`
+	KafkaPassword       string `envconfig:"kafka_password"`
+	KafkaMaxInFlight    string `envconfig:"kafka_max_in_flight"`
+	KafkaMaxAttempts    string `envconfig:"kafka_max_attempts"`
+	KafkaRetryBackoffMs string `envconfig:"kafka_retry_backoff_ms"`
+	KafkaRetryTiers     string `envconfig:"kafka_retry_tiers"`
+	KafkaPartitioner    string `envconfig:"kafka_partitioner"`
 }
 
 type JwtConfig struct {
@@ -94,6 +102,36 @@ type JwtConfig struct {
 	JwtRefreshPublicKey  string `envconfig:"public_key_refresh"`
 }
 
+type WorkerConfig struct {
+	BankTicketChunkSize            string `envconfig:"worker_bank_ticket_chunk_size"`
+	ExpiryBatchSize                string `envconfig:"worker_expiry_batch_size"`
+	ExpiryTimeBudget               string `envconfig:"worker_expiry_time_budget"`
+	InstanceId                     string `envconfig:"worker_instance_id"`
+	JobStaleAfter                  string `envconfig:"worker_job_stale_after"`
+	CronLeaseTTL                   string `envconfig:"worker_cron_lease_ttl"`
+	CronTimezone                   string `envconfig:"worker_cron_timezone"`
+	CronTimeout                    string `envconfig:"worker_cron_timeout"`
+	CronExpiryPayment              string `envconfig:"worker_cron_expiry_payment"`
+	CronExpiryBankTicket           string `envconfig:"worker_cron_expiry_bank_ticket"`
+	CronReconcileInventory         string `envconfig:"worker_cron_reconcile_inventory"`
+	InventoryBatchSize             string `envconfig:"worker_inventory_batch_size"`
+	CronReconcileTicketDetail      string `envconfig:"worker_cron_reconcile_ticket_detail"`
+	ReconcileTicketDetailFix       string `envconfig:"worker_reconcile_ticket_detail_fix"`
+	ReconcileTicketDetailBatchSize string `envconfig:"worker_reconcile_ticket_detail_batch_size"`
+	CronCleanupOrphan              string `envconfig:"worker_cron_cleanup_orphan"`
+	OrphanGracePeriod              string `envconfig:"worker_orphan_grace_period"`
+	OrphanPaymentLookback          string `envconfig:"worker_orphan_payment_lookback"`
+	OrphanOrderAction              string `envconfig:"worker_orphan_order_action"`
+	OrphanBankTicketAction         string `envconfig:"worker_orphan_bank_ticket_action"`
+	OrphanPaymentAction            string `envconfig:"worker_orphan_payment_action"`
+	OutboxInterval                 string `envconfig:"worker_outbox_interval"`
+	OutboxBatchSize                string `envconfig:"worker_outbox_batch_size"`
+	OutboxClaimTimeout             string `envconfig:"worker_outbox_claim_timeout"`
+	OutboxMinBackoff               string `envconfig:"worker_outbox_min_backoff"`
+	OutboxMaxBackoff               string `envconfig:"worker_outbox_max_backoff"`
+	OutboxSentRetention            string `envconfig:"worker_outbox_sent_retention"`
+}
+
 func InitConfig() *Config {
 	err := godotenv.Load()
 	if err != nil {
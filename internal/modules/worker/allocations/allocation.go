@@ -0,0 +1,266 @@
+package allocations
+
+import (
+	"fmt"
+	"sort"
+	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/models/dto"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
+)
+
+// NewAllocationStrategy returns the strategy registered under name, an empty name is rank by sellout
+func NewAllocationStrategy(name string) (worker.AllocationStrategy, error) {
+	switch name {
+	case "", constants.AllocationRankBySellout:
+		return rankBySellout{}, nil
+	case constants.AllocationOfflineDemand:
+		return offlineDemand{}, nil
+	case constants.AllocationFixedQuota:
+		return fixedQuota{}, nil
+	case constants.AllocationWaitingQueue:
+		return waitingQueue{}, nil
+	}
+	return nil, errors.BadRequest(fmt.Sprintf("allocation strategy %s is unknown", name))
+}
+
+// rankBySellout gives the country list percentages to countries by their rank,
+// the best ranked country must have sold out its offline tickets first
+type rankBySellout struct{}
+
+func (rankBySellout) Name() string {
+	return constants.AllocationRankBySellout
+}
+
+func (rankBySellout) Allocate(input dto.AllocationInput) ([]dto.CountryQuota, error) {
+	if err := validateCountryList(input.CountryList); err != nil {
+		return nil, err
+	}
+
+	// the country numbers run from 1, so the rank picks the country list entry
+	countryList := sortedCountryList(input.CountryList)
+	percentages := make([]int, 0, len(countryList))
+	for _, v := range countryList {
+		percentages = append(percentages, v.Percentage)
+	}
+	quotas := helpers.Apportion(input.TotalQuota, percentages)
+
+	assigned := assignedCountries(input.CountryList)
+	countries := make([]dto.CountryQuota, 0)
+	for i, v := range input.Countries {
+		if i == len(countryList) {
+			break
+		}
+		if countryList[i].CountryCode != "" || assigned[v.CountryCode] {
+			continue
+		}
+		if i == 0 && v.TotalAvailableTicket != 0 {
+			return nil, errors.BadRequest("offline ticket still ready")
+		}
+		countries = append(countries, dto.CountryQuota{
+			CountryCode:   v.CountryCode,
+			CountryNumber: countryList[i].CountryNumber,
+			TotalQuota:    quotas[i],
+		})
+	}
+	return countries, nil
+}
+
+// offlineDemand splits the online quota by the offline tickets each country has sold
+type offlineDemand struct{}
+
+func (offlineDemand) Name() string {
+	return constants.AllocationOfflineDemand
+}
+
+func (offlineDemand) Allocate(input dto.AllocationInput) ([]dto.CountryQuota, error) {
+	ranked, err := rankedCountries(input)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make([]int, 0, len(ranked))
+	for _, v := range ranked {
+		sold := v.TotalTicket - v.TotalAvailableTicket
+		if sold < 0 {
+			sold = 0
+		}
+		weights = append(weights, sold)
+	}
+	if sum(weights) == 0 {
+		return nil, errors.BadRequest("no offline ticket sold yet")
+	}
+	return weightedQuotas(input, ranked, weights), nil
+}
+
+// fixedQuota gives each country of the country list its configured quota
+type fixedQuota struct{}
+
+func (fixedQuota) Name() string {
+	return constants.AllocationFixedQuota
+}
+
+func (fixedQuota) Allocate(input dto.AllocationInput) ([]dto.CountryQuota, error) {
+	if len(input.CountryList) == 0 {
+		return nil, errors.BadRequest("country list is empty")
+	}
+
+	total := 0
+	codes := make(map[string]bool)
+	for _, v := range input.CountryList {
+		if v.CountryCode == "" {
+			return nil, errors.BadRequest(fmt.Sprintf("country code of country number %d is empty", v.CountryNumber))
+		}
+		if v.Quota < 0 {
+			return nil, errors.BadRequest(fmt.Sprintf("quota of country %s is negative", v.CountryCode))
+		}
+		if codes[v.CountryCode] {
+			return nil, errors.BadRequest(fmt.Sprintf("country %s is duplicated", v.CountryCode))
+		}
+		codes[v.CountryCode] = true
+		total += v.Quota
+	}
+	if total > input.TotalQuota {
+		return nil, errors.BadRequest(fmt.Sprintf("country list quota %d exceeds total quota %d", total, input.TotalQuota))
+	}
+
+	countries := make([]dto.CountryQuota, 0)
+	for _, v := range sortedCountryList(input.CountryList) {
+		if v.Quota == 0 {
+			continue
+		}
+		countries = append(countries, dto.CountryQuota{
+			CountryCode:   v.CountryCode,
+			CountryNumber: v.CountryNumber,
+			TotalQuota:    v.Quota,
+		})
+	}
+	return countries, nil
+}
+
+// waitingQueue splits the online quota by the size of each country waiting queue
+type waitingQueue struct{}
+
+func (waitingQueue) Name() string {
+	return constants.AllocationWaitingQueue
+}
+
+func (waitingQueue) Allocate(input dto.AllocationInput) ([]dto.CountryQuota, error) {
+	ranked, err := rankedCountries(input)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make([]int, 0, len(ranked))
+	for _, v := range ranked {
+		size := v.QueueSize
+		if size < 0 {
+			size = 0
+		}
+		weights = append(weights, size)
+	}
+	if sum(weights) == 0 {
+		return nil, errors.BadRequest("waiting queue is empty")
+	}
+	return weightedQuotas(input, ranked, weights), nil
+}
+
+// validateCountryList checks the country list ranks and its percentages sum to 100
+func validateCountryList(countryList []entity.CountryList) error {
+	if err := validateCountryNumbers(countryList); err != nil {
+		return err
+	}
+
+	total := 0
+	for _, v := range countryList {
+		if v.Percentage < 0 {
+			return errors.BadRequest(fmt.Sprintf("percentage of country number %d is negative", v.CountryNumber))
+		}
+		total += v.Percentage
+	}
+	if total != 100 {
+		return errors.BadRequest(fmt.Sprintf("country list percentage must sum to 100, got %d", total))
+	}
+
+	return nil
+}
+
+// validateCountryNumbers checks the country list ranks are unique and run from 1 to its length,
+// a rank outside of it has no entry to save its country in
+func validateCountryNumbers(countryList []entity.CountryList) error {
+	if len(countryList) == 0 {
+		return errors.BadRequest("country list is empty")
+	}
+
+	numbers := make(map[int]bool)
+	for _, v := range countryList {
+		if v.CountryNumber < 1 || v.CountryNumber > len(countryList) {
+			return errors.BadRequest(fmt.Sprintf("country number %d is outside the country list of %d countries", v.CountryNumber, len(countryList)))
+		}
+		if numbers[v.CountryNumber] {
+			return errors.BadRequest(fmt.Sprintf("country number %d is duplicated", v.CountryNumber))
+		}
+		numbers[v.CountryNumber] = true
+	}
+	return nil
+}
+
+// sortedCountryList sorts a copy of the country list by rank so leftover seats go to the best ranked countries on ties
+func sortedCountryList(countryList []entity.CountryList) []entity.CountryList {
+	sorted := append([]entity.CountryList{}, countryList...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CountryNumber < sorted[j].CountryNumber
+	})
+	return sorted
+}
+
+// rankedCountries returns the ranked countries that have an entry in the country list
+func rankedCountries(input dto.AllocationInput) ([]dto.CountryDemand, error) {
+	if err := validateCountryNumbers(input.CountryList); err != nil {
+		return nil, err
+	}
+	if len(input.Countries) > len(input.CountryList) {
+		return input.Countries[:len(input.CountryList)], nil
+	}
+	return input.Countries, nil
+}
+
+// weightedQuotas splits the total quota between the ranked countries by weights, countries already allocated
+// keep their allocation and countries left without seats are dropped
+func weightedQuotas(input dto.AllocationInput, ranked []dto.CountryDemand, weights []int) []dto.CountryQuota {
+	countryList := sortedCountryList(input.CountryList)
+	assigned := assignedCountries(input.CountryList)
+	countries := make([]dto.CountryQuota, 0)
+	for i, quota := range helpers.Apportion(input.TotalQuota, weights) {
+		if quota == 0 || countryList[i].CountryCode != "" || assigned[ranked[i].CountryCode] {
+			continue
+		}
+		countries = append(countries, dto.CountryQuota{
+			CountryCode:   ranked[i].CountryCode,
+			CountryNumber: countryList[i].CountryNumber,
+			TotalQuota:    quota,
+		})
+	}
+	return countries
+}
+
+// assignedCountries returns the countries the country list already has an allocation for
+func assignedCountries(countryList []entity.CountryList) map[string]bool {
+	assigned := make(map[string]bool)
+	for _, v := range countryList {
+		if v.CountryCode != "" {
+			assigned[v.CountryCode] = true
+		}
+	}
+	return assigned
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,286 @@
+package allocations_test
+
+import (
+	"fmt"
+	"testing"
+	"worker-service/internal/modules/worker/allocations"
+	"worker-service/internal/modules/worker/models/dto"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/constants"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AllocationTestSuite struct {
+	suite.Suite
+	countries   []dto.CountryDemand
+	countryList []entity.CountryList
+}
+
+func (suite *AllocationTestSuite) SetupTest() {
+	// ranked by remaining offline tickets
+	suite.countries = []dto.CountryDemand{
+		{CountryCode: "ID", TotalTicket: 100, TotalAvailableTicket: 0, QueueSize: 50},
+		{CountryCode: "SG", TotalTicket: 100, TotalAvailableTicket: 40, QueueSize: 30},
+		{CountryCode: "MY", TotalTicket: 100, TotalAvailableTicket: 70, QueueSize: 20},
+	}
+	suite.countryList = []entity.CountryList{{CountryNumber: 1}, {CountryNumber: 2}, {CountryNumber: 3}}
+}
+
+func TestAllocationTestSuite(t *testing.T) {
+	suite.Run(t, new(AllocationTestSuite))
+}
+
+func (suite *AllocationTestSuite) allocate(name string, input dto.AllocationInput) ([]dto.CountryQuota, error) {
+	strategy, err := allocations.NewAllocationStrategy(name)
+	suite.NoError(err)
+	suite.Equal(name, strategy.Name())
+	return strategy.Allocate(input)
+}
+
+func (suite *AllocationTestSuite) TestDefaultStrategy() {
+	strategy, err := allocations.NewAllocationStrategy("")
+	suite.NoError(err)
+	suite.Equal(constants.AllocationRankBySellout, strategy.Name())
+}
+
+func (suite *AllocationTestSuite) TestUnknownStrategy() {
+	_, err := allocations.NewAllocationStrategy("random")
+	suite.Error(err)
+}
+
+func (suite *AllocationTestSuite) TestRankBySellout() {
+	countries, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota: 10,
+		CountryList: []entity.CountryList{
+			{CountryNumber: 2, Percentage: 33},
+			{CountryNumber: 1, Percentage: 67},
+		},
+		Countries: suite.countries,
+	})
+	suite.NoError(err)
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "ID", CountryNumber: 1, TotalQuota: 7},
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 3},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestRankBySelloutSkipAssigned() {
+	countries, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota: 10,
+		CountryList: []entity.CountryList{
+			{CountryNumber: 1, Percentage: 50, CountryCode: "ID"},
+			{CountryNumber: 2, Percentage: 50},
+		},
+		Countries: suite.countries,
+	})
+	suite.NoError(err)
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 5},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestRankBySelloutSkipAssignedRank() {
+	countries, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota: 10,
+		CountryList: []entity.CountryList{
+			{CountryNumber: 1, Percentage: 50, CountryCode: "MY"},
+			{CountryNumber: 2, Percentage: 50},
+		},
+		Countries: suite.countries,
+	})
+	suite.NoError(err)
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 5},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestRankBySelloutProportionalRemainder() {
+	countries, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota: 7,
+		CountryList: []entity.CountryList{
+			{CountryNumber: 1, Percentage: 20},
+			{CountryNumber: 2, Percentage: 40},
+			{CountryNumber: 3, Percentage: 40},
+		},
+		Countries: suite.countries,
+	})
+	suite.NoError(err)
+	// 1.4, 2.8 and 2.8 seats, the leftover seats go to the largest remainders
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "ID", CountryNumber: 1, TotalQuota: 1},
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 3},
+		{CountryCode: "MY", CountryNumber: 3, TotalQuota: 3},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestRankBySelloutRemainderTiesByRank() {
+	countryList := make([]entity.CountryList, 0, 10)
+	demands := make([]dto.CountryDemand, 0, 10)
+	for i := 1; i <= 10; i++ {
+		countryList = append(countryList, entity.CountryList{CountryNumber: i, Percentage: 10})
+		demands = append(demands, dto.CountryDemand{CountryCode: fmt.Sprintf("C%d", i)})
+	}
+
+	countries, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota:  19,
+		CountryList: countryList,
+		Countries:   demands,
+	})
+	suite.NoError(err)
+	// 1.9 seats each, the 9 leftover seats go to the best ranked countries
+	quotas := make([]int, 0, len(countries))
+	for _, country := range countries {
+		quotas = append(quotas, country.TotalQuota)
+	}
+	suite.Equal([]int{2, 2, 2, 2, 2, 2, 2, 2, 2, 1}, quotas)
+}
+
+func (suite *AllocationTestSuite) TestRankBySelloutErrCountryNumber() {
+	_, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota: 10,
+		CountryList: []entity.CountryList{
+			{CountryNumber: 1, Percentage: 50},
+			{CountryNumber: 3, Percentage: 50},
+		},
+		Countries: suite.countries,
+	})
+	suite.EqualError(err, "country number 3 is outside the country list of 2 countries")
+}
+
+func (suite *AllocationTestSuite) TestRankBySelloutNotSoldOut() {
+	suite.countries[0].TotalAvailableTicket = 1
+	_, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota:  10,
+		CountryList: []entity.CountryList{{CountryNumber: 1, Percentage: 100}},
+		Countries:   suite.countries,
+	})
+	suite.EqualError(err, "offline ticket still ready")
+}
+
+func (suite *AllocationTestSuite) TestRankBySelloutErrPercentage() {
+	_, err := suite.allocate(constants.AllocationRankBySellout, dto.AllocationInput{
+		TotalQuota:  10,
+		CountryList: []entity.CountryList{{CountryNumber: 1, Percentage: 90}},
+		Countries:   suite.countries,
+	})
+	suite.EqualError(err, "country list percentage must sum to 100, got 90")
+}
+
+func (suite *AllocationTestSuite) TestOfflineDemand() {
+	countries, err := suite.allocate(constants.AllocationOfflineDemand, dto.AllocationInput{
+		TotalQuota:  100,
+		CountryList: suite.countryList,
+		Countries:   suite.countries,
+	})
+	suite.NoError(err)
+	// sold 100, 60 and 30 offline tickets
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "ID", CountryNumber: 1, TotalQuota: 53},
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 31},
+		{CountryCode: "MY", CountryNumber: 3, TotalQuota: 16},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestOfflineDemandSkipAssigned() {
+	suite.countryList[0].CountryCode = "ID"
+	countries, err := suite.allocate(constants.AllocationOfflineDemand, dto.AllocationInput{
+		TotalQuota:  100,
+		CountryList: suite.countryList,
+		Countries:   suite.countries,
+	})
+	suite.NoError(err)
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 31},
+		{CountryCode: "MY", CountryNumber: 3, TotalQuota: 16},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestOfflineDemandListedRanksOnly() {
+	countries, err := suite.allocate(constants.AllocationOfflineDemand, dto.AllocationInput{
+		TotalQuota:  100,
+		CountryList: suite.countryList[:2],
+		Countries:   suite.countries,
+	})
+	suite.NoError(err)
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "ID", CountryNumber: 1, TotalQuota: 63},
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 37},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestOfflineDemandErrCountryList() {
+	_, err := suite.allocate(constants.AllocationOfflineDemand, dto.AllocationInput{
+		TotalQuota: 100,
+		Countries:  suite.countries,
+	})
+	suite.EqualError(err, "country list is empty")
+}
+
+func (suite *AllocationTestSuite) TestOfflineDemandNothingSold() {
+	_, err := suite.allocate(constants.AllocationOfflineDemand, dto.AllocationInput{
+		TotalQuota:  100,
+		CountryList: suite.countryList,
+		Countries:   []dto.CountryDemand{{CountryCode: "ID", TotalTicket: 10, TotalAvailableTicket: 10}},
+	})
+	suite.EqualError(err, "no offline ticket sold yet")
+}
+
+func (suite *AllocationTestSuite) TestFixedQuota() {
+	countries, err := suite.allocate(constants.AllocationFixedQuota, dto.AllocationInput{
+		TotalQuota: 100,
+		CountryList: []entity.CountryList{
+			{CountryNumber: 2, CountryCode: "MY", Quota: 20},
+			{CountryNumber: 1, CountryCode: "SG", Quota: 70},
+			{CountryNumber: 3, CountryCode: "ID", Quota: 0},
+		},
+		Countries: suite.countries,
+	})
+	suite.NoError(err)
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "SG", CountryNumber: 1, TotalQuota: 70},
+		{CountryCode: "MY", CountryNumber: 2, TotalQuota: 20},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestFixedQuotaExceedsTotal() {
+	_, err := suite.allocate(constants.AllocationFixedQuota, dto.AllocationInput{
+		TotalQuota: 50,
+		CountryList: []entity.CountryList{
+			{CountryNumber: 1, CountryCode: "SG", Quota: 30},
+			{CountryNumber: 2, CountryCode: "MY", Quota: 30},
+		},
+	})
+	suite.EqualError(err, "country list quota 60 exceeds total quota 50")
+}
+
+func (suite *AllocationTestSuite) TestFixedQuotaMissingCountryCode() {
+	_, err := suite.allocate(constants.AllocationFixedQuota, dto.AllocationInput{
+		TotalQuota:  50,
+		CountryList: []entity.CountryList{{CountryNumber: 1, Quota: 30}},
+	})
+	suite.EqualError(err, "country code of country number 1 is empty")
+}
+
+func (suite *AllocationTestSuite) TestWaitingQueue() {
+	suite.countries[2].QueueSize = 0
+	countries, err := suite.allocate(constants.AllocationWaitingQueue, dto.AllocationInput{
+		TotalQuota:  10,
+		CountryList: suite.countryList,
+		Countries:   suite.countries,
+	})
+	suite.NoError(err)
+	suite.Equal([]dto.CountryQuota{
+		{CountryCode: "ID", CountryNumber: 1, TotalQuota: 6},
+		{CountryCode: "SG", CountryNumber: 2, TotalQuota: 4},
+	}, countries)
+}
+
+func (suite *AllocationTestSuite) TestWaitingQueueEmpty() {
+	_, err := suite.allocate(constants.AllocationWaitingQueue, dto.AllocationInput{
+		TotalQuota:  10,
+		CountryList: suite.countryList,
+		Countries:   []dto.CountryDemand{{CountryCode: "ID"}},
+	})
+	suite.EqualError(err, "waiting queue is empty")
+}
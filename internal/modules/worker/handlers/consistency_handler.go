@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"worker-service/configs/middleware"
+	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
+	"worker-service/internal/pkg/log"
+	"worker-service/internal/pkg/redis"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ConsistencyHttpHandler struct {
+	WorkerUsecaseCommand worker.UsecaseCommand
+	WorkerUsecaseQuery   worker.UsecaseQuery
+	Logger               log.Logger
+	Validator            *validator.Validate
+}
+
+func InitConsistencyHttpHandler(app *fiber.App, wuc worker.UsecaseCommand, wuq worker.UsecaseQuery, log log.Logger, redisClient redis.Collections) {
+	handler := &ConsistencyHttpHandler{
+		WorkerUsecaseCommand: wuc,
+		WorkerUsecaseQuery:   wuq,
+		Logger:               log,
+		Validator:            validator.New(),
+	}
+	middlewares := middleware.NewMiddlewares(redisClient)
+	route := app.Group("/api/worker")
+
+	route.Post("/v1/consistency/ticket-detail/reconcile", middlewares.VerifyBasicAuth(), handler.ReconcileTicketDetail)
+	route.Get("/v1/consistency/ticket-detail/runs", middlewares.VerifyBasicAuth(), handler.FindReconcileTicketDetailRuns)
+}
+
+// ReconcileTicketDetail runs the reconciliation at once and responds with its run, discrepancies included
+func (h ConsistencyHttpHandler) ReconcileTicketDetail(c *fiber.Ctx) error {
+	req := new(request.ReconcileTicketDetailReq)
+	if err := c.QueryParser(req); err != nil {
+		return helpers.RespError(c, h.Logger, errors.BadRequest("bad request"))
+	}
+
+	resp, err := h.WorkerUsecaseCommand.ReconcileTicketDetail(c.Context(), req.Fix)
+	if err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespSuccess(c, h.Logger, resp, "Reconcile ticket detail success")
+}
+
+func (h ConsistencyHttpHandler) FindReconcileTicketDetailRuns(c *fiber.Ctx) error {
+	req := new(request.CronRunReq)
+	if err := c.QueryParser(req); err != nil {
+		return helpers.RespError(c, h.Logger, errors.BadRequest("bad request"))
+	}
+
+	req.Job = constants.JobTypeReconcileTicketDetail
+	if err := h.Validator.Struct(req); err != nil {
+		return helpers.RespError(c, h.Logger, errors.BadRequest(err.Error()))
+	}
+
+	resp, err := h.WorkerUsecaseQuery.FindAllCronRun(c.Context(), *req)
+	if err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespPagination(c, h.Logger, resp.CollectionData, resp.MetaData, "Get reconcile ticket detail runs success")
+}
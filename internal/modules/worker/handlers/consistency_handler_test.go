@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"worker-service/internal/modules/worker/handlers"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/modules/worker/models/response"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	mockcert "worker-service/mocks/modules/worker"
+	mocklog "worker-service/mocks/pkg/log"
+	mockredis "worker-service/mocks/pkg/redis"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConsistencyHttpHandlerTestSuite struct {
+	suite.Suite
+
+	cUC    *mockcert.UsecaseCommand
+	cUQ    *mockcert.UsecaseQuery
+	cLog   *mocklog.Logger
+	cRedis *mockredis.Collections
+	app    *fiber.App
+}
+
+func (suite *ConsistencyHttpHandlerTestSuite) SetupTest() {
+	suite.cUC = new(mockcert.UsecaseCommand)
+	suite.cUQ = new(mockcert.UsecaseQuery)
+	suite.cLog = new(mocklog.Logger)
+	suite.cRedis = new(mockredis.Collections)
+	suite.app = fiber.New()
+	handlers.InitConsistencyHttpHandler(suite.app, suite.cUC, suite.cUQ, suite.cLog, suite.cRedis)
+}
+
+func TestConsistencyHttpHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(ConsistencyHttpHandlerTestSuite))
+}
+
+// request calls a route through the basic auth of the unset test config
+func (suite *ConsistencyHttpHandlerTestSuite) request(method, uri string) *http.Response {
+	req := httptest.NewRequest(method, uri, nil)
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	return resp
+}
+
+func (suite *ConsistencyHttpHandlerTestSuite) TestReconcileTicketDetail() {
+	run := &entity.WorkerJobRun{
+		Job:     constants.JobTypeReconcileTicketDetail,
+		FixMode: true,
+		Discrepancies: []entity.Discrepancy{
+			{TicketId: "id", CountryCode: "ID", Field: constants.DiscrepancyTotalRemaining, Expected: 5, Actual: 6, Fixed: true},
+		},
+	}
+	suite.cUC.On("ReconcileTicketDetail", mock.Anything, true).Return(run, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPost, "/api/worker/v1/consistency/ticket-detail/reconcile?fix=true")
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+	suite.cUC.AssertExpectations(suite.T())
+}
+
+func (suite *ConsistencyHttpHandlerTestSuite) TestReconcileTicketDetailReportOnly() {
+	suite.cUC.On("ReconcileTicketDetail", mock.Anything, false).Return(&entity.WorkerJobRun{}, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPost, "/api/worker/v1/consistency/ticket-detail/reconcile")
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+	suite.cUC.AssertExpectations(suite.T())
+}
+
+func (suite *ConsistencyHttpHandlerTestSuite) TestReconcileTicketDetailErr() {
+	suite.cUC.On("ReconcileTicketDetail", mock.Anything, false).Return(nil, errors.InternalServerError("error"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPost, "/api/worker/v1/consistency/ticket-detail/reconcile")
+	assert.Equal(suite.T(), fiber.StatusInternalServerError, resp.StatusCode)
+}
+
+func (suite *ConsistencyHttpHandlerTestSuite) TestFindReconcileTicketDetailRuns() {
+	resp := &response.CronRunResp{
+		CollectionData: []entity.WorkerJobRun{{Id: "run", Job: constants.JobTypeReconcileTicketDetail, Status: "succeeded"}},
+	}
+	suite.cUQ.On("FindAllCronRun", mock.Anything, request.CronRunReq{Job: constants.JobTypeReconcileTicketDetail, Page: 2, Size: 10}).Return(resp, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	httpResp := suite.request(fiber.MethodGet, "/api/worker/v1/consistency/ticket-detail/runs?page=2&size=10")
+	assert.Equal(suite.T(), fiber.StatusOK, httpResp.StatusCode)
+	suite.cUQ.AssertExpectations(suite.T())
+}
+
+func (suite *ConsistencyHttpHandlerTestSuite) TestFindReconcileTicketDetailRunsErrValidate() {
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodGet, "/api/worker/v1/consistency/ticket-detail/runs?size=1000")
+	assert.Equal(suite.T(), fiber.StatusBadRequest, resp.StatusCode)
+	suite.cUQ.AssertNotCalled(suite.T(), "FindAllCronRun", mock.Anything, mock.Anything)
+}
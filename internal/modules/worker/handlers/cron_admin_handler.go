@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"worker-service/configs/middleware"
+	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/modules/worker/models/response"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
+	"worker-service/internal/pkg/log"
+	"worker-service/internal/pkg/redis"
+	"worker-service/internal/pkg/scheduler"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CronAdminHttpHandler struct {
+	Scheduler          scheduler.Scheduler
+	WorkerUsecaseQuery worker.UsecaseQuery
+	Logger             log.Logger
+	Validator          *validator.Validate
+}
+
+func InitCronAdminHttpHandler(app *fiber.App, sched scheduler.Scheduler, wuq worker.UsecaseQuery, log log.Logger, redisClient redis.Collections) {
+	handler := &CronAdminHttpHandler{
+		Scheduler:          sched,
+		WorkerUsecaseQuery: wuq,
+		Logger:             log,
+		Validator:          validator.New(),
+	}
+	middlewares := middleware.NewMiddlewares(redisClient)
+	route := app.Group("/api/worker")
+
+	route.Get("/v1/cron/leader", middlewares.VerifyBasicAuth(), handler.FindCronLeaders)
+	route.Get("/v1/cron/jobs", middlewares.VerifyBasicAuth(), handler.FindCronJobs)
+	route.Put("/v1/cron/jobs/:name", middlewares.VerifyBasicAuth(), handler.UpdateCronJob)
+	route.Post("/v1/cron/jobs/:name/pause", middlewares.VerifyBasicAuth(), handler.PauseCronJob)
+	route.Post("/v1/cron/jobs/:name/resume", middlewares.VerifyBasicAuth(), handler.ResumeCronJob)
+	route.Post("/v1/cron/jobs/:name/trigger", middlewares.VerifyBasicAuth(), handler.TriggerCronJob)
+	route.Get("/v1/cron/runs", middlewares.VerifyBasicAuth(), handler.FindCronRuns)
+}
+
+func (h CronAdminHttpHandler) FindCronLeaders(c *fiber.Ctx) error {
+	resp, err := h.Scheduler.Leaders(c.Context())
+	if err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespSuccess(c, h.Logger, resp, "Get cron leaders success")
+}
+
+func (h CronAdminHttpHandler) FindCronJobs(c *fiber.Ctx) error {
+	jobs, err := h.Scheduler.Jobs(c.Context())
+	if err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+
+	resp := make([]response.CronJobResp, 0, len(jobs))
+	for _, job := range jobs {
+		resp = append(resp, response.CronJobResp{
+			Name:     job.Name,
+			Spec:     job.Spec,
+			Timezone: job.Timezone,
+			Enabled:  job.Enabled,
+			Timeout:  formatCronTimeout(job.Timeout),
+			Overlap:  job.Overlap,
+			NextRun:  job.NextRun,
+			LastRun:  job.LastRun,
+		})
+	}
+	return helpers.RespSuccess(c, h.Logger, resp, "Get cron jobs success")
+}
+
+func (h CronAdminHttpHandler) UpdateCronJob(c *fiber.Ctx) error {
+	req := new(request.UpdateCronJobReq)
+	if err := c.BodyParser(req); err != nil {
+		return helpers.RespError(c, h.Logger, errors.BadRequest("bad request"))
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		return helpers.RespError(c, h.Logger, errors.BadRequest(err.Error()))
+	}
+
+	// the fields left out of the request keep their current value
+	config, err := h.Scheduler.Config(c.Params("name"))
+	if err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	if req.Timeout != nil {
+		timeout, err := parseCronTimeout(*req.Timeout)
+		if err != nil {
+			return helpers.RespError(c, h.Logger, errors.BadRequest("timeout must be a duration such as 5m"))
+		}
+		config.Timeout = timeout
+	}
+	if req.Spec != nil {
+		config.Spec = *req.Spec
+	}
+	if req.Timezone != nil {
+		config.Timezone = *req.Timezone
+	}
+	if req.Enabled != nil {
+		config.Enabled = *req.Enabled
+	}
+	if req.Overlap != nil {
+		config.Overlap = *req.Overlap
+	}
+	if err := h.Scheduler.Update(c.Context(), config); err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespSuccess(c, h.Logger, nil, "Update cron job success")
+}
+
+func (h CronAdminHttpHandler) PauseCronJob(c *fiber.Ctx) error {
+	if err := h.Scheduler.Pause(c.Context(), c.Params("name")); err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespSuccess(c, h.Logger, nil, "Pause cron job success")
+}
+
+func (h CronAdminHttpHandler) ResumeCronJob(c *fiber.Ctx) error {
+	if err := h.Scheduler.Resume(c.Context(), c.Params("name")); err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespSuccess(c, h.Logger, nil, "Resume cron job success")
+}
+
+func (h CronAdminHttpHandler) TriggerCronJob(c *fiber.Ctx) error {
+	if err := h.Scheduler.Trigger(c.Context(), c.Params("name")); err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespAccepted(c, h.Logger, nil, "Trigger cron job accepted")
+}
+
+func (h CronAdminHttpHandler) FindCronRuns(c *fiber.Ctx) error {
+	req := new(request.CronRunReq)
+	if err := c.QueryParser(req); err != nil {
+		return helpers.RespError(c, h.Logger, errors.BadRequest("bad request"))
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		return helpers.RespError(c, h.Logger, errors.BadRequest(err.Error()))
+	}
+
+	resp, err := h.WorkerUsecaseQuery.FindAllCronRun(c.Context(), *req)
+	if err != nil {
+		return helpers.RespCustomError(c, h.Logger, err)
+	}
+	return helpers.RespPagination(c, h.Logger, resp.CollectionData, resp.MetaData, "Get cron runs success")
+}
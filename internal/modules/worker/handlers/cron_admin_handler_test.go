@@ -0,0 +1,242 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"worker-service/internal/modules/worker/handlers"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/modules/worker/models/response"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/scheduler"
+	mockcert "worker-service/mocks/modules/worker"
+	mocklog "worker-service/mocks/pkg/log"
+	mockredis "worker-service/mocks/pkg/redis"
+	mockscheduler "worker-service/mocks/pkg/scheduler"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/valyala/fasthttp"
+)
+
+type CronAdminHttpHandlerTestSuite struct {
+	suite.Suite
+
+	cScheduler *mockscheduler.Scheduler
+	cUQ        *mockcert.UsecaseQuery
+	cLog       *mocklog.Logger
+	cRedis     *mockredis.Collections
+	handler    *handlers.CronAdminHttpHandler
+	app        *fiber.App
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) SetupTest() {
+	suite.cScheduler = new(mockscheduler.Scheduler)
+	suite.cUQ = new(mockcert.UsecaseQuery)
+	suite.cLog = new(mocklog.Logger)
+	suite.cRedis = new(mockredis.Collections)
+	suite.handler = &handlers.CronAdminHttpHandler{
+		Scheduler: suite.cScheduler,
+		Logger:    suite.cLog,
+		Validator: validator.New(),
+	}
+	suite.app = fiber.New()
+	handlers.InitCronAdminHttpHandler(suite.app, suite.cScheduler, suite.cUQ, suite.cLog, suite.cRedis)
+}
+
+func TestCronAdminHttpHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(CronAdminHttpHandlerTestSuite))
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) newCtx(method, uri string) *fiber.Ctx {
+	ctx := suite.app.AcquireCtx(&fasthttp.RequestCtx{})
+	ctx.Request().SetRequestURI(uri)
+	ctx.Request().Header.SetMethod(method)
+	return ctx
+}
+
+// request calls a route through the basic auth of the unset test config
+func (suite *CronAdminHttpHandlerTestSuite) request(method, uri string, body interface{}) *http.Response {
+	var reqBody io.Reader
+	if body != nil {
+		requestBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(requestBody)
+	}
+	req := httptest.NewRequest(method, uri, reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	return resp
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestFindCronLeaders() {
+	leaders := []scheduler.Leader{{Job: "expiry-payment", Holder: "pod-1", Fence: 3, Self: true}}
+	suite.cScheduler.On("Leaders", mock.Anything).Return(leaders, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx := suite.newCtx(fiber.MethodGet, "/v1/cron/leader")
+	err := suite.handler.FindCronLeaders(ctx)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, ctx.Response().StatusCode())
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestFindCronLeadersErr() {
+	suite.cScheduler.On("Leaders", mock.Anything).Return(nil, errors.InternalServerError("cannot get cron lease"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx := suite.newCtx(fiber.MethodGet, "/v1/cron/leader")
+	err := suite.handler.FindCronLeaders(ctx)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusInternalServerError, ctx.Response().StatusCode())
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestFindCronJobs() {
+	nextRun := time.Now()
+	jobs := []scheduler.JobStatus{{
+		JobConfig: scheduler.JobConfig{Name: "expiry-payment", Spec: "*/5 * * * *", Timezone: "Asia/Jakarta", Enabled: true, Timeout: 5 * time.Minute},
+		NextRun:   &nextRun,
+	}}
+	suite.cScheduler.On("Jobs", mock.Anything).Return(jobs, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx := suite.newCtx(fiber.MethodGet, "/v1/cron/jobs")
+	err := suite.handler.FindCronJobs(ctx)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, ctx.Response().StatusCode())
+	assert.Contains(suite.T(), string(ctx.Response().Body()), `"timeout":"5m0s"`)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestUpdateCronJob() {
+	current := scheduler.JobConfig{Name: "expiry-payment", Spec: "*/5 * * * *", Timezone: "Asia/Jakarta", Enabled: true, Overlap: scheduler.OverlapQueue}
+	config := scheduler.JobConfig{Name: "expiry-payment", Spec: "0 * * * *", Timezone: "Asia/Jakarta", Enabled: true, Timeout: time.Minute, Overlap: scheduler.OverlapQueue}
+	suite.cScheduler.On("Config", "expiry-payment").Return(current, nil)
+	suite.cScheduler.On("Update", mock.Anything, config).Return(nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPut, "/api/worker/v1/cron/jobs/expiry-payment", map[string]interface{}{"spec": "0 * * * *", "timeout": "1m"})
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+	suite.cScheduler.AssertExpectations(suite.T())
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestUpdateCronJobDisable() {
+	current := scheduler.JobConfig{Name: "expiry-payment", Spec: "*/5 * * * *", Timezone: "Asia/Jakarta", Enabled: true, Timeout: time.Minute}
+	config := current
+	config.Enabled = false
+	suite.cScheduler.On("Config", "expiry-payment").Return(current, nil)
+	suite.cScheduler.On("Update", mock.Anything, config).Return(nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	// only the enabled flag changes, the timezone and the timeout are kept
+	resp := suite.request(fiber.MethodPut, "/api/worker/v1/cron/jobs/expiry-payment", map[string]interface{}{"enabled": false})
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+	suite.cScheduler.AssertExpectations(suite.T())
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestUpdateCronJobErrNotFound() {
+	suite.cScheduler.On("Config", "unknown").Return(scheduler.JobConfig{}, errors.NotFound("cron job unknown not found"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPut, "/api/worker/v1/cron/jobs/unknown", map[string]interface{}{"enabled": true})
+	assert.Equal(suite.T(), fiber.StatusNotFound, resp.StatusCode)
+	suite.cScheduler.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestUpdateCronJobErrTimeout() {
+	suite.cScheduler.On("Config", "expiry-payment").Return(scheduler.JobConfig{Name: "expiry-payment", Spec: "0 * * * *"}, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPut, "/api/worker/v1/cron/jobs/expiry-payment", map[string]interface{}{"timeout": "five minutes"})
+	assert.Equal(suite.T(), fiber.StatusBadRequest, resp.StatusCode)
+	suite.cScheduler.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestUpdateCronJobErrValidate() {
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPut, "/api/worker/v1/cron/jobs/expiry-payment", map[string]interface{}{"spec": "", "overlap": "later"})
+	assert.Equal(suite.T(), fiber.StatusBadRequest, resp.StatusCode)
+	suite.cScheduler.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestUpdateCronJobErrUnauthorized() {
+	req := httptest.NewRequest(fiber.MethodPut, "/api/worker/v1/cron/jobs/expiry-payment", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestPauseCronJobErr() {
+	suite.cScheduler.On("Pause", mock.Anything, "unknown").Return(errors.NotFound("cron job unknown not found"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPost, "/api/worker/v1/cron/jobs/unknown/pause", nil)
+	assert.Equal(suite.T(), fiber.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestResumeCronJob() {
+	suite.cScheduler.On("Resume", mock.Anything, "expiry-payment").Return(nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPost, "/api/worker/v1/cron/jobs/expiry-payment/resume", nil)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestTriggerCronJob() {
+	suite.cScheduler.On("Trigger", mock.Anything, "expiry-payment").Return(nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPost, "/api/worker/v1/cron/jobs/expiry-payment/trigger", nil)
+	assert.Equal(suite.T(), fiber.StatusAccepted, resp.StatusCode)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestTriggerCronJobErrRunning() {
+	suite.cScheduler.On("Trigger", mock.Anything, "expiry-payment").Return(errors.Conflict("cron job expiry-payment is already running"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodPost, "/api/worker/v1/cron/jobs/expiry-payment/trigger", nil)
+	assert.Equal(suite.T(), fiber.StatusConflict, resp.StatusCode)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestFindCronRuns() {
+	resp := &response.CronRunResp{
+		CollectionData: []entity.WorkerJobRun{{Id: "run", Job: "expiry-payment", Status: "succeeded", Instance: "pod-1"}},
+	}
+	suite.cUQ.On("FindAllCronRun", mock.Anything, request.CronRunReq{Job: "expiry-payment", Page: 2, Size: 10}).Return(resp, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	httpResp := suite.request(fiber.MethodGet, "/api/worker/v1/cron/runs?job=expiry-payment&page=2&size=10", nil)
+	assert.Equal(suite.T(), fiber.StatusOK, httpResp.StatusCode)
+	suite.cUQ.AssertExpectations(suite.T())
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestFindCronRunsErrValidate() {
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodGet, "/api/worker/v1/cron/runs?size=1000", nil)
+	assert.Equal(suite.T(), fiber.StatusBadRequest, resp.StatusCode)
+	suite.cUQ.AssertNotCalled(suite.T(), "FindAllCronRun", mock.Anything, mock.Anything)
+}
+
+func (suite *CronAdminHttpHandlerTestSuite) TestFindCronRunsErr() {
+	suite.cUQ.On("FindAllCronRun", mock.Anything, mock.Anything).Return(nil, errors.InternalServerError("error"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	resp := suite.request(fiber.MethodGet, "/api/worker/v1/cron/runs?job=expiry-payment", nil)
+	assert.Equal(suite.T(), fiber.StatusInternalServerError, resp.StatusCode)
+}
@@ -2,11 +2,22 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"time"
+	"worker-service/configs"
 	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/helpers"
 	"worker-service/internal/pkg/log"
+	"worker-service/internal/pkg/redis"
+	"worker-service/internal/pkg/scheduler"
+)
 
-	"github.com/robfig/cron/v3"
+const (
+	defaultCronLeaseTTL = time.Minute
+	defaultCronTimezone = "Asia/Jakarta"
+	defaultCronTimeout  = 5 * time.Minute
 )
 
 type CronHttpHandler struct {
@@ -14,42 +25,182 @@ type CronHttpHandler struct {
 	Logger               log.Logger
 }
 
-func InitCronHandler(wuc worker.UsecaseCommand, log log.Logger) {
+// InitCronHandler schedules the cron jobs, each run happens on the one replica taking the job lease.
+// A job whose configuration is invalid is not scheduled.
+func InitCronHandler(wuc worker.UsecaseCommand, wuq worker.UsecaseQuery, redisClient redis.Collections, log log.Logger) scheduler.Scheduler {
 	handler := &CronHttpHandler{
 		WorkerUsecaseCommand: wuc,
 		Logger:               log,
 	}
 
-	jakartaTime, _ := time.LoadLocation("Asia/Jakarta")
-	// scheduler := cron.New(cron.WithLocation(jakartaTime), cron.WithSeconds())
-	scheduler := cron.New(cron.WithLocation(jakartaTime))
+	store := CronJobStore{WorkerUsecaseCommand: wuc, WorkerUsecaseQuery: wuq}
+	cronScheduler := scheduler.NewScheduler(redisClient, store, helpers.InstanceId(), cronLeaseTTL(), log)
+
+	jobs := []scheduler.Job{
+		{
+			JobConfig: cronJobConfig(constants.JobTypeExpiryPayment, configs.GetConfig().Worker.CronExpiryPayment, "*/5 * * * *"),
+			Run:       handler.UpdateAllExpiryPayment,
+		},
+		{
+			JobConfig: cronJobConfig(constants.JobTypeExpiryBankTicket, configs.GetConfig().Worker.CronExpiryBankTicket, "*/10 * * * *"),
+			Run:       handler.UpdateAllExpiryBankTicket,
+		},
+		{
+			JobConfig: cronJobConfig(constants.JobTypeReconcileInventory, configs.GetConfig().Worker.CronReconcileInventory, "*/15 * * * *"),
+			Run:       handler.ReconcileInventory,
+		},
+		{
+			JobConfig: cronJobConfig(constants.JobTypeReconcileTicketDetail, configs.GetConfig().Worker.CronReconcileTicketDetail, "0 * * * *"),
+			Run:       handler.ReconcileTicketDetail,
+		},
+		{
+			JobConfig: cronJobConfig(constants.JobTypeCleanupOrphan, configs.GetConfig().Worker.CronCleanupOrphan, "*/30 * * * *"),
+			Run:       handler.CleanupOrphan,
+		},
+	}
+	for _, job := range jobs {
+		if err := cronScheduler.Register(job); err != nil {
+			log.Error(context.Background(), fmt.Sprintf("Failed register cron job %s", job.Name), err.Error())
+		}
+	}
+
+	cronScheduler.Start()
+	return cronScheduler
+}
+
+// cronJobConfig is the configured default of a job, the store replaces it once the job is changed at runtime
+func cronJobConfig(name, spec, defaultSpec string) scheduler.JobConfig {
+	return scheduler.JobConfig{
+		Name:     name,
+		Spec:     helpers.CustomIfEmpty(spec, defaultSpec),
+		Timezone: helpers.CustomIfEmpty(configs.GetConfig().Worker.CronTimezone, defaultCronTimezone),
+		Enabled:  true,
+		Timeout:  cronTimeout(),
+		Overlap:  scheduler.OverlapSkip,
+	}
+}
+
+func cronLeaseTTL() time.Duration {
+	ttl, err := time.ParseDuration(configs.GetConfig().Worker.CronLeaseTTL)
+	if err != nil || ttl <= 0 {
+		return defaultCronLeaseTTL
+	}
+	return ttl
+}
+
+func cronTimeout() time.Duration {
+	timeout, err := time.ParseDuration(configs.GetConfig().Worker.CronTimeout)
+	if err != nil || timeout < 0 {
+		return defaultCronTimeout
+	}
+	return timeout
+}
+
+// CronJobStore keeps the cron job configuration changed at runtime in mongo
+type CronJobStore struct {
+	WorkerUsecaseCommand worker.UsecaseCommand
+	WorkerUsecaseQuery   worker.UsecaseQuery
+}
+
+func (s CronJobStore) FindJobs(ctx context.Context) ([]scheduler.JobConfig, error) {
+	jobs, err := s.WorkerUsecaseQuery.FindAllCronJob(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	scheduler.AddFunc("*/5 * * * *", handler.UpdateAllExpiryPayment)
-	scheduler.AddFunc("*/10 * * * *", handler.UpdateAllExpiryBankTicket)
+	configs := make([]scheduler.JobConfig, 0, len(jobs))
+	for _, job := range jobs {
+		timeout, err := parseCronTimeout(job.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q of cron job %s", job.Timeout, job.Name)
+		}
+		configs = append(configs, scheduler.JobConfig{
+			Name:     job.Name,
+			Spec:     job.Spec,
+			Timezone: job.Timezone,
+			Enabled:  job.Enabled,
+			Timeout:  timeout,
+			Overlap:  job.Overlap,
+		})
+	}
+	return configs, nil
+}
 
-	go scheduler.Start()
+func (s CronJobStore) SaveJob(ctx context.Context, config scheduler.JobConfig) error {
+	return s.WorkerUsecaseCommand.UpsertCronJob(ctx, entity.CronJob{
+		Name:     config.Name,
+		Spec:     config.Spec,
+		Timezone: config.Timezone,
+		Enabled:  config.Enabled,
+		Timeout:  formatCronTimeout(config.Timeout),
+		Overlap:  config.Overlap,
+	})
 }
 
-func (c CronHttpHandler) UpdateAllExpiryPayment() {
-	ctx := context.Background()
+// parseCronTimeout parses a timeout such as "5m", empty never times out
+func parseCronTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(timeout)
+}
+
+func formatCronTimeout(timeout time.Duration) string {
+	if timeout == 0 {
+		return ""
+	}
+	return timeout.String()
+}
+
+func (c CronHttpHandler) UpdateAllExpiryPayment(ctx context.Context) {
 	resp, err := c.WorkerUsecaseCommand.UpdateAllExpiryPayment(ctx)
 	if err != nil {
 		c.Logger.Error(ctx, "error UpdateAllExpiryPayment", err.Error())
 	}
 	if resp != nil {
-		c.Logger.Info(ctx, *resp, "success UpdateAllExpiryPayment")
+		c.Logger.Info(ctx, "success UpdateAllExpiryPayment", resp)
 	}
 
 }
 
-func (c CronHttpHandler) UpdateAllExpiryBankTicket() {
-	ctx := context.Background()
+func (c CronHttpHandler) UpdateAllExpiryBankTicket(ctx context.Context) {
 	resp, err := c.WorkerUsecaseCommand.UpdateAllExpiryBankTicket(ctx)
 	if err != nil {
 		c.Logger.Error(ctx, "error UpdateAllExpiryBankTicket", err.Error())
 	}
 	if resp != nil {
-		c.Logger.Info(ctx, *resp, "success UpdateAllExpiryBankTicket")
+		c.Logger.Info(ctx, "success UpdateAllExpiryBankTicket", resp)
 	}
 
 }
+
+func (c CronHttpHandler) ReconcileInventory(ctx context.Context) {
+	resp, err := c.WorkerUsecaseCommand.ReconcileInventory(ctx)
+	if err != nil {
+		c.Logger.Error(ctx, "error ReconcileInventory", err.Error())
+	}
+	if resp != nil {
+		c.Logger.Info(ctx, "success ReconcileInventory", resp)
+	}
+}
+
+// ReconcileTicketDetail repairs the ticket details only when the fix mode is configured, otherwise it reports them
+func (c CronHttpHandler) ReconcileTicketDetail(ctx context.Context) {
+	resp, err := c.WorkerUsecaseCommand.ReconcileTicketDetail(ctx, configs.GetConfig().Worker.ReconcileTicketDetailFix == "true")
+	if err != nil {
+		c.Logger.Error(ctx, "error ReconcileTicketDetail", err.Error())
+	}
+	if resp != nil {
+		c.Logger.Info(ctx, "success ReconcileTicketDetail", resp)
+	}
+}
+
+func (c CronHttpHandler) CleanupOrphan(ctx context.Context) {
+	resp, err := c.WorkerUsecaseCommand.CleanupOrphan(ctx)
+	if err != nil {
+		c.Logger.Error(ctx, "error CleanupOrphan", err.Error())
+	}
+	if resp != nil {
+		c.Logger.Info(ctx, "success CleanupOrphan", resp)
+	}
+}
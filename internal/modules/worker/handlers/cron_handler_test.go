@@ -3,11 +3,19 @@
 package handlers_test
 
 import (
+	"context"
 	"testing"
+	"time"
+	"worker-service/configs"
 	"worker-service/internal/modules/worker/handlers"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/scheduler"
 	mockcert "worker-service/mocks/modules/worker"
 	mocklog "worker-service/mocks/pkg/log"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -15,29 +23,103 @@ type CronHandlerTestSuite struct {
 	suite.Suite
 
 	cUC         *mockcert.UsecaseCommand
+	cUQ         *mockcert.UsecaseQuery
 	cLog        *mocklog.Logger
 	cronHandler *handlers.CronHttpHandler
 }
 
-func (suite *CronHandlerTestSuite) SetupTestCron() {
+func (suite *CronHandlerTestSuite) SetupTest() {
 	suite.cUC = new(mockcert.UsecaseCommand)
+	suite.cUQ = new(mockcert.UsecaseQuery)
 	suite.cLog = &mocklog.Logger{}
 	suite.cronHandler = &handlers.CronHttpHandler{
 		WorkerUsecaseCommand: suite.cUC,
 		Logger:               suite.cLog,
 	}
-	handlers.InitCronHandler(suite.cUC, suite.cLog)
 }
 
 func TestCronHandlerTestSuite(t *testing.T) {
 	suite.Run(t, new(CronHandlerTestSuite))
 }
 
-// func (suite *CronHandlerTestSuite) TestUpdateAllExpiryPayment() {
-// 	// rs := "result"
-// 	// suite.cUC.On("UpdateAllExpiryPayment", context.Background()).Return(&rs, nil)
-// 	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-// 	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+func (suite *CronHandlerTestSuite) TestUpdateAllExpiryPayment() {
+	ctx := context.Background()
+	suite.cUC.On("UpdateAllExpiryPayment", ctx).Return(&entity.WorkerJobRun{Id: "id"}, nil)
+	suite.cLog.On("Info", ctx, "success UpdateAllExpiryPayment", mock.Anything)
 
-// 	suite.cronHandler.UpdateAllExpiryPayment()
-// }
+	suite.cronHandler.UpdateAllExpiryPayment(ctx)
+	suite.cLog.AssertExpectations(suite.T())
+}
+
+func (suite *CronHandlerTestSuite) TestUpdateAllExpiryBankTicketErr() {
+	ctx := context.Background()
+	suite.cUC.On("UpdateAllExpiryBankTicket", ctx).Return(nil, errors.InternalServerError("error"))
+	suite.cLog.On("Error", ctx, "error UpdateAllExpiryBankTicket", "error")
+
+	suite.cronHandler.UpdateAllExpiryBankTicket(ctx)
+	suite.cLog.AssertExpectations(suite.T())
+	suite.cLog.AssertNotCalled(suite.T(), "Info", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CronHandlerTestSuite) TestReconcileInventory() {
+	ctx := context.Background()
+	suite.cUC.On("ReconcileInventory", ctx).Return(&entity.WorkerJobRun{Id: "id"}, nil)
+	suite.cLog.On("Info", ctx, "success ReconcileInventory", mock.Anything)
+
+	suite.cronHandler.ReconcileInventory(ctx)
+	suite.cLog.AssertExpectations(suite.T())
+}
+
+func (suite *CronHandlerTestSuite) TestReconcileTicketDetail() {
+	ctx := context.Background()
+	configs.GetConfig().Worker.ReconcileTicketDetailFix = "true"
+	defer func() { configs.GetConfig().Worker.ReconcileTicketDetailFix = "" }()
+	suite.cUC.On("ReconcileTicketDetail", ctx, true).Return(&entity.WorkerJobRun{Id: "id", FixMode: true}, nil)
+	suite.cLog.On("Info", ctx, "success ReconcileTicketDetail", mock.Anything)
+
+	suite.cronHandler.ReconcileTicketDetail(ctx)
+	suite.cUC.AssertExpectations(suite.T())
+	suite.cLog.AssertExpectations(suite.T())
+}
+
+func (suite *CronHandlerTestSuite) TestCleanupOrphanErr() {
+	ctx := context.Background()
+	suite.cUC.On("CleanupOrphan", ctx).Return(nil, errors.InternalServerError("error"))
+	suite.cLog.On("Error", ctx, "error CleanupOrphan", "error")
+
+	suite.cronHandler.CleanupOrphan(ctx)
+	suite.cLog.AssertExpectations(suite.T())
+	suite.cLog.AssertNotCalled(suite.T(), "Info", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CronHandlerTestSuite) TestCronJobStoreFindJobs() {
+	store := handlers.CronJobStore{WorkerUsecaseCommand: suite.cUC, WorkerUsecaseQuery: suite.cUQ}
+	suite.cUQ.On("FindAllCronJob", mock.Anything).Return([]entity.CronJob{
+		{Name: "expiry-payment", Spec: "0 * * * *", Timezone: "UTC", Timeout: "90s"},
+		{Name: "expiry-bank-ticket", Spec: "*/10 * * * *", Enabled: true},
+	}, nil)
+
+	jobs, err := store.FindJobs(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []scheduler.JobConfig{
+		{Name: "expiry-payment", Spec: "0 * * * *", Timezone: "UTC", Timeout: 90 * time.Second},
+		{Name: "expiry-bank-ticket", Spec: "*/10 * * * *", Enabled: true},
+	}, jobs)
+}
+
+func (suite *CronHandlerTestSuite) TestCronJobStoreFindJobsErrTimeout() {
+	store := handlers.CronJobStore{WorkerUsecaseCommand: suite.cUC, WorkerUsecaseQuery: suite.cUQ}
+	suite.cUQ.On("FindAllCronJob", mock.Anything).Return([]entity.CronJob{{Name: "expiry-payment", Timeout: "soon"}}, nil)
+
+	_, err := store.FindJobs(context.Background())
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CronHandlerTestSuite) TestCronJobStoreSaveJob() {
+	store := handlers.CronJobStore{WorkerUsecaseCommand: suite.cUC, WorkerUsecaseQuery: suite.cUQ}
+	suite.cUC.On("UpsertCronJob", mock.Anything, entity.CronJob{Name: "expiry-payment", Spec: "0 * * * *", Timezone: "UTC", Timeout: "5m0s"}).Return(nil)
+
+	err := store.SaveJob(context.Background(), scheduler.JobConfig{Name: "expiry-payment", Spec: "0 * * * *", Timezone: "UTC", Timeout: 5 * time.Minute})
+	assert.NoError(suite.T(), err)
+	suite.cUC.AssertExpectations(suite.T())
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"worker-service/configs/middleware"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/modules/worker/models/response"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
+	"worker-service/internal/pkg/log"
+	"worker-service/internal/pkg/redis"
+
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultReplayLimit = 100
+
+type DeadLetterHttpHandler struct {
+	Retrier   kafkaConfluent.Retrier
+	Logger    log.Logger
+	Validator *validator.Validate
+}
+
+func InitDeadLetterHttpHandler(app *fiber.App, retrier kafkaConfluent.Retrier, log log.Logger, redisClient redis.Collections) {
+	handler := &DeadLetterHttpHandler{
+		Retrier:   retrier,
+		Logger:    log,
+		Validator: validator.New(),
+	}
+	middlewares := middleware.NewMiddlewares(redisClient)
+	route := app.Group("/api/worker")
+
+	route.Post("/v1/dlq/replay", middlewares.VerifyBasicAuth(), handler.ReplayDeadLetter)
+}
+
+func (d DeadLetterHttpHandler) ReplayDeadLetter(c *fiber.Ctx) error {
+	req := new(request.ReplayDeadLetterReq)
+	if err := c.BodyParser(req); err != nil {
+		return helpers.RespError(c, d.Logger, errors.BadRequest("bad request"))
+	}
+
+	if err := d.Validator.Struct(req); err != nil {
+		return helpers.RespError(c, d.Logger, errors.BadRequest(err.Error()))
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultReplayLimit
+	}
+
+	replayed, err := d.Retrier.Replay(c.Context(), req.Topic, req.Limit)
+	if err != nil {
+		return helpers.RespCustomError(c, d.Logger, err)
+	}
+	resp := response.ReplayDeadLetterResp{
+		Topic:    req.Topic,
+		Replayed: replayed,
+	}
+	return helpers.RespSuccess(c, d.Logger, resp, "Replay dead letter queue success")
+}
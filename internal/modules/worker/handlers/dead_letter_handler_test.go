@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"worker-service/internal/modules/worker/handlers"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/errors"
+	mockkafka "worker-service/mocks/pkg/kafka"
+	mocklog "worker-service/mocks/pkg/log"
+	mockredis "worker-service/mocks/pkg/redis"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/valyala/fasthttp"
+)
+
+type DeadLetterHttpHandlerTestSuite struct {
+	suite.Suite
+
+	cRetrier *mockkafka.Retrier
+	cLog     *mocklog.Logger
+	cRedis   *mockredis.Collections
+	handler  *handlers.DeadLetterHttpHandler
+	app      *fiber.App
+}
+
+func (suite *DeadLetterHttpHandlerTestSuite) SetupTest() {
+	suite.cRetrier = new(mockkafka.Retrier)
+	suite.cLog = new(mocklog.Logger)
+	suite.cRedis = new(mockredis.Collections)
+	suite.handler = &handlers.DeadLetterHttpHandler{
+		Retrier:   suite.cRetrier,
+		Logger:    suite.cLog,
+		Validator: validator.New(),
+	}
+	suite.app = fiber.New()
+	handlers.InitDeadLetterHttpHandler(suite.app, suite.cRetrier, suite.cLog, suite.cRedis)
+}
+
+func TestDeadLetterHttpHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(DeadLetterHttpHandlerTestSuite))
+}
+
+func (suite *DeadLetterHttpHandlerTestSuite) newCtx(body []byte) *fiber.Ctx {
+	ctx := suite.app.AcquireCtx(&fasthttp.RequestCtx{})
+	ctx.Request().SetRequestURI("/v1/dlq/replay")
+	ctx.Request().Header.SetMethod(fiber.MethodPost)
+	ctx.Request().Header.SetContentType("application/json")
+	if body != nil {
+		ctx.Request().SetBody(body)
+	}
+	return ctx
+}
+
+func (suite *DeadLetterHttpHandlerTestSuite) TestReplayDeadLetter() {
+	suite.cRetrier.On("Replay", mock.Anything, "concert-create-bank-ticket", 100).Return(2, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestBody, _ := json.Marshal(request.ReplayDeadLetterReq{
+		Topic: "concert-create-bank-ticket",
+	})
+	ctx := suite.newCtx(requestBody)
+
+	err := suite.handler.ReplayDeadLetter(ctx)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, ctx.Response().StatusCode())
+	suite.cRetrier.AssertCalled(suite.T(), "Replay", mock.Anything, "concert-create-bank-ticket", 100)
+}
+
+func (suite *DeadLetterHttpHandlerTestSuite) TestReplayDeadLetterErrBodyParser() {
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	ctx := suite.newCtx(nil)
+
+	err := suite.handler.ReplayDeadLetter(ctx)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusBadRequest, ctx.Response().StatusCode())
+}
+
+func (suite *DeadLetterHttpHandlerTestSuite) TestReplayDeadLetterErrValidate() {
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	requestBody, _ := json.Marshal(request.ReplayDeadLetterReq{
+		Limit: 5000,
+	})
+	ctx := suite.newCtx(requestBody)
+
+	err := suite.handler.ReplayDeadLetter(ctx)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusBadRequest, ctx.Response().StatusCode())
+}
+
+func (suite *DeadLetterHttpHandlerTestSuite) TestReplayDeadLetterErr() {
+	suite.cRetrier.On("Replay", mock.Anything, mock.Anything, mock.Anything).Return(0, errors.BadRequest("topic has no dead letter queue"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestBody, _ := json.Marshal(request.ReplayDeadLetterReq{
+		Topic: "unknown",
+		Limit: 10,
+	})
+	ctx := suite.newCtx(requestBody)
+
+	err := suite.handler.ReplayDeadLetter(ctx)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusBadRequest, ctx.Response().StatusCode())
+}
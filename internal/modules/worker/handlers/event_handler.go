@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
 	"worker-service/configs"
 	"worker-service/internal/modules/worker"
 	"worker-service/internal/pkg/log"
@@ -8,15 +12,47 @@ import (
 	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
 )
 
-func InitWorkerEventConflHandler(wc worker.UsecaseCommand, log log.Logger) {
-	topicKcr := "concert-create-bank-ticket"
-	kcr, _ := kafkaConfluent.NewConsumer(kafkaConfluent.GetConfig().GetKafkaConfig(configs.GetConfig().ServiceName, true), log)
-	kcr.SetHandler(NewWorkerEventConsumer(wc, log))
-	kcr.Subscribe(topicKcr)
+func InitWorkerEventConflHandler(wc worker.UsecaseCommand, retrier kafkaConfluent.Retrier, log log.Logger) kafkaConfluent.Consumer {
+	router := kafkaConfluent.NewRouter()
+	NewWorkerEventConsumer(wc, log).RegisterRoutes(router, retrier)
 
-	topicKut := "concert-update-online-bank-ticket"
-	kut, _ := kafkaConfluent.NewConsumer(kafkaConfluent.GetConfig().GetKafkaConfig(configs.GetConfig().ServiceName, true), log)
-	kut.SetHandler(NewWorkerEventConsumer(wc, log))
-	kut.Subscribe(topicKut)
+	// offsets are committed by the consumer once a handler succeeds, so auto commit stays off
+	kc, err := kafkaConfluent.NewConsumer(kafkaConfluent.GetConfig().GetKafkaConfig(configs.GetConfig().ServiceName, false), processingConfig(), log)
+	if err != nil {
+		log.Error(context.Background(), "Kafka Consumer Error: cannot create consumer", err.Error())
+		return nil
+	}
+	kc.SetRouter(router)
+	kc.SetFailureHandler(retrier.Fail)
+	kc.Subscribe()
 
+	return kc
+}
+
+// NewWorkerRetrier builds the retrier moving failed worker events through the configured retry tiers
+func NewWorkerRetrier(producer kafkaConfluent.Producer, log log.Logger) kafkaConfluent.Retrier {
+	tiers, err := kafkaConfluent.ParseRetryTiers(configs.GetConfig().Kafka.KafkaRetryTiers)
+	if err != nil {
+		log.Error(context.Background(), "Kafka Retry Error: fallback to default retry tiers", err.Error())
+		tiers, _ = kafkaConfluent.ParseRetryTiers("")
+	}
+
+	// dead letter queues are read from the oldest uncommitted message when replayed
+	replayConfig := kafkaConfluent.GetConfig().GetKafkaConfig(fmt.Sprintf("%s-dlq-replay", configs.GetConfig().ServiceName), false)
+	replayConfig.SetKey("auto.offset.reset", "earliest")
+
+	return kafkaConfluent.NewRetrier(producer, tiers, replayConfig, log)
+}
+
+func processingConfig() kafkaConfluent.ProcessingConfig {
+	cfg := configs.GetConfig().Kafka
+	maxInFlight, _ := strconv.Atoi(cfg.KafkaMaxInFlight)
+	maxAttempts, _ := strconv.Atoi(cfg.KafkaMaxAttempts)
+	retryBackoffMs, _ := strconv.Atoi(cfg.KafkaRetryBackoffMs)
+
+	return kafkaConfluent.ProcessingConfig{
+		MaxInFlight:  maxInFlight,
+		MaxAttempts:  maxAttempts,
+		RetryBackoff: time.Duration(retryBackoffMs) * time.Millisecond,
+	}
 }
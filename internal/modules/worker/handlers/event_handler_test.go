@@ -3,8 +3,10 @@ package handlers_test
 import (
 	"worker-service/internal/modules/worker/handlers"
 	mockcert "worker-service/mocks/modules/worker"
+	mockkafka "worker-service/mocks/pkg/kafka"
 	mocklog "worker-service/mocks/pkg/log"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -25,5 +27,7 @@ func (suite *WorkerHandlerTestSuite) TestInitWorkerEventConflHandler() {
 	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
 	suite.workerUsecaseCommand.On("CreateBankTicket", mock.Anything, mock.Anything).Return(nil)
 	suite.workerUsecaseCommand.On("CreateOnlineBankTicket", mock.Anything, mock.Anything).Return(nil)
-	handlers.InitWorkerEventConflHandler(suite.workerUsecaseCommand, suite.mockLogger)
+	retrier := handlers.NewWorkerRetrier(new(mockkafka.Producer), suite.mockLogger)
+	consumer := handlers.InitWorkerEventConflHandler(suite.workerUsecaseCommand, retrier, suite.mockLogger)
+	assert.NotNil(suite.T(), consumer)
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"worker-service/configs/middleware"
 	"worker-service/internal/modules/worker"
 	"worker-service/internal/modules/worker/models/request"
 	"worker-service/internal/pkg/errors"
@@ -10,24 +11,34 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
 type WorkerHttpHandler struct {
 	WorkerUsecaseCommand worker.UsecaseCommand
+	WorkerUsecaseQuery   worker.UsecaseQuery
 	Logger               log.Logger
 	Validator            *validator.Validate
 }
 
-func InitWorkerHttpHandler(app *fiber.App, wuc worker.UsecaseCommand, log log.Logger, redisClient redis.Collections) {
+func InitWorkerHttpHandler(app *fiber.App, wuc worker.UsecaseCommand, wuq worker.UsecaseQuery, log log.Logger, redisClient redis.Collections) {
 	handler := &WorkerHttpHandler{
 		WorkerUsecaseCommand: wuc,
+		WorkerUsecaseQuery:   wuq,
 		Logger:               log,
 		Validator:            validator.New(),
 	}
-	// middlewares := middlewares.NewMiddlewares(redisClient)
+	middlewares := middleware.NewMiddlewares(redisClient)
 	route := app.Group("/api/worker")
 
 	route.Post("/v1/ticket", handler.CreateBankTicket)
+	route.Get("/v1/jobs/:id", handler.FindWorkerJob)
+	route.Post("/v1/online-ticket/simulate", handler.SimulateOnlineBankTicket)
+	route.Get("/v1/expiry-policies", handler.FindAllExpiryPolicy)
+	route.Put("/v1/expiry-policies", middlewares.VerifyBasicAuth(), handler.UpsertExpiryPolicy)
+	route.Delete("/v1/expiry-policies/:id", middlewares.VerifyBasicAuth(), handler.DeleteExpiryPolicy)
+	route.Get("/v1/inventory/:ticketId/:countryCode", handler.FindInventory)
+	route.Post("/v1/seat-pool/:ticketId/rebuild", middlewares.VerifyBasicAuth(), handler.RebuildSeatPool)
 }
 
 func (w WorkerHttpHandler) CreateBankTicket(c *fiber.Ctx) error {
@@ -35,13 +46,88 @@ func (w WorkerHttpHandler) CreateBankTicket(c *fiber.Ctx) error {
 	if err := c.BodyParser(req); err != nil {
 		return helpers.RespError(c, w.Logger, errors.BadRequest("bad request"))
 	}
+	if req.IdempotencyKey == "" {
+		// the header value is only valid during the request, the job keeps it after the response
+		req.IdempotencyKey = utils.CopyString(c.Get("Idempotency-Key"))
+	}
 
 	if err := w.Validator.Struct(req); err != nil {
 		return helpers.RespError(c, w.Logger, errors.BadRequest(err.Error()))
 	}
-	resp, err := w.WorkerUsecaseCommand.CreateBankTicket(c.Context(), *req)
+	resp, err := w.WorkerUsecaseCommand.EnqueueBankTicketJob(c.Context(), *req)
+	if err != nil {
+		return helpers.RespCustomError(c, w.Logger, err)
+	}
+	return helpers.RespAccepted(c, w.Logger, resp, "Create bank ticket accepted")
+}
+
+func (w WorkerHttpHandler) FindWorkerJob(c *fiber.Ctx) error {
+	resp, err := w.WorkerUsecaseQuery.FindWorkerJob(c.Context(), c.Params("id"))
+	if err != nil {
+		return helpers.RespCustomError(c, w.Logger, err)
+	}
+	return helpers.RespSuccess(c, w.Logger, resp, "Get job success")
+}
+
+func (w WorkerHttpHandler) SimulateOnlineBankTicket(c *fiber.Ctx) error {
+	req := new(request.SimulateOnlineTicketReq)
+	if err := c.BodyParser(req); err != nil {
+		return helpers.RespError(c, w.Logger, errors.BadRequest("bad request"))
+	}
+
+	if err := w.Validator.Struct(req); err != nil {
+		return helpers.RespError(c, w.Logger, errors.BadRequest(err.Error()))
+	}
+	resp, err := w.WorkerUsecaseCommand.SimulateOnlineBankTicket(c.Context(), *req)
+	if err != nil {
+		return helpers.RespCustomError(c, w.Logger, err)
+	}
+	return helpers.RespSuccess(c, w.Logger, resp, "Simulate online ticket success")
+}
+
+func (w WorkerHttpHandler) FindAllExpiryPolicy(c *fiber.Ctx) error {
+	resp, err := w.WorkerUsecaseQuery.FindAllExpiryPolicy(c.Context())
+	if err != nil {
+		return helpers.RespCustomError(c, w.Logger, err)
+	}
+	return helpers.RespSuccess(c, w.Logger, resp, "Get expiry policies success")
+}
+
+func (w WorkerHttpHandler) UpsertExpiryPolicy(c *fiber.Ctx) error {
+	req := new(request.UpsertExpiryPolicyReq)
+	if err := c.BodyParser(req); err != nil {
+		return helpers.RespError(c, w.Logger, errors.BadRequest("bad request"))
+	}
+
+	if err := w.Validator.Struct(req); err != nil {
+		return helpers.RespError(c, w.Logger, errors.BadRequest(err.Error()))
+	}
+	resp, err := w.WorkerUsecaseCommand.UpsertExpiryPolicy(c.Context(), *req)
+	if err != nil {
+		return helpers.RespCustomError(c, w.Logger, err)
+	}
+	return helpers.RespSuccess(c, w.Logger, resp, "Set expiry policy success")
+}
+
+func (w WorkerHttpHandler) DeleteExpiryPolicy(c *fiber.Ctx) error {
+	if err := w.WorkerUsecaseCommand.DeleteExpiryPolicy(c.Context(), c.Params("id")); err != nil {
+		return helpers.RespCustomError(c, w.Logger, err)
+	}
+	return helpers.RespSuccess(c, w.Logger, nil, "Delete expiry policy success")
+}
+
+func (w WorkerHttpHandler) FindInventory(c *fiber.Ctx) error {
+	resp, err := w.WorkerUsecaseQuery.FindInventory(c.Context(), c.Params("ticketId"), c.Params("countryCode"))
+	if err != nil {
+		return helpers.RespCustomError(c, w.Logger, err)
+	}
+	return helpers.RespSuccess(c, w.Logger, resp, "Get inventory success")
+}
+
+func (w WorkerHttpHandler) RebuildSeatPool(c *fiber.Ctx) error {
+	resp, err := w.WorkerUsecaseCommand.RebuildSeatPool(c.Context(), c.Params("ticketId"))
 	if err != nil {
 		return helpers.RespCustomError(c, w.Logger, err)
 	}
-	return helpers.RespSuccess(c, w.Logger, resp, "Create bank ticket success")
+	return helpers.RespSuccess(c, w.Logger, resp, "Rebuild seat pool success")
 }
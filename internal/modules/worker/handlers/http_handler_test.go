@@ -8,7 +8,10 @@ import (
 	"net/http/httptest"
 	"testing"
 	"worker-service/internal/modules/worker/handlers"
+	"worker-service/internal/modules/worker/models/dto"
+	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
 	"worker-service/internal/pkg/errors"
 	mockcert "worker-service/mocks/modules/worker"
 	mocklog "worker-service/mocks/pkg/log"
@@ -26,6 +29,7 @@ type WorkerHttpHandlerTestSuite struct {
 	suite.Suite
 
 	cUC       *mockcert.UsecaseCommand
+	cUQ       *mockcert.UsecaseQuery
 	cLog      *mocklog.Logger
 	validator *validator.Validate
 	cRedis    *mockredis.Collections
@@ -35,16 +39,18 @@ type WorkerHttpHandlerTestSuite struct {
 
 func (suite *WorkerHttpHandlerTestSuite) SetupTest() {
 	suite.cUC = new(mockcert.UsecaseCommand)
+	suite.cUQ = new(mockcert.UsecaseQuery)
 	suite.cLog = new(mocklog.Logger)
 	suite.validator = validator.New()
 	suite.cRedis = new(mockredis.Collections)
 	suite.handler = &handlers.WorkerHttpHandler{
 		WorkerUsecaseCommand: suite.cUC,
+		WorkerUsecaseQuery:   suite.cUQ,
 		Logger:               suite.cLog,
 		Validator:            suite.validator,
 	}
 	suite.app = fiber.New()
-	handlers.InitWorkerHttpHandler(suite.app, suite.cUC, suite.cLog, suite.cRedis)
+	handlers.InitWorkerHttpHandler(suite.app, suite.cUC, suite.cUQ, suite.cLog, suite.cRedis)
 }
 
 func TestUserHttpHandlerTestSuite(t *testing.T) {
@@ -52,8 +58,8 @@ func TestUserHttpHandlerTestSuite(t *testing.T) {
 }
 
 func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicket() {
-	rs := "result"
-	suite.cUC.On("CreateBankTicket", mock.Anything, mock.Anything).Return(&rs, nil)
+	job := &entity.WorkerJob{Id: "id", State: constants.JobQueued}
+	suite.cUC.On("EnqueueBankTicketJob", mock.Anything, mock.Anything).Return(job, nil)
 	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	reqM := request.CreateTicketReq{
@@ -72,11 +78,37 @@ func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicket() {
 
 	err := suite.handler.CreateBankTicket(ctx)
 	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusAccepted, ctx.Response().StatusCode())
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicketIdempotencyHeader() {
+	job := &entity.WorkerJob{Id: "id", State: constants.JobQueued}
+	suite.cUC.On("EnqueueBankTicketJob", mock.Anything, mock.MatchedBy(func(req request.CreateTicketReq) bool {
+		return req.IdempotencyKey == "key"
+	})).Return(job, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	reqM := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+	requestBody, _ := json.Marshal(reqM)
+
+	ctx := suite.app.AcquireCtx(&fasthttp.RequestCtx{})
+	ctx.Request().SetRequestURI("/v1/ticket")
+	ctx.Request().Header.SetMethod(fiber.MethodPost)
+	ctx.Request().Header.SetContentType("application/json")
+	ctx.Request().Header.Set("Idempotency-Key", "key")
+	ctx.Request().SetBody(requestBody)
+
+	err := suite.handler.CreateBankTicket(ctx)
+	assert.Nil(suite.T(), err)
+	suite.cUC.AssertExpectations(suite.T())
 }
 
 func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicketErrBodyParser() {
-	rs := "result"
-	suite.cUC.On("CreateBankTicket", mock.Anything, mock.Anything).Return(&rs, nil)
+	job := &entity.WorkerJob{Id: "id", State: constants.JobQueued}
+	suite.cUC.On("EnqueueBankTicketJob", mock.Anything, mock.Anything).Return(job, nil)
 	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	reqM := request.CreateTicketReq{
@@ -98,8 +130,8 @@ func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicketErrBodyParser() {
 }
 
 func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicketErrValidate() {
-	rs := "result"
-	suite.cUC.On("CreateBankTicket", mock.Anything, mock.Anything).Return(&rs, nil)
+	job := &entity.WorkerJob{Id: "id", State: constants.JobQueued}
+	suite.cUC.On("EnqueueBankTicketJob", mock.Anything, mock.Anything).Return(job, nil)
 	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	reqM := request.CreateTicketReq{}
@@ -118,7 +150,7 @@ func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicketErrValidate() {
 }
 
 func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicketErr() {
-	suite.cUC.On("CreateBankTicket", mock.Anything, mock.Anything).Return(nil, errors.BadRequest("error"))
+	suite.cUC.On("EnqueueBankTicketJob", mock.Anything, mock.Anything).Return(nil, errors.BadRequest("error"))
 	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	reqM := request.CreateTicketReq{
@@ -138,3 +170,199 @@ func (suite *WorkerHttpHandlerTestSuite) TestCreateBankTicketErr() {
 	err := suite.handler.CreateBankTicket(ctx)
 	assert.Nil(suite.T(), err)
 }
+
+func (suite *WorkerHttpHandlerTestSuite) TestFindWorkerJob() {
+	job := &entity.WorkerJob{Id: "id", State: constants.JobRunning}
+	suite.cUQ.On("FindWorkerJob", mock.Anything, "id").Return(job, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/worker/v1/jobs/id", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestFindWorkerJobErr() {
+	suite.cUQ.On("FindWorkerJob", mock.Anything, "id").Return(nil, errors.NotFound("job not found"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/worker/v1/jobs/id", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestFindInventory() {
+	inventory := &dto.Inventory{TicketId: "id", CountryCode: "ID", TotalRemaining: 5, Source: constants.InventorySourceRedis}
+	suite.cUQ.On("FindInventory", mock.Anything, "id", "ID").Return(inventory, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/worker/v1/inventory/id/ID", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestFindInventoryErr() {
+	suite.cUQ.On("FindInventory", mock.Anything, "id", "ID").Return(nil, errors.NotFound("ticket detail not found"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/worker/v1/inventory/id/ID", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestRebuildSeatPool() {
+	suite.cUC.On("RebuildSeatPool", mock.Anything, "id").Return(&dto.SeatPool{TicketId: "id", Size: 2}, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/api/worker/v1/seat-pool/id/rebuild", nil)
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestRebuildSeatPoolErr() {
+	suite.cUC.On("RebuildSeatPool", mock.Anything, "id").Return(nil, errors.Conflict("rebuild seat pool already in progress"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/api/worker/v1/seat-pool/id/rebuild", nil)
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusConflict, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestRebuildSeatPoolErrUnauthorized() {
+	req := httptest.NewRequest(fiber.MethodPost, "/api/worker/v1/seat-pool/id/rebuild", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusUnauthorized, resp.StatusCode)
+	suite.cUC.AssertNotCalled(suite.T(), "RebuildSeatPool", mock.Anything, mock.Anything)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestSimulateOnlineBankTicket() {
+	simulation := &dto.OnlineTicketSimulation{Tag: "tag", Strategy: constants.AllocationRankBySellout}
+	suite.cUC.On("SimulateOnlineBankTicket", mock.Anything, mock.MatchedBy(func(req request.SimulateOnlineTicketReq) bool {
+		return req.Tag == "tag" && req.Config != nil && req.Config.TotalQuota == 10
+	})).Return(simulation, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestBody, _ := json.Marshal(request.SimulateOnlineTicketReq{
+		Tag:    "tag",
+		Config: &request.OnlineTicketConfigReq{TotalQuota: 10},
+	})
+	req := httptest.NewRequest(fiber.MethodPost, "/api/worker/v1/online-ticket/simulate", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+	suite.cUC.AssertExpectations(suite.T())
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestSimulateOnlineBankTicketErrValidate() {
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestBody, _ := json.Marshal(request.SimulateOnlineTicketReq{})
+	req := httptest.NewRequest(fiber.MethodPost, "/api/worker/v1/online-ticket/simulate", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusBadRequest, resp.StatusCode)
+	suite.cUC.AssertNotCalled(suite.T(), "SimulateOnlineBankTicket", mock.Anything, mock.Anything)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestSimulateOnlineBankTicketErr() {
+	suite.cUC.On("SimulateOnlineBankTicket", mock.Anything, mock.Anything).Return(nil, errors.NotFound("ticket config not found"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestBody, _ := json.Marshal(request.SimulateOnlineTicketReq{Tag: "tag"})
+	req := httptest.NewRequest(fiber.MethodPost, "/api/worker/v1/online-ticket/simulate", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestFindAllExpiryPolicy() {
+	suite.cUQ.On("FindAllExpiryPolicy", mock.Anything).Return([]entity.ExpiryPolicy{{Id: "*:*:*", WindowMinutes: 15}}, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/worker/v1/expiry-policies", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestUpsertExpiryPolicy() {
+	suite.cUC.On("UpsertExpiryPolicy", mock.Anything, request.UpsertExpiryPolicyReq{PaymentType: "ewallet", WindowMinutes: 5}).Return(&entity.ExpiryPolicy{Id: "*:*:ewallet"}, nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestBody, _ := json.Marshal(request.UpsertExpiryPolicyReq{PaymentType: "ewallet", WindowMinutes: 5})
+	req := httptest.NewRequest(fiber.MethodPut, "/api/worker/v1/expiry-policies", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+	suite.cUC.AssertExpectations(suite.T())
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestUpsertExpiryPolicyErrValidate() {
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	requestBody, _ := json.Marshal(request.UpsertExpiryPolicyReq{EventId: "event"})
+	req := httptest.NewRequest(fiber.MethodPut, "/api/worker/v1/expiry-policies", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusBadRequest, resp.StatusCode)
+	suite.cUC.AssertNotCalled(suite.T(), "UpsertExpiryPolicy", mock.Anything, mock.Anything)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestUpsertExpiryPolicyErrUnauthorized() {
+	requestBody, _ := json.Marshal(request.UpsertExpiryPolicyReq{PaymentType: "ewallet", WindowMinutes: 5})
+	req := httptest.NewRequest(fiber.MethodPut, "/api/worker/v1/expiry-policies", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusUnauthorized, resp.StatusCode)
+	suite.cUC.AssertNotCalled(suite.T(), "UpsertExpiryPolicy", mock.Anything, mock.Anything)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestDeleteExpiryPolicy() {
+	suite.cUC.On("DeleteExpiryPolicy", mock.Anything, "event:*:*").Return(nil)
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodDelete, "/api/worker/v1/expiry-policies/event:*:*", nil)
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusOK, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestDeleteExpiryPolicyErr() {
+	suite.cUC.On("DeleteExpiryPolicy", mock.Anything, "event:*:*").Return(errors.NotFound("expiry policy not found"))
+	suite.cLog.On("Info", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.cLog.On("Error", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	req := httptest.NewRequest(fiber.MethodDelete, "/api/worker/v1/expiry-policies/event:*:*", nil)
+	req.SetBasicAuth("", "")
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *WorkerHttpHandlerTestSuite) TestDeleteExpiryPolicyErrUnauthorized() {
+	req := httptest.NewRequest(fiber.MethodDelete, "/api/worker/v1/expiry-policies/event:*:*", nil)
+	resp, err := suite.app.Test(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), fiber.StatusUnauthorized, resp.StatusCode)
+	suite.cUC.AssertNotCalled(suite.T(), "DeleteExpiryPolicy", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+	"worker-service/configs"
+	"worker-service/internal/modules/worker"
+	"worker-service/internal/pkg/events"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+	"worker-service/internal/pkg/log"
+)
+
+const (
+	defaultOutboxInterval      = time.Second
+	defaultOutboxBatchSize     = 100
+	defaultOutboxClaimTimeout  = 10 * time.Minute
+	defaultOutboxMinBackoff    = time.Second
+	defaultOutboxMaxBackoff    = 5 * time.Minute
+	defaultOutboxSentRetention = 7 * 24 * time.Hour
+)
+
+// InitOutboxRelay starts the relay publishing the events the worker saved in the outbox
+func InitOutboxRelay(wuc worker.UsecaseCommand, wuq worker.UsecaseQuery, producer kafkaConfluent.Producer, log log.Logger) events.Relay {
+	store := OutboxStore{WorkerUsecaseCommand: wuc, WorkerUsecaseQuery: wuq}
+	relay := events.NewRelay(producer, store, events.RelayConfig{
+		Interval:     outboxDuration(configs.GetConfig().Worker.OutboxInterval, defaultOutboxInterval),
+		BatchSize:    outboxBatchSize(),
+		ClaimTimeout: outboxDuration(configs.GetConfig().Worker.OutboxClaimTimeout, defaultOutboxClaimTimeout),
+		MinBackoff:   outboxDuration(configs.GetConfig().Worker.OutboxMinBackoff, defaultOutboxMinBackoff),
+		MaxBackoff:   outboxDuration(configs.GetConfig().Worker.OutboxMaxBackoff, defaultOutboxMaxBackoff),
+	}, log)
+
+	relay.Start()
+	return relay
+}
+
+// OutboxStore keeps the outbox of the worker in mongo
+type OutboxStore struct {
+	WorkerUsecaseCommand worker.UsecaseCommand
+	WorkerUsecaseQuery   worker.UsecaseQuery
+}
+
+func (s OutboxStore) FindPending(ctx context.Context, limit int) ([]events.Message, error) {
+	outboxEvents, err := s.WorkerUsecaseQuery.FindAllPendingOutboxEvent(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]events.Message, 0, len(outboxEvents))
+	for _, outboxEvent := range outboxEvents {
+		messages = append(messages, events.Message{
+			Id:       outboxEvent.Id,
+			Topic:    outboxEvent.Topic,
+			Key:      outboxEvent.Key,
+			Headers:  outboxEvent.Headers,
+			Value:    []byte(outboxEvent.Value),
+			Attempts: outboxEvent.Attempts,
+		})
+	}
+	return messages, nil
+}
+
+func (s OutboxStore) Claim(ctx context.Context, id string, until time.Time) (bool, error) {
+	return s.WorkerUsecaseCommand.ClaimOutboxEvent(ctx, id, until)
+}
+
+func (s OutboxStore) MarkSent(ctx context.Context, id string) error {
+	return s.WorkerUsecaseCommand.UpdateOutboxEventSent(ctx, id)
+}
+
+func (s OutboxStore) Retry(ctx context.Context, id string, next time.Time, reason string) error {
+	return s.WorkerUsecaseCommand.UpdateOutboxEventRetry(ctx, id, next, reason)
+}
+
+// OutboxSentRetention is how long a sent outbox event is kept before it is purged
+func OutboxSentRetention() time.Duration {
+	return outboxDuration(configs.GetConfig().Worker.OutboxSentRetention, defaultOutboxSentRetention)
+}
+
+func outboxDuration(value string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return defaultValue
+	}
+	return duration
+}
+
+func outboxBatchSize() int {
+	batchSize, err := strconv.Atoi(configs.GetConfig().Worker.OutboxBatchSize)
+	if err != nil || batchSize <= 0 {
+		return defaultOutboxBatchSize
+	}
+	return batchSize
+}
@@ -0,0 +1,89 @@
+package handlers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"worker-service/internal/modules/worker/handlers"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/events"
+	mockcert "worker-service/mocks/modules/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type OutboxHandlerTestSuite struct {
+	suite.Suite
+
+	cUC   *mockcert.UsecaseCommand
+	cUQ   *mockcert.UsecaseQuery
+	store handlers.OutboxStore
+}
+
+func (suite *OutboxHandlerTestSuite) SetupTest() {
+	suite.cUC = new(mockcert.UsecaseCommand)
+	suite.cUQ = new(mockcert.UsecaseQuery)
+	suite.store = handlers.OutboxStore{WorkerUsecaseCommand: suite.cUC, WorkerUsecaseQuery: suite.cUQ}
+}
+
+func TestOutboxHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboxHandlerTestSuite))
+}
+
+func (suite *OutboxHandlerTestSuite) TestFindPending() {
+	suite.cUQ.On("FindAllPendingOutboxEvent", mock.Anything, 100).Return([]entity.OutboxEvent{{
+		Id:       "event",
+		Topic:    "concert-ticket-released",
+		Key:      "ticket",
+		Headers:  map[string]string{events.HeaderEventType: "concert-ticket-released"},
+		Value:    `{"id":"event"}`,
+		Attempts: 2,
+	}}, nil)
+
+	messages, err := suite.store.FindPending(context.Background(), 100)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []events.Message{{
+		Id:       "event",
+		Topic:    "concert-ticket-released",
+		Key:      "ticket",
+		Headers:  map[string]string{events.HeaderEventType: "concert-ticket-released"},
+		Value:    []byte(`{"id":"event"}`),
+		Attempts: 2,
+	}}, messages)
+}
+
+func (suite *OutboxHandlerTestSuite) TestFindPendingErr() {
+	suite.cUQ.On("FindAllPendingOutboxEvent", mock.Anything, 100).Return(nil, errors.InternalServerError("error"))
+
+	_, err := suite.store.FindPending(context.Background(), 100)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *OutboxHandlerTestSuite) TestClaim() {
+	until := time.Now().Add(time.Minute)
+	suite.cUC.On("ClaimOutboxEvent", mock.Anything, "event", until).Return(true, nil)
+
+	claimed, err := suite.store.Claim(context.Background(), "event", until)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), claimed)
+}
+
+func (suite *OutboxHandlerTestSuite) TestMarkSent() {
+	suite.cUC.On("UpdateOutboxEventSent", mock.Anything, "event").Return(nil)
+
+	err := suite.store.MarkSent(context.Background(), "event")
+	assert.NoError(suite.T(), err)
+	suite.cUC.AssertExpectations(suite.T())
+}
+
+func (suite *OutboxHandlerTestSuite) TestRetry() {
+	next := time.Now().Add(time.Second)
+	suite.cUC.On("UpdateOutboxEventRetry", mock.Anything, "event", next, "broker down").Return(nil)
+
+	err := suite.store.Retry(context.Background(), "event", next, "broker down")
+	assert.NoError(suite.T(), err)
+	suite.cUC.AssertExpectations(suite.T())
+}
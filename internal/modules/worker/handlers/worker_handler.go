@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"worker-service/internal/modules/worker"
 	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
 	"worker-service/internal/pkg/log"
 
 	kafkaPkgConfluent "worker-service/internal/pkg/kafka/confluent"
@@ -18,45 +19,50 @@ type WorkerEventHandler struct {
 	Logger               log.Logger
 }
 
-func NewWorkerEventConsumer(wc worker.UsecaseCommand, log log.Logger) kafkaPkgConfluent.ConsumerHandler {
+func NewWorkerEventConsumer(wc worker.UsecaseCommand, log log.Logger) *WorkerEventHandler {
 	return &WorkerEventHandler{
 		WorkerUsecaseCommand: wc,
 		Logger:               log,
 	}
 }
 
-func (w WorkerEventHandler) CreateBankTicket(message *k.Message, topic string) {
-	w.Logger.Info(context.Background(), string(message.Value), fmt.Sprintf("Topic: %v Partition: %v - Offset: %v", *message.TopicPartition.Topic, message.TopicPartition.Partition, message.TopicPartition.Offset.String()))
+// RegisterRoutes registers every worker topic handler, with its retry tiers, to the kafka router
+func (w WorkerEventHandler) RegisterRoutes(router kafkaPkgConfluent.Router, retrier kafkaPkgConfluent.Retrier) {
+	retrier.Handle(router, constants.TopicCreateBankTicket, w.CreateBankTicket)
+	retrier.Handle(router, constants.TopicUpdateOnlineBankTicket, w.UpdateOnlineBankTicket)
+}
+
+func (w WorkerEventHandler) CreateBankTicket(ctx context.Context, message *k.Message) error {
+	w.Logger.Info(ctx, string(message.Value), fmt.Sprintf("Topic: %v Partition: %v - Offset: %v", *message.TopicPartition.Topic, message.TopicPartition.Partition, message.TopicPartition.Offset.String()))
 
 	var msg request.CreateTicketReq
 	if err := json.Unmarshal(message.Value, &msg); err != nil {
-		w.Logger.Error(context.Background(), err.Error(), string(message.Value))
-		return
+		w.Logger.Error(ctx, err.Error(), string(message.Value))
+		return err
 	}
-	if _, err := w.WorkerUsecaseCommand.CreateBankTicket(context.Background(), msg); err != nil {
-		w.Logger.Error(context.Background(), err.Error(), string(message.Value))
-		return
+	if _, err := w.WorkerUsecaseCommand.CreateBankTicket(ctx, msg); err != nil {
+		w.Logger.Error(ctx, err.Error(), string(message.Value))
+		return err
 	}
-	return
+	return nil
 }
 
-func (w WorkerEventHandler) UpdateOnlineBankTicket(message *k.Message, topic string) {
-	w.Logger.Info(context.Background(), string(message.Value), fmt.Sprintf("Topic: %v Partition: %v - Offset: %v", *message.TopicPartition.Topic, message.TopicPartition.Partition, message.TopicPartition.Offset.String()))
+func (w WorkerEventHandler) UpdateOnlineBankTicket(ctx context.Context, message *k.Message) error {
+	w.Logger.Info(ctx, string(message.Value), fmt.Sprintf("Topic: %v Partition: %v - Offset: %v", *message.TopicPartition.Topic, message.TopicPartition.Partition, message.TopicPartition.Offset.String()))
 
 	var msg request.CreateOnlineTicketReq
 	if err := json.Unmarshal(message.Value, &msg); err != nil {
-		w.Logger.Error(context.Background(), err.Error(), string(message.Value))
-		return
+		w.Logger.Error(ctx, err.Error(), string(message.Value))
+		return err
 	}
 
-	resp, err := w.WorkerUsecaseCommand.CreateOnlineBankTicket(context.Background(), msg)
+	resp, err := w.WorkerUsecaseCommand.CreateOnlineBankTicket(ctx, msg)
 	if err != nil {
-		w.Logger.Error(context.Background(), err.Error(), string(message.Value))
-		return
+		w.Logger.Error(ctx, err.Error(), string(message.Value))
+		return err
 	}
 	if resp != nil {
-		w.Logger.Info(context.Background(), *resp, string(message.Value))
-		return
+		w.Logger.Info(ctx, *resp, string(message.Value))
 	}
-	return
+	return nil
 }
@@ -1,12 +1,16 @@
 package handlers_test
 
 import (
+	"context"
 	"testing"
 	"worker-service/internal/modules/worker/handlers"
 	"worker-service/internal/pkg/errors"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
 	mockcert "worker-service/mocks/modules/worker"
+	mockkafka "worker-service/mocks/pkg/kafka"
 	mocklog "worker-service/mocks/pkg/log"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
@@ -47,7 +51,8 @@ func (suite *WorkerHandlerTestSuite) TestCreateBankTicket() {
 			Offset:    kafka.OffsetBeginning,
 		},
 	}
-	suite.handler.CreateBankTicket(&msg, topic)
+	err := suite.handler.CreateBankTicket(context.Background(), &msg)
+	assert.NoError(suite.T(), err)
 }
 
 func (suite *WorkerHandlerTestSuite) TestCreateBankTicketErr() {
@@ -64,7 +69,8 @@ func (suite *WorkerHandlerTestSuite) TestCreateBankTicketErr() {
 			Offset:    kafka.OffsetBeginning,
 		},
 	}
-	suite.handler.CreateBankTicket(&msg, topic)
+	err := suite.handler.CreateBankTicket(context.Background(), &msg)
+	assert.Error(suite.T(), err)
 }
 
 func (suite *WorkerHandlerTestSuite) TestCreateBankTicketErrParse() {
@@ -80,7 +86,8 @@ func (suite *WorkerHandlerTestSuite) TestCreateBankTicketErrParse() {
 			Offset:    kafka.OffsetBeginning,
 		},
 	}
-	suite.handler.CreateBankTicket(&msg, topic)
+	err := suite.handler.CreateBankTicket(context.Background(), &msg)
+	assert.Error(suite.T(), err)
 }
 
 func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicket() {
@@ -96,7 +103,8 @@ func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicket() {
 			Offset:    kafka.OffsetBeginning,
 		},
 	}
-	suite.handler.UpdateOnlineBankTicket(&msg, topic)
+	err := suite.handler.UpdateOnlineBankTicket(context.Background(), &msg)
+	assert.NoError(suite.T(), err)
 }
 
 func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicketNil() {
@@ -112,7 +120,8 @@ func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicketNil() {
 			Offset:    kafka.OffsetBeginning,
 		},
 	}
-	suite.handler.UpdateOnlineBankTicket(&msg, topic)
+	err := suite.handler.UpdateOnlineBankTicket(context.Background(), &msg)
+	assert.NoError(suite.T(), err)
 }
 
 func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicketErr() {
@@ -128,7 +137,8 @@ func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicketErr() {
 			Offset:    kafka.OffsetBeginning,
 		},
 	}
-	suite.handler.UpdateOnlineBankTicket(&msg, topic)
+	err := suite.handler.UpdateOnlineBankTicket(context.Background(), &msg)
+	assert.Error(suite.T(), err)
 }
 
 func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicketErrParse() {
@@ -144,5 +154,22 @@ func (suite *WorkerHandlerTestSuite) TestUpdateOnlineBankTicketErrParse() {
 			Offset:    kafka.OffsetBeginning,
 		},
 	}
-	suite.handler.UpdateOnlineBankTicket(&msg, topic)
+	err := suite.handler.UpdateOnlineBankTicket(context.Background(), &msg)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *WorkerHandlerTestSuite) TestRegisterRoutes() {
+	router := kafkaConfluent.NewRouter()
+	tiers, _ := kafkaConfluent.ParseRetryTiers("1m,10m")
+	retrier := kafkaConfluent.NewRetrier(new(mockkafka.Producer), tiers, nil, suite.mockLogger)
+	suite.handler.RegisterRoutes(router, retrier)
+
+	assert.Equal(suite.T(), []string{
+		"concert-create-bank-ticket",
+		"concert-create-bank-ticket.retry.10m",
+		"concert-create-bank-ticket.retry.1m",
+		"concert-update-online-bank-ticket",
+		"concert-update-online-bank-ticket.retry.10m",
+		"concert-update-online-bank-ticket.retry.1m",
+	}, router.Topics())
 }
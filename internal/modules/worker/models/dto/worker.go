@@ -1,7 +1,137 @@
 package dto
 
+import (
+	"net/http"
+	"time"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/errors"
+)
+
 type CountryQuota struct {
 	CountryCode   string `json:"countryCode"`
 	CountryNumber int    `json:"countryNumber"`
 	TotalQuota    int    `json:"totalQuota"`
 }
+
+// OnlineTicketSimulation is what creating the online tickets of a tag would do
+type OnlineTicketSimulation struct {
+	Tag        string                    `json:"tag"`
+	Strategy   string                    `json:"strategy"`
+	TotalQuota int                       `json:"totalQuota"`
+	Countries  []OnlineTicketCountryPlan `json:"countries"`
+}
+
+// OnlineTicketCountryPlan is the seat range created for a country and its ticket detail totals afterwards,
+// the seat numbers are zero when no seat is left to create
+type OnlineTicketCountryPlan struct {
+	CountryCode     string `json:"countryCode"`
+	CountryNumber   int    `json:"countryNumber"`
+	EventId         string `json:"eventId"`
+	TicketId        string `json:"ticketId"`
+	FirstSeatNumber int    `json:"firstSeatNumber"`
+	LastSeatNumber  int    `json:"lastSeatNumber"`
+	TotalSeat       int    `json:"totalSeat"`
+	TotalQuota      int    `json:"totalQuota"`
+	TotalRemaining  int    `json:"totalRemaining"`
+}
+
+// CountryDemand is the offline sale and the waiting queue of a country
+type CountryDemand struct {
+	CountryCode          string `json:"countryCode"`
+	TotalTicket          int    `json:"totalTicket"`
+	TotalAvailableTicket int    `json:"totalAvailableTicket"`
+	QueueSize            int    `json:"queueSize"`
+}
+
+// AllocationInput is what an allocation strategy splits the online quota on,
+// countries are ranked by their remaining offline tickets
+type AllocationInput struct {
+	TotalQuota  int                  `json:"totalQuota"`
+	CountryList []entity.CountryList `json:"countryList"`
+	Countries   []CountryDemand      `json:"countries"`
+}
+
+// IdempotencyOutcome is the stored outcome of a request sent with an idempotency key
+type IdempotencyOutcome struct {
+	Result  string `json:"result"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// NewIdempotencyOutcome builds the outcome of a request result, errors without a code are server errors
+func NewIdempotencyOutcome(result *string, err error) IdempotencyOutcome {
+	if err == nil {
+		outcome := IdempotencyOutcome{Code: http.StatusOK}
+		if result != nil {
+			outcome.Result = *result
+		}
+		return outcome
+	}
+
+	outcome := IdempotencyOutcome{
+		Message: err.Error(),
+		Code:    http.StatusInternalServerError,
+	}
+	if e, ok := err.(*errors.ErrorString); ok {
+		outcome.Code = e.Code()
+	}
+	return outcome
+}
+
+// Response returns the stored result or error of the outcome
+func (o IdempotencyOutcome) Response() (*string, error) {
+	if o.Code == http.StatusOK {
+		return &o.Result, nil
+	}
+	return nil, errors.CustomError(o.Message, o.Code, 0)
+}
+
+// TicketReleased is the data of a concert-ticket-released event, a seat given back to the ticket inventory
+type TicketReleased struct {
+	TicketNumber string    `json:"ticketNumber"`
+	TicketId     string    `json:"ticketId"`
+	EventId      string    `json:"eventId"`
+	CountryCode  string    `json:"countryCode"`
+	TicketType   string    `json:"ticketType"`
+	SeatNumber   int       `json:"seatNumber"`
+	Reason       string    `json:"reason"`
+	ReleasedAt   time.Time `json:"releasedAt"`
+}
+
+// PaymentExpired is the data of a concert-payment-expired event, a payment invalidated once its window passed
+type PaymentExpired struct {
+	PaymentId    string    `json:"paymentId"`
+	UserId       string    `json:"userId"`
+	TicketNumber string    `json:"ticketNumber"`
+	TicketId     string    `json:"ticketId"`
+	EventId      string    `json:"eventId"`
+	ExpiryTime   time.Time `json:"expiryTime"`
+	ExpiredAt    time.Time `json:"expiredAt"`
+}
+
+// BankTicketGenerated is the data of a concert-bank-ticket-generated event, a range of seats created for a ticket
+type BankTicketGenerated struct {
+	TicketId        string    `json:"ticketId"`
+	EventId         string    `json:"eventId"`
+	CountryCode     string    `json:"countryCode"`
+	TicketType      string    `json:"ticketType"`
+	FirstSeatNumber int       `json:"firstSeatNumber"`
+	LastSeatNumber  int       `json:"lastSeatNumber"`
+	Total           int       `json:"total"`
+	GeneratedAt     time.Time `json:"generatedAt"`
+}
+
+// Inventory is the live remaining tickets of a ticket detail, Source tells whether it was read from its redis counter
+// or from mongo when the counter is not seeded yet
+type Inventory struct {
+	TicketId       string `json:"ticketId"`
+	CountryCode    string `json:"countryCode"`
+	TotalRemaining int    `json:"totalRemaining"`
+	Source         string `json:"source"`
+}
+
+// SeatPool is the pool of free seats of a ticket once rebuilt, Size is the number of seats in it
+type SeatPool struct {
+	TicketId string `json:"ticketId"`
+	Size     int    `json:"size"`
+}
@@ -1,21 +1,26 @@
 package entity
 
-import "time"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type BankTicket struct {
-	TicketNumber  string    `json:"ticketNumber" bson:"ticketNumber"`
-	SeatNumber    int       `json:"seatNumber" bson:"seatNumber"`
-	IsUsed        bool      `json:"isUsed" bson:"isUsed"`
-	UserId        string    `json:"userId" bson:"userId"`
-	QueueId       string    `json:"queueId" bson:"queueId"`
-	TicketId      string    `json:"ticketId" bson:"ticketId"`
-	EventId       string    `json:"eventId" bson:"eventId"`
-	CountryCode   string    `json:"countryCode" bson:"countryCode"`
-	Price         int       `json:"price" bson:"price"`
-	TicketType    string    `json:"ticketType" bson:"ticketType"`
-	PaymentStatus string    `json:"paymentStatus" bson:"paymentStatus"`
-	CreatedAt     time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt" bson:"updatedAt"`
+	Id            primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	TicketNumber  string             `json:"ticketNumber" bson:"ticketNumber"`
+	SeatNumber    int                `json:"seatNumber" bson:"seatNumber"`
+	IsUsed        bool               `json:"isUsed" bson:"isUsed"`
+	UserId        string             `json:"userId" bson:"userId"`
+	QueueId       string             `json:"queueId" bson:"queueId"`
+	TicketId      string             `json:"ticketId" bson:"ticketId"`
+	EventId       string             `json:"eventId" bson:"eventId"`
+	CountryCode   string             `json:"countryCode" bson:"countryCode"`
+	Price         int                `json:"price" bson:"price"`
+	TicketType    string             `json:"ticketType" bson:"ticketType"`
+	PaymentStatus string             `json:"paymentStatus" bson:"paymentStatus"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 type Country struct {
@@ -26,18 +31,19 @@ type Country struct {
 }
 
 type TicketDetail struct {
-	TicketId       string    `json:"ticketId" bson:"ticketId"`
-	EventId        string    `json:"eventId" bson:"eventId"`
-	TicketType     string    `json:"ticketType" bson:"ticketType"`
-	TicketPrice    int       `json:"ticketPrice" bson:"ticketPrice"`
-	TotalQuota     int       `json:"totalQuota" bson:"totalQuota"`
-	TotalRemaining int       `json:"totalRemaining" bson:"totalRemaining"`
-	ContinentName  string    `json:"continentName" bson:"continentName"`
-	ContinentCode  string    `json:"continentCode" bson:"continentCode"`
-	Country        Country   `json:"country" bson:"country"`
-	Tag            string    `json:"tag" bson:"tag"`
-	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
+	Id             primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	TicketId       string             `json:"ticketId" bson:"ticketId"`
+	EventId        string             `json:"eventId" bson:"eventId"`
+	TicketType     string             `json:"ticketType" bson:"ticketType"`
+	TicketPrice    int                `json:"ticketPrice" bson:"ticketPrice"`
+	TotalQuota     int                `json:"totalQuota" bson:"totalQuota"`
+	TotalRemaining int                `json:"totalRemaining" bson:"totalRemaining"`
+	ContinentName  string             `json:"continentName" bson:"continentName"`
+	ContinentCode  string             `json:"continentCode" bson:"continentCode"`
+	Country        Country            `json:"country" bson:"country"`
+	Tag            string             `json:"tag" bson:"tag"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 type VaNumber struct {
@@ -70,19 +76,28 @@ type Payment struct {
 }
 
 type PaymentHistory struct {
-	PaymentId      string    `json:"paymentId" bson:"paymentId"`
-	UserId         string    `json:"userId" bson:"userId"`
-	Ticket         *Ticket   `json:"ticket" bson:"ticket"`
-	Payment        *Payment  `json:"payment" bson:"payment"`
-	IsValidPayment bool      `json:"isValidPayment" bson:"isValidPayment"`
-	ExpiryTime     time.Time `json:"expiryTime" bson:"expiryTime"`
-	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
+	Id             primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	PaymentId      string             `json:"paymentId" bson:"paymentId"`
+	UserId         string             `json:"userId" bson:"userId"`
+	Ticket         *Ticket            `json:"ticket" bson:"ticket"`
+	Payment        *Payment           `json:"payment" bson:"payment"`
+	IsValidPayment bool               `json:"isValidPayment" bson:"isValidPayment"`
+	ExpiryTime     time.Time          `json:"expiryTime" bson:"expiryTime"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Order is the order of a seat, the worker only reads the link to its bank ticket
+type Order struct {
+	TicketNumber string    `json:"ticketNumber" bson:"ticketNumber"`
+	UserId       string    `json:"userId" bson:"userId"`
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
 }
 
 type OnlineTicketConfig struct {
 	Tag         string        `json:"tag" bson:"tag"`
 	TotalQuota  int           `json:"totalQuota" bson:"totalQuota"`
+	Strategy    string        `json:"strategy" bson:"strategy"`
 	CountryList []CountryList `json:"countryList" bson:"countryList"`
 	CreatedAt   time.Time     `json:"createdAt" bson:"createdAt"`
 	UpdatedAt   time.Time     `json:"updatedAt" bson:"updatedAt"`
@@ -93,6 +108,7 @@ type OnlineTicketConfig struct {
 type CountryList struct {
 	CountryNumber int    `json:"countryNumber" bson:"countryNumber"`
 	Percentage    int    `json:"percentage" bson:"percentage"`
+	Quota         int    `json:"quota" bson:"quota"`
 	CountryCode   string `json:"countryCode" bson:"countryCode"`
 }
 
@@ -102,3 +118,161 @@ type AggregateTotalTicket struct {
 	TotalAvailableTicket int    `json:"totalAvailableTicket" bson:"totalAvailableTicket"`
 	TotalTicket          int    `json:"totalTicket" bson:"totalTicket"`
 }
+
+// AggregateBankTicket counts the bank tickets of a ticket, and those of them which are not used
+type AggregateBankTicket struct {
+	TicketId string `json:"ticketId" bson:"_id"`
+	Total    int    `json:"total" bson:"total"`
+	Unused   int    `json:"unused" bson:"unused"`
+}
+
+// BankTicketProgress is the checkpoint of a bank ticket generation, seats up to LastSeatNumber are committed
+type BankTicketProgress struct {
+	Id             string     `json:"id" bson:"_id,omitempty"`
+	EventId        string     `json:"eventId" bson:"eventId"`
+	TicketId       string     `json:"ticketId" bson:"ticketId"`
+	CountryCode    string     `json:"countryCode" bson:"countryCode"`
+	TicketType     string     `json:"ticketType" bson:"ticketType"`
+	State          string     `json:"state" bson:"state"`
+	LastSeatNumber int        `json:"lastSeatNumber" bson:"lastSeatNumber"`
+	Generated      int        `json:"generated" bson:"generated"`
+	Total          int        `json:"total" bson:"total"`
+	Error          string     `json:"error" bson:"error"`
+	StartedAt      time.Time  `json:"startedAt" bson:"startedAt"`
+	UpdatedAt      time.Time  `json:"updatedAt" bson:"updatedAt"`
+	CompletedAt    *time.Time `json:"completedAt" bson:"completedAt"`
+}
+
+type JobProgress struct {
+	Generated int `json:"generated" bson:"generated"`
+	Total     int `json:"total" bson:"total"`
+}
+
+// WorkerJob is a run of a worker task, whether it was requested over http or received from kafka
+type WorkerJob struct {
+	Id     string `json:"id" bson:"_id,omitempty"`
+	Type   string `json:"type" bson:"type"`
+	Source string `json:"source" bson:"source"`
+	State  string `json:"state" bson:"state"`
+	// Instance is the replica running the job, its unfinished jobs are failed when it starts again
+	Instance   string            `json:"instance" bson:"instance"`
+	Payload    map[string]string `json:"payload" bson:"payload"`
+	Progress   JobProgress       `json:"progress" bson:"progress"`
+	Result     string            `json:"result" bson:"result"`
+	Error      string            `json:"error" bson:"error"`
+	CreatedAt  time.Time         `json:"createdAt" bson:"createdAt"`
+	StartedAt  *time.Time        `json:"startedAt" bson:"startedAt"`
+	FinishedAt *time.Time        `json:"finishedAt" bson:"finishedAt"`
+	UpdatedAt  time.Time         `json:"updatedAt" bson:"updatedAt"`
+}
+
+// WorkerJobRun is the report of one run of a cron job, failed records do not stop the run
+type WorkerJobRun struct {
+	Id  string `json:"id" bson:"_id,omitempty"`
+	Job string `json:"job" bson:"job"`
+	// Status is the outcome of the run, see the run states in constants
+	Status string `json:"status" bson:"status"`
+	// Instance is the replica the run happened on, empty when it was not scheduled
+	Instance  string `json:"instance" bson:"instance"`
+	Scanned   int    `json:"scanned" bson:"scanned"`
+	Reclaimed int    `json:"reclaimed" bson:"reclaimed"`
+	// Fixed counts the records a reconciliation run repaired
+	Fixed    int          `json:"fixed" bson:"fixed"`
+	Skipped  int          `json:"skipped" bson:"skipped"`
+	Failed   int          `json:"failed" bson:"failed"`
+	Failures []RunFailure `json:"failures" bson:"failures"`
+	// FailuresOmitted counts the failures past the ones listed on the run
+	FailuresOmitted int    `json:"failuresOmitted,omitempty" bson:"failuresOmitted,omitempty"`
+	Error           string `json:"error" bson:"error"`
+	// FixMode is set on a reconciliation run allowed to repair the discrepancies it finds
+	FixMode       bool          `json:"fixMode,omitempty" bson:"fixMode,omitempty"`
+	Discrepancies []Discrepancy `json:"discrepancies,omitempty" bson:"discrepancies,omitempty"`
+	// DiscrepanciesOmitted counts the discrepancies found past the ones listed on the run
+	DiscrepanciesOmitted int      `json:"discrepanciesOmitted,omitempty" bson:"discrepanciesOmitted,omitempty"`
+	Orphans              []Orphan `json:"orphans,omitempty" bson:"orphans,omitempty"`
+	// OrphansOmitted counts the orphans found past the ones listed on the run
+	OrphansOmitted int `json:"orphansOmitted,omitempty" bson:"orphansOmitted,omitempty"`
+	// TimedOut is set when the run stopped at its time budget before draining every record
+	TimedOut bool `json:"timedOut" bson:"timedOut"`
+	// Fence is the fencing token of the cron lease the run held, a later lease has a larger one, 0 when it was not scheduled.
+	// It tells overlapping runs apart, their writes do not check it.
+	Fence      int64     `json:"fence" bson:"fence"`
+	StartedAt  time.Time `json:"startedAt" bson:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt" bson:"finishedAt"`
+	DurationMs int64     `json:"durationMs" bson:"durationMs"`
+}
+
+// RunFailure is a record a job run could not process
+type RunFailure struct {
+	Id     string `json:"id" bson:"id"`
+	Reason string `json:"reason" bson:"reason"`
+}
+
+// Discrepancy is a ticket detail field which does not match its bank tickets,
+// Expected is the value counted from the bank tickets and Actual the one of the ticket detail
+type Discrepancy struct {
+	TicketId    string `json:"ticketId" bson:"ticketId"`
+	CountryCode string `json:"countryCode" bson:"countryCode"`
+	Field       string `json:"field" bson:"field"`
+	Expected    int    `json:"expected" bson:"expected"`
+	Actual      int    `json:"actual" bson:"actual"`
+	Fixed       bool   `json:"fixed" bson:"fixed"`
+}
+
+// Orphan is an order, payment or bank ticket whose link by ticketNumber is broken, Action is the rule it was resolved with
+type Orphan struct {
+	Kind         string `json:"kind" bson:"kind"`
+	TicketNumber string `json:"ticketNumber" bson:"ticketNumber"`
+	PaymentId    string `json:"paymentId,omitempty" bson:"paymentId,omitempty"`
+	Reason       string `json:"reason" bson:"reason"`
+	Action       string `json:"action" bson:"action"`
+	Fixed        bool   `json:"fixed" bson:"fixed"`
+}
+
+// OrphanAudit is the record of an orphan fixed by the cleanup, it is saved in the transaction of the fix
+type OrphanAudit struct {
+	Id        string `json:"id" bson:"_id"`
+	RunId     string `json:"runId" bson:"runId"`
+	Orphan    `bson:",inline"`
+	TicketId  string    `json:"ticketId" bson:"ticketId"`
+	UserId    string    `json:"userId" bson:"userId"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// ExpiryPolicy is how long a pending payment or bank ticket is held before the expiry jobs reclaim it.
+// An empty EventId, TicketType or PaymentType matches any value, the policy with all of them empty is the global default.
+type ExpiryPolicy struct {
+	Id            string    `json:"id" bson:"_id,omitempty"`
+	EventId       string    `json:"eventId" bson:"eventId"`
+	TicketType    string    `json:"ticketType" bson:"ticketType"`
+	PaymentType   string    `json:"paymentType" bson:"paymentType"`
+	WindowMinutes int       `json:"windowMinutes" bson:"windowMinutes"`
+	UpdatedAt     time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// CronJob is the configuration of a cron job changed at runtime, it replaces the configured defaults of the job
+type CronJob struct {
+	Name      string    `json:"name" bson:"_id,omitempty"`
+	Spec      string    `json:"spec" bson:"spec"`
+	Timezone  string    `json:"timezone" bson:"timezone"`
+	Enabled   bool      `json:"enabled" bson:"enabled"`
+	Timeout   string    `json:"timeout" bson:"timeout"`
+	Overlap   string    `json:"overlap" bson:"overlap"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// OutboxEvent is an event saved in the transaction of the change it describes, the outbox relay publishes it afterwards.
+// Value is the JSON envelope of the event, a pending event is due to be published from NextAttemptAt.
+type OutboxEvent struct {
+	Id            string            `json:"id" bson:"_id"`
+	Topic         string            `json:"topic" bson:"topic"`
+	Key           string            `json:"key" bson:"key"`
+	Headers       map[string]string `json:"headers" bson:"headers"`
+	Value         string            `json:"value" bson:"value"`
+	Status        string            `json:"status" bson:"status"`
+	Attempts      int               `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time         `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LastError     string            `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt" bson:"createdAt"`
+	SentAt        *time.Time        `json:"sentAt,omitempty" bson:"sentAt,omitempty"`
+}
@@ -1,5 +1,11 @@
 package request
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 type CreateTicketRequest struct {
 	TicketType  string `json:"ticketType" validate:"required"`
 	CountryCode string `json:"countryCode" validate:"required"`
@@ -8,8 +14,9 @@ type CreateTicketRequest struct {
 }
 
 type CreateTicketReq struct {
-	TicketId string `json:"ticketId" validate:"required"`
-	EventId  string `json:"eventId" validate:"required"`
+	TicketId       string `json:"ticketId" validate:"required"`
+	EventId        string `json:"eventId" validate:"required"`
+	IdempotencyKey string `json:"idempotencyKey"`
 }
 
 type CreateOnlineTicketReq struct {
@@ -22,6 +29,14 @@ type UpdateBankTicketRequest struct {
 	Price        int    `json:"price"`
 }
 
+// ReleaseBankTicketReq releases a bank ticket only while it is still used by UserId and unchanged since UpdatedAt
+type ReleaseBankTicketReq struct {
+	TicketNumber string
+	UserId       string
+	UpdatedAt    time.Time
+	Price        int
+}
+
 type UpdateOnlineTicketConfigReq struct {
 	Tag           string `json:"tag"`
 	CountryNumber int    `json:"countryNumber"`
@@ -31,9 +46,21 @@ type UpdateOnlineTicketConfigReq struct {
 type CountryList struct {
 	CountryNumber int    `json:"countryNumber"`
 	Percentage    int    `json:"percentage"`
+	Quota         int    `json:"quota"`
 	CountryCode   string `json:"countryCode"`
 }
 
+type OnlineTicketConfigReq struct {
+	TotalQuota  int           `json:"totalQuota" validate:"min=0"`
+	Strategy    string        `json:"strategy"`
+	CountryList []CountryList `json:"countryList"`
+}
+
+type SimulateOnlineTicketReq struct {
+	Tag    string                 `json:"tag" validate:"required"`
+	Config *OnlineTicketConfigReq `json:"config"`
+}
+
 type UpdateTicketDetailReq struct {
 	Tag            string `json:"tag"`
 	TicketType     string `json:"ticketType"`
@@ -42,13 +69,60 @@ type UpdateTicketDetailReq struct {
 	TotalRemaining int    `json:"totalRemaining"`
 }
 
-type UpdateTicketDetailByIdReq struct {
-	TicketId       string `json:"ticketId"`
-	TotalRemaining int    `json:"totalRemaining"`
-}
-
 type TicketDetailByTagReq struct {
 	Tag         string `json:"tag"`
 	TicketType  string `json:"ticketType"`
 	CountryCode string `json:"countryCode"`
 }
+
+type ReplayDeadLetterReq struct {
+	Topic string `json:"topic" validate:"required"`
+	Limit int    `json:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+// KeysetPageReq is a page of documents ordered by createdAt and _id, starting after the last document of the previous page.
+// A zero AfterId reads the first page.
+type KeysetPageReq struct {
+	AfterCreatedAt time.Time
+	AfterId        primitive.ObjectID
+	Size           int64
+}
+
+// OrderPageReq is a page of orders ordered by createdAt and ticketNumber, starting after the last order of the previous page.
+// An empty AfterTicketNumber reads the first page.
+type OrderPageReq struct {
+	AfterCreatedAt    time.Time
+	AfterTicketNumber string
+	Size              int64
+}
+
+// UpsertExpiryPolicyReq sets the expiry window of an event, ticket type and payment type, empty fields match any value
+type UpsertExpiryPolicyReq struct {
+	EventId       string `json:"eventId"`
+	TicketType    string `json:"ticketType"`
+	PaymentType   string `json:"paymentType"`
+	WindowMinutes int    `json:"windowMinutes" validate:"required,min=1"`
+}
+
+// UpdateCronJobReq changes the configuration of a cron job, a field left out keeps its current value.
+// Timeout is a duration such as "5m" and empty never times out.
+// Overlap is what a tick firing during a run does, skip drops it and queue runs once more after the run.
+type UpdateCronJobReq struct {
+	Spec     *string `json:"spec" validate:"omitempty,min=1"`
+	Timezone *string `json:"timezone"`
+	Enabled  *bool   `json:"enabled"`
+	Timeout  *string `json:"timeout"`
+	Overlap  *string `json:"overlap" validate:"omitempty,oneof=skip queue"`
+}
+
+// CronRunReq is a page of the runs of a cron job, newest first
+type CronRunReq struct {
+	Job  string `query:"job" validate:"required"`
+	Page int64  `query:"page" validate:"omitempty,min=1"`
+	Size int64  `query:"size" validate:"omitempty,min=1,max=100"`
+}
+
+// ReconcileTicketDetailReq runs the ticket detail reconciliation, Fix repairs the totalRemaining found drifted
+type ReconcileTicketDetailReq struct {
+	Fix bool `query:"fix"`
+}
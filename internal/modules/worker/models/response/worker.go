@@ -1,6 +1,8 @@
 package response
 
 import (
+	"time"
+	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/pkg/constants"
 )
 
@@ -55,3 +57,24 @@ type SubDistrictResp struct {
 	CollectionData []SubDistrict
 	MetaData       constants.MetaData
 }
+
+type ReplayDeadLetterResp struct {
+	Topic    string `json:"topic"`
+	Replayed int    `json:"replayed"`
+}
+
+type CronJobResp struct {
+	Name     string     `json:"name"`
+	Spec     string     `json:"spec"`
+	Timezone string     `json:"timezone"`
+	Enabled  bool       `json:"enabled"`
+	Timeout  string     `json:"timeout"`
+	Overlap  string     `json:"overlap"`
+	NextRun  *time.Time `json:"nextRun"`
+	LastRun  *time.Time `json:"lastRun"`
+}
+
+type CronRunResp struct {
+	CollectionData []entity.WorkerJobRun
+	MetaData       constants.MetaData
+}
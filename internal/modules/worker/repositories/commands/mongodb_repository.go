@@ -6,11 +6,13 @@ import (
 	"worker-service/internal/modules/worker"
 	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
 	"worker-service/internal/pkg/databases/mongodb"
 	wrapper "worker-service/internal/pkg/helpers"
 	"worker-service/internal/pkg/log"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type commandMongodbRepository struct {
@@ -25,6 +27,11 @@ func NewCommandMongodbRepository(mongodb mongodb.Collections, log log.Logger) wo
 	}
 }
 
+// WithTransaction runs fn in one transaction, the repository methods called by fn must be given its context
+func (c commandMongodbRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return c.mongoDb.WithTransaction(ctx, fn)
+}
+
 func (c commandMongodbRepository) InsertManyTicketCollection(ctx context.Context, collection string, ticket []entity.BankTicket) <-chan wrapper.Result {
 	output := make(chan wrapper.Result)
 
@@ -86,6 +93,37 @@ func (c commandMongodbRepository) UpdateOneBankTicket(ctx context.Context, paylo
 	return output
 }
 
+// ReleaseOneBankTicket frees a bank ticket like UpdateOneBankTicket only while it is held as it was read,
+// Data is the released bank ticket or nil when it was released, reserved again or changed meanwhile
+func (c commandMongodbRepository) ReleaseOneBankTicket(ctx context.Context, payload request.ReleaseBankTicketReq) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.IncrementOne(mongodb.IncrementOne{
+			CollectionName: "bank-ticket",
+			Filter: bson.M{
+				"ticketNumber": payload.TicketNumber,
+				"isUsed":       true,
+				"userId":       payload.UserId,
+				"updatedAt":    payload.UpdatedAt,
+			},
+			Set: bson.M{
+				"isUsed":        false,
+				"userId":        "",
+				"queueId":       "",
+				"paymentStatus": "",
+				"price":         payload.Price,
+				"updatedAt":     time.Now(),
+			},
+			Result: &entity.BankTicket{},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
 func (c commandMongodbRepository) UpdateOnePayment(ctx context.Context, paymentId string) <-chan wrapper.Result {
 	output := make(chan wrapper.Result)
 
@@ -152,18 +190,367 @@ func (c commandMongodbRepository) UpdateTicketDetailByTag(ctx context.Context, p
 	return output
 }
 
-func (c commandMongodbRepository) UpdateTicketDetailById(ctx context.Context, payload request.UpdateTicketDetailByIdReq) <-chan wrapper.Result {
+// IncrementTicketDetailRemaining adds delta to totalRemaining only while it stays between 0 and totalQuota,
+// Data is the updated ticket detail or nil when the guard rejects the change
+func (c commandMongodbRepository) IncrementTicketDetailRemaining(ctx context.Context, ticketId string, delta int) <-chan wrapper.Result {
 	output := make(chan wrapper.Result)
 
 	go func() {
-		resp := <-c.mongoDb.UpdateOne(mongodb.UpdateOne{
+		remaining := bson.M{"$add": bson.A{"$totalRemaining", delta}}
+		resp := <-c.mongoDb.IncrementOne(mongodb.IncrementOne{
 			CollectionName: "ticket-detail",
 			Filter: bson.M{
-				"ticketId": payload.TicketId,
+				"ticketId": ticketId,
+				"$expr": bson.M{
+					"$and": bson.A{
+						bson.M{"$gte": bson.A{remaining, 0}},
+						bson.M{"$lte": bson.A{remaining, "$totalQuota"}},
+					},
+				},
+			},
+			Increment: bson.M{
+				"totalRemaining": delta,
+			},
+			Set: bson.M{
+				"updatedAt": time.Now(),
+			},
+			Result: &entity.TicketDetail{},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// UpdateTicketDetailRemaining sets totalRemaining to to only while it is still from,
+// Data is the updated ticket detail or nil when it changed meanwhile
+func (c commandMongodbRepository) UpdateTicketDetailRemaining(ctx context.Context, ticketId string, from, to int) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.IncrementOne(mongodb.IncrementOne{
+			CollectionName: "ticket-detail",
+			Filter: bson.M{
+				"ticketId":       ticketId,
+				"totalRemaining": from,
+			},
+			Increment: bson.M{
+				"totalRemaining": to - from,
+			},
+			Set: bson.M{
+				"updatedAt": time.Now(),
+			},
+			Result: &entity.TicketDetail{},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) CreateBankTicketIndex(ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.CreateIndex(mongodb.CreateIndex{
+			CollectionName: "bank-ticket",
+			Name:           "unique_seat",
+			Keys: bson.D{
+				{Key: "eventId", Value: 1},
+				{Key: "ticketType", Value: 1},
+				{Key: "countryCode", Value: 1},
+				{Key: "seatNumber", Value: 1},
+			},
+			Unique: true,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// CreatePaymentHistoryIndex covers the keyset scan of invalidated payments by the orphan cleanup
+func (c commandMongodbRepository) CreatePaymentHistoryIndex(ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.CreateIndex(mongodb.CreateIndex{
+			CollectionName: "payment-history",
+			Name:           "invalid_payment_scan",
+			Keys: bson.D{
+				{Key: "isValidPayment", Value: 1},
+				{Key: "createdAt", Value: 1},
+				{Key: "_id", Value: 1},
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// CreateOrderIndex covers the keyset scan of orders by the orphan cleanup
+func (c commandMongodbRepository) CreateOrderIndex(ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.CreateIndex(mongodb.CreateIndex{
+			CollectionName: "order",
+			Name:           "order_scan",
+			Keys: bson.D{
+				{Key: "createdAt", Value: 1},
+				{Key: "ticketNumber", Value: 1},
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) BulkInsertBankTicket(ctx context.Context, ticket []entity.BankTicket) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		documentsInsert := make([]interface{}, 0, len(ticket))
+		for _, v := range ticket {
+			documentsInsert = append(documentsInsert, v)
+		}
+		resp := <-c.mongoDb.BulkInsert(mongodb.BulkInsert{
+			CollectionName: "bank-ticket",
+			Documents:      documentsInsert,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) UpsertBankTicketProgress(ctx context.Context, progress entity.BankTicketProgress) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		id := progress.Id
+		progress.Id = ""
+		resp := <-c.mongoDb.UpsertOne(mongodb.UpdateOne{
+			CollectionName: "bank-ticket-progress",
+			Filter: bson.M{
+				"_id": id,
+			},
+			Document: progress,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) InsertOneWorkerJob(ctx context.Context, job entity.WorkerJob) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.InsertOne(mongodb.InsertOne{
+			CollectionName: "worker-jobs",
+			Document:       job,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) UpdateOneWorkerJob(ctx context.Context, job entity.WorkerJob) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		id := job.Id
+		job.Id = ""
+		resp := <-c.mongoDb.UpdateOne(mongodb.UpdateOne{
+			CollectionName: "worker-jobs",
+			Filter: bson.M{
+				"_id": id,
+			},
+			Document: job,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// FailAllAbandonedWorkerJob fails the queued and running jobs of instance, and those of any instance not saved since before
+func (c commandMongodbRepository) FailAllAbandonedWorkerJob(ctx context.Context, instance string, before time.Time, reason string) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		now := time.Now()
+		resp := <-c.mongoDb.UpdateMany(mongodb.UpdateOne{
+			CollectionName: "worker-jobs",
+			Filter: bson.M{
+				"state": bson.M{"$in": []string{constants.JobQueued, constants.JobRunning}},
+				"$or": []bson.M{
+					{"instance": instance},
+					{"updatedAt": bson.M{"$lt": before}},
+				},
 			},
 			Document: bson.M{
-				"totalRemaining": payload.TotalRemaining,
-				"updatedAt":      time.Now(),
+				"state":      constants.JobFailed,
+				"error":      reason,
+				"finishedAt": now,
+				"updatedAt":  now,
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) InsertOneWorkerJobRun(ctx context.Context, run entity.WorkerJobRun) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.InsertOne(mongodb.InsertOne{
+			CollectionName: "worker-job-runs",
+			Document:       run,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) UpsertExpiryPolicy(ctx context.Context, policy entity.ExpiryPolicy) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		id := policy.Id
+		policy.Id = ""
+		resp := <-c.mongoDb.UpsertOne(mongodb.UpdateOne{
+			CollectionName: "expiry-policy",
+			Filter: bson.M{
+				"_id": id,
+			},
+			Document: policy,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// DeleteOneExpiryPolicy deletes a policy, Count is the number of deleted policies
+func (c commandMongodbRepository) DeleteOneExpiryPolicy(ctx context.Context, id string) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.DeleteOne(mongodb.DeleteOne{
+			CollectionName: "expiry-policy",
+			Filter: bson.M{
+				"_id": id,
+			},
+		}, ctx)
+		if deleted, ok := resp.Data.(*mongo.DeleteResult); ok && deleted != nil {
+			resp.Count = deleted.DeletedCount
+		}
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) UpsertCronJob(ctx context.Context, job entity.CronJob) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		name := job.Name
+		job.Name = ""
+		resp := <-c.mongoDb.UpsertOne(mongodb.UpdateOne{
+			CollectionName: "cron-jobs",
+			Filter: bson.M{
+				"_id": name,
+			},
+			Document: job,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// InsertManyOutboxEvent saves the events of a change, given the transaction context of the change they are committed with it
+func (c commandMongodbRepository) InsertManyOutboxEvent(ctx context.Context, outboxEvents []entity.OutboxEvent) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		documentsInsert := make([]interface{}, 0, len(outboxEvents))
+		for _, v := range outboxEvents {
+			documentsInsert = append(documentsInsert, v)
+		}
+		resp := <-c.mongoDb.InsertMany(mongodb.InsertMany{
+			CollectionName: "outbox-events",
+			Documents:      documentsInsert,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// ClaimOutboxEvent holds a due pending outbox event until the given time and counts an attempt,
+// Data is the claimed event or nil when it is not due anymore
+func (c commandMongodbRepository) ClaimOutboxEvent(ctx context.Context, id string, until time.Time) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.IncrementOne(mongodb.IncrementOne{
+			CollectionName: "outbox-events",
+			Filter: bson.M{
+				"_id":           id,
+				"status":        constants.OutboxPending,
+				"nextAttemptAt": bson.M{"$lte": time.Now()},
+			},
+			Increment: bson.M{
+				"attempts": 1,
+			},
+			Set: bson.M{
+				"nextAttemptAt": until,
+			},
+			Result: &entity.OutboxEvent{},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// CreateOutboxEventIndex covers the relay scan of the pending outbox events which are due
+func (c commandMongodbRepository) CreateOutboxEventIndex(ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.CreateIndex(mongodb.CreateIndex{
+			CollectionName: "outbox-events",
+			Name:           "pending_scan",
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "nextAttemptAt", Value: 1},
 			},
 		}, ctx)
 		output <- resp
@@ -172,3 +559,81 @@ func (c commandMongodbRepository) UpdateTicketDetailById(ctx context.Context, pa
 
 	return output
 }
+
+// CreateOutboxEventSentIndex purges the sent outbox events once kept for retention, the pending ones have no sentAt
+func (c commandMongodbRepository) CreateOutboxEventSentIndex(ctx context.Context, retention time.Duration) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.CreateIndex(mongodb.CreateIndex{
+			CollectionName: "outbox-events",
+			Name:           "sent_ttl",
+			Keys: bson.D{
+				{Key: "sentAt", Value: 1},
+			},
+			ExpireAfter: retention,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) UpdateOneOutboxEventSent(ctx context.Context, id string) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.UpdateOne(mongodb.UpdateOne{
+			CollectionName: "outbox-events",
+			Filter: bson.M{
+				"_id": id,
+			},
+			Document: bson.M{
+				"status": constants.OutboxSent,
+				"sentAt": time.Now(),
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) UpdateOneOutboxEventRetry(ctx context.Context, id string, nextAttemptAt time.Time, reason string) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.UpdateOne(mongodb.UpdateOne{
+			CollectionName: "outbox-events",
+			Filter: bson.M{
+				"_id":    id,
+				"status": constants.OutboxPending,
+			},
+			Document: bson.M{
+				"nextAttemptAt": nextAttemptAt,
+				"lastError":     reason,
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (c commandMongodbRepository) InsertOneOrphanAudit(ctx context.Context, audit entity.OrphanAudit) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-c.mongoDb.InsertOne(mongodb.InsertOne{
+			CollectionName: "orphan-audit",
+			Document:       audit,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
@@ -3,10 +3,13 @@ package commands_test
 import (
 	"context"
 	"testing"
+	"time"
 	"worker-service/internal/modules/worker"
 	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
 	mongoRC "worker-service/internal/modules/worker/repositories/commands"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/databases/mongodb"
 	"worker-service/internal/pkg/helpers"
 	mocks "worker-service/mocks/pkg/databases/mongodb"
 	mocklog "worker-service/mocks/pkg/log"
@@ -14,6 +17,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type CommandTestSuite struct {
@@ -186,14 +191,209 @@ func (suite *CommandTestSuite) TestUpdateTicketDetailByTag() {
 	suite.mockMongodb.AssertCalled(suite.T(), "UpdateOne", mock.Anything, mock.Anything)
 }
 
-func (suite *CommandTestSuite) TestUpdateTicketDetailById() {
+func (suite *CommandTestSuite) TestIncrementTicketDetailRemaining() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("IncrementOne", mock.MatchedBy(func(payload mongodb.IncrementOne) bool {
+		return payload.CollectionName == "ticket-detail" && payload.Increment["totalRemaining"] == -1
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.IncrementTicketDetailRemaining(suite.ctx, "id", -1)
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: &entity.TicketDetail{TicketId: "id"}, Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	resp := <-result
+	assert.Equal(suite.T(), &entity.TicketDetail{TicketId: "id"}, resp.Data)
+
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestUpdateTicketDetailRemaining() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("IncrementOne", mock.MatchedBy(func(payload mongodb.IncrementOne) bool {
+		filter := payload.Filter.(bson.M)
+		return payload.CollectionName == "ticket-detail" && filter["totalRemaining"] == 6 && payload.Increment["totalRemaining"] == -1
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.UpdateTicketDetailRemaining(suite.ctx, "id", 6, 5)
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// a ticket detail changed meanwhile is not matched
+	go func() {
+		expectedResult <- helpers.Result{}
+		close(expectedResult)
+	}()
+
+	resp := <-result
+	assert.Nil(suite.T(), resp.Data)
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestReleaseOneBankTicket() {
+	updatedAt := time.Now().Add(-time.Hour)
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("IncrementOne", mock.MatchedBy(func(payload mongodb.IncrementOne) bool {
+		filter := payload.Filter.(bson.M)
+		return payload.CollectionName == "bank-ticket" && filter["isUsed"] == true && filter["userId"] == "user" &&
+			filter["updatedAt"] == updatedAt && payload.Set["isUsed"] == false && len(payload.Increment) == 0
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.ReleaseOneBankTicket(suite.ctx, request.ReleaseBankTicketReq{TicketNumber: "1", UserId: "user", UpdatedAt: updatedAt})
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// a bank ticket paid or reserved again meanwhile is not matched
+	go func() {
+		expectedResult <- helpers.Result{}
+		close(expectedResult)
+	}()
+
+	resp := <-result
+	assert.Nil(suite.T(), resp.Data)
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestCreateBankTicketIndex() {
+
+	// Mock CreateIndex
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("CreateIndex", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.CreateBankTicketIndex(suite.ctx)
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: "unique_seat", Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	<-result
+
+	// Assert CreateIndex
+	suite.mockMongodb.AssertCalled(suite.T(), "CreateIndex", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandTestSuite) TestCreatePaymentHistoryIndex() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("CreateIndex", mock.MatchedBy(func(payload mongodb.CreateIndex) bool {
+		return payload.CollectionName == "payment-history" && len(payload.Keys) == 3 && payload.Keys[0].Key == "isValidPayment"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.CreatePaymentHistoryIndex(suite.ctx)
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "invalid_payment_scan", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestCreateOrderIndex() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("CreateIndex", mock.MatchedBy(func(payload mongodb.CreateIndex) bool {
+		return payload.CollectionName == "order" && payload.Keys[0].Key == "createdAt" && payload.Keys[1].Key == "ticketNumber"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.CreateOrderIndex(suite.ctx)
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "order_scan", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestCreateOutboxEventIndex() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("CreateIndex", mock.MatchedBy(func(payload mongodb.CreateIndex) bool {
+		return payload.CollectionName == "outbox-events" && payload.Keys[0].Key == "status" && payload.Keys[1].Key == "nextAttemptAt"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.CreateOutboxEventIndex(suite.ctx)
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "pending_scan", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestCreateOutboxEventSentIndex() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("CreateIndex", mock.MatchedBy(func(payload mongodb.CreateIndex) bool {
+		return payload.CollectionName == "outbox-events" && payload.Keys[0].Key == "sentAt" && payload.ExpireAfter == time.Hour
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.CreateOutboxEventSentIndex(suite.ctx, time.Hour)
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "sent_ttl", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestBulkInsertBankTicket() {
+
+	// Mock BulkInsert
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("BulkInsert", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.BulkInsertBankTicket(suite.ctx, []entity.BankTicket{
+		{
+			TicketNumber: "1",
+		},
+	})
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: int64(1), Count: 1, Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	<-result
+
+	// Assert BulkInsert
+	suite.mockMongodb.AssertCalled(suite.T(), "BulkInsert", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandTestSuite) TestUpsertBankTicketProgress() {
 
 	// Mock UpsertOne
 	expectedResult := make(chan helpers.Result)
-	suite.mockMongodb.On("UpdateOne", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+	suite.mockMongodb.On("UpsertOne", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
 
 	// Act
-	result := suite.repository.UpdateTicketDetailById(suite.ctx, request.UpdateTicketDetailByIdReq{})
+	result := suite.repository.UpsertBankTicketProgress(suite.ctx, entity.BankTicketProgress{
+		Id:             "event:ticket:code",
+		LastSeatNumber: 10,
+	})
 	// Asset
 	assert.NotNil(suite.T(), result, "Expected a result")
 
@@ -207,5 +407,261 @@ func (suite *CommandTestSuite) TestUpdateTicketDetailById() {
 	<-result
 
 	// Assert UpsertOne
-	suite.mockMongodb.AssertCalled(suite.T(), "UpdateOne", mock.Anything, mock.Anything)
+	suite.mockMongodb.AssertCalled(suite.T(), "UpsertOne", mock.MatchedBy(func(payload mongodb.UpdateOne) bool {
+		return payload.CollectionName == "bank-ticket-progress" && payload.Document.(entity.BankTicketProgress).Id == ""
+	}), mock.Anything)
+}
+
+func (suite *CommandTestSuite) TestInsertOneWorkerJob() {
+
+	// Mock InsertOne
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("InsertOne", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.InsertOneWorkerJob(suite.ctx, entity.WorkerJob{Id: "id"})
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	<-result
+
+	// Assert InsertOne
+	suite.mockMongodb.AssertCalled(suite.T(), "InsertOne", mock.MatchedBy(func(payload mongodb.InsertOne) bool {
+		return payload.CollectionName == "worker-jobs"
+	}), mock.Anything)
+}
+
+func (suite *CommandTestSuite) TestFailAllAbandonedWorkerJob() {
+	before := time.Now().Add(-time.Hour)
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("UpdateMany", mock.MatchedBy(func(payload mongodb.UpdateOne) bool {
+		filter := payload.Filter.(bson.M)
+		or := filter["$or"].([]bson.M)
+		return payload.CollectionName == "worker-jobs" && or[0]["instance"] == "worker-1" &&
+			or[1]["updatedAt"].(bson.M)["$lt"] == before && payload.Document.(bson.M)["state"] == constants.JobFailed
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FailAllAbandonedWorkerJob(suite.ctx, "worker-1", before, "abandoned")
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: int64(2)}
+		close(expectedResult)
+	}()
+
+	resp := <-result
+	assert.Equal(suite.T(), int64(2), resp.Data)
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestUpdateOneWorkerJob() {
+
+	// Mock UpdateOne
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("UpdateOne", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.UpdateOneWorkerJob(suite.ctx, entity.WorkerJob{Id: "id", State: "running"})
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	<-result
+
+	// Assert UpdateOne
+	suite.mockMongodb.AssertCalled(suite.T(), "UpdateOne", mock.MatchedBy(func(payload mongodb.UpdateOne) bool {
+		return payload.CollectionName == "worker-jobs" && payload.Document.(entity.WorkerJob).Id == ""
+	}), mock.Anything)
+}
+
+func (suite *CommandTestSuite) TestWithTransaction() {
+	suite.mockMongodb.On("WithTransaction", suite.ctx, mock.Anything).Return(func(ctx context.Context, fn func(sessCtx context.Context) error) error {
+		return fn(ctx)
+	})
+
+	called := false
+	err := suite.repository.WithTransaction(suite.ctx, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), called)
+}
+
+func (suite *CommandTestSuite) TestInsertOneWorkerJobRun() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("InsertOne", mock.MatchedBy(func(payload mongodb.InsertOne) bool {
+		return payload.CollectionName == "worker-job-runs"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.InsertOneWorkerJobRun(suite.ctx, entity.WorkerJobRun{Id: "id"})
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	<-result
+
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestUpsertExpiryPolicy() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("UpsertOne", mock.MatchedBy(func(payload mongodb.UpdateOne) bool {
+		return payload.CollectionName == "expiry-policy" && payload.Document.(entity.ExpiryPolicy).Id == ""
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.UpsertExpiryPolicy(suite.ctx, entity.ExpiryPolicy{Id: "*:*:*", WindowMinutes: 15})
+
+	go func() {
+		expectedResult <- helpers.Result{}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestDeleteOneExpiryPolicy() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("DeleteOne", mock.MatchedBy(func(payload mongodb.DeleteOne) bool {
+		return payload.CollectionName == "expiry-policy"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.DeleteOneExpiryPolicy(suite.ctx, "*:*:*")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &mongo.DeleteResult{DeletedCount: 1}}
+		close(expectedResult)
+	}()
+
+	resp := <-result
+	assert.Equal(suite.T(), int64(1), resp.Count)
+}
+
+func (suite *CommandTestSuite) TestUpsertCronJob() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("UpsertOne", mock.MatchedBy(func(payload mongodb.UpdateOne) bool {
+		return payload.CollectionName == "cron-jobs" && payload.Document.(entity.CronJob).Name == ""
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.UpsertCronJob(suite.ctx, entity.CronJob{Name: "expiry-payment", Spec: "*/5 * * * *"})
+
+	go func() {
+		expectedResult <- helpers.Result{}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestInsertManyOutboxEvent() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("InsertMany", mock.MatchedBy(func(payload mongodb.InsertMany) bool {
+		return payload.CollectionName == "outbox-events" && len(payload.Documents) == 2
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.InsertManyOutboxEvent(suite.ctx, []entity.OutboxEvent{{Id: "a"}, {Id: "b"}})
+
+	go func() {
+		expectedResult <- helpers.Result{}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestClaimOutboxEvent() {
+	until := time.Now().Add(time.Minute)
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("IncrementOne", mock.MatchedBy(func(payload mongodb.IncrementOne) bool {
+		filter := payload.Filter.(bson.M)
+		return payload.CollectionName == "outbox-events" && filter["_id"] == "event" && filter["status"] == constants.OutboxPending &&
+			payload.Increment["attempts"] == 1 && payload.Set["nextAttemptAt"] == until
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.ClaimOutboxEvent(suite.ctx, "event", until)
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &entity.OutboxEvent{Id: "event"}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestUpdateOneOutboxEventSent() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("UpdateOne", mock.MatchedBy(func(payload mongodb.UpdateOne) bool {
+		return payload.CollectionName == "outbox-events" && payload.Document.(bson.M)["status"] == constants.OutboxSent
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.UpdateOneOutboxEventSent(suite.ctx, "event")
+
+	go func() {
+		expectedResult <- helpers.Result{}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestUpdateOneOutboxEventRetry() {
+	next := time.Now().Add(time.Second)
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("UpdateOne", mock.MatchedBy(func(payload mongodb.UpdateOne) bool {
+		document := payload.Document.(bson.M)
+		return payload.CollectionName == "outbox-events" && document["nextAttemptAt"] == next && document["lastError"] == "broker down"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.UpdateOneOutboxEventRetry(suite.ctx, "event", next, "broker down")
+
+	go func() {
+		expectedResult <- helpers.Result{}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *CommandTestSuite) TestInsertOneOrphanAudit() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("InsertOne", mock.MatchedBy(func(payload mongodb.InsertOne) bool {
+		return payload.CollectionName == "orphan-audit"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.InsertOneOrphanAudit(suite.ctx, entity.OrphanAudit{Id: "id", RunId: "run"})
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
 }
@@ -2,10 +2,12 @@ package queries
 
 import (
 	"context"
+	"sort"
 	"time"
 	"worker-service/internal/modules/worker"
 	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
 	"worker-service/internal/pkg/databases/mongodb"
 	wrapper "worker-service/internal/pkg/helpers"
 	"worker-service/internal/pkg/log"
@@ -14,6 +16,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	// expiryPolicyLimit bounds how many expiry policies are read at once
+	expiryPolicyLimit = 1000
+	// cronJobLimit bounds how many cron job configurations are read at once
+	cronJobLimit = 100
+)
+
 type queryMongodbRepository struct {
 	mongoDb mongodb.Collections
 	logger  log.Logger
@@ -111,30 +120,31 @@ func (q queryMongodbRepository) FindOneLastTicket(ctx context.Context, countryCo
 	return output
 }
 
-func (q queryMongodbRepository) FindAllExpirePayment(ctx context.Context) <-chan wrapper.Result {
+func (q queryMongodbRepository) FindAllExpirePayment(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan wrapper.Result {
 	var payment []entity.PaymentHistory
 	output := make(chan wrapper.Result)
 
-	count := 15
-	then := time.Now().Add(time.Duration(-count) * time.Minute)
-
 	go func() {
+		filter := expiredFilter(time.Now(), policies, paymentExpiryFields)
+		filter["payment.transactionStatus"] = "pending"
+		filter["isValidPayment"] = true
+		if !page.AfterId.IsZero() {
+			filter["$or"] = keysetAfter(page)
+		}
+
 		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
 			Result:         &payment,
 			CollectionName: "payment-history",
-			Filter: bson.M{
-				"payment.transactionStatus": "pending",
-				"isValidPayment":            true,
-				"expiryTime": bson.M{
-					"$lte": primitive.NewDateTimeFromTime(then),
-				},
-			},
+			Filter:         filter,
 			Sort: &mongodb.Sort{
 				FieldName: "createdAt",
 				By:        mongodb.SortAscending,
 			},
+			ThenSort: []mongodb.Sort{
+				{FieldName: "_id", By: mongodb.SortAscending},
+			},
 			Page: 1,
-			Size: 100,
+			Size: page.Size,
 		}, ctx)
 		output <- resp
 		close(output)
@@ -218,29 +228,63 @@ func (q queryMongodbRepository) FindTotalAvalailableTicket(ctx context.Context,
 	return output
 }
 
-func (q queryMongodbRepository) FindAllExpireBankTicket(ctx context.Context) <-chan wrapper.Result {
-	var bankTicket []entity.BankTicket
+// AggregateBankTicketByTicketId counts the bank tickets of each ticket, and those of them which are not used
+func (q queryMongodbRepository) AggregateBankTicketByTicketId(ctx context.Context, ticketIds []string) <-chan wrapper.Result {
+	var bankTicket []entity.AggregateBankTicket
 	output := make(chan wrapper.Result)
 
-	count := 15
-	then := time.Now().Add(time.Duration(-count) * time.Minute)
-
 	go func() {
-		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+		resp := <-q.mongoDb.Aggregate(mongodb.Aggregate{
 			Result:         &bankTicket,
 			CollectionName: "bank-ticket",
-			Filter: bson.M{
-				"paymentStatus": "pending",
-				"updatedAt": bson.M{
-					"$lte": primitive.NewDateTimeFromTime(then),
+			Filter: []bson.M{
+				{
+					"$match": bson.M{
+						"ticketId": bson.M{"$in": ticketIds},
+					},
+				},
+				{
+					"$group": bson.M{
+						"_id":   "$ticketId",
+						"total": bson.M{"$sum": 1},
+						"unused": bson.M{"$sum": bson.M{
+							"$cond": bson.A{bson.M{"$eq": bson.A{"$isUsed", false}}, 1, 0},
+						}},
+					},
 				},
 			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (q queryMongodbRepository) FindAllExpireBankTicket(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan wrapper.Result {
+	var bankTicket []entity.BankTicket
+	output := make(chan wrapper.Result)
+
+	go func() {
+		filter := expiredFilter(time.Now(), policies, bankTicketExpiryFields)
+		filter["paymentStatus"] = "pending"
+		if !page.AfterId.IsZero() {
+			filter["$or"] = keysetAfter(page)
+		}
+
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &bankTicket,
+			CollectionName: "bank-ticket",
+			Filter:         filter,
 			Sort: &mongodb.Sort{
 				FieldName: "createdAt",
 				By:        mongodb.SortAscending,
 			},
+			ThenSort: []mongodb.Sort{
+				{FieldName: "_id", By: mongodb.SortAscending},
+			},
 			Page: 1,
-			Size: 100,
+			Size: page.Size,
 		}, ctx)
 		output <- resp
 		close(output)
@@ -268,3 +312,454 @@ func (q queryMongodbRepository) FindPaymentByTicketNumber(ctx context.Context, t
 
 	return output
 }
+
+func (q queryMongodbRepository) FindOneBankTicketProgress(ctx context.Context, id string) <-chan wrapper.Result {
+	var progress entity.BankTicketProgress
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-q.mongoDb.FindOne(mongodb.FindOne{
+			Result:         &progress,
+			CollectionName: "bank-ticket-progress",
+			Filter: bson.M{
+				"_id": id,
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (q queryMongodbRepository) FindOneWorkerJob(ctx context.Context, id string) <-chan wrapper.Result {
+	var job entity.WorkerJob
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-q.mongoDb.FindOne(mongodb.FindOne{
+			Result:         &job,
+			CollectionName: "worker-jobs",
+			Filter: bson.M{
+				"_id": id,
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// FindAllTicketDetail reads a page of every ticket detail
+func (q queryMongodbRepository) FindAllTicketDetail(ctx context.Context, page request.KeysetPageReq) <-chan wrapper.Result {
+	var ticketDetail []entity.TicketDetail
+	output := make(chan wrapper.Result)
+
+	go func() {
+		filter := bson.M{}
+		if !page.AfterId.IsZero() {
+			filter["$or"] = keysetAfter(page)
+		}
+
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &ticketDetail,
+			CollectionName: "ticket-detail",
+			Filter:         filter,
+			Sort: &mongodb.Sort{
+				FieldName: "createdAt",
+				By:        mongodb.SortAscending,
+			},
+			ThenSort: []mongodb.Sort{
+				{FieldName: "_id", By: mongodb.SortAscending},
+			},
+			Page: 1,
+			Size: page.Size,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// FindAllUnusedBankTicket reads a page of the bank tickets of a ticket which are not used
+func (q queryMongodbRepository) FindAllUnusedBankTicket(ctx context.Context, ticketId string, page request.KeysetPageReq) <-chan wrapper.Result {
+	var bankTicket []entity.BankTicket
+	output := make(chan wrapper.Result)
+
+	go func() {
+		filter := bson.M{
+			"ticketId": ticketId,
+			"isUsed":   false,
+		}
+		if !page.AfterId.IsZero() {
+			filter["$or"] = keysetAfter(page)
+		}
+
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &bankTicket,
+			CollectionName: "bank-ticket",
+			Filter:         filter,
+			Sort: &mongodb.Sort{
+				FieldName: "createdAt",
+				By:        mongodb.SortAscending,
+			},
+			ThenSort: []mongodb.Sort{
+				{FieldName: "_id", By: mongodb.SortAscending},
+			},
+			Page: 1,
+			Size: page.Size,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// FindAllOrder reads a page of the orders created before a time, ordered by _id which starts with its creation time
+func (q queryMongodbRepository) FindAllOrder(ctx context.Context, page request.OrderPageReq, before time.Time) <-chan wrapper.Result {
+	var order []entity.Order
+	output := make(chan wrapper.Result)
+
+	go func() {
+		filter := bson.M{
+			"createdAt": bson.M{"$lt": before},
+		}
+		if page.AfterTicketNumber != "" {
+			createdAt := primitive.NewDateTimeFromTime(page.AfterCreatedAt)
+			filter["$or"] = bson.A{
+				bson.M{"createdAt": bson.M{"$gt": createdAt}},
+				bson.M{"createdAt": createdAt, "ticketNumber": bson.M{"$gt": page.AfterTicketNumber}},
+			}
+		}
+
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &order,
+			CollectionName: "order",
+			Filter:         filter,
+			Sort: &mongodb.Sort{
+				FieldName: "createdAt",
+				By:        mongodb.SortAscending,
+			},
+			ThenSort: []mongodb.Sort{
+				{FieldName: "ticketNumber", By: mongodb.SortAscending},
+			},
+			Page: 1,
+			Size: page.Size,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (q queryMongodbRepository) FindOrderByTicketNumber(ctx context.Context, ticketNumber string) <-chan wrapper.Result {
+	var order entity.Order
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-q.mongoDb.FindOne(mongodb.FindOne{
+			Result:         &order,
+			CollectionName: "order",
+			Filter: bson.M{
+				"ticketNumber": ticketNumber,
+			},
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// FindAllUsedBankTicket reads a page of the used bank tickets last changed before a time,
+// a ticket waiting for its payment is left to the expiry of bank tickets
+func (q queryMongodbRepository) FindAllUsedBankTicket(ctx context.Context, page request.KeysetPageReq, before time.Time) <-chan wrapper.Result {
+	var bankTicket []entity.BankTicket
+	output := make(chan wrapper.Result)
+
+	go func() {
+		filter := bson.M{
+			"isUsed":        true,
+			"paymentStatus": bson.M{"$ne": "pending"},
+			"updatedAt":     bson.M{"$lt": before},
+		}
+		if !page.AfterId.IsZero() {
+			filter["$or"] = keysetAfter(page)
+		}
+
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &bankTicket,
+			CollectionName: "bank-ticket",
+			Filter:         filter,
+			Sort: &mongodb.Sort{
+				FieldName: "createdAt",
+				By:        mongodb.SortAscending,
+			},
+			ThenSort: []mongodb.Sort{
+				{FieldName: "_id", By: mongodb.SortAscending},
+			},
+			Page: 1,
+			Size: page.Size,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// FindAllInvalidPayment reads a page of the invalidated payments created before a time
+func (q queryMongodbRepository) FindAllInvalidPayment(ctx context.Context, page request.KeysetPageReq, from, before time.Time) <-chan wrapper.Result {
+	var payment []entity.PaymentHistory
+	output := make(chan wrapper.Result)
+
+	go func() {
+		filter := bson.M{
+			"isValidPayment": false,
+			"createdAt":      bson.M{"$gte": from, "$lt": before},
+		}
+		if !page.AfterId.IsZero() {
+			filter["$or"] = keysetAfter(page)
+		}
+
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &payment,
+			CollectionName: "payment-history",
+			Filter:         filter,
+			Sort: &mongodb.Sort{
+				FieldName: "createdAt",
+				By:        mongodb.SortAscending,
+			},
+			ThenSort: []mongodb.Sort{
+				{FieldName: "_id", By: mongodb.SortAscending},
+			},
+			Page: 1,
+			Size: page.Size,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// keysetAfter matches the documents ordered after the last document of the previous page
+func keysetAfter(page request.KeysetPageReq) bson.A {
+	createdAt := primitive.NewDateTimeFromTime(page.AfterCreatedAt)
+	return bson.A{
+		bson.M{"createdAt": bson.M{"$gt": createdAt}},
+		bson.M{"createdAt": createdAt, "_id": bson.M{"$gt": page.AfterId}},
+	}
+}
+
+func (q queryMongodbRepository) FindAllExpiryPolicy(ctx context.Context) <-chan wrapper.Result {
+	var policies []entity.ExpiryPolicy
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &policies,
+			CollectionName: "expiry-policy",
+			Filter:         bson.M{},
+			Sort: &mongodb.Sort{
+				FieldName: "_id",
+				By:        mongodb.SortAscending,
+			},
+			Page: 1,
+			Size: expiryPolicyLimit,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// expiryFields are the document fields an expiry policy is matched on, a document without paymentType ignores payment type policies
+type expiryFields struct {
+	eventId     string
+	ticketType  string
+	paymentType string
+	time        string
+}
+
+var (
+	paymentExpiryFields = expiryFields{
+		eventId:     "ticket.eventId",
+		ticketType:  "ticket.ticketType",
+		paymentType: "payment.paymentType",
+		time:        "expiryTime",
+	}
+	bankTicketExpiryFields = expiryFields{
+		eventId:    "eventId",
+		ticketType: "ticketType",
+		time:       "updatedAt",
+	}
+)
+
+// expiredFilter matches documents held longer than the window of their most specific expiry policy.
+// The plain time bound uses the shortest window so the index still narrows the scan, $expr then applies each document's own window.
+func expiredFilter(now time.Time, policies []entity.ExpiryPolicy, fields expiryFields) bson.M {
+	defaultWindow := constants.DefaultExpiryWindowMinutes
+	specific := make([]entity.ExpiryPolicy, 0, len(policies))
+	for _, p := range policies {
+		if p.PaymentType != "" && fields.paymentType == "" {
+			continue
+		}
+		if p.EventId == "" && p.TicketType == "" && p.PaymentType == "" {
+			defaultWindow = p.WindowMinutes
+			continue
+		}
+		specific = append(specific, p)
+	}
+
+	shortest := defaultWindow
+	for _, p := range specific {
+		if p.WindowMinutes < shortest {
+			shortest = p.WindowMinutes
+		}
+	}
+	filter := bson.M{
+		fields.time: bson.M{
+			"$lte": primitive.NewDateTimeFromTime(now.Add(-time.Duration(shortest) * time.Minute)),
+		},
+	}
+	if len(specific) == 0 {
+		return filter
+	}
+
+	// $switch takes the first matching branch, so the most specific policies go first
+	sort.SliceStable(specific, func(i, j int) bool {
+		return policyRank(specific[i]) > policyRank(specific[j])
+	})
+	branches := make(bson.A, 0, len(specific))
+	for _, p := range specific {
+		conditions := bson.A{}
+		if p.EventId != "" {
+			conditions = append(conditions, bson.M{"$eq": bson.A{"$" + fields.eventId, p.EventId}})
+		}
+		if p.TicketType != "" {
+			conditions = append(conditions, bson.M{"$eq": bson.A{"$" + fields.ticketType, p.TicketType}})
+		}
+		if p.PaymentType != "" {
+			conditions = append(conditions, bson.M{"$eq": bson.A{"$" + fields.paymentType, p.PaymentType}})
+		}
+		branches = append(branches, bson.M{
+			"case": bson.M{"$and": conditions},
+			"then": windowMillis(p.WindowMinutes),
+		})
+	}
+	filter["$expr"] = bson.M{
+		"$lte": bson.A{
+			"$" + fields.time,
+			bson.M{"$subtract": bson.A{
+				primitive.NewDateTimeFromTime(now),
+				bson.M{"$switch": bson.M{
+					"branches": branches,
+					"default":  windowMillis(defaultWindow),
+				}},
+			}},
+		},
+	}
+	return filter
+}
+
+// policyRank orders policies by specificity, an event beats a ticket type which beats a payment type
+func policyRank(p entity.ExpiryPolicy) int {
+	rank := 0
+	if p.EventId != "" {
+		rank += 4
+	}
+	if p.TicketType != "" {
+		rank += 2
+	}
+	if p.PaymentType != "" {
+		rank++
+	}
+	return rank
+}
+
+func windowMillis(minutes int) int64 {
+	return (time.Duration(minutes) * time.Minute).Milliseconds()
+}
+
+func (q queryMongodbRepository) FindAllCronJob(ctx context.Context) <-chan wrapper.Result {
+	var jobs []entity.CronJob
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &jobs,
+			CollectionName: "cron-jobs",
+			Filter:         bson.M{},
+			Sort: &mongodb.Sort{
+				FieldName: "_id",
+				By:        mongodb.SortAscending,
+			},
+			Page: 1,
+			Size: cronJobLimit,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+func (q queryMongodbRepository) FindAllWorkerJobRun(ctx context.Context, payload request.CronRunReq) <-chan wrapper.Result {
+	var runs []entity.WorkerJobRun
+	var countData int64
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &runs,
+			CountData:      &countData,
+			CollectionName: "worker-job-runs",
+			Filter: bson.M{
+				"job": payload.Job,
+			},
+			Sort: &mongodb.Sort{
+				FieldName: "startedAt",
+				By:        mongodb.SortDescending,
+			},
+			Page: payload.Page,
+			Size: payload.Size,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
+
+// FindAllPendingOutboxEvent returns the oldest due pending outbox events, a replica may lag so they are claimed on the master
+func (q queryMongodbRepository) FindAllPendingOutboxEvent(ctx context.Context, limit int64) <-chan wrapper.Result {
+	var outboxEvents []entity.OutboxEvent
+	output := make(chan wrapper.Result)
+
+	go func() {
+		resp := <-q.mongoDb.FindAllData(mongodb.FindAllData{
+			Result:         &outboxEvents,
+			CollectionName: "outbox-events",
+			Filter: bson.M{
+				"status":        constants.OutboxPending,
+				"nextAttemptAt": bson.M{"$lte": time.Now()},
+			},
+			Sort: &mongodb.Sort{
+				FieldName: "nextAttemptAt",
+				By:        mongodb.SortAscending,
+			},
+			Page: 1,
+			Size: limit,
+		}, ctx)
+		output <- resp
+		close(output)
+	}()
+
+	return output
+}
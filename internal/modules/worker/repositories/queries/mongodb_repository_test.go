@@ -3,10 +3,12 @@ package queries_test
 import (
 	"context"
 	"testing"
+	"time"
 	"worker-service/internal/modules/worker"
 	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
 	mongoRQ "worker-service/internal/modules/worker/repositories/queries"
+	"worker-service/internal/pkg/constants"
 	"worker-service/internal/pkg/databases/mongodb"
 	"worker-service/internal/pkg/helpers"
 	mocks "worker-service/mocks/pkg/databases/mongodb"
@@ -16,6 +18,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type QueryTestSuite struct {
@@ -179,7 +182,7 @@ func (suite *QueryTestSuite) TestFindAllExpirePayment() {
 	suite.mockMongodb.On("FindAllData", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
 
 	// Act
-	result := suite.repository.FindAllExpirePayment(suite.ctx)
+	result := suite.repository.FindAllExpirePayment(suite.ctx, request.KeysetPageReq{Size: 100}, nil)
 	// Asset
 	assert.NotNil(suite.T(), result, "Expected a result")
 
@@ -289,7 +292,7 @@ func (suite *QueryTestSuite) TestFindAllExpireBankTicket() {
 	suite.mockMongodb.On("FindAllData", mock.Anything, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
 
 	// Act
-	result := suite.repository.FindAllExpireBankTicket(suite.ctx)
+	result := suite.repository.FindAllExpireBankTicket(suite.ctx, request.KeysetPageReq{Size: 100}, nil)
 	// Asset
 	assert.NotNil(suite.T(), result, "Expected a result")
 
@@ -337,3 +340,358 @@ func (suite *QueryTestSuite) TestFindPaymentByTicketNumber() {
 	// Assert FindOne
 	suite.mockMongodb.AssertCalled(suite.T(), "FindOne", req, mock.Anything)
 }
+
+func (suite *QueryTestSuite) TestFindOneBankTicketProgress() {
+	req := mongodb.FindOne{
+		Result:         &entity.BankTicketProgress{},
+		CollectionName: "bank-ticket-progress",
+		Filter: bson.M{
+			"_id": "event:ticket:code",
+		},
+	}
+	// Mock FindOne
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindOne", req, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.FindOneBankTicketProgress(suite.ctx, "event:ticket:code")
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	<-result
+
+	// Assert FindOne
+	suite.mockMongodb.AssertCalled(suite.T(), "FindOne", req, mock.Anything)
+}
+
+func (suite *QueryTestSuite) TestFindOneWorkerJob() {
+	req := mongodb.FindOne{
+		Result:         &entity.WorkerJob{},
+		CollectionName: "worker-jobs",
+		Filter: bson.M{
+			"_id": "id",
+		},
+	}
+	// Mock FindOne
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindOne", req, mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	// Act
+	result := suite.repository.FindOneWorkerJob(suite.ctx, "id")
+	// Asset
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	// Simulate receiving a result from the channel
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	// Wait for the goroutine to complete
+	<-result
+
+	// Assert FindOne
+	suite.mockMongodb.AssertCalled(suite.T(), "FindOne", req, mock.Anything)
+}
+
+func (suite *QueryTestSuite) TestFindAllExpirePaymentAfter() {
+	afterId := primitive.NewObjectID()
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		filter := payload.Filter.(bson.M)
+		after, ok := filter["$or"].(bson.A)
+		return ok && len(after) == 2 && payload.Size == 50 && payload.ThenSort[0].FieldName == "_id"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllExpirePayment(suite.ctx, request.KeysetPageReq{
+		AfterCreatedAt: time.Now(),
+		AfterId:        afterId,
+		Size:           50,
+	}, nil)
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &[]entity.PaymentHistory{}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllExpiryPolicy() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		return payload.CollectionName == "expiry-policy"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllExpiryPolicy(suite.ctx)
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &[]entity.ExpiryPolicy{}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllExpirePaymentPolicies() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		filter := payload.Filter.(bson.M)
+		expr, ok := filter["$expr"].(bson.M)
+		if !ok {
+			return false
+		}
+		switchExpr := expr["$lte"].(bson.A)[1].(bson.M)["$subtract"].(bson.A)[1].(bson.M)["$switch"].(bson.M)
+		branches := switchExpr["branches"].(bson.A)
+		// the event policy is the most specific, the global default is not a branch
+		return len(branches) == 2 &&
+			branches[0].(bson.M)["then"] == int64(60*60*1000) &&
+			branches[1].(bson.M)["then"] == int64(5*60*1000) &&
+			switchExpr["default"] == int64(30*60*1000)
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllExpirePayment(suite.ctx, request.KeysetPageReq{Size: 100}, []entity.ExpiryPolicy{
+		{WindowMinutes: 30},
+		{PaymentType: "ewallet", WindowMinutes: 5},
+		{EventId: "event", WindowMinutes: 60},
+	})
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &[]entity.PaymentHistory{}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllExpireBankTicketSkipsPaymentPolicies() {
+	now := time.Now()
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		filter := payload.Filter.(bson.M)
+		_, hasExpr := filter["$expr"]
+		cutoff := filter["updatedAt"].(bson.M)["$lte"].(primitive.DateTime).Time()
+		// only the global default window applies
+		return !hasExpr && cutoff.Before(now.Add(-29*time.Minute)) && cutoff.After(now.Add(-31*time.Minute))
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllExpireBankTicket(suite.ctx, request.KeysetPageReq{Size: 100}, []entity.ExpiryPolicy{
+		{WindowMinutes: 30},
+		{PaymentType: "ewallet", WindowMinutes: 5},
+	})
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &[]entity.BankTicket{}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllCronJob() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		return payload.CollectionName == "cron-jobs"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllCronJob(suite.ctx)
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &[]entity.CronJob{}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllWorkerJobRun() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		return payload.CollectionName == "worker-job-runs" && payload.Filter.(bson.M)["job"] == "expiry-payment" &&
+			payload.Sort.By == mongodb.SortDescending && payload.CountData != nil
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllWorkerJobRun(suite.ctx, request.CronRunReq{Job: "expiry-payment", Page: 1, Size: 20})
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &[]entity.WorkerJobRun{}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllPendingOutboxEvent() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		return payload.CollectionName == "outbox-events" && payload.Filter.(bson.M)["status"] == constants.OutboxPending &&
+			payload.Sort.FieldName == "nextAttemptAt" && payload.Sort.By == mongodb.SortAscending && payload.Size == 100
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllPendingOutboxEvent(suite.ctx, 100)
+
+	go func() {
+		expectedResult <- helpers.Result{Data: &[]entity.OutboxEvent{}}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllTicketDetail() {
+	after := primitive.NewObjectID()
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		_, hasKeyset := payload.Filter.(bson.M)["$or"]
+		return payload.CollectionName == "ticket-detail" && payload.Size == 50 && hasKeyset
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllTicketDetail(suite.ctx, request.KeysetPageReq{AfterId: after, Size: 50})
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllUnusedBankTicket() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		filter := payload.Filter.(bson.M)
+		_, hasKeyset := filter["$or"]
+		return payload.CollectionName == "bank-ticket" && filter["ticketId"] == "id" && filter["isUsed"] == false && !hasKeyset
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllUnusedBankTicket(suite.ctx, "id", request.KeysetPageReq{Size: 50})
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestAggregateBankTicketByTicketId() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("Aggregate", mock.MatchedBy(func(payload mongodb.Aggregate) bool {
+		match := payload.Filter.([]bson.M)[0]["$match"].(bson.M)
+		return payload.CollectionName == "bank-ticket" && assert.ObjectsAreEqual(bson.M{"$in": []string{"a", "b"}}, match["ticketId"])
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.AggregateBankTicketByTicketId(suite.ctx, []string{"a", "b"})
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllOrder() {
+	before := time.Now().Add(-time.Hour)
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		filter := payload.Filter.(bson.M)
+		keyset := filter["$or"].(bson.A)
+		return payload.CollectionName == "order" && assert.ObjectsAreEqual(bson.M{"$lt": before}, filter["createdAt"]) &&
+			assert.ObjectsAreEqual(bson.M{"$gt": "number"}, keyset[1].(bson.M)["ticketNumber"]) && payload.ThenSort[0].FieldName == "ticketNumber"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	page := request.OrderPageReq{AfterCreatedAt: before.Add(-time.Hour), AfterTicketNumber: "number", Size: 50}
+	result := suite.repository.FindAllOrder(suite.ctx, page, before)
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindOrderByTicketNumber() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindOne", mock.MatchedBy(func(payload mongodb.FindOne) bool {
+		return payload.CollectionName == "order" && payload.Filter.(bson.M)["ticketNumber"] == "number"
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindOrderByTicketNumber(suite.ctx, "number")
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllUsedBankTicket() {
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		filter := payload.Filter.(bson.M)
+		return payload.CollectionName == "bank-ticket" && filter["isUsed"] == true &&
+			assert.ObjectsAreEqual(bson.M{"$ne": "pending"}, filter["paymentStatus"])
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	result := suite.repository.FindAllUsedBankTicket(suite.ctx, request.KeysetPageReq{Size: 50}, time.Now())
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
+
+func (suite *QueryTestSuite) TestFindAllInvalidPayment() {
+	before := time.Now()
+	from := before.Add(-time.Hour)
+	expectedResult := make(chan helpers.Result)
+	suite.mockMongodb.On("FindAllData", mock.MatchedBy(func(payload mongodb.FindAllData) bool {
+		filter := payload.Filter.(bson.M)
+		_, hasKeyset := filter["$or"]
+		return payload.CollectionName == "payment-history" && filter["isValidPayment"] == false && hasKeyset &&
+			assert.ObjectsAreEqual(bson.M{"$gte": from, "$lt": before}, filter["createdAt"])
+	}), mock.Anything).Return((<-chan helpers.Result)(expectedResult))
+
+	page := request.KeysetPageReq{AfterCreatedAt: from, AfterId: primitive.NewObjectID(), Size: 50}
+	result := suite.repository.FindAllInvalidPayment(suite.ctx, page, from, before)
+	assert.NotNil(suite.T(), result, "Expected a result")
+
+	go func() {
+		expectedResult <- helpers.Result{Data: "result not nil", Error: nil}
+		close(expectedResult)
+	}()
+
+	<-result
+	suite.mockMongodb.AssertExpectations(suite.T())
+}
@@ -2,34 +2,105 @@ package usecases
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
 	"time"
+	"worker-service/configs"
 	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/allocations"
 	"worker-service/internal/modules/worker/models/dto"
 	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
 	"worker-service/internal/pkg/constants"
 	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
 	"worker-service/internal/pkg/log"
+	"worker-service/internal/pkg/redis"
 
+	goRedis "github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"go.elastic.co/apm"
 )
 
+const (
+	bankTicketLockTTL          = 5 * time.Minute
+	idempotencyTTL             = 24 * time.Hour
+	defaultBankTicketChunkSize = 1000
+	defaultExpiryBatchSize     = 100
+	defaultExpiryTimeBudget    = 4 * time.Minute
+)
+
+// errBankTicketCompleted is returned when every seat of a ticket detail is already generated
+var errBankTicketCompleted = errors.BadRequest("create bank ticket already completed")
+
 type commandUsecase struct {
 	workerRepositoryQuery   worker.MongodbRepositoryQuery
 	workerRepositoryCommand worker.MongodbRepositoryCommand
+	redisClient             redis.Collections
 	logger                  log.Logger
+	expiryPolicies          *expiryPolicyCache
+	// workerRepositoryPrimary reads from the primary, for the reads a write is decided on
+	workerRepositoryPrimary worker.MongodbRepositoryQuery
+	// instance names this replica on the jobs it runs
+	instance string
+
+	// background jobs run on jobCtx, which is canceled on Close
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	jobs       *sync.WaitGroup
 }
 
-func NewCommandUsecase(wrq worker.MongodbRepositoryQuery, wrc worker.MongodbRepositoryCommand, log log.Logger) worker.UsecaseCommand {
+func NewCommandUsecase(wrq, wrp worker.MongodbRepositoryQuery, wrc worker.MongodbRepositoryCommand, rc redis.Collections, log log.Logger) worker.UsecaseCommand {
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
 	return commandUsecase{
 		workerRepositoryQuery:   wrq,
+		workerRepositoryPrimary: wrp,
 		workerRepositoryCommand: wrc,
+		redisClient:             rc,
 		logger:                  log,
+		expiryPolicies:          new(expiryPolicyCache),
+		instance:                helpers.InstanceId(),
+		jobCtx:                  jobCtx,
+		cancelJobs:              cancelJobs,
+		jobs:                    new(sync.WaitGroup),
+	}
+}
+
+// findOutcome returns the outcome stored for an idempotency key
+func (c commandUsecase) findOutcome(ctx context.Context, key string) (*dto.IdempotencyOutcome, bool) {
+	value, err := c.redisClient.Get(ctx, key).Result()
+	if err != nil {
+		if err != goRedis.Nil {
+			c.logger.Error(ctx, "Failed get idempotency outcome", err.Error())
+		}
+		return nil, false
+	}
+
+	var outcome dto.IdempotencyOutcome
+	if err := json.Unmarshal([]byte(value), &outcome); err != nil {
+		c.logger.Error(ctx, "Failed parse idempotency outcome", err.Error())
+		return nil, false
+	}
+	return &outcome, true
+}
+
+// saveOutcome stores a final outcome, server errors and conflicts are not stored so the request can be retried
+func (c commandUsecase) saveOutcome(ctx context.Context, key string, resp *string, err error) {
+	outcome := dto.NewIdempotencyOutcome(resp, err)
+	if outcome.Code >= http.StatusInternalServerError || outcome.Code == http.StatusConflict {
+		return
+	}
+
+	value, _ := json.Marshal(outcome)
+	if err := c.redisClient.Set(ctx, key, value, idempotencyTTL).Err(); err != nil {
+		c.logger.Error(ctx, "Failed save idempotency outcome", err.Error())
 	}
 }
 
+// CreateBankTicket generates the bank tickets of a ticket detail and waits for the generation, it is tracked as a kafka job
 func (c commandUsecase) CreateBankTicket(origCtx context.Context, payload request.CreateTicketReq) (*string, error) {
 	domain := "workerUsecase-CreateBankTicket"
 	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
@@ -38,6 +109,116 @@ func (c commandUsecase) CreateBankTicket(origCtx context.Context, payload reques
 	})
 	defer span.End()
 
+	job := c.newJob(constants.JobTypeCreateBankTicket, constants.JobSourceKafka, map[string]string{
+		"ticketId":       payload.TicketId,
+		"eventId":        payload.EventId,
+		"idempotencyKey": payload.IdempotencyKey,
+	})
+	// the message is only committed once its job is saved, a job which cannot be saved is retried
+	if resp := <-c.workerRepositoryCommand.InsertOneWorkerJob(ctx, job); resp.Error != nil {
+		c.logger.Error(ctx, "Failed insert worker job", resp.Error.Error())
+		return nil, resp.Error
+	}
+
+	// a message redelivered after its generation completed is done, it is not sent to retry or to the dead letter topic
+	generate := func(ctx context.Context, report progressFunc) (*string, error) {
+		resp, err := c.createBankTicket(ctx, payload, report)
+		if err == errBankTicketCompleted {
+			rs := "Bank ticket already completed"
+			return &rs, nil
+		}
+		return resp, err
+	}
+
+	return c.runJob(ctx, &job, func(ctx context.Context, report progressFunc) (*string, error) {
+		if payload.IdempotencyKey == "" {
+			return generate(ctx, report)
+		}
+
+		idempotencyKey := fmt.Sprintf("%s:%s:%s", constants.RedisKeyIdempotency, domain, payload.IdempotencyKey)
+		if outcome, ok := c.findOutcome(ctx, idempotencyKey); ok {
+			c.logger.Info(ctx, "Return stored outcome", idempotencyKey)
+			return outcome.Response()
+		}
+
+		resp, err := generate(ctx, report)
+		c.saveOutcome(ctx, idempotencyKey, resp, err)
+		return resp, err
+	})
+}
+
+// EnqueueBankTicketJob saves a queued bank ticket job and generates the bank tickets in background,
+// the same job is returned for every request sent with the same idempotency key once it is saved
+func (c commandUsecase) EnqueueBankTicketJob(origCtx context.Context, payload request.CreateTicketReq) (*entity.WorkerJob, error) {
+	domain := "workerUsecase-EnqueueBankTicketJob"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	job := c.newJob(constants.JobTypeCreateBankTicket, constants.JobSourceHttp, map[string]string{
+		"ticketId":       payload.TicketId,
+		"eventId":        payload.EventId,
+		"idempotencyKey": payload.IdempotencyKey,
+	})
+
+	idempotencyKey := ""
+	if payload.IdempotencyKey != "" {
+		idempotencyKey = fmt.Sprintf("%s:%s:%s", constants.RedisKeyIdempotency, domain, payload.IdempotencyKey)
+		ok, err := c.redisClient.SetNX(ctx, idempotencyKey, job.Id, idempotencyTTL).Result()
+		if err != nil {
+			return nil, errors.InternalServerError(fmt.Sprintf("cannot save idempotency key: %v", err))
+		}
+		if !ok {
+			return c.findIdempotentJob(ctx, idempotencyKey)
+		}
+	}
+
+	// the job is saved before it is accepted, the key of a job which cannot be saved is released for the retry
+	if resp := <-c.workerRepositoryCommand.InsertOneWorkerJob(ctx, job); resp.Error != nil {
+		if idempotencyKey != "" {
+			if err := c.redisClient.Del(ctx, idempotencyKey).Err(); err != nil {
+				c.logger.Error(ctx, "Failed release idempotency key", err.Error())
+			}
+		}
+		return nil, resp.Error
+	}
+
+	queued := job
+	c.jobs.Add(1)
+	go func() {
+		defer c.jobs.Done()
+		resp, err := c.runJob(c.jobCtx, &job, func(ctx context.Context, report progressFunc) (*string, error) {
+			return c.createBankTicket(ctx, payload, report)
+		})
+		if err != nil {
+			c.logger.Error(c.jobCtx, fmt.Sprintf("Failed bank ticket job %s", job.Id), err.Error())
+			return
+		}
+		c.logger.Info(c.jobCtx, *resp, job.Id)
+	}()
+
+	return &queued, nil
+}
+
+// findIdempotentJob returns the job saved for an idempotency key, a job whose key is taken but which is not saved yet
+// is reported as a conflict so the request is sent again later
+func (c commandUsecase) findIdempotentJob(ctx context.Context, idempotencyKey string) (*entity.WorkerJob, error) {
+	jobId, err := c.redisClient.Get(ctx, idempotencyKey).Result()
+	if err != nil {
+		return nil, errors.InternalServerError(fmt.Sprintf("cannot get idempotency key: %v", err))
+	}
+
+	c.logger.Info(ctx, "Return existing job", jobId)
+	job, err := NewQueryUsecase(c.workerRepositoryQuery, c.redisClient, c.logger).FindWorkerJob(ctx, jobId)
+	if e, ok := err.(*errors.ErrorString); ok && e.Code() == http.StatusNotFound {
+		return nil, errors.Conflict(fmt.Sprintf("job %s is not saved yet", jobId))
+	}
+	return job, err
+}
+
+func (c commandUsecase) createBankTicket(ctx context.Context, payload request.CreateTicketReq, report progressFunc) (*string, error) {
 	ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetail(ctx, payload)
 	if ticketDetailData.Error != nil {
 		return nil, ticketDetailData.Error
@@ -52,9 +233,142 @@ func (c commandUsecase) CreateBankTicket(origCtx context.Context, payload reques
 		return nil, errors.InternalServerError("cannot parsing data")
 	}
 
-	state := 1
-	collection := "bank-ticket"
-	lastTicket := <-c.workerRepositoryQuery.FindOneLastTicket(ctx, ticketDetail.Country.Code, ticketDetail.TicketType, ticketDetail.EventId, collection)
+	lock, err := c.lockBankTicket(ctx, ticketDetail.EventId, ticketDetail.TicketType, ticketDetail.Country.Code)
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseBankTicket(ctx, lock)
+
+	progress, err := c.findBankTicketProgress(ctx, ticketDetail)
+	if err != nil {
+		return nil, err
+	}
+	if progress.LastSeatNumber >= ticketDetail.TotalQuota {
+		return nil, errBankTicketCompleted
+	}
+
+	progress.State = constants.GenerationRunning
+	progress.Total = ticketDetail.TotalQuota
+	progress.Error = ""
+	progress.UpdatedAt = time.Now()
+	if resp := <-c.workerRepositoryCommand.UpsertBankTicketProgress(ctx, *progress); resp.Error != nil {
+		return nil, resp.Error
+	}
+	report(progress.Generated, progress.Total)
+
+	// seats are inserted chunk by chunk, the progress is saved after each chunk so a crashed run resumes from there
+	chunkSize := bankTicketChunkSize()
+	for from := progress.LastSeatNumber + 1; from <= ticketDetail.TotalQuota; from += chunkSize {
+		to := from + chunkSize - 1
+		if to > ticketDetail.TotalQuota {
+			to = ticketDetail.TotalQuota
+		}
+
+		if err := lock.Refresh(ctx, bankTicketLockTTL); err != nil {
+			return nil, c.failBankTicketProgress(progress, errors.Conflict(fmt.Sprintf("bank ticket lock lost: %v", err)))
+		}
+
+		results := make([]entity.BankTicket, 0, to-from+1)
+		for i := from; i <= to; i++ {
+			results = append(results, entity.BankTicket{
+				TicketNumber: uuid.NewString(),
+				SeatNumber:   i,
+				IsUsed:       false,
+				TicketId:     ticketDetail.TicketId,
+				EventId:      ticketDetail.EventId,
+				CountryCode:  ticketDetail.Country.Code,
+				Price:        ticketDetail.TicketPrice,
+				TicketType:   ticketDetail.TicketType,
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+			})
+		}
+
+		// the chunk, its checkpoint and its event are saved together, so a resumed run starts right after a saved chunk
+		// and never sends a seat already inserted, which would abort the transaction
+		checkpoint := *progress
+		checkpoint.LastSeatNumber = to
+		checkpoint.Generated = to
+		checkpoint.UpdatedAt = time.Now()
+		err = c.workerRepositoryCommand.WithTransaction(ctx, func(txCtx context.Context) error {
+			respTicket := <-c.workerRepositoryCommand.BulkInsertBankTicket(txCtx, results)
+			if respTicket.Error != nil {
+				return respTicket.Error
+			}
+			if resp := <-c.workerRepositoryCommand.UpsertBankTicketProgress(txCtx, checkpoint); resp.Error != nil {
+				return resp.Error
+			}
+			return c.saveEvents(txCtx, bankTicketGeneratedEvent(results))
+		})
+		if err != nil {
+			return nil, c.failBankTicketProgress(progress, err)
+		}
+
+		*progress = checkpoint
+		c.pushSeatPool(ctx, ticketDetail.TicketId, seatMembers(results)...)
+		c.logger.Info(ctx, fmt.Sprintf("Generated bank ticket %d/%d", progress.Generated, progress.Total), progress.Id)
+		report(progress.Generated, progress.Total)
+	}
+
+	completedAt := time.Now()
+	progress.State = constants.GenerationCompleted
+	progress.UpdatedAt = completedAt
+	progress.CompletedAt = &completedAt
+	if resp := <-c.workerRepositoryCommand.UpsertBankTicketProgress(ctx, *progress); resp.Error != nil {
+		return nil, resp.Error
+	}
+	c.seedInventory(ctx, ticketDetail.TicketId, ticketDetail.Country.Code, ticketDetail.TotalRemaining)
+
+	rs := "Success create bank ticket"
+	return &rs, nil
+}
+
+// lockBankTicket takes the generation lock of a ticket type in a country, seats are numbered from the last one
+// of the event, ticket type and country so only one generation may create them at a time
+func (c commandUsecase) lockBankTicket(ctx context.Context, eventId, ticketType, countryCode string) (*redis.Lock, error) {
+	lockKey := fmt.Sprintf("%s:%s:%s:%s", constants.RedisKeyBankTicketLock, eventId, ticketType, countryCode)
+	lock, err := redis.AcquireLock(ctx, c.redisClient, lockKey, bankTicketLockTTL)
+	if err == redis.ErrLockNotAcquired {
+		return nil, errors.Conflict("create bank ticket already in progress")
+	}
+	if err != nil {
+		return nil, errors.InternalServerError(fmt.Sprintf("cannot acquire bank ticket lock: %v", err))
+	}
+	return lock, nil
+}
+
+func (c commandUsecase) releaseBankTicket(ctx context.Context, lock *redis.Lock) {
+	if err := lock.Release(context.Background()); err != nil {
+		c.logger.Error(ctx, "Failed release bank ticket lock", err.Error())
+	}
+}
+
+// findBankTicketProgress returns the checkpoint of the ticket, runs started before checkpoints existed resume from the last seat.
+// It is read from the primary, a stale checkpoint would send seats already inserted and abort the chunk transaction.
+func (c commandUsecase) findBankTicketProgress(ctx context.Context, ticketDetail *entity.TicketDetail) (*entity.BankTicketProgress, error) {
+	id := fmt.Sprintf("%s:%s:%s", ticketDetail.EventId, ticketDetail.TicketId, ticketDetail.Country.Code)
+	progressData := <-c.workerRepositoryPrimary.FindOneBankTicketProgress(ctx, id)
+	if progressData.Error != nil {
+		return nil, progressData.Error
+	}
+	if progressData.Data != nil {
+		progress, ok := progressData.Data.(*entity.BankTicketProgress)
+		if !ok {
+			return nil, errors.InternalServerError("cannot parsing data progress")
+		}
+		return progress, nil
+	}
+
+	progress := &entity.BankTicketProgress{
+		Id:          id,
+		EventId:     ticketDetail.EventId,
+		TicketId:    ticketDetail.TicketId,
+		CountryCode: ticketDetail.Country.Code,
+		TicketType:  ticketDetail.TicketType,
+		StartedAt:   time.Now(),
+	}
+
+	lastTicket := <-c.workerRepositoryPrimary.FindOneLastTicket(ctx, ticketDetail.Country.Code, ticketDetail.TicketType, ticketDetail.EventId, "bank-ticket")
 	if lastTicket.Error != nil {
 		return nil, lastTicket.Error
 	}
@@ -63,42 +377,123 @@ func (c commandUsecase) CreateBankTicket(origCtx context.Context, payload reques
 		if !ok {
 			return nil, errors.InternalServerError("cannot parsing data")
 		}
-		state = ticket.SeatNumber + 1
+		progress.LastSeatNumber = ticket.SeatNumber
+		progress.Generated = ticket.SeatNumber
 	}
 
-	var results = make([]entity.BankTicket, 0)
-	counter := ticketDetail.TotalQuota
-	for i := state; i <= counter; i++ {
-		// Create a ticket map and append it to results
-		ticket := entity.BankTicket{
-			TicketNumber: uuid.NewString(),
-			SeatNumber:   i,
-			IsUsed:       false,
-			TicketId:     ticketDetail.TicketId,
-			EventId:      ticketDetail.EventId,
-			CountryCode:  ticketDetail.Country.Code,
-			Price:        ticketDetail.TicketPrice,
-			TicketType:   ticketDetail.TicketType,
-			CreatedAt:    time.Now(),
-			UpdatedAt:    time.Now(),
+	return progress, nil
+}
+
+// failBankTicketProgress marks the progress failed and returns cause, the run can be resumed by sending it again
+func (c commandUsecase) failBankTicketProgress(progress *entity.BankTicketProgress, cause error) error {
+	// the caller context may be the one which was canceled
+	ctx := context.Background()
+	progress.State = constants.GenerationFailed
+	progress.Error = cause.Error()
+	progress.UpdatedAt = time.Now()
+	if resp := <-c.workerRepositoryCommand.UpsertBankTicketProgress(ctx, *progress); resp.Error != nil {
+		c.logger.Error(ctx, "Failed save bank ticket progress", resp.Error.Error())
+	}
+	return cause
+}
+
+// releaseTicketDetail gives one seat back to the ticket detail inventory, it fails when the inventory is already full
+func (c commandUsecase) releaseTicketDetail(ctx context.Context, ticketId string) error {
+	ticketDetailResp := <-c.workerRepositoryCommand.IncrementTicketDetailRemaining(ctx, ticketId, 1)
+	if ticketDetailResp.Error != nil {
+		return ticketDetailResp.Error
+	}
+	if ticketDetailResp.Data == nil {
+		return errors.BadRequest("totalRemaining full")
+	}
+	return nil
+}
+
+// allocateOnlineTicket splits the online quota of a tag between countries with the strategy of its config
+func (c commandUsecase) allocateOnlineTicket(ctx context.Context, ticketConfig *entity.OnlineTicketConfig) ([]dto.CountryQuota, error) {
+	strategy, err := allocations.NewAllocationStrategy(ticketConfig.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	totalTicketAvailable := <-c.workerRepositoryQuery.FindTotalAvalailableTicket(ctx, ticketConfig.Tag)
+	if totalTicketAvailable.Error != nil {
+		return nil, totalTicketAvailable.Error
+	}
+
+	if totalTicketAvailable.Data == nil {
+		return nil, errors.BadRequest("ticket not found")
+	}
+
+	ticketAvailable, ok := totalTicketAvailable.Data.(*[]entity.AggregateTotalTicket)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data ticket")
+	}
+
+	// countries are ranked by their remaining offline tickets
+	input := dto.AllocationInput{
+		TotalQuota:  ticketConfig.TotalQuota,
+		CountryList: ticketConfig.CountryList,
+		Countries:   make([]dto.CountryDemand, 0, len(*ticketAvailable)),
+	}
+	for _, v := range *ticketAvailable {
+		demand := dto.CountryDemand{
+			CountryCode:          v.Id,
+			TotalTicket:          v.TotalTicket,
+			TotalAvailableTicket: v.TotalAvailableTicket,
 		}
-		results = append(results, ticket)
+		if strategy.Name() == constants.AllocationWaitingQueue {
+			size, err := c.waitingQueueSize(ctx, ticketConfig.Tag, v.Id)
+			if err != nil {
+				return nil, err
+			}
+			demand.QueueSize = size
+		}
+		input.Countries = append(input.Countries, demand)
 	}
 
-	if len(results) == 0 {
-		return nil, errors.BadRequest("create bank ticket already completed")
+	return strategy.Allocate(input)
+}
+
+// waitingQueueSize reads the waiting queue size of a country kept by the queue service, a missing key is an empty queue
+func (c commandUsecase) waitingQueueSize(ctx context.Context, tag, countryCode string) (int, error) {
+	key := fmt.Sprintf("%s:%s:%s", constants.RedisKeyWaitingQueue, tag, countryCode)
+	size, err := c.redisClient.Get(ctx, key).Int()
+	if err == goRedis.Nil {
+		return 0, nil
 	}
+	if err != nil {
+		c.logger.Error(ctx, "Failed get waiting queue size", err.Error())
+		return 0, errors.InternalServerError("cannot get waiting queue size")
+	}
+	return size, nil
+}
 
-	respTicket := <-c.workerRepositoryCommand.InsertManyTicketCollection(ctx, collection, results)
-	if respTicket.Error != nil {
-		return nil, respTicket.Error
+func bankTicketChunkSize() int {
+	chunkSize, err := strconv.Atoi(configs.GetConfig().Worker.BankTicketChunkSize)
+	if err != nil || chunkSize <= 0 {
+		return defaultBankTicketChunkSize
 	}
+	return chunkSize
+}
 
-	rs := "Success create bank ticket"
-	return &rs, nil
+func expiryBatchSize() int64 {
+	batchSize, err := strconv.ParseInt(configs.GetConfig().Worker.ExpiryBatchSize, 10, 64)
+	if err != nil || batchSize <= 0 {
+		return defaultExpiryBatchSize
+	}
+	return batchSize
 }
 
-func (c commandUsecase) UpdateAllExpiryPayment(origCtx context.Context) (*string, error) {
+func expiryTimeBudget() time.Duration {
+	budget, err := time.ParseDuration(configs.GetConfig().Worker.ExpiryTimeBudget)
+	if err != nil || budget <= 0 {
+		return defaultExpiryTimeBudget
+	}
+	return budget
+}
+
+func (c commandUsecase) UpdateAllExpiryPayment(origCtx context.Context) (*entity.WorkerJobRun, error) {
 	domain := "workerUsecase-UpdateAllExpiryPayment"
 	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
 		Start:  time.Now(),
@@ -106,85 +501,131 @@ func (c commandUsecase) UpdateAllExpiryPayment(origCtx context.Context) (*string
 	})
 	defer span.End()
 
-	paymentData := <-c.workerRepositoryQuery.FindAllExpirePayment(ctx)
-	if paymentData.Error != nil {
-		return nil, paymentData.Error
-	}
-
-	if paymentData.Data == nil {
-		return nil, errors.BadRequest("payment not found")
+	run := newRun(constants.JobTypeExpiryPayment)
+	deadline := run.StartedAt.Add(expiryTimeBudget())
+	policies, err := c.findExpiryPolicies(ctx)
+	if err != nil {
+		c.finishRun(ctx, run, err)
+		return nil, err
 	}
 
-	payments, ok := paymentData.Data.(*[]entity.PaymentHistory)
-	if !ok {
-		return nil, errors.InternalServerError("cannot parsing data payment")
-	}
+	page := request.KeysetPageReq{Size: expiryBatchSize()}
+	for {
+		paymentData := <-c.workerRepositoryQuery.FindAllExpirePayment(ctx, page, policies)
+		if paymentData.Error != nil {
+			c.finishRun(ctx, run, paymentData.Error)
+			return nil, paymentData.Error
+		}
 
-	result := "Success delete expired payment ticket"
-	if len(*payments) == 0 {
-		result = "Expiry payment ticket empty"
-		c.logger.Info(ctx, result, payments)
-		return &result, nil
-	}
+		if paymentData.Data == nil {
+			err := errors.BadRequest("payment not found")
+			c.finishRun(ctx, run, err)
+			return nil, err
+		}
 
-	for _, p := range *payments {
-		ticketNumber := p.Ticket.TicketNumber
-		ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetailById(ctx, p.Ticket.TicketId)
-		if ticketDetailData.Error != nil {
-			return nil, ticketDetailData.Error
+		payments, ok := paymentData.Data.(*[]entity.PaymentHistory)
+		if !ok {
+			err := errors.InternalServerError("cannot parsing data payment")
+			c.finishRun(ctx, run, err)
+			return nil, err
 		}
 
-		if ticketDetailData.Data == nil {
-			return nil, errors.BadRequest("ticket not found")
+		// a payment which cannot be reclaimed does not stop the others
+		run.Scanned += len(*payments)
+		for _, p := range *payments {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := c.reclaimPayment(ctx, p); err != nil {
+				failRun(run, p.PaymentId, err)
+				continue
+			}
+			run.Reclaimed++
 		}
 
-		ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
-		if !ok {
-			return nil, errors.InternalServerError("cannot parsing data ticket")
+		// a canceled or timed out run stops before its next record, the rest is left to the next run
+		if ctx.Err() != nil {
+			run.TimedOut = true
+			break
 		}
+		if int64(len(*payments)) < page.Size {
+			break
+		}
+		if time.Now().After(deadline) {
+			run.TimedOut = true
+			break
+		}
+		last := (*payments)[len(*payments)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterId = last.Id
+	}
+
+	c.finishRun(ctx, run, nil)
+	return run, nil
+}
+
+// reclaimPayment invalidates an expired payment and releases its order, its seat and its inventory
+func (c commandUsecase) reclaimPayment(ctx context.Context, p entity.PaymentHistory) error {
+	if p.Ticket == nil {
+		return errors.BadRequest("payment ticket not found")
+	}
+
+	ticketNumber := p.Ticket.TicketNumber
+	ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetailById(ctx, p.Ticket.TicketId)
+	if ticketDetailData.Error != nil {
+		return ticketDetailData.Error
+	}
 
-		c.logger.Info(ctx, "Payment Expired", p)
+	if ticketDetailData.Data == nil {
+		return errors.BadRequest("ticket not found")
+	}
 
-		updatePaymentResp := <-c.workerRepositoryCommand.UpdateOnePayment(ctx, p.PaymentId)
+	ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
+	if !ok {
+		return errors.InternalServerError("cannot parsing data ticket")
+	}
+
+	c.logger.Info(ctx, "Payment Expired", p)
+
+	// the payment, its order, its seat and the inventory are released together with their events or not at all
+	err := c.workerRepositoryCommand.WithTransaction(ctx, func(txCtx context.Context) error {
+		updatePaymentResp := <-c.workerRepositoryCommand.UpdateOnePayment(txCtx, p.PaymentId)
 		if updatePaymentResp.Error != nil {
-			return nil, updatePaymentResp.Error
+			return updatePaymentResp.Error
 		}
 
 		c.logger.Info(ctx, "Deleted Ticket Order", ticketNumber)
 
-		deleteOrderResp := <-c.workerRepositoryCommand.DeleteOneOrder(ctx, ticketNumber)
+		deleteOrderResp := <-c.workerRepositoryCommand.DeleteOneOrder(txCtx, ticketNumber)
 		if deleteOrderResp.Error != nil {
-			return nil, deleteOrderResp.Error
+			return deleteOrderResp.Error
 		}
 
 		bankTicketReq := request.UpdateBankTicketRequest{
 			TicketNumber: ticketNumber,
 			Price:        ticketDetail.TicketPrice,
 		}
-		bankTicketResp := <-c.workerRepositoryCommand.UpdateOneBankTicket(ctx, bankTicketReq)
+		bankTicketResp := <-c.workerRepositoryCommand.UpdateOneBankTicket(txCtx, bankTicketReq)
 		if bankTicketResp.Error != nil {
-			return nil, bankTicketResp.Error
+			return bankTicketResp.Error
 		}
 
-		totalRemaining := ticketDetail.TotalRemaining + 1
-		if totalRemaining > ticketDetail.TotalQuota {
-			return nil, errors.BadRequest("totalRemaining full")
-		}
-		ticketDetailReq := request.UpdateTicketDetailByIdReq{
-			TicketId:       ticketDetail.TicketId,
-			TotalRemaining: totalRemaining,
+		if err := c.releaseTicketDetail(txCtx, ticketDetail.TicketId); err != nil {
+			return err
 		}
-		ticketDetailResp := <-c.workerRepositoryCommand.UpdateTicketDetailById(ctx, ticketDetailReq)
-		if ticketDetailResp.Error != nil {
-			return nil, ticketDetailResp.Error
-		}
-	}
 
-	return &result, nil
+		return c.saveEvents(txCtx, paymentExpiredEvent(p), ticketReleasedEvent(*p.Ticket, constants.ReleaseExpiredPayment))
+	})
+	if err != nil {
+		return err
+	}
 
+	c.releaseInventory(ctx, ticketDetail)
+	c.pushSeatPool(ctx, ticketDetail.TicketId, ticketNumber)
+	return nil
 }
 
-func (c commandUsecase) UpdateAllExpiryBankTicket(origCtx context.Context) (*string, error) {
+func (c commandUsecase) UpdateAllExpiryBankTicket(origCtx context.Context) (*entity.WorkerJobRun, error) {
 	domain := "workerUsecase-UpdateAllExpiryBankTicket"
 	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
 		Start:  time.Now(),
@@ -192,82 +633,136 @@ func (c commandUsecase) UpdateAllExpiryBankTicket(origCtx context.Context) (*str
 	})
 	defer span.End()
 
-	bankTicketData := <-c.workerRepositoryQuery.FindAllExpireBankTicket(ctx)
-	if bankTicketData.Error != nil {
-		return nil, bankTicketData.Error
-	}
-
-	if bankTicketData.Data == nil {
-		return nil, errors.BadRequest("bank ticket not found")
-	}
-
-	bankTickets, ok := bankTicketData.Data.(*[]entity.BankTicket)
-	if !ok {
-		return nil, errors.InternalServerError("cannot parsing data bank ticket")
-	}
-
-	result := "Success delete expired bank ticket"
-	if len(*bankTickets) == 0 {
-		result = "Expiry bank ticket empty"
-		c.logger.Info(ctx, result, bankTickets)
-		return &result, nil
+	run := newRun(constants.JobTypeExpiryBankTicket)
+	deadline := run.StartedAt.Add(expiryTimeBudget())
+	policies, err := c.findExpiryPolicies(ctx)
+	if err != nil {
+		c.finishRun(ctx, run, err)
+		return nil, err
 	}
 
-	for _, b := range *bankTickets {
-		ticketNumber := b.TicketNumber
-		paymentData := <-c.workerRepositoryQuery.FindPaymentByTicketNumber(ctx, ticketNumber)
-		if paymentData.Error != nil {
-			return nil, paymentData.Error
+	page := request.KeysetPageReq{Size: expiryBatchSize()}
+	for {
+		bankTicketData := <-c.workerRepositoryQuery.FindAllExpireBankTicket(ctx, page, policies)
+		if bankTicketData.Error != nil {
+			c.finishRun(ctx, run, bankTicketData.Error)
+			return nil, bankTicketData.Error
 		}
-		if paymentData.Data != nil {
-			c.logger.Info(ctx, "Skip ticketNumber: ", b)
-			continue
+
+		if bankTicketData.Data == nil {
+			err := errors.BadRequest("bank ticket not found")
+			c.finishRun(ctx, run, err)
+			return nil, err
 		}
 
-		ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetailById(ctx, b.TicketId)
-		if ticketDetailData.Error != nil {
-			return nil, ticketDetailData.Error
+		bankTickets, ok := bankTicketData.Data.(*[]entity.BankTicket)
+		if !ok {
+			err := errors.InternalServerError("cannot parsing data bank ticket")
+			c.finishRun(ctx, run, err)
+			return nil, err
 		}
 
-		if ticketDetailData.Data == nil {
-			return nil, errors.BadRequest("ticket not found")
+		// a bank ticket which cannot be reclaimed does not stop the others
+		run.Scanned += len(*bankTickets)
+		for _, b := range *bankTickets {
+			if ctx.Err() != nil {
+				break
+			}
+			reclaimed, err := c.reclaimBankTicket(ctx, b)
+			if err != nil {
+				failRun(run, b.TicketNumber, err)
+				continue
+			}
+			if !reclaimed {
+				run.Skipped++
+				continue
+			}
+			run.Reclaimed++
 		}
 
-		ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
-		if !ok {
-			return nil, errors.InternalServerError("cannot parsing data ticket")
+		// a canceled or timed out run stops before its next record, the rest is left to the next run
+		if ctx.Err() != nil {
+			run.TimedOut = true
+			break
 		}
+		if int64(len(*bankTickets)) < page.Size {
+			break
+		}
+		if time.Now().After(deadline) {
+			run.TimedOut = true
+			break
+		}
+		last := (*bankTickets)[len(*bankTickets)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterId = last.Id
+	}
+
+	c.finishRun(ctx, run, nil)
+	return run, nil
+}
+
+// reclaimBankTicket releases an expired bank ticket and its inventory, a ticket which has a payment is skipped
+func (c commandUsecase) reclaimBankTicket(ctx context.Context, b entity.BankTicket) (bool, error) {
+	ticketNumber := b.TicketNumber
+	paymentData := <-c.workerRepositoryQuery.FindPaymentByTicketNumber(ctx, ticketNumber)
+	if paymentData.Error != nil {
+		return false, paymentData.Error
+	}
+	if paymentData.Data != nil {
+		c.logger.Info(ctx, "Skip ticketNumber: ", b)
+		return false, nil
+	}
 
-		c.logger.Info(ctx, "Bank Ticket Expired", b)
+	ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetailById(ctx, b.TicketId)
+	if ticketDetailData.Error != nil {
+		return false, ticketDetailData.Error
+	}
+
+	if ticketDetailData.Data == nil {
+		return false, errors.BadRequest("ticket not found")
+	}
+
+	ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
+	if !ok {
+		return false, errors.InternalServerError("cannot parsing data ticket")
+	}
 
+	c.logger.Info(ctx, "Bank Ticket Expired", b)
+
+	// the seat and the inventory are released together with their event or not at all
+	err := c.workerRepositoryCommand.WithTransaction(ctx, func(txCtx context.Context) error {
 		bankTicketReq := request.UpdateBankTicketRequest{
 			TicketNumber: ticketNumber,
 			Price:        ticketDetail.TicketPrice,
 		}
-		bankTicketResp := <-c.workerRepositoryCommand.UpdateOneBankTicket(ctx, bankTicketReq)
+		bankTicketResp := <-c.workerRepositoryCommand.UpdateOneBankTicket(txCtx, bankTicketReq)
 		if bankTicketResp.Error != nil {
-			return nil, bankTicketResp.Error
+			return bankTicketResp.Error
 		}
 
-		totalRemaining := ticketDetail.TotalRemaining + 1
-		if totalRemaining > ticketDetail.TotalQuota {
-			return nil, errors.BadRequest("totalRemaining full")
+		if err := c.releaseTicketDetail(txCtx, ticketDetail.TicketId); err != nil {
+			return err
 		}
 
-		ticketDetailReq := request.UpdateTicketDetailByIdReq{
-			TicketId:       ticketDetail.TicketId,
-			TotalRemaining: totalRemaining,
-		}
-		ticketDetailResp := <-c.workerRepositoryCommand.UpdateTicketDetailById(ctx, ticketDetailReq)
-		if ticketDetailResp.Error != nil {
-			return nil, ticketDetailResp.Error
-		}
+		return c.saveEvents(txCtx, ticketReleasedEvent(entity.Ticket{
+			TicketNumber: b.TicketNumber,
+			EventId:      b.EventId,
+			TicketType:   b.TicketType,
+			SeatNumber:   b.SeatNumber,
+			CountryCode:  b.CountryCode,
+			TicketId:     b.TicketId,
+		}, constants.ReleaseExpiredBankTicket))
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return &result, nil
-
+	c.releaseInventory(ctx, ticketDetail)
+	c.pushSeatPool(ctx, ticketDetail.TicketId, ticketNumber)
+	return true, nil
 }
 
+// CreateOnlineBankTicket generates the online bank tickets of a tag and waits for the generation, it is tracked as a kafka job
 func (c commandUsecase) CreateOnlineBankTicket(origCtx context.Context, payload request.CreateOnlineTicketReq) (*string, error) {
 	domain := "workerUsecase-CreateOnlineBankTicket"
 	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
@@ -276,91 +771,30 @@ func (c commandUsecase) CreateOnlineBankTicket(origCtx context.Context, payload
 	})
 	defer span.End()
 
-	ticketConfigData := <-c.workerRepositoryQuery.FindOnlineTicketConfigByTag(ctx, payload.Tag)
-	if ticketConfigData.Error != nil {
-		return nil, ticketConfigData.Error
-	}
-	if ticketConfigData.Data == nil {
-		msg := "ticket config not found"
-		return nil, errors.NotFound(msg)
-	}
-
-	ticketConfig, ok := ticketConfigData.Data.(*entity.OnlineTicketConfig)
-	if !ok {
-		return nil, errors.InternalServerError("cannot parsing data")
-	}
-
-	totalTicketAvailable := <-c.workerRepositoryQuery.FindTotalAvalailableTicket(ctx, payload.Tag)
-	if totalTicketAvailable.Error != nil {
-		return nil, totalTicketAvailable.Error
+	job := c.newJob(constants.JobTypeCreateOnlineBankTicket, constants.JobSourceKafka, map[string]string{
+		"tag":         payload.Tag,
+		"countryCode": payload.CountryCode,
+	})
+	// the message is only committed once its job is saved, a job which cannot be saved is retried
+	if resp := <-c.workerRepositoryCommand.InsertOneWorkerJob(ctx, job); resp.Error != nil {
+		c.logger.Error(ctx, "Failed insert worker job", resp.Error.Error())
+		return nil, resp.Error
 	}
 
-	if totalTicketAvailable.Data == nil {
-		return nil, errors.BadRequest("ticket not found")
-	}
+	return c.runJob(ctx, &job, func(ctx context.Context, report progressFunc) (*string, error) {
+		return c.createOnlineBankTicket(ctx, payload)
+	})
+}
 
-	ticketAvailable, ok := totalTicketAvailable.Data.(*[]entity.AggregateTotalTicket)
-	if !ok {
-		return nil, errors.InternalServerError("cannot parsing data ticket")
+func (c commandUsecase) createOnlineBankTicket(ctx context.Context, payload request.CreateOnlineTicketReq) (*string, error) {
+	ticketConfig, err := c.findOnlineTicketConfig(ctx, payload.Tag)
+	if err != nil {
+		return nil, err
 	}
 
-	var percentage1, percentage2, percentage3, percentage4 int
-	for _, v := range ticketConfig.CountryList {
-		if v.CountryNumber == 1 {
-			percentage1 = v.Percentage
-		}
-		if v.CountryNumber == 2 {
-			percentage2 = v.Percentage
-		}
-		if v.CountryNumber == 3 {
-			percentage3 = v.Percentage
-		}
-		if v.CountryNumber == 4 {
-			percentage4 = v.Percentage
-		}
-	}
-	countries := make([]dto.CountryQuota, 0)
-	for i, v := range *ticketAvailable {
-		isSkiped := false
-		for _, t := range ticketConfig.CountryList {
-			if v.Id == t.CountryCode {
-				isSkiped = true
-			}
-		}
-		if isSkiped {
-			continue
-		}
-		if i == 0 {
-			if v.TotalAvailableTicket != 0 {
-				return nil, errors.BadRequest("offline ticket still ready")
-			}
-			countries = append(countries, dto.CountryQuota{
-				CountryCode:   v.Id,
-				CountryNumber: i + 1,
-				TotalQuota:    ticketConfig.TotalQuota * percentage1 / 100,
-			})
-		}
-		if i == 1 {
-			countries = append(countries, dto.CountryQuota{
-				CountryCode:   v.Id,
-				CountryNumber: i + 1,
-				TotalQuota:    ticketConfig.TotalQuota * percentage2 / 100,
-			})
-		}
-		if i == 2 {
-			countries = append(countries, dto.CountryQuota{
-				CountryCode:   v.Id,
-				CountryNumber: i + 1,
-				TotalQuota:    ticketConfig.TotalQuota * percentage3 / 100,
-			})
-		}
-		if i == 3 {
-			countries = append(countries, dto.CountryQuota{
-				CountryCode:   v.Id,
-				CountryNumber: i + 1,
-				TotalQuota:    ticketConfig.TotalQuota * percentage4 / 100,
-			})
-		}
+	countries, err := c.allocateOnlineTicket(ctx, ticketConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(countries) == 0 {
@@ -369,96 +803,233 @@ func (c commandUsecase) CreateOnlineBankTicket(origCtx context.Context, payload
 	}
 
 	for _, country := range countries {
-		state := 1
-		collection := "bank-ticket"
-		ticketDetailReq := request.TicketDetailByTagReq{
-			Tag:         payload.Tag,
-			CountryCode: country.CountryCode,
-			TicketType:  constants.Online,
-		}
-
-		ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetailByTag(ctx, ticketDetailReq)
-		if ticketDetailData.Error != nil {
-			return nil, ticketDetailData.Error
+		if err := c.createOnlineCountry(ctx, payload.Tag, country); err != nil {
+			return nil, err
 		}
+	}
 
-		if ticketDetailData.Data == nil {
-			return nil, errors.BadRequest("ticket detail not found")
-		}
+	rs := "Success create bank ticket online"
+	return &rs, nil
+}
 
-		ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
-		if !ok {
-			return nil, errors.InternalServerError("cannot parsing data ticket")
-		}
-		lastTicket := <-c.workerRepositoryQuery.FindOneLastTicket(ctx, country.CountryCode, constants.Online, ticketDetail.EventId, collection)
-		if lastTicket.Error != nil {
-			return nil, lastTicket.Error
-		}
-		if lastTicket.Data != nil {
-			ticket, ok := lastTicket.Data.(*entity.BankTicket)
-			if !ok {
-				return nil, errors.InternalServerError("cannot parsing data")
-			}
-			state = ticket.SeatNumber + 1
-		}
+// createOnlineCountry creates the online seats of a country quota under the generation lock of the country
+func (c commandUsecase) createOnlineCountry(ctx context.Context, tag string, country dto.CountryQuota) error {
+	ticketDetail, err := c.findOnlineTicketDetail(ctx, tag, country.CountryCode)
+	if err != nil {
+		return err
+	}
 
-		fmt.Println("lastTicket.Data: ", lastTicket.Data)
+	lock, err := c.lockBankTicket(ctx, ticketDetail.EventId, constants.Online, country.CountryCode)
+	if err != nil {
+		return err
+	}
+	defer c.releaseBankTicket(ctx, lock)
 
-		fmt.Println(state)
+	plan, err := c.planOnlineCountry(ctx, ticketDetail, country)
+	if err != nil {
+		return err
+	}
 
-		var results = make([]entity.BankTicket, 0)
-		counter := country.TotalQuota
-		for i := state; i <= counter; i++ {
-			// Create a ticket map and append it to results
-			ticket := entity.BankTicket{
-				TicketNumber: uuid.NewString(),
-				SeatNumber:   i,
-				IsUsed:       false,
-				TicketId:     ticketDetail.TicketId,
-				EventId:      ticketDetail.EventId,
-				CountryCode:  country.CountryCode,
-				Price:        ticketDetail.TicketPrice,
-				TicketType:   ticketDetail.TicketType,
-				CreatedAt:    time.Now(),
-				UpdatedAt:    time.Now(),
-			}
-			results = append(results, ticket)
+	var results = make([]entity.BankTicket, 0, plan.TotalSeat)
+	for i := plan.FirstSeatNumber; plan.TotalSeat > 0 && i <= plan.LastSeatNumber; i++ {
+		// Create a ticket map and append it to results
+		ticket := entity.BankTicket{
+			TicketNumber: uuid.NewString(),
+			SeatNumber:   i,
+			IsUsed:       false,
+			TicketId:     ticketDetail.TicketId,
+			EventId:      ticketDetail.EventId,
+			CountryCode:  country.CountryCode,
+			Price:        ticketDetail.TicketPrice,
+			TicketType:   ticketDetail.TicketType,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
 		}
+		results = append(results, ticket)
+	}
 
-		respTicket := <-c.workerRepositoryCommand.InsertManyTicketCollection(ctx, collection, results)
+	// the seats, the quotas and the event of a country are saved together or not at all
+	err = c.workerRepositoryCommand.WithTransaction(ctx, func(txCtx context.Context) error {
+		respTicket := <-c.workerRepositoryCommand.InsertManyTicketCollection(txCtx, "bank-ticket", results)
 		if respTicket.Error != nil {
-			return nil, respTicket.Error
+			return respTicket.Error
 		}
 
 		updateTicketConfigReq := request.UpdateOnlineTicketConfigReq{
-			Tag:           payload.Tag,
+			Tag:           tag,
 			CountryNumber: country.CountryNumber,
 			CountryCode:   country.CountryCode,
 		}
-		respConfig := <-c.workerRepositoryCommand.UpdateOnlineTicketConfig(ctx, updateTicketConfigReq)
+		respConfig := <-c.workerRepositoryCommand.UpdateOnlineTicketConfig(txCtx, updateTicketConfigReq)
 		if respConfig.Error != nil {
 			c.logger.Error(ctx, "Failed UpdateOnlineTicketConfig", respConfig)
-			return nil, respConfig.Error
+			return respConfig.Error
 		}
 		c.logger.Info(ctx, "Success UpdateOnlineTicketConfig", respConfig)
 
 		updateTicketDetailReq := request.UpdateTicketDetailReq{
-			Tag:            payload.Tag,
+			Tag:            tag,
 			TicketType:     constants.Online,
 			CountryCode:    country.CountryCode,
-			TotalQuota:     country.TotalQuota,
-			TotalRemaining: (country.TotalQuota - ticketDetail.TotalQuota) + ticketDetail.TotalRemaining,
+			TotalQuota:     plan.TotalQuota,
+			TotalRemaining: plan.TotalRemaining,
 		}
 
-		respTicketDetail := <-c.workerRepositoryCommand.UpdateTicketDetailByTag(ctx, updateTicketDetailReq)
+		respTicketDetail := <-c.workerRepositoryCommand.UpdateTicketDetailByTag(txCtx, updateTicketDetailReq)
 		if respTicketDetail.Error != nil {
 			c.logger.Error(ctx, "Failed UpdateTicketDetailByTag", respTicketDetail)
-			return nil, respTicketDetail.Error
+			return respTicketDetail.Error
 		}
 		c.logger.Info(ctx, "Success UpdateTicketDetailByTag", respTicketDetail)
 
+		if len(results) == 0 {
+			return nil
+		}
+		return c.saveEvents(txCtx, bankTicketGeneratedEvent(results))
+	})
+	if err != nil {
+		return err
+	}
+	c.adjustInventory(ctx, ticketDetail.TicketId, country.CountryCode, plan.TotalRemaining-ticketDetail.TotalRemaining, plan.TotalQuota, plan.TotalRemaining)
+	c.pushSeatPool(ctx, ticketDetail.TicketId, seatMembers(results)...)
+	return nil
+}
+
+// SimulateOnlineBankTicket computes what CreateOnlineBankTicket would do for a tag without writing anything,
+// an overridden config replaces the stored one
+func (c commandUsecase) SimulateOnlineBankTicket(origCtx context.Context, payload request.SimulateOnlineTicketReq) (*dto.OnlineTicketSimulation, error) {
+	domain := "workerUsecase-SimulateOnlineBankTicket"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	var ticketConfig *entity.OnlineTicketConfig
+	if payload.Config != nil {
+		ticketConfig = &entity.OnlineTicketConfig{
+			Tag:         payload.Tag,
+			TotalQuota:  payload.Config.TotalQuota,
+			Strategy:    payload.Config.Strategy,
+			CountryList: make([]entity.CountryList, 0, len(payload.Config.CountryList)),
+		}
+		for _, v := range payload.Config.CountryList {
+			ticketConfig.CountryList = append(ticketConfig.CountryList, entity.CountryList{
+				CountryNumber: v.CountryNumber,
+				Percentage:    v.Percentage,
+				Quota:         v.Quota,
+				CountryCode:   v.CountryCode,
+			})
+		}
+	} else {
+		var err error
+		if ticketConfig, err = c.findOnlineTicketConfig(ctx, payload.Tag); err != nil {
+			return nil, err
+		}
+	}
+
+	countries, err := c.allocateOnlineTicket(ctx, ticketConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	rs := "Success create bank ticket online"
-	return &rs, nil
+	simulation := &dto.OnlineTicketSimulation{
+		Tag:        payload.Tag,
+		Strategy:   helpers.CustomIfEmpty(ticketConfig.Strategy, constants.AllocationRankBySellout),
+		TotalQuota: ticketConfig.TotalQuota,
+		Countries:  make([]dto.OnlineTicketCountryPlan, 0, len(countries)),
+	}
+	for _, country := range countries {
+		ticketDetail, err := c.findOnlineTicketDetail(ctx, payload.Tag, country.CountryCode)
+		if err != nil {
+			return nil, err
+		}
+		plan, err := c.planOnlineCountry(ctx, ticketDetail, country)
+		if err != nil {
+			return nil, err
+		}
+		simulation.Countries = append(simulation.Countries, *plan)
+	}
+	return simulation, nil
+}
+
+// findOnlineTicketConfig returns the online ticket config of a tag
+func (c commandUsecase) findOnlineTicketConfig(ctx context.Context, tag string) (*entity.OnlineTicketConfig, error) {
+	ticketConfigData := <-c.workerRepositoryQuery.FindOnlineTicketConfigByTag(ctx, tag)
+	if ticketConfigData.Error != nil {
+		return nil, ticketConfigData.Error
+	}
+	if ticketConfigData.Data == nil {
+		msg := "ticket config not found"
+		return nil, errors.NotFound(msg)
+	}
+
+	ticketConfig, ok := ticketConfigData.Data.(*entity.OnlineTicketConfig)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data")
+	}
+	return ticketConfig, nil
+}
+
+// findOnlineTicketDetail returns the online ticket detail of a country
+func (c commandUsecase) findOnlineTicketDetail(ctx context.Context, tag, countryCode string) (*entity.TicketDetail, error) {
+	ticketDetailReq := request.TicketDetailByTagReq{
+		Tag:         tag,
+		CountryCode: countryCode,
+		TicketType:  constants.Online,
+	}
+
+	ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetailByTag(ctx, ticketDetailReq)
+	if ticketDetailData.Error != nil {
+		return nil, ticketDetailData.Error
+	}
+
+	if ticketDetailData.Data == nil {
+		return nil, errors.BadRequest("ticket detail not found")
+	}
+
+	ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data ticket")
+	}
+	return ticketDetail, nil
+}
+
+// planOnlineCountry computes the seats to create for a country quota, continuing after its last online seat,
+// and the ticket detail totals once they are created
+func (c commandUsecase) planOnlineCountry(ctx context.Context, ticketDetail *entity.TicketDetail, country dto.CountryQuota) (*dto.OnlineTicketCountryPlan, error) {
+
+	// the seats of the previous quota are already created, a smaller quota would leave a negative remaining
+	if country.TotalQuota < ticketDetail.TotalQuota {
+		msg := fmt.Sprintf("online quota of country %s cannot shrink from %d to %d", country.CountryCode, ticketDetail.TotalQuota, country.TotalQuota)
+		return nil, errors.BadRequest(msg)
+	}
+
+	state := 1
+	lastTicket := <-c.workerRepositoryQuery.FindOneLastTicket(ctx, country.CountryCode, constants.Online, ticketDetail.EventId, "bank-ticket")
+	if lastTicket.Error != nil {
+		return nil, lastTicket.Error
+	}
+	if lastTicket.Data != nil {
+		ticket, ok := lastTicket.Data.(*entity.BankTicket)
+		if !ok {
+			return nil, errors.InternalServerError("cannot parsing data")
+		}
+		state = ticket.SeatNumber + 1
+	}
+
+	plan := &dto.OnlineTicketCountryPlan{
+		CountryCode:    country.CountryCode,
+		CountryNumber:  country.CountryNumber,
+		EventId:        ticketDetail.EventId,
+		TicketId:       ticketDetail.TicketId,
+		TotalQuota:     country.TotalQuota,
+		TotalRemaining: (country.TotalQuota - ticketDetail.TotalQuota) + ticketDetail.TotalRemaining,
+	}
+	if state <= country.TotalQuota {
+		plan.FirstSeatNumber = state
+		plan.LastSeatNumber = country.TotalQuota
+		plan.TotalSeat = country.TotalQuota - state + 1
+	}
+	return plan, nil
 }
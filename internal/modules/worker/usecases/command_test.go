@@ -2,26 +2,38 @@ package usecases_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
+	"worker-service/configs"
 	"worker-service/internal/modules/worker"
+	"worker-service/internal/pkg/constants"
 	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/events"
 	"worker-service/internal/pkg/helpers"
+	"worker-service/internal/pkg/scheduler"
 
+	"worker-service/internal/modules/worker/models/dto"
 	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
 	uc "worker-service/internal/modules/worker/usecases"
 	mockcert "worker-service/mocks/modules/worker"
 	mocklog "worker-service/mocks/pkg/log"
+	mockredis "worker-service/mocks/pkg/redis"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CommandUsecaseTestSuite struct {
 	suite.Suite
 	mockWorkerRepositoryQuery   *mockcert.MongodbRepositoryQuery
 	mockWorkerRepositoryCommand *mockcert.MongodbRepositoryCommand
+	mockRedis                   *mockredis.Collections
 	mockLogger                  *mocklog.Logger
 	usecase                     worker.UsecaseCommand
 	ctx                         context.Context
@@ -30,11 +42,35 @@ type CommandUsecaseTestSuite struct {
 func (suite *CommandUsecaseTestSuite) SetupTest() {
 	suite.mockWorkerRepositoryQuery = &mockcert.MongodbRepositoryQuery{}
 	suite.mockWorkerRepositoryCommand = &mockcert.MongodbRepositoryCommand{}
+	suite.mockRedis = &mockredis.Collections{}
+	suite.mockRedis.On("SetNX", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewBoolResult(true, nil))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewCmdResult(int64(1), nil))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewCmdResult(int64(1), nil))
+	suite.mockRedis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewStatusResult("OK", nil))
+	suite.mockRedis.On("SAdd", mock.Anything, mock.Anything, mock.Anything).Return(redis.NewIntResult(1, nil))
 	suite.mockLogger = &mocklog.Logger{}
+	suite.mockWorkerRepositoryQuery.On("FindOneBankTicketProgress", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("UpsertBankTicketProgress", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("InsertOneWorkerJob", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("UpdateOneWorkerJob", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("WithTransaction", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	suite.mockWorkerRepositoryCommand.On("InsertOneWorkerJobRun", mock.Anything, mock.Anything).Return(func(ctx context.Context, run entity.WorkerJobRun) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryQuery.On("FindAllExpiryPolicy", mock.Anything).Return(func(ctx context.Context) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &[]entity.ExpiryPolicy{}})
+	})
+	suite.mockWorkerRepositoryCommand.On("InsertManyOutboxEvent", mock.Anything, mock.Anything).Return(func(ctx context.Context, outboxEvents []entity.OutboxEvent) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
 	suite.ctx = context.Background()
 	suite.usecase = uc.NewCommandUsecase(
+		suite.mockWorkerRepositoryQuery,
 		suite.mockWorkerRepositoryQuery,
 		suite.mockWorkerRepositoryCommand,
+		suite.mockRedis,
 		suite.mockLogger,
 	)
 }
@@ -43,6 +79,51 @@ func TestCommandUsecaseTestSuite(t *testing.T) {
 	suite.Run(t, new(CommandUsecaseTestSuite))
 }
 
+// txKey marks the context of a transaction in tests checking what runs in it
+type txKey struct{}
+
+// savedEvent matches the outbox events saved at once when one of them is an event of eventType keyed by key
+// whose data passes check
+func savedEvent[T any](eventType, key string, check func(data T) bool) interface{} {
+	return mock.MatchedBy(func(outboxEvents []entity.OutboxEvent) bool {
+		for _, outboxEvent := range outboxEvents {
+			var envelope events.Envelope
+			if outboxEvent.Topic != eventType || outboxEvent.Key != key || outboxEvent.Status != constants.OutboxPending {
+				continue
+			}
+			if err := json.Unmarshal([]byte(outboxEvent.Value), &envelope); err != nil {
+				continue
+			}
+			var data T
+			if err := json.Unmarshal(envelope.Data, &data); err == nil && check(data) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// pushesSeats stubs a push of each count of seats at once to any seat pool
+func (suite *CommandUsecaseTestSuite) pushesSeats(counts ...int) {
+	for _, count := range counts {
+		args := []interface{}{mock.Anything, mock.Anything, mock.Anything}
+		for i := 0; i < count; i++ {
+			args = append(args, mock.Anything)
+		}
+		suite.mockRedis.On("EvalSha", args...).Return(redis.NewCmdResult(int64(count), nil))
+	}
+}
+
+// callIndex is the position of the first call of method whose third argument is arg, -1 when there is none
+func callIndex(calls []mock.Call, method string, arg interface{}) int {
+	for i, call := range calls {
+		if call.Method == method && len(call.Arguments) > 2 && call.Arguments[2] == arg {
+			return i
+		}
+	}
+	return -1
+}
+
 // Helper function to create a channel
 func mockChannel(result helpers.Result) <-chan helpers.Result {
 	responseChan := make(chan helpers.Result)
@@ -89,12 +170,359 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicket() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	seats := []interface{}{mock.Anything, mock.Anything, []string{"SEAT-POOL:{id}", "SEAT-POOL-REBUILD:{id}"}, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything}
+	suite.mockRedis.On("EvalSha", seats...).Return(redis.NewCmdResult(int64(5), nil))
+	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertManyOutboxEvent", mock.Anything,
+		savedEvent(constants.EventBankTicketGenerated, "id", func(generated dto.BankTicketGenerated) bool {
+			return generated.FirstSeatNumber == 6 && generated.LastSeatNumber == 10 && generated.Total == 5
+		}))
+	suite.mockRedis.AssertCalled(suite.T(), "SetNX", mock.Anything, "INVENTORY:id:code", 0, time.Duration(0))
+	// the five generated seats are pushed at once
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", seats...)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketResume() {
+	suite.pushesSeats(3)
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:    "id",
+			EventId:     "id",
+			TotalQuota:  10,
+			TicketPrice: 40,
+			Country: entity.Country{
+				Code: "code",
+			},
+		},
+		Error: nil,
+	}
+
+	mockProgress := helpers.Result{
+		Data: &entity.BankTicketProgress{
+			Id:             "id:id:code",
+			State:          constants.GenerationFailed,
+			LastSeatNumber: 6,
+			Generated:      6,
+			Total:          10,
+		},
+		Error: nil,
+	}
+
+	configs.GetConfig().Worker.BankTicketChunkSize = "3"
+	defer func() { configs.GetConfig().Worker.BankTicketChunkSize = "" }()
+
+	inserted := make([][]int, 0)
+	suite.mockWorkerRepositoryQuery.ExpectedCalls = nil
+	suite.mockWorkerRepositoryQuery.On("FindOneBankTicketProgress", mock.Anything, "id:id:code").Return(mockChannel(mockProgress))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		seats := make([]int, 0)
+		for _, ticket := range args.Get(1).([]entity.BankTicket) {
+			seats = append(seats, ticket.SeatNumber)
+		}
+		inserted = append(inserted, seats)
+	}).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), [][]int{{7, 8, 9}, {10}}, inserted)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "UpsertBankTicketProgress", mock.Anything, mock.MatchedBy(func(progress entity.BankTicketProgress) bool {
+		return progress.State == constants.GenerationCompleted && progress.Generated == 10 && progress.CompletedAt != nil
+	}))
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketResumesFromPrimaryCheckpoint() {
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:   "id",
+			EventId:    "id",
+			TotalQuota: 4,
+			Country: entity.Country{
+				Code: "code",
+			},
+		},
+	}
+
+	// the secondary has not replicated the last checkpoint yet, seats 1 and 2 are already inserted
+	mockPrimary := &mockcert.MongodbRepositoryQuery{}
+	mockPrimary.On("FindOneBankTicketProgress", mock.Anything, "id:id:code").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicketProgress{Id: "id:id:code", State: constants.GenerationFailed, LastSeatNumber: 2, Generated: 2, Total: 4},
+	}))
+	suite.mockWorkerRepositoryQuery.ExpectedCalls = nil
+	suite.mockWorkerRepositoryQuery.On("FindOneBankTicketProgress", mock.Anything, "id:id:code").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
+
+	inserted := make([][]int, 0)
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		seats := make([]int, 0)
+		for _, ticket := range args.Get(1).([]entity.BankTicket) {
+			seats = append(seats, ticket.SeatNumber)
+		}
+		inserted = append(inserted, seats)
+	}).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.pushesSeats(2)
+
+	usecase := uc.NewCommandUsecase(suite.mockWorkerRepositoryQuery, mockPrimary, suite.mockWorkerRepositoryCommand, suite.mockRedis, suite.mockLogger)
+	_, err := usecase.CreateBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), [][]int{{3, 4}}, inserted)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneBankTicketProgress", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketJobFailed() {
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertOneWorkerJob", mock.Anything, mock.MatchedBy(func(job entity.WorkerJob) bool {
+		return job.State == constants.JobQueued && job.Source == constants.JobSourceKafka && job.Type == constants.JobTypeCreateBankTicket
+	}))
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "UpdateOneWorkerJob", mock.Anything, mock.MatchedBy(func(job entity.WorkerJob) bool {
+		return job.State == constants.JobFailed && job.Error == "Price not found" && job.FinishedAt != nil
+	}))
+}
+
+func (suite *CommandUsecaseTestSuite) TestEnqueueBankTicketJob() {
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:    "id",
+			EventId:     "id",
+			TotalQuota:  4,
+			TicketPrice: 40,
+			Country: entity.Country{
+				Code: "code",
+			},
+		},
+		Error: nil,
+	}
+
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL:{id}", "SEAT-POOL-REBUILD:{id}"}, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewCmdResult(int64(4), nil))
+	job, err := suite.usecase.EnqueueBankTicketJob(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constants.JobQueued, job.State)
+	assert.Equal(suite.T(), constants.JobSourceHttp, job.Source)
+	assert.NotEmpty(suite.T(), job.Instance)
+
+	// Close waits for the background job
+	assert.NoError(suite.T(), suite.usecase.Close(suite.ctx))
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "UpdateOneWorkerJob", mock.Anything, mock.MatchedBy(func(updated entity.WorkerJob) bool {
+		return updated.Id == job.Id && updated.State == constants.JobSucceeded && updated.Progress.Generated == 4 && updated.Progress.Total == 4
+	}))
+}
+
+func (suite *CommandUsecaseTestSuite) TestFailAbandonedWorkerJob() {
+	configs.GetConfig().Worker.InstanceId = "worker-1"
+	configs.GetConfig().Worker.JobStaleAfter = "1h"
+	defer func() {
+		configs.GetConfig().Worker.InstanceId = ""
+		configs.GetConfig().Worker.JobStaleAfter = ""
+	}()
+	usecase := uc.NewCommandUsecase(suite.mockWorkerRepositoryQuery, suite.mockWorkerRepositoryQuery, suite.mockWorkerRepositoryCommand, suite.mockRedis, suite.mockLogger)
+
+	staleBefore := time.Now().Add(-time.Hour)
+	suite.mockWorkerRepositoryCommand.On("FailAllAbandonedWorkerJob", mock.Anything, "worker-1", mock.MatchedBy(func(before time.Time) bool {
+		return !before.Before(staleBefore) && before.Before(staleBefore.Add(time.Minute))
+	}), mock.Anything).Return(mockChannel(helpers.Result{Data: int64(2)}))
+
+	failed, err := usecase.FailAbandonedWorkerJob(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(2), failed)
+}
+
+func (suite *CommandUsecaseTestSuite) TestEnqueueBankTicketJobIdempotent() {
+	payload := request.CreateTicketReq{
+		TicketId:       "id",
+		EventId:        "id",
+		IdempotencyKey: "key",
+	}
+
+	mockJob := helpers.Result{
+		Data: &entity.WorkerJob{
+			Id:    "job",
+			State: constants.JobRunning,
+		},
+		Error: nil,
+	}
+
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockRedis.ExpectedCalls = nil
+	suite.mockRedis.On("SetNX", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewBoolResult(false, nil))
+	suite.mockRedis.On("Get", mock.Anything, "IDEMPOTENCY:workerUsecase-EnqueueBankTicketJob:key").Return(redis.NewStringResult("job", nil))
+	suite.mockWorkerRepositoryQuery.On("FindOneWorkerJob", mock.Anything, "job").Return(mockChannel(mockJob))
+	job, err := suite.usecase.EnqueueBankTicketJob(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "job", job.Id)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "InsertOneWorkerJob", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestEnqueueBankTicketJobErrInsert() {
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	suite.mockWorkerRepositoryCommand.ExpectedCalls = nil
+	suite.mockWorkerRepositoryCommand.On("InsertOneWorkerJob", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	_, err := suite.usecase.EnqueueBankTicketJob(suite.ctx, payload)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestEnqueueBankTicketJobErrInsertReleaseKey() {
+	payload := request.CreateTicketReq{
+		TicketId:       "id",
+		EventId:        "id",
+		IdempotencyKey: "key",
+	}
+
+	suite.mockRedis.ExpectedCalls = nil
+	suite.mockRedis.On("SetNX", mock.Anything, "IDEMPOTENCY:workerUsecase-EnqueueBankTicketJob:key", mock.Anything, mock.Anything).Return(redis.NewBoolResult(true, nil))
+	suite.mockRedis.On("Del", mock.Anything, "IDEMPOTENCY:workerUsecase-EnqueueBankTicketJob:key").Return(redis.NewIntResult(1, nil))
+	suite.mockWorkerRepositoryCommand.ExpectedCalls = nil
+	suite.mockWorkerRepositoryCommand.On("InsertOneWorkerJob", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	_, err := suite.usecase.EnqueueBankTicketJob(suite.ctx, payload)
+	assert.Error(suite.T(), err)
+	// the retry of the request is not answered with a job which was never saved
+	suite.mockRedis.AssertCalled(suite.T(), "Del", mock.Anything, "IDEMPOTENCY:workerUsecase-EnqueueBankTicketJob:key")
+}
+
+func (suite *CommandUsecaseTestSuite) TestEnqueueBankTicketJobIdempotentNotSaved() {
+	payload := request.CreateTicketReq{
+		TicketId:       "id",
+		EventId:        "id",
+		IdempotencyKey: "key",
+	}
+
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockRedis.ExpectedCalls = nil
+	suite.mockRedis.On("SetNX", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewBoolResult(false, nil))
+	suite.mockRedis.On("Get", mock.Anything, "IDEMPOTENCY:workerUsecase-EnqueueBankTicketJob:key").Return(redis.NewStringResult("job", nil))
+	suite.mockWorkerRepositoryQuery.On("FindOneWorkerJob", mock.Anything, "job").Return(mockChannel(helpers.Result{}))
+	_, err := suite.usecase.EnqueueBankTicketJob(suite.ctx, payload)
+	assert.Equal(suite.T(), errors.Conflict("job job is not saved yet"), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrInsertJob() {
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.ExpectedCalls = nil
+	suite.mockWorkerRepositoryCommand.On("InsertOneWorkerJob", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.Error(suite.T(), err)
+	// the message is redelivered instead of being generated without its job
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneTicketDetail", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrLocked() {
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:    "id",
+			EventId:     "event",
+			TicketType:  "Gold",
+			TotalQuota:  10,
+			TicketPrice: 40,
+			Country: entity.Country{
+				Code: "code",
+			},
+		},
+		Error: nil,
+	}
+
+	suite.mockRedis.ExpectedCalls = nil
+	suite.mockRedis.On("SetNX", mock.Anything, "BANK-TICKET-LOCK:event:Gold:code", mock.Anything, mock.Anything).Return(redis.NewBoolResult(false, nil))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
+	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), 409, err.(*errors.ErrorString).Code())
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketIdempotent() {
+	payload := request.CreateTicketReq{
+		TicketId:       "id",
+		EventId:        "id",
+		IdempotencyKey: "key",
+	}
+
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockRedis.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult(`{"result":"Success create bank ticket","code":200}`, nil))
+	resp, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Success create bank ticket", *resp)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneTicketDetail", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketIdempotentSave() {
+	payload := request.CreateTicketReq{
+		TicketId:       "id",
+		EventId:        "id",
+		IdempotencyKey: "key",
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:    "id",
+			TotalQuota:  0,
+			TicketPrice: 40,
+			Country: entity.Country{
+				Code: "code",
+			},
+		},
+		Error: nil,
+	}
+
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockRedis.On("Get", mock.Anything, mock.Anything).Return(redis.NewStringResult("", redis.Nil))
+	suite.mockRedis.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewStatusResult("OK", nil))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
 	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
 	assert.NoError(suite.T(), err)
+	suite.mockRedis.AssertCalled(suite.T(), "Set", mock.Anything, "IDEMPOTENCY:workerUsecase-CreateBankTicket:key",
+		[]byte(`{"result":"Bank ticket already completed","message":"","code":200}`), mock.Anything)
 }
 
-func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrCompleted() {
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketRedeliveredCompleted() {
 	payload := request.CreateTicketReq{
 		TicketId: "id",
 		EventId:  "id",
@@ -128,9 +556,14 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrCompleted() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
-	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
-	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	resp, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Bank ticket already completed", *resp)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "BulkInsertBankTicket", mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "UpdateOneWorkerJob", mock.Anything, mock.MatchedBy(func(job entity.WorkerJob) bool {
+		return job.State == constants.JobSucceeded
+	}))
 }
 
 func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrDetail() {
@@ -167,7 +600,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrDetail() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
 	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
 	assert.Error(suite.T(), err)
 }
@@ -199,7 +632,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrNilDetail() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
 	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
 	assert.Error(suite.T(), err)
 }
@@ -233,7 +666,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrParseDetail() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
 	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
 	assert.Error(suite.T(), err)
 }
@@ -272,7 +705,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrBank() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
 	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
 	assert.Error(suite.T(), err)
 }
@@ -310,7 +743,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrParseBank() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
 	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
 	assert.Error(suite.T(), err)
 }
@@ -349,9 +782,49 @@ func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrInsert() {
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
-	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockInsertManyTicket))
+	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
+	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "UpsertBankTicketProgress", mock.Anything, mock.MatchedBy(func(progress entity.BankTicketProgress) bool {
+		return progress.State == constants.GenerationFailed && progress.LastSeatNumber == 5
+	}))
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateBankTicketErrOutbox() {
+	payload := request.CreateTicketReq{
+		TicketId: "id",
+		EventId:  "id",
+	}
+
+	inTransaction := mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(txKey{}) != nil
+	})
+	suite.mockWorkerRepositoryCommand.On("WithTransaction", mock.Anything, mock.Anything).Unset()
+	suite.mockWorkerRepositoryCommand.On("WithTransaction", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(context.WithValue(ctx, txKey{}, true))
+	})
+	suite.mockWorkerRepositoryCommand.On("InsertManyOutboxEvent", mock.Anything, mock.Anything).Unset()
+	suite.mockWorkerRepositoryCommand.On("InsertManyOutboxEvent", inTransaction, mock.Anything).Return(mockChannel(helpers.Result{
+		Error: errors.InternalServerError("Error mongodb connection"),
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalQuota: 10, TicketPrice: 40, Country: entity.Country{Code: "code"}},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{TicketNumber: "5", SeatNumber: 5},
+	}))
+	suite.mockWorkerRepositoryCommand.On("BulkInsertBankTicket", inTransaction, mock.Anything).Return(mockChannel(helpers.Result{}))
+
+	// the chunk is rolled back with its event, so the failed run resumes from the seat before it
 	_, err := suite.usecase.CreateBankTicket(suite.ctx, payload)
 	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "UpsertBankTicketProgress", inTransaction, mock.MatchedBy(func(progress entity.BankTicketProgress) bool {
+		return progress.LastSeatNumber == 10
+	}))
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "UpsertBankTicketProgress", mock.Anything, mock.MatchedBy(func(progress entity.BankTicketProgress) bool {
+		return progress.State == constants.GenerationFailed && progress.LastSeatNumber == 5
+	}))
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPayment() {
@@ -397,20 +870,31 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPayment() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
 	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), resp.Scanned, resp.Reclaimed)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertManyOutboxEvent", mock.Anything,
+		savedEvent(constants.EventPaymentExpired, "id", func(expired dto.PaymentExpired) bool {
+			return expired.TicketNumber == "1"
+		}))
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertManyOutboxEvent", mock.Anything,
+		savedEvent(constants.EventTicketReleased, "id", func(released dto.TicketReleased) bool {
+			return released.Reason == constants.ReleaseExpiredPayment
+		}))
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrHistory() {
@@ -456,18 +940,20 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrHistory() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
 	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
 	assert.Error(suite.T(), err)
 }
@@ -506,18 +992,20 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrNilHistory()
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
 	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
 	assert.Error(suite.T(), err)
 }
@@ -563,18 +1051,20 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrParseHistory(
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
 	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
 	assert.Error(suite.T(), err)
 }
@@ -613,20 +1103,23 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrEmpty() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
 	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Scanned)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrTicket() {
@@ -672,20 +1165,24 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrTicket() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrNilTicket() {
@@ -724,20 +1221,24 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrNilTicket() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrParseTicket() {
@@ -778,20 +1279,24 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrParseTicket()
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrUpdatePayment() {
@@ -837,20 +1342,25 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrUpdatePayment
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "InsertManyOutboxEvent", mock.Anything, mock.Anything)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrDeleteOrder() {
@@ -896,20 +1406,24 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrDeleteOrder()
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrUpdateBank() {
@@ -955,20 +1469,24 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrUpdateBank()
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrTotalRemaining() {
@@ -1014,21 +1532,77 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrTotalRemainin
 		Error: nil,
 	}
 
+	// the inventory guard rejects the increment
 	mockUpdateTicketDetail := helpers.Result{
 		Data:  nil,
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	assert.Equal(suite.T(), "totalRemaining full", resp.Failures[0].Reason)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentTransaction() {
+	mockPaymentHistory := helpers.Result{
+		Data: &[]entity.PaymentHistory{
+			{
+				PaymentId:      "id",
+				IsValidPayment: true,
+				Ticket: &entity.Ticket{
+					TicketNumber: "1",
+					TicketId:     "id",
+				},
+			},
+		},
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:       "id",
+			TotalQuota:     10,
+			TicketPrice:    40,
+			TotalRemaining: 5,
+		},
+	}
+
+	inTransaction := mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(txKey{}) != nil
+	})
+	suite.mockWorkerRepositoryCommand.ExpectedCalls = nil
+	suite.mockWorkerRepositoryCommand.On("WithTransaction", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(context.WithValue(ctx, txKey{}, true))
+	})
+	suite.mockWorkerRepositoryCommand.On("InsertOneWorkerJobRun", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", inTransaction, "id").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", inTransaction, "1").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", inTransaction, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", inTransaction, "id", 1).Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalRemaining: 6},
+	}))
+	suite.mockWorkerRepositoryCommand.On("InsertManyOutboxEvent", inTransaction, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), resp.Scanned, resp.Reclaimed)
+	suite.mockWorkerRepositoryCommand.AssertExpectations(suite.T())
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrUpdateTicketDetail() {
@@ -1078,16 +1652,20 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrUpdateTicketD
 		Error: errors.BadRequest("error"),
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, mock.Anything).Return(mockChannel(mockUpdatePayment))
 	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(mockChannel(mockDeleteOrder))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicket() {
@@ -1124,19 +1702,28 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicket() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
 	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), resp.Scanned, resp.Reclaimed)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertManyOutboxEvent", mock.Anything,
+		savedEvent(constants.EventTicketReleased, "id", func(released dto.TicketReleased) bool {
+			return released.TicketNumber == "1" && released.Reason == constants.ReleaseExpiredBankTicket
+		}))
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:id:code"}, int64(1), int64(10))
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL:{id}", "SEAT-POOL-REBUILD:{id}"}, "1")
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErr() {
@@ -1173,17 +1760,19 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErr() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
 	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
 	assert.Error(suite.T(), err)
 }
@@ -1217,17 +1806,19 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrNil() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
 	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
 	assert.Error(suite.T(), err)
 }
@@ -1264,17 +1855,19 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrParse() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
 	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
 	assert.Error(suite.T(), err)
 }
@@ -1308,19 +1901,22 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrEmpty() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
 	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Scanned)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrPayment() {
@@ -1357,19 +1953,23 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrPayment()
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketExistPayment() {
@@ -1408,19 +2008,22 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketExistPayment(
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
 	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Skipped)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrDetail() {
@@ -1457,19 +2060,23 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrDetail() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrNilDetail() {
@@ -1499,19 +2106,23 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrNilDetail(
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrParseDetail() {
@@ -1543,19 +2154,23 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrParseDetai
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrUpdate() {
@@ -1592,19 +2207,23 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrUpdate() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrTotalRemaining() {
@@ -1641,20 +2260,26 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrTotalRemai
 		Error: nil,
 	}
 
+	// the inventory guard rejects the increment
 	mockUpdateTicketDetail := helpers.Result{
 		Data:  nil,
 		Error: nil,
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	assert.Equal(suite.T(), "totalRemaining full", resp.Failures[0].Reason)
 }
 
 func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrUpdateDetail() {
@@ -1695,18 +2320,23 @@ func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrUpdateDeta
 		Error: errors.BadRequest("error"),
 	}
 
-	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything).Return(mockChannel(mockBankTicket))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockBankTicket))
 	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
 	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
 	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateBankTicket))
-	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailById", mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockUpdateTicketDetail))
 	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
 
-	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
-	assert.Error(suite.T(), err)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
 }
 
 func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicket() {
+	suite.pushesSeats(395)
 	payload := request.CreateOnlineTicketReq{
 		Tag:         "tag",
 		CountryCode: "code",
@@ -1720,22 +2350,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicket() {
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -1786,7 +2412,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicket() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -1828,6 +2454,239 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicket() {
 
 	_, err := suite.usecase.CreateOnlineBankTicket(suite.ctx, payload)
 	assert.NoError(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertNumberOfCalls(suite.T(), "UpdateOnlineTicketConfig", 4)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrLocked() {
+	payload := request.CreateOnlineTicketReq{
+		Tag: "tag",
+	}
+
+	mockOnlineTicketConfig := helpers.Result{
+		Data: &entity.OnlineTicketConfig{
+			Tag:         "tag",
+			TotalQuota:  10,
+			CountryList: []entity.CountryList{{CountryNumber: 1, Percentage: 100}},
+		},
+	}
+
+	mockTotalAvailableTicket := helpers.Result{
+		Data: &[]entity.AggregateTotalTicket{{Id: "c1", TotalTicket: 100}},
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:   "id",
+			EventId:    "event",
+			Tag:        "tag",
+			TicketType: constants.Online,
+		},
+	}
+
+	// online seats are numbered after the last one of the country, like the seats created by CreateBankTicket
+	suite.mockRedis.ExpectedCalls = nil
+	suite.mockRedis.On("SetNX", mock.Anything, "BANK-TICKET-LOCK:event:Online:c1", mock.Anything, mock.Anything).Return(redis.NewBoolResult(false, nil))
+	suite.mockWorkerRepositoryQuery.On("FindOnlineTicketConfigByTag", mock.Anything, "tag").Return(mockChannel(mockOnlineTicketConfig))
+	suite.mockWorkerRepositoryQuery.On("FindTotalAvalailableTicket", mock.Anything, "tag").Return(mockChannel(mockTotalAvailableTicket))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailByTag", mock.Anything, mock.Anything).Return(mockChannel(mockTicketDetail))
+
+	_, err := suite.usecase.CreateOnlineBankTicket(suite.ctx, payload)
+	assert.Equal(suite.T(), errors.Conflict("create bank ticket already in progress"), err)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketManyCountries() {
+	suite.pushesSeats(2)
+	payload := request.CreateOnlineTicketReq{
+		Tag: "tag",
+	}
+
+	countryList := make([]entity.CountryList, 0)
+	ticketAvailable := make([]entity.AggregateTotalTicket, 0)
+	for i, percentage := range []int{20, 16, 16, 16, 16, 16} {
+		countryList = append(countryList, entity.CountryList{
+			CountryNumber: i + 1,
+			Percentage:    percentage,
+		})
+		ticketAvailable = append(ticketAvailable, entity.AggregateTotalTicket{
+			Id:          fmt.Sprintf("c%d", i+1),
+			TotalTicket: 100,
+		})
+	}
+
+	mockOnlineTicketConfig := helpers.Result{
+		Data: &entity.OnlineTicketConfig{
+			Tag:         "tag",
+			TotalQuota:  7,
+			CountryList: countryList,
+		},
+		Error: nil,
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:   "id",
+			EventId:    "id",
+			Tag:        "tag",
+			TicketType: constants.Online,
+		},
+		Error: nil,
+	}
+
+	quotas := make(map[string]int)
+	suite.mockWorkerRepositoryQuery.On("FindOnlineTicketConfigByTag", mock.Anything, mock.Anything).Return(mockChannel(mockOnlineTicketConfig))
+	suite.mockWorkerRepositoryQuery.On("FindTotalAvalailableTicket", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &ticketAvailable}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailByTag", mock.Anything, mock.Anything).Return(func(ctx context.Context, payload request.TicketDetailByTagReq) <-chan helpers.Result {
+		return mockChannel(mockTicketDetail)
+	})
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("UpdateOnlineTicketConfig", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailByTag", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args.Get(1).(request.UpdateTicketDetailReq)
+		quotas[req.CountryCode] = req.TotalQuota
+	}).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.CreateOnlineBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]int{"c1": 2, "c2": 1, "c3": 1, "c4": 1, "c5": 1, "c6": 1}, quotas)
+	suite.mockWorkerRepositoryCommand.AssertNumberOfCalls(suite.T(), "InsertManyOutboxEvent", 6)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertManyOutboxEvent", mock.Anything,
+		savedEvent(constants.EventBankTicketGenerated, "id", func(generated dto.BankTicketGenerated) bool {
+			return generated.FirstSeatNumber == 1 && generated.LastSeatNumber == 2 && generated.Total == 2
+		}))
+	// the two new seats are added to the counter
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:id:c1"}, int64(2), int64(2))
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrPercentage() {
+	payload := request.CreateOnlineTicketReq{
+		Tag: "tag",
+	}
+
+	mockOnlineTicketConfig := helpers.Result{
+		Data: &entity.OnlineTicketConfig{
+			Tag:        "tag",
+			TotalQuota: 100,
+			CountryList: []entity.CountryList{
+				{
+					CountryNumber: 1,
+					Percentage:    50,
+				},
+				{
+					CountryNumber: 2,
+					Percentage:    40,
+				},
+			},
+		},
+		Error: nil,
+	}
+
+	mockTotalAvailableTicket := helpers.Result{
+		Data: &[]entity.AggregateTotalTicket{
+			{
+				Id:          "c1",
+				TotalTicket: 100,
+			},
+		},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindOnlineTicketConfigByTag", mock.Anything, mock.Anything).Return(mockChannel(mockOnlineTicketConfig))
+	suite.mockWorkerRepositoryQuery.On("FindTotalAvalailableTicket", mock.Anything, mock.Anything).Return(mockChannel(mockTotalAvailableTicket))
+
+	_, err := suite.usecase.CreateOnlineBankTicket(suite.ctx, payload)
+	assert.EqualError(suite.T(), err, "country list percentage must sum to 100, got 90")
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketWaitingQueue() {
+	suite.pushesSeats(6)
+	payload := request.CreateOnlineTicketReq{
+		Tag: "tag",
+	}
+
+	mockOnlineTicketConfig := helpers.Result{
+		Data: &entity.OnlineTicketConfig{
+			Tag:        "tag",
+			TotalQuota: 10,
+			Strategy:   constants.AllocationWaitingQueue,
+			CountryList: []entity.CountryList{
+				{CountryNumber: 1},
+				{CountryNumber: 2},
+				{CountryNumber: 3},
+			},
+		},
+	}
+
+	mockTotalAvailableTicket := helpers.Result{
+		Data: &[]entity.AggregateTotalTicket{
+			{Id: "c1", TotalTicket: 100},
+			{Id: "c2", TotalTicket: 100},
+			{Id: "c3", TotalTicket: 100},
+		},
+	}
+
+	mockTicketDetail := helpers.Result{
+		Data: &entity.TicketDetail{
+			TicketId:   "id",
+			EventId:    "id",
+			Tag:        "tag",
+			TicketType: constants.Online,
+		},
+	}
+
+	quotas := make(map[string]int)
+	suite.mockRedis.On("Get", mock.Anything, "WAITING-QUEUE:tag:c1").Return(redis.NewStringResult("30", nil))
+	suite.mockRedis.On("Get", mock.Anything, "WAITING-QUEUE:tag:c2").Return(redis.NewStringResult("", redis.Nil))
+	suite.mockRedis.On("Get", mock.Anything, "WAITING-QUEUE:tag:c3").Return(redis.NewStringResult("20", nil))
+	suite.mockWorkerRepositoryQuery.On("FindOnlineTicketConfigByTag", mock.Anything, mock.Anything).Return(mockChannel(mockOnlineTicketConfig))
+	suite.mockWorkerRepositoryQuery.On("FindTotalAvalailableTicket", mock.Anything, mock.Anything).Return(mockChannel(mockTotalAvailableTicket))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailByTag", mock.Anything, mock.Anything).Return(func(ctx context.Context, payload request.TicketDetailByTagReq) <-chan helpers.Result {
+		return mockChannel(mockTicketDetail)
+	})
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything).Return(func(ctx context.Context, countryCode, ticketType, eventId, collection string) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryCommand.On("InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything).Return(func(ctx context.Context, collection string, tickets []entity.BankTicket) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryCommand.On("UpdateOnlineTicketConfig", mock.Anything, mock.Anything).Return(func(ctx context.Context, payload request.UpdateOnlineTicketConfigReq) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailByTag", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args.Get(1).(request.UpdateTicketDetailReq)
+		quotas[req.CountryCode] = req.TotalQuota
+	}).Return(func(ctx context.Context, payload request.UpdateTicketDetailReq) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.CreateOnlineBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]int{"c1": 6, "c3": 4}, quotas)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrStrategy() {
+	payload := request.CreateOnlineTicketReq{
+		Tag: "tag",
+	}
+
+	mockOnlineTicketConfig := helpers.Result{
+		Data: &entity.OnlineTicketConfig{
+			Tag:        "tag",
+			TotalQuota: 10,
+			Strategy:   "random",
+		},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindOnlineTicketConfigByTag", mock.Anything, mock.Anything).Return(mockChannel(mockOnlineTicketConfig))
+
+	_, err := suite.usecase.CreateOnlineBankTicket(suite.ctx, payload)
+	assert.EqualError(suite.T(), err, "allocation strategy random is unknown")
 }
 
 func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrConfig() {
@@ -1910,7 +2769,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrConfig() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2009,7 +2868,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrNil() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2110,7 +2969,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrParse() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2235,7 +3094,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrTotal() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2339,7 +3198,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrNilTotal() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2447,7 +3306,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrParseTotal()
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2492,6 +3351,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrParseTotal()
 }
 
 func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketSkip() {
+	suite.pushesSeats(195, 200)
 	payload := request.CreateOnlineTicketReq{
 		Tag:         "tag",
 		CountryCode: "code",
@@ -2571,7 +3431,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketSkip() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2629,22 +3489,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrExist() {
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -2695,7 +3551,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrExist() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2798,7 +3654,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrEmpty() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2856,22 +3712,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrDetail() {
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -2922,7 +3774,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrDetail() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -2980,22 +3832,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrNilDetail() {
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -3046,7 +3894,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrNilDetail() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -3092,22 +3940,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrParseDetail()
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -3158,7 +4002,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrParseDetail()
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -3206,22 +4050,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrLast() {
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -3272,7 +4112,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrLast() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -3330,22 +4170,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrParseLast() {
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -3395,7 +4231,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrParseLast() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -3453,22 +4289,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrInsert() {
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -3519,7 +4351,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrInsert() {
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -3577,22 +4409,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrUpdateOnline(
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -3643,7 +4471,7 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrUpdateOnline(
 	}
 
 	mockUpdateTicketDetail := helpers.Result{
-		Data:  nil,
+		Data:  &entity.TicketDetail{},
 		Error: nil,
 	}
 
@@ -3702,22 +4530,18 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrUpdateDetail(
 				{
 					CountryNumber: 1,
 					Percentage:    40,
-					CountryCode:   "c1",
 				},
 				{
 					CountryNumber: 2,
 					Percentage:    20,
-					CountryCode:   "c2",
 				},
 				{
 					CountryNumber: 3,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 				{
 					CountryNumber: 4,
 					Percentage:    20,
-					CountryCode:   "c3",
 				},
 			},
 		},
@@ -3812,3 +4636,968 @@ func (suite *CommandUsecaseTestSuite) TestCreateOnlineBankTicketErrUpdateDetail(
 	_, err := suite.usecase.CreateOnlineBankTicket(suite.ctx, payload)
 	assert.Error(suite.T(), err)
 }
+
+func (suite *CommandUsecaseTestSuite) TestSimulateOnlineBankTicket() {
+	payload := request.SimulateOnlineTicketReq{
+		Tag: "tag",
+	}
+
+	mockOnlineTicketConfig := helpers.Result{
+		Data: &entity.OnlineTicketConfig{
+			Tag:        "tag",
+			TotalQuota: 10,
+			CountryList: []entity.CountryList{
+				{CountryNumber: 1, Percentage: 60},
+				{CountryNumber: 2, Percentage: 40},
+			},
+		},
+	}
+
+	mockTotalAvailableTicket := helpers.Result{
+		Data: &[]entity.AggregateTotalTicket{
+			{Id: "c1", TotalTicket: 100},
+			{Id: "c2", TotalTicket: 100, TotalAvailableTicket: 10},
+		},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindOnlineTicketConfigByTag", mock.Anything, "tag").Return(mockChannel(mockOnlineTicketConfig))
+	suite.mockWorkerRepositoryQuery.On("FindTotalAvalailableTicket", mock.Anything, "tag").Return(mockChannel(mockTotalAvailableTicket))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailByTag", mock.Anything, mock.Anything).Return(func(ctx context.Context, payload request.TicketDetailByTagReq) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &entity.TicketDetail{
+			TicketId:       "ticket-" + payload.CountryCode,
+			EventId:        "event",
+			TotalQuota:     2,
+			TotalRemaining: 1,
+		}})
+	})
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, "c1", constants.Online, "event", "bank-ticket").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{SeatNumber: 2},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, "c2", constants.Online, "event", "bank-ticket").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{SeatNumber: 4},
+	}))
+
+	resp, err := suite.usecase.SimulateOnlineBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), &dto.OnlineTicketSimulation{
+		Tag:        "tag",
+		Strategy:   constants.AllocationRankBySellout,
+		TotalQuota: 10,
+		Countries: []dto.OnlineTicketCountryPlan{
+			{
+				CountryCode:     "c1",
+				CountryNumber:   1,
+				EventId:         "event",
+				TicketId:        "ticket-c1",
+				FirstSeatNumber: 3,
+				LastSeatNumber:  6,
+				TotalSeat:       4,
+				TotalQuota:      6,
+				TotalRemaining:  5,
+			},
+			{
+				CountryCode:    "c2",
+				CountryNumber:  2,
+				EventId:        "event",
+				TicketId:       "ticket-c2",
+				TotalQuota:     4,
+				TotalRemaining: 3,
+			},
+		},
+	}, resp)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "InsertManyTicketCollection", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "UpdateOnlineTicketConfig", mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "UpdateTicketDetailByTag", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestSimulateOnlineBankTicketOverride() {
+	payload := request.SimulateOnlineTicketReq{
+		Tag: "tag",
+		Config: &request.OnlineTicketConfigReq{
+			TotalQuota: 5,
+			Strategy:   constants.AllocationFixedQuota,
+			CountryList: []request.CountryList{
+				{CountryNumber: 1, CountryCode: "c2", Quota: 5},
+			},
+		},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindTotalAvalailableTicket", mock.Anything, "tag").Return(mockChannel(helpers.Result{
+		Data: &[]entity.AggregateTotalTicket{{Id: "c1", TotalTicket: 100}},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailByTag", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "ticket", EventId: "event"},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindOneLastTicket", mock.Anything, "c2", constants.Online, "event", "bank-ticket").Return(mockChannel(helpers.Result{}))
+
+	resp, err := suite.usecase.SimulateOnlineBankTicket(suite.ctx, payload)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constants.AllocationFixedQuota, resp.Strategy)
+	assert.Equal(suite.T(), []dto.OnlineTicketCountryPlan{{
+		CountryCode:     "c2",
+		CountryNumber:   1,
+		EventId:         "event",
+		TicketId:        "ticket",
+		FirstSeatNumber: 1,
+		LastSeatNumber:  5,
+		TotalSeat:       5,
+		TotalQuota:      5,
+		TotalRemaining:  5,
+	}}, resp.Countries)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOnlineTicketConfigByTag", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestSimulateOnlineBankTicketErrShrink() {
+	payload := request.SimulateOnlineTicketReq{
+		Tag: "tag",
+		Config: &request.OnlineTicketConfigReq{
+			TotalQuota: 5,
+			Strategy:   constants.AllocationFixedQuota,
+			CountryList: []request.CountryList{
+				{CountryNumber: 1, CountryCode: "c1", Quota: 5},
+			},
+		},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindTotalAvalailableTicket", mock.Anything, "tag").Return(mockChannel(helpers.Result{
+		Data: &[]entity.AggregateTotalTicket{{Id: "c1", TotalTicket: 100}},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailByTag", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "ticket", EventId: "event", TotalQuota: 8, TotalRemaining: 2},
+	}))
+
+	_, err := suite.usecase.SimulateOnlineBankTicket(suite.ctx, payload)
+	assert.EqualError(suite.T(), err, "online quota of country c1 cannot shrink from 8 to 5")
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneLastTicket", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestSimulateOnlineBankTicketErrConfig() {
+	suite.mockWorkerRepositoryQuery.On("FindOnlineTicketConfigByTag", mock.Anything, "tag").Return(mockChannel(helpers.Result{}))
+
+	_, err := suite.usecase.SimulateOnlineBankTicket(suite.ctx, request.SimulateOnlineTicketReq{Tag: "tag"})
+	assert.EqualError(suite.T(), err, "ticket config not found")
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentIsolatesFailures() {
+	mockPaymentHistory := helpers.Result{
+		Data: &[]entity.PaymentHistory{
+			{PaymentId: "bad", Ticket: &entity.Ticket{TicketNumber: "1", TicketId: "missing"}},
+			{PaymentId: "good", Ticket: &entity.Ticket{TicketNumber: "2", TicketId: "id"}},
+		},
+	}
+
+	var saved entity.WorkerJobRun
+	suite.mockWorkerRepositoryCommand.ExpectedCalls = nil
+	suite.mockWorkerRepositoryCommand.On("WithTransaction", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	})
+	suite.mockWorkerRepositoryCommand.On("InsertOneWorkerJobRun", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(entity.WorkerJobRun)
+	}).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(mockPaymentHistory))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "missing").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalQuota: 10, TotalRemaining: 5},
+	}))
+	suite.mockWorkerRepositoryCommand.On("UpdateOnePayment", mock.Anything, "good").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("InsertManyOutboxEvent", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, "2").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, "id", 1).Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalRemaining: 6},
+	}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	ctx := scheduler.WithInstance(scheduler.WithFence(suite.ctx, 7), "pod-1")
+	resp, err := suite.usecase.UpdateAllExpiryPayment(ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constants.JobTypeExpiryPayment, resp.Job)
+	assert.Equal(suite.T(), constants.RunPartial, resp.Status)
+	assert.Equal(suite.T(), "pod-1", resp.Instance)
+	assert.Equal(suite.T(), int64(7), resp.Fence)
+	assert.Equal(suite.T(), 2, resp.Scanned)
+	assert.Equal(suite.T(), 1, resp.Reclaimed)
+	assert.Equal(suite.T(), []entity.RunFailure{{Id: "bad", Reason: "ticket not found"}}, resp.Failures)
+	assert.Equal(suite.T(), *resp, saved)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketPages() {
+	configs.GetConfig().Worker.ExpiryBatchSize = "2"
+	defer func() { configs.GetConfig().Worker.ExpiryBatchSize = "" }()
+
+	createdAt := time.Now().Add(-time.Hour)
+	firstPage := []entity.BankTicket{
+		{Id: primitive.NewObjectID(), TicketNumber: "1", TicketId: "id", CreatedAt: createdAt},
+		{Id: primitive.NewObjectID(), TicketNumber: "2", TicketId: "id", CreatedAt: createdAt},
+	}
+	secondPage := []entity.BankTicket{
+		{Id: primitive.NewObjectID(), TicketNumber: "3", TicketId: "id", CreatedAt: createdAt},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, request.KeysetPageReq{Size: 2}, mock.Anything).Return(mockChannel(helpers.Result{Data: &firstPage}))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, request.KeysetPageReq{
+		AfterCreatedAt: createdAt,
+		AfterId:        firstPage[1].Id,
+		Size:           2,
+	}, mock.Anything).Return(mockChannel(helpers.Result{Data: &secondPage}))
+	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(func(ctx context.Context, ticketNumber string) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(func(ctx context.Context, ticketId string) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &entity.TicketDetail{TicketId: "id", TotalQuota: 10}})
+	})
+	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(func(ctx context.Context, payload request.UpdateBankTicketRequest) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, "id", 1).Return(func(ctx context.Context, ticketId string, delta int) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &entity.TicketDetail{TicketId: "id"}})
+	})
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, resp.Scanned)
+	assert.Equal(suite.T(), 3, resp.Reclaimed)
+	assert.False(suite.T(), resp.TimedOut)
+	assert.Equal(suite.T(), constants.RunSucceeded, resp.Status)
+	suite.mockWorkerRepositoryQuery.AssertNumberOfCalls(suite.T(), "FindAllExpireBankTicket", 2)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketTimeBudget() {
+	configs.GetConfig().Worker.ExpiryBatchSize = "1"
+	configs.GetConfig().Worker.ExpiryTimeBudget = "1ns"
+	defer func() {
+		configs.GetConfig().Worker.ExpiryBatchSize = ""
+		configs.GetConfig().Worker.ExpiryTimeBudget = ""
+	}()
+
+	page := []entity.BankTicket{{Id: primitive.NewObjectID(), TicketNumber: "1", TicketId: "id"}}
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &entity.PaymentHistory{}}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), resp.TimedOut)
+	assert.Equal(suite.T(), constants.RunTimedOut, resp.Status)
+	assert.Equal(suite.T(), 1, resp.Skipped)
+	suite.mockWorkerRepositoryQuery.AssertNumberOfCalls(suite.T(), "FindAllExpireBankTicket", 1)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketCanceled() {
+	page := []entity.BankTicket{
+		{Id: primitive.NewObjectID(), TicketNumber: "1", TicketId: "id"},
+		{Id: primitive.NewObjectID(), TicketNumber: "2", TicketId: "id"},
+	}
+	ctx, cancel := context.WithCancel(suite.ctx)
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, "1").Run(func(args mock.Arguments) {
+		cancel()
+	}).Return(mockChannel(helpers.Result{Data: &entity.PaymentHistory{}}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(ctx)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), resp.TimedOut)
+	assert.Equal(suite.T(), 2, resp.Scanned)
+	assert.Equal(suite.T(), 1, resp.Skipped)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindPaymentByTicketNumber", mock.Anything, "2")
+	suite.mockWorkerRepositoryQuery.AssertNumberOfCalls(suite.T(), "FindAllExpireBankTicket", 1)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentCanceled() {
+	payments := []entity.PaymentHistory{
+		{PaymentId: "1", Ticket: &entity.Ticket{TicketNumber: "1", TicketId: "missing"}},
+		{PaymentId: "2", Ticket: &entity.Ticket{TicketNumber: "2", TicketId: "id"}},
+	}
+	ctx, cancel := context.WithCancel(suite.ctx)
+	suite.mockWorkerRepositoryQuery.On("FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &payments}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "missing").Run(func(args mock.Arguments) {
+		cancel()
+	}).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryPayment(ctx)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), resp.TimedOut)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneTicketDetailById", mock.Anything, "id")
+	suite.mockWorkerRepositoryQuery.AssertNumberOfCalls(suite.T(), "FindAllExpirePayment", 1)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketPolicies() {
+	policies := []entity.ExpiryPolicy{{EventId: "event", WindowMinutes: 60}}
+	suite.mockWorkerRepositoryQuery.ExpectedCalls = nil
+	suite.mockWorkerRepositoryQuery.On("FindAllExpiryPolicy", mock.Anything).Return(func(ctx context.Context) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &policies})
+	})
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, policies).Return(func(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &[]entity.BankTicket{}})
+	})
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	_, err = suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	// the second run reads the cached policies
+	suite.mockWorkerRepositoryQuery.AssertNumberOfCalls(suite.T(), "FindAllExpiryPolicy", 1)
+	suite.mockWorkerRepositoryQuery.AssertNumberOfCalls(suite.T(), "FindAllExpireBankTicket", 2)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryPaymentErrPolicy() {
+	suite.mockWorkerRepositoryQuery.ExpectedCalls = nil
+	suite.mockWorkerRepositoryQuery.On("FindAllExpiryPolicy", mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.UpdateAllExpiryPayment(suite.ctx)
+	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindAllExpirePayment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpsertExpiryPolicy() {
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(func(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &[]entity.BankTicket{}})
+	})
+	suite.mockWorkerRepositoryCommand.On("UpsertExpiryPolicy", mock.Anything, mock.MatchedBy(func(policy entity.ExpiryPolicy) bool {
+		return policy.Id == "*:VIP:*" && policy.TicketType == "VIP" && policy.WindowMinutes == 30
+	})).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+
+	policy, err := suite.usecase.UpsertExpiryPolicy(suite.ctx, request.UpsertExpiryPolicyReq{TicketType: "VIP", WindowMinutes: 30})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "*:VIP:*", policy.Id)
+
+	// the changed policy is read by the next run
+	_, err = suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	suite.mockWorkerRepositoryQuery.AssertNumberOfCalls(suite.T(), "FindAllExpiryPolicy", 2)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpsertExpiryPolicyErrWindow() {
+	_, err := suite.usecase.UpsertExpiryPolicy(suite.ctx, request.UpsertExpiryPolicyReq{EventId: "event"})
+	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "UpsertExpiryPolicy", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpsertExpiryPolicyErr() {
+	suite.mockWorkerRepositoryCommand.On("UpsertExpiryPolicy", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+
+	_, err := suite.usecase.UpsertExpiryPolicy(suite.ctx, request.UpsertExpiryPolicyReq{WindowMinutes: 15})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestDeleteExpiryPolicy() {
+	suite.mockWorkerRepositoryCommand.On("DeleteOneExpiryPolicy", mock.Anything, "event:*:*").Return(mockChannel(helpers.Result{Count: 1}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	err := suite.usecase.DeleteExpiryPolicy(suite.ctx, "event:*:*")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestDeleteExpiryPolicyNotFound() {
+	suite.mockWorkerRepositoryCommand.On("DeleteOneExpiryPolicy", mock.Anything, "event:*:*").Return(mockChannel(helpers.Result{}))
+
+	err := suite.usecase.DeleteExpiryPolicy(suite.ctx, "event:*:*")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpsertCronJob() {
+	suite.mockWorkerRepositoryCommand.On("UpsertCronJob", mock.Anything, mock.MatchedBy(func(job entity.CronJob) bool {
+		return job.Name == "expiry-payment" && !job.UpdatedAt.IsZero()
+	})).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	err := suite.usecase.UpsertCronJob(suite.ctx, entity.CronJob{Name: "expiry-payment", Spec: "*/5 * * * *"})
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpsertCronJobErr() {
+	suite.mockWorkerRepositoryCommand.On("UpsertCronJob", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+
+	err := suite.usecase.UpsertCronJob(suite.ctx, entity.CronJob{Name: "expiry-payment"})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketErrOutbox() {
+	bankTickets := []entity.BankTicket{{TicketNumber: "1", TicketId: "id"}}
+	suite.mockWorkerRepositoryCommand.On("InsertManyOutboxEvent", mock.Anything, mock.Anything).Unset()
+	suite.mockWorkerRepositoryCommand.On("InsertManyOutboxEvent", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Error: errors.InternalServerError("Error mongodb connection"),
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &bankTickets}))
+	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, "1").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalQuota: 10, TotalRemaining: 5},
+	}))
+	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, "id", 1).Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalRemaining: 6},
+	}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	// the seat is released with its event or not at all
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	assert.Equal(suite.T(), 1, resp.Failed)
+}
+
+func (suite *CommandUsecaseTestSuite) TestClaimOutboxEvent() {
+	until := time.Now().Add(time.Minute)
+	suite.mockWorkerRepositoryCommand.On("ClaimOutboxEvent", mock.Anything, "event", until).Return(mockChannel(helpers.Result{
+		Data: &entity.OutboxEvent{Id: "event", Attempts: 1},
+	}))
+
+	claimed, err := suite.usecase.ClaimOutboxEvent(suite.ctx, "event", until)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), claimed)
+}
+
+func (suite *CommandUsecaseTestSuite) TestClaimOutboxEventNotDue() {
+	suite.mockWorkerRepositoryCommand.On("ClaimOutboxEvent", mock.Anything, "event", mock.Anything).Return(mockChannel(helpers.Result{}))
+
+	claimed, err := suite.usecase.ClaimOutboxEvent(suite.ctx, "event", time.Now())
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), claimed)
+}
+
+func (suite *CommandUsecaseTestSuite) TestClaimOutboxEventErr() {
+	suite.mockWorkerRepositoryCommand.On("ClaimOutboxEvent", mock.Anything, "event", mock.Anything).Return(mockChannel(helpers.Result{
+		Error: errors.InternalServerError("error"),
+	}))
+
+	_, err := suite.usecase.ClaimOutboxEvent(suite.ctx, "event", time.Now())
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateOutboxEventSent() {
+	suite.mockWorkerRepositoryCommand.On("UpdateOneOutboxEventSent", mock.Anything, "event").Return(mockChannel(helpers.Result{}))
+
+	err := suite.usecase.UpdateOutboxEventSent(suite.ctx, "event")
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateOutboxEventRetry() {
+	next := time.Now().Add(time.Second)
+	suite.mockWorkerRepositoryCommand.On("UpdateOneOutboxEventRetry", mock.Anything, "event", next, "broker down").Return(mockChannel(helpers.Result{
+		Error: errors.InternalServerError("error"),
+	}))
+
+	err := suite.usecase.UpdateOutboxEventRetry(suite.ctx, "event", next, "broker down")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *CommandUsecaseTestSuite) TestUpdateAllExpiryBankTicketInventoryNotSeeded() {
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:id:code"}, int64(1), int64(10)).
+		Return(redis.NewCmdResult(int64(-1), nil))
+	suite.pushesSeats(1)
+	page := []entity.BankTicket{{TicketNumber: "1", TicketId: "id"}}
+	suite.mockWorkerRepositoryQuery.On("FindAllExpireBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalQuota: 10, Country: entity.Country{Code: "code"}},
+	}))
+	suite.mockWorkerRepositoryCommand.On("UpdateOneBankTicket", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, "id", 1).Return(mockChannel(helpers.Result{Data: &entity.TicketDetail{}}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.UpdateAllExpiryBankTicket(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Reclaimed)
+	assert.Equal(suite.T(), constants.RunSucceeded, resp.Status)
+	// the increment of the missing counter and the push of the seat
+	suite.mockRedis.AssertNumberOfCalls(suite.T(), "EvalSha", 2)
+	suite.mockLogger.AssertNotCalled(suite.T(), "Error", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileInventory() {
+	configs.GetConfig().Worker.InventoryBatchSize = "2"
+	defer func() { configs.GetConfig().Worker.InventoryBatchSize = "" }()
+
+	createdAt := time.Now().Add(-time.Hour)
+	firstPage := []entity.TicketDetail{
+		{Id: primitive.NewObjectID(), TicketId: "synced", TotalRemaining: 5, Country: entity.Country{Code: "ID"}, CreatedAt: createdAt},
+		{Id: primitive.NewObjectID(), TicketId: "drifted", TotalRemaining: 4, Country: entity.Country{Code: "ID"}, CreatedAt: createdAt},
+	}
+	secondPage := []entity.TicketDetail{
+		{Id: primitive.NewObjectID(), TicketId: "missing", TotalRemaining: 7, Country: entity.Country{Code: "SG"}, CreatedAt: createdAt},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, request.KeysetPageReq{Size: 2}).Return(mockChannel(helpers.Result{Data: &firstPage}))
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, request.KeysetPageReq{
+		AfterCreatedAt: createdAt,
+		AfterId:        firstPage[1].Id,
+		Size:           2,
+	}).Return(mockChannel(helpers.Result{Data: &secondPage}))
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:synced:ID").Return(redis.NewStringResult("5", nil))
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:drifted:ID").Return(redis.NewStringResult("3", nil))
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:missing:SG").Return(redis.NewStringResult("", redis.Nil))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileInventory(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constants.JobTypeReconcileInventory, resp.Job)
+	assert.Equal(suite.T(), 3, resp.Scanned)
+	assert.Equal(suite.T(), 2, resp.Fixed)
+	assert.Equal(suite.T(), 1, resp.Skipped)
+	assert.Equal(suite.T(), constants.RunSucceeded, resp.Status)
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:drifted:ID"}, "3", int64(4))
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:missing:SG"}, "", int64(7))
+	suite.mockRedis.AssertNotCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:synced:ID"}, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileInventoryChanged() {
+	page := []entity.TicketDetail{{TicketId: "id", TotalRemaining: 4, Country: entity.Country{Code: "ID"}}}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:ID").Return(redis.NewStringResult("3", nil))
+	// a seat was taken between the read and the set, the counter is left to the next run
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:id:ID"}, "3", int64(4)).Return(redis.NewCmdResult(int64(0), nil))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileInventory(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Fixed)
+	assert.Equal(suite.T(), 1, resp.Skipped)
+	assert.Equal(suite.T(), constants.RunSucceeded, resp.Status)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileInventoryReadsPrimary() {
+	page := []entity.TicketDetail{{TicketId: "id", TotalRemaining: 4, Country: entity.Country{Code: "ID"}}}
+	mockPrimary := &mockcert.MongodbRepositoryQuery{}
+	mockPrimary.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:ID").Return(redis.NewStringResult("3", nil))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	usecase := uc.NewCommandUsecase(suite.mockWorkerRepositoryQuery, mockPrimary, suite.mockWorkerRepositoryCommand, suite.mockRedis, suite.mockLogger)
+	resp, err := usecase.ReconcileInventory(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Fixed)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindAllTicketDetail", mock.Anything, mock.Anything)
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:id:ID"}, "3", int64(4))
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileInventoryErrRedis() {
+	page := []entity.TicketDetail{{TicketId: "id", Country: entity.Country{Code: "ID"}}}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:ID").Return(redis.NewStringResult("", fmt.Errorf("redis down")))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileInventory(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), "id", resp.Failures[0].Id)
+	assert.Equal(suite.T(), constants.RunPartial, resp.Status)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileInventoryErrTicketDetail() {
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.ReconcileInventory(suite.ctx)
+	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertOneWorkerJobRun", mock.Anything, mock.MatchedBy(func(run entity.WorkerJobRun) bool {
+		return run.Job == constants.JobTypeReconcileInventory && run.Status == constants.RunFailed
+	}))
+}
+
+func (suite *CommandUsecaseTestSuite) TestRebuildSeatPool() {
+	page := []entity.BankTicket{
+		{Id: primitive.NewObjectID(), TicketNumber: "1", TicketId: "id"},
+		{Id: primitive.NewObjectID(), TicketNumber: "2", TicketId: "id"},
+	}
+	mockPrimary := &mockcert.MongodbRepositoryQuery{}
+	mockPrimary.On("FindAllUnusedBankTicket", mock.Anything, "id", request.KeysetPageReq{Size: 1000}).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockRedis.On("Del", mock.Anything, "SEAT-POOL-REBUILD:{id}").Return(redis.NewIntResult(0, nil))
+	suite.mockRedis.On("SAdd", mock.Anything, "SEAT-POOL-REBUILD:{id}", "1", "2").Return(redis.NewIntResult(2, nil))
+	// the rebuild replaces the pool, with a seat pushed while it was rebuilt
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL-REBUILD:{id}", "SEAT-POOL:{id}"}, "REBUILDING").Return(redis.NewCmdResult(int64(3), nil))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewCmdResult(int64(1), nil))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	usecase := uc.NewCommandUsecase(suite.mockWorkerRepositoryQuery, mockPrimary, suite.mockWorkerRepositoryCommand, suite.mockRedis, suite.mockLogger)
+	resp, err := usecase.RebuildSeatPool(suite.ctx, "id")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), &dto.SeatPool{TicketId: "id", Size: 3}, resp)
+	// seats are pushed to the rebuild from before the bank tickets are read
+	assert.Less(suite.T(), callIndex(suite.mockRedis.Calls, "SAdd", "REBUILDING"), callIndex(suite.mockRedis.Calls, "SAdd", "1"))
+	suite.mockRedis.AssertCalled(suite.T(), "SAdd", mock.Anything, "SEAT-POOL-REBUILD:{id}", "1", "2")
+	suite.mockRedis.AssertNumberOfCalls(suite.T(), "Del", 1)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindAllUnusedBankTicket", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestRebuildSeatPoolEmpty() {
+	suite.mockWorkerRepositoryQuery.On("FindAllUnusedBankTicket", mock.Anything, "id", mock.Anything).Return(mockChannel(helpers.Result{Data: &[]entity.BankTicket{}}))
+	suite.mockRedis.On("Del", mock.Anything, mock.Anything).Return(redis.NewIntResult(1, nil))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL-REBUILD:{id}", "SEAT-POOL:{id}"}, "REBUILDING").Return(redis.NewCmdResult(int64(0), nil))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(redis.NewCmdResult(int64(1), nil))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	// the pool is emptied when no seat is free
+	resp, err := suite.usecase.RebuildSeatPool(suite.ctx, "id")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Size)
+	suite.mockRedis.AssertNumberOfCalls(suite.T(), "SAdd", 1)
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL-REBUILD:{id}", "SEAT-POOL:{id}"}, "REBUILDING")
+}
+
+func (suite *CommandUsecaseTestSuite) TestRebuildSeatPoolErrLocked() {
+	suite.mockRedis.On("SetNX", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Unset()
+	suite.mockRedis.On("SetNX", mock.Anything, "SEAT-POOL-LOCK:id", mock.Anything, mock.Anything).Return(redis.NewBoolResult(false, nil))
+
+	_, err := suite.usecase.RebuildSeatPool(suite.ctx, "id")
+	assert.Equal(suite.T(), errors.Conflict("rebuild seat pool already in progress"), err)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindAllUnusedBankTicket", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestRebuildSeatPoolErrBankTicket() {
+	suite.mockWorkerRepositoryQuery.On("FindAllUnusedBankTicket", mock.Anything, "id", mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	suite.mockRedis.On("Del", mock.Anything, mock.Anything).Return(redis.NewIntResult(0, nil))
+
+	_, err := suite.usecase.RebuildSeatPool(suite.ctx, "id")
+	assert.Error(suite.T(), err)
+	suite.mockRedis.AssertNotCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL-REBUILD:{id}", "SEAT-POOL:{id}"}, mock.Anything)
+	// seats are no longer pushed to the failed rebuild
+	suite.mockRedis.AssertNumberOfCalls(suite.T(), "Del", 2)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileTicketDetail() {
+	page := []entity.TicketDetail{
+		{TicketId: "consistent", TotalQuota: 10, TotalRemaining: 4, Country: entity.Country{Code: "ID"}},
+		{TicketId: "drifted", TotalQuota: 10, TotalRemaining: 6, Country: entity.Country{Code: "ID"}},
+		{TicketId: "generating", TotalQuota: 10, TotalRemaining: 10, Country: entity.Country{Code: "SG"}},
+	}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("AggregateBankTicketByTicketId", mock.Anything, []string{"consistent", "drifted", "generating"}).Return(mockChannel(helpers.Result{
+		Data: &[]entity.AggregateBankTicket{
+			{TicketId: "consistent", Total: 10, Unused: 4},
+			{TicketId: "drifted", Total: 10, Unused: 5},
+			{TicketId: "generating", Total: 3, Unused: 3},
+		},
+	}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileTicketDetail(suite.ctx, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constants.JobTypeReconcileTicketDetail, resp.Job)
+	assert.False(suite.T(), resp.FixMode)
+	assert.Equal(suite.T(), 3, resp.Scanned)
+	assert.Equal(suite.T(), 1, resp.Skipped)
+	assert.Equal(suite.T(), 0, resp.Fixed)
+	assert.Equal(suite.T(), []entity.Discrepancy{
+		{TicketId: "drifted", CountryCode: "ID", Field: constants.DiscrepancyTotalRemaining, Expected: 5, Actual: 6},
+		{TicketId: "generating", CountryCode: "SG", Field: constants.DiscrepancyTotalQuota, Expected: 3, Actual: 10},
+		{TicketId: "generating", CountryCode: "SG", Field: constants.DiscrepancyTotalRemaining, Expected: 3, Actual: 10},
+	}, resp.Discrepancies)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "UpdateTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileTicketDetailCapsDiscrepancies() {
+	configs.GetConfig().Worker.ReconcileTicketDetailBatchSize = "600"
+	defer func() { configs.GetConfig().Worker.ReconcileTicketDetailBatchSize = "" }()
+
+	// each ticket detail without bank tickets has a totalQuota and a totalRemaining discrepancy
+	page := make([]entity.TicketDetail, 0, 501)
+	for i := 0; i < 501; i++ {
+		page = append(page, entity.TicketDetail{TicketId: fmt.Sprintf("id-%d", i), TotalQuota: 1, TotalRemaining: 1})
+	}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, request.KeysetPageReq{Size: 600}).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("AggregateBankTicketByTicketId", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileTicketDetail(suite.ctx, false)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 501, resp.Scanned)
+	assert.Len(suite.T(), resp.Discrepancies, 1000)
+	assert.Equal(suite.T(), 2, resp.DiscrepanciesOmitted)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileTicketDetailFix() {
+	page := []entity.TicketDetail{
+		{TicketId: "drifted", TotalQuota: 10, TotalRemaining: 6, Country: entity.Country{Code: "ID"}},
+		{TicketId: "changed", TotalQuota: 10, TotalRemaining: 6, Country: entity.Country{Code: "ID"}},
+		{TicketId: "generating", TotalQuota: 10, TotalRemaining: 10, Country: entity.Country{Code: "SG"}},
+	}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("AggregateBankTicketByTicketId", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &[]entity.AggregateBankTicket{
+			{TicketId: "drifted", Total: 10, Unused: 5},
+			{TicketId: "changed", Total: 10, Unused: 5},
+		},
+	}))
+	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailRemaining", mock.Anything, "drifted", 6, 5).Return(mockChannel(helpers.Result{Data: &entity.TicketDetail{}}))
+	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailRemaining", mock.Anything, "changed", 6, 5).Return(mockChannel(helpers.Result{}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileTicketDetail(suite.ctx, true)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), resp.FixMode)
+	assert.Equal(suite.T(), 1, resp.Fixed)
+	assert.Equal(suite.T(), 0, resp.Skipped)
+	assert.Equal(suite.T(), []entity.Discrepancy{
+		{TicketId: "drifted", CountryCode: "ID", Field: constants.DiscrepancyTotalRemaining, Expected: 5, Actual: 6, Fixed: true},
+		{TicketId: "changed", CountryCode: "ID", Field: constants.DiscrepancyTotalRemaining, Expected: 5, Actual: 6},
+		{TicketId: "generating", CountryCode: "SG", Field: constants.DiscrepancyTotalQuota, Expected: 0, Actual: 10},
+		{TicketId: "generating", CountryCode: "SG", Field: constants.DiscrepancyTotalRemaining, Expected: 0, Actual: 10},
+	}, resp.Discrepancies)
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:drifted:ID"}, int64(-1), int64(10))
+	suite.mockRedis.AssertNotCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"INVENTORY:changed:ID"}, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "UpdateTicketDetailRemaining", mock.Anything, "generating", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileTicketDetailErrFix() {
+	page := []entity.TicketDetail{{TicketId: "id", TotalQuota: 1, TotalRemaining: 1, Country: entity.Country{Code: "ID"}}}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("AggregateBankTicketByTicketId", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &[]entity.AggregateBankTicket{{TicketId: "id", Total: 1}},
+	}))
+	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailRemaining", mock.Anything, "id", 1, 0).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileTicketDetail(suite.ctx, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), "id", resp.Failures[0].Id)
+	assert.Len(suite.T(), resp.Discrepancies, 1)
+	assert.Equal(suite.T(), constants.RunPartial, resp.Status)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileTicketDetailCapsFailures() {
+	configs.GetConfig().Worker.ReconcileTicketDetailBatchSize = "1100"
+	defer func() { configs.GetConfig().Worker.ReconcileTicketDetailBatchSize = "" }()
+
+	page := make([]entity.TicketDetail, 0, 1002)
+	counts := make([]entity.AggregateBankTicket, 0, 1002)
+	for i := 0; i < 1002; i++ {
+		page = append(page, entity.TicketDetail{TicketId: fmt.Sprintf("id-%d", i), TotalQuota: 1, TotalRemaining: 1})
+		counts = append(counts, entity.AggregateBankTicket{TicketId: fmt.Sprintf("id-%d", i), Total: 1})
+	}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, request.KeysetPageReq{Size: 1100}).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("AggregateBankTicketByTicketId", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &counts}))
+	suite.mockWorkerRepositoryCommand.On("UpdateTicketDetailRemaining", mock.Anything, mock.Anything, 1, 0).Return(func(ctx context.Context, ticketId string, from, to int) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Error: errors.InternalServerError("error")})
+	})
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.ReconcileTicketDetail(suite.ctx, true)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1002, resp.Failed)
+	assert.Len(suite.T(), resp.Failures, 1000)
+	assert.Equal(suite.T(), 2, resp.FailuresOmitted)
+}
+
+func (suite *CommandUsecaseTestSuite) TestReconcileTicketDetailErrBankTicket() {
+	page := []entity.TicketDetail{{TicketId: "id"}}
+	suite.mockWorkerRepositoryQuery.On("FindAllTicketDetail", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Data: &page}))
+	suite.mockWorkerRepositoryQuery.On("AggregateBankTicketByTicketId", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.ReconcileTicketDetail(suite.ctx, false)
+	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertOneWorkerJobRun", mock.Anything, mock.MatchedBy(func(run entity.WorkerJobRun) bool {
+		return run.Job == constants.JobTypeReconcileTicketDetail && run.Status == constants.RunFailed
+	}))
+}
+
+// orphanSeenAt is when the seats of the orphans were last changed as they are checked
+var orphanSeenAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// stubOrphans stubs one page of each kind of orphan candidate, with the links of the ticket numbers
+// paid, held and ordered consistent and those of lost, dangling and stale broken
+func (suite *CommandUsecaseTestSuite) stubOrphans() {
+	suite.mockWorkerRepositoryQuery.On("FindAllInvalidPayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &[]entity.PaymentHistory{
+			{PaymentId: "p-lost", UserId: "user", Ticket: &entity.Ticket{TicketNumber: "lost", TicketId: "id"}},
+			{PaymentId: "p-paid", UserId: "user", Ticket: &entity.Ticket{TicketNumber: "paid", TicketId: "id"}},
+		},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindAllUsedBankTicket", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &[]entity.BankTicket{
+			{TicketNumber: "dangling", TicketId: "id", UserId: "user", IsUsed: true, UpdatedAt: orphanSeenAt},
+			{TicketNumber: "held", TicketId: "id", UserId: "user", IsUsed: true},
+		},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindAllOrder", mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{
+		Data: &[]entity.Order{
+			{TicketNumber: "stale", UserId: "user"},
+			{TicketNumber: "ordered", UserId: "user"},
+		},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindBankTicketByTicketNumber", mock.Anything, "lost").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{TicketNumber: "lost", TicketId: "id", UserId: "user", IsUsed: true, UpdatedAt: orphanSeenAt},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindBankTicketByTicketNumber", mock.Anything, "paid").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{TicketNumber: "paid", TicketId: "id", UserId: "other", IsUsed: true},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindBankTicketByTicketNumber", mock.Anything, "stale").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{TicketNumber: "stale", TicketId: "id"},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindBankTicketByTicketNumber", mock.Anything, "ordered").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{TicketNumber: "ordered", TicketId: "id", IsUsed: true},
+	}))
+	suite.mockWorkerRepositoryQuery.On("FindPaymentByTicketNumber", mock.Anything, mock.Anything).Return(func(ctx context.Context, ticketNumber string) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryQuery.On("FindOrderByTicketNumber", mock.Anything, "dangling").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryQuery.On("FindOrderByTicketNumber", mock.Anything, "held").Return(mockChannel(helpers.Result{Data: &entity.Order{TicketNumber: "held"}}))
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCleanupOrphanReport() {
+	suite.stubOrphans()
+
+	resp, err := suite.usecase.CleanupOrphan(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constants.JobTypeCleanupOrphan, resp.Job)
+	assert.Equal(suite.T(), 6, resp.Scanned)
+	assert.Equal(suite.T(), 3, resp.Skipped)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	assert.Equal(suite.T(), []entity.Orphan{
+		{Kind: constants.OrphanPayment, TicketNumber: "lost", PaymentId: "p-lost", Reason: "payment invalidated while its seat is held", Action: constants.OrphanActionReport},
+		{Kind: constants.OrphanBankTicket, TicketNumber: "dangling", Reason: "bank ticket used without an order or a valid payment", Action: constants.OrphanActionReport},
+		{Kind: constants.OrphanOrder, TicketNumber: "stale", Reason: "order without a reserved bank ticket", Action: constants.OrphanActionReport},
+	}, resp.Orphans)
+	assert.Equal(suite.T(), constants.RunSucceeded, resp.Status)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "WithTransaction", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCleanupOrphanFix() {
+	worker := &configs.GetConfig().Worker
+	worker.OrphanOrderAction, worker.OrphanBankTicketAction, worker.OrphanPaymentAction = "delete", "release", "release"
+	defer func() {
+		worker.OrphanOrderAction, worker.OrphanBankTicketAction, worker.OrphanPaymentAction = "", "", ""
+	}()
+
+	suite.stubOrphans()
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(func(ctx context.Context, id string) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &entity.TicketDetail{TicketId: "id", TicketPrice: 100, TotalQuota: 10, Country: entity.Country{Code: "ID"}}})
+	})
+	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, mock.Anything).Return(func(ctx context.Context, ticketNumber string) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+	suite.mockWorkerRepositoryCommand.On("ReleaseOneBankTicket", mock.Anything, mock.Anything).Return(func(ctx context.Context, payload request.ReleaseBankTicketReq) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &entity.BankTicket{TicketNumber: payload.TicketNumber}})
+	})
+	suite.mockWorkerRepositoryCommand.On("IncrementTicketDetailRemaining", mock.Anything, "id", 1).Return(func(ctx context.Context, ticketId string, delta int) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &entity.TicketDetail{}})
+	})
+	suite.mockWorkerRepositoryCommand.On("InsertOneOrphanAudit", mock.Anything, mock.Anything).Return(func(ctx context.Context, audit entity.OrphanAudit) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+
+	resp, err := suite.usecase.CleanupOrphan(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, resp.Reclaimed)
+	assert.Equal(suite.T(), 3, resp.Skipped)
+	for _, orphan := range resp.Orphans {
+		assert.True(suite.T(), orphan.Fixed, orphan.TicketNumber)
+	}
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertOneOrphanAudit", mock.Anything, mock.MatchedBy(func(audit entity.OrphanAudit) bool {
+		return audit.RunId == resp.Id && audit.Kind == constants.OrphanPayment && audit.PaymentId == "p-lost" && audit.Fixed && audit.UserId == "user"
+	}))
+	suite.mockWorkerRepositoryCommand.AssertNumberOfCalls(suite.T(), "InsertOneOrphanAudit", 3)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "DeleteOneOrder", mock.Anything, "lost")
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "DeleteOneOrder", mock.Anything, "stale")
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "ReleaseOneBankTicket", mock.Anything, request.ReleaseBankTicketReq{TicketNumber: "lost", UserId: "user", UpdatedAt: orphanSeenAt, Price: 100})
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "ReleaseOneBankTicket", mock.Anything, request.ReleaseBankTicketReq{TicketNumber: "dangling", UserId: "user", UpdatedAt: orphanSeenAt, Price: 100})
+	suite.mockWorkerRepositoryCommand.AssertNumberOfCalls(suite.T(), "IncrementTicketDetailRemaining", 2)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertManyOutboxEvent", mock.Anything, savedEvent(constants.EventTicketReleased, "id", func(data dto.TicketReleased) bool {
+		return data.TicketNumber == "dangling" && data.Reason == constants.ReleaseOrphanBankTicket
+	}))
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL:{id}", "SEAT-POOL-REBUILD:{id}"}, "lost")
+	suite.mockRedis.AssertCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL:{id}", "SEAT-POOL-REBUILD:{id}"}, "dangling")
+	// the two seats released are counted back and pushed
+	suite.mockRedis.AssertNumberOfCalls(suite.T(), "EvalSha", 4)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCleanupOrphanChanged() {
+	worker := &configs.GetConfig().Worker
+	worker.OrphanBankTicketAction, worker.OrphanPaymentAction = "release", "release"
+	defer func() { worker.OrphanBankTicketAction, worker.OrphanPaymentAction = "", "" }()
+
+	suite.stubOrphans()
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(func(ctx context.Context, id string) <-chan helpers.Result {
+		return mockChannel(helpers.Result{Data: &entity.TicketDetail{TicketId: "id", TicketPrice: 100, TotalQuota: 10, Country: entity.Country{Code: "ID"}}})
+	})
+	// both seats were paid or released again between the check and the fix
+	suite.mockWorkerRepositoryCommand.On("ReleaseOneBankTicket", mock.Anything, mock.Anything).Return(func(ctx context.Context, payload request.ReleaseBankTicketReq) <-chan helpers.Result {
+		return mockChannel(helpers.Result{})
+	})
+
+	resp, err := suite.usecase.CleanupOrphan(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	assert.Equal(suite.T(), 5, resp.Skipped)
+	assert.Equal(suite.T(), 0, resp.Failed)
+	assert.Equal(suite.T(), constants.RunSucceeded, resp.Status)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "DeleteOneOrder", mock.Anything, "lost")
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "IncrementTicketDetailRemaining", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "InsertOneOrphanAudit", mock.Anything, mock.Anything)
+	suite.mockRedis.AssertNotCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, []string{"SEAT-POOL:{id}", "SEAT-POOL-REBUILD:{id}"}, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCleanupOrphanOrderChanged() {
+	configs.GetConfig().Worker.OrphanOrderAction = "delete"
+	defer func() { configs.GetConfig().Worker.OrphanOrderAction = "" }()
+
+	suite.stubOrphans()
+	// the seat of the stale order was reserved between the check and the fix
+	mockPrimary := &mockcert.MongodbRepositoryQuery{}
+	mockPrimary.On("FindBankTicketByTicketNumber", mock.Anything, "stale").Return(mockChannel(helpers.Result{
+		Data: &entity.BankTicket{TicketNumber: "stale", TicketId: "id", UserId: "other", IsUsed: true},
+	}))
+
+	usecase := uc.NewCommandUsecase(suite.mockWorkerRepositoryQuery, mockPrimary, suite.mockWorkerRepositoryCommand, suite.mockRedis, suite.mockLogger)
+	resp, err := usecase.CleanupOrphan(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	assert.Equal(suite.T(), 4, resp.Skipped)
+	assert.Equal(suite.T(), 0, resp.Failed)
+	mockPrimary.AssertCalled(suite.T(), "FindBankTicketByTicketNumber", mock.Anything, "stale")
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "DeleteOneOrder", mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertNotCalled(suite.T(), "InsertOneOrphanAudit", mock.Anything, mock.Anything)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCleanupOrphanErrAudit() {
+	configs.GetConfig().Worker.OrphanOrderAction = "delete"
+	defer func() { configs.GetConfig().Worker.OrphanOrderAction = "" }()
+
+	suite.stubOrphans()
+	suite.mockWorkerRepositoryCommand.On("DeleteOneOrder", mock.Anything, "stale").Return(mockChannel(helpers.Result{}))
+	suite.mockWorkerRepositoryCommand.On("InsertOneOrphanAudit", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	resp, err := suite.usecase.CleanupOrphan(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resp.Failed)
+	assert.Equal(suite.T(), "stale", resp.Failures[0].Id)
+	assert.Equal(suite.T(), 0, resp.Reclaimed)
+	assert.False(suite.T(), resp.Orphans[2].Fixed)
+	assert.Equal(suite.T(), constants.RunPartial, resp.Status)
+}
+
+func (suite *CommandUsecaseTestSuite) TestCleanupOrphanErrPayment() {
+	suite.mockWorkerRepositoryQuery.On("FindAllInvalidPayment", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.usecase.CleanupOrphan(suite.ctx)
+	assert.Error(suite.T(), err)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindAllOrder", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryCommand.AssertCalled(suite.T(), "InsertOneWorkerJobRun", mock.Anything, mock.MatchedBy(func(run entity.WorkerJobRun) bool {
+		return run.Job == constants.JobTypeCleanupOrphan && run.Status == constants.RunFailed
+	}))
+}
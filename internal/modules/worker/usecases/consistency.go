@@ -0,0 +1,170 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"worker-service/configs"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+
+	"go.elastic.co/apm"
+)
+
+// defaultReconcileTicketDetailBatchSize is smaller than the inventory batch, each page of ticket details
+// is counted against its bank tickets in one aggregation
+const defaultReconcileTicketDetailBatchSize = 200
+
+func reconcileTicketDetailBatchSize() int64 {
+	batchSize, err := strconv.ParseInt(configs.GetConfig().Worker.ReconcileTicketDetailBatchSize, 10, 64)
+	if err != nil || batchSize <= 0 {
+		return defaultReconcileTicketDetailBatchSize
+	}
+	return batchSize
+}
+
+// ReconcileTicketDetail checks the totals of every ticket detail against its bank tickets: totalQuota against the
+// generated seats and totalRemaining against the unused ones. The discrepancies are reported on the run up to
+// maxRunRecords, a ticket detail without any is counted as skipped.
+//
+// In fix mode totalRemaining is set to the unused seats once every seat of the ticket is generated, a fixed ticket
+// detail is counted as fixed. A ticket detail changed since it was read is left to the next run, and totalQuota
+// is never fixed since it is what the seats are generated from.
+func (c commandUsecase) ReconcileTicketDetail(origCtx context.Context, fix bool) (*entity.WorkerJobRun, error) {
+	domain := "workerUsecase-ReconcileTicketDetail"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	run := newRun(constants.JobTypeReconcileTicketDetail)
+	run.FixMode = fix
+	page := request.KeysetPageReq{Size: reconcileTicketDetailBatchSize()}
+	for {
+		ticketDetailData := <-c.workerRepositoryQuery.FindAllTicketDetail(ctx, page)
+		if ticketDetailData.Error != nil {
+			c.finishRun(ctx, run, ticketDetailData.Error)
+			return nil, ticketDetailData.Error
+		}
+		if ticketDetailData.Data == nil {
+			break
+		}
+
+		ticketDetails, ok := ticketDetailData.Data.(*[]entity.TicketDetail)
+		if !ok {
+			err := errors.InternalServerError("cannot parsing data ticket detail")
+			c.finishRun(ctx, run, err)
+			return nil, err
+		}
+
+		counts, err := c.countBankTickets(ctx, *ticketDetails)
+		if err != nil {
+			c.finishRun(ctx, run, err)
+			return nil, err
+		}
+
+		run.Scanned += len(*ticketDetails)
+		for _, ticketDetail := range *ticketDetails {
+			discrepancies, err := c.reconcileTicketDetail(ctx, ticketDetail, counts[ticketDetail.TicketId], fix)
+			addDiscrepancies(run, discrepancies...)
+			if err != nil {
+				failRun(run, ticketDetail.TicketId, err)
+				continue
+			}
+			if len(discrepancies) == 0 {
+				run.Skipped++
+				continue
+			}
+			for _, discrepancy := range discrepancies {
+				if discrepancy.Fixed {
+					run.Fixed++
+					break
+				}
+			}
+		}
+
+		if int64(len(*ticketDetails)) < page.Size {
+			break
+		}
+		if ctx.Err() != nil {
+			run.TimedOut = true
+			break
+		}
+		last := (*ticketDetails)[len(*ticketDetails)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterId = last.Id
+	}
+
+	c.finishRun(ctx, run, nil)
+	return run, nil
+}
+
+// countBankTickets counts the bank tickets of a page of ticket details by ticketId, a ticket without any is missing
+func (c commandUsecase) countBankTickets(ctx context.Context, ticketDetails []entity.TicketDetail) (map[string]entity.AggregateBankTicket, error) {
+	ticketIds := make([]string, 0, len(ticketDetails))
+	for _, ticketDetail := range ticketDetails {
+		ticketIds = append(ticketIds, ticketDetail.TicketId)
+	}
+
+	bankTicketData := <-c.workerRepositoryQuery.AggregateBankTicketByTicketId(ctx, ticketIds)
+	if bankTicketData.Error != nil {
+		return nil, bankTicketData.Error
+	}
+
+	counts := make(map[string]entity.AggregateBankTicket, len(ticketIds))
+	if bankTicketData.Data == nil {
+		return counts, nil
+	}
+	bankTickets, ok := bankTicketData.Data.(*[]entity.AggregateBankTicket)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data bank ticket count")
+	}
+	for _, count := range *bankTickets {
+		counts[count.TicketId] = count
+	}
+	return counts, nil
+}
+
+// reconcileTicketDetail returns the discrepancies of a ticket detail, fixing totalRemaining in fix mode
+func (c commandUsecase) reconcileTicketDetail(ctx context.Context, ticketDetail entity.TicketDetail, count entity.AggregateBankTicket, fix bool) ([]entity.Discrepancy, error) {
+	discrepancies := make([]entity.Discrepancy, 0)
+	if count.Total != ticketDetail.TotalQuota {
+		discrepancies = append(discrepancies, entity.Discrepancy{
+			TicketId:    ticketDetail.TicketId,
+			CountryCode: ticketDetail.Country.Code,
+			Field:       constants.DiscrepancyTotalQuota,
+			Expected:    count.Total,
+			Actual:      ticketDetail.TotalQuota,
+		})
+	}
+	if count.Unused == ticketDetail.TotalRemaining {
+		return discrepancies, nil
+	}
+
+	remaining := entity.Discrepancy{
+		TicketId:    ticketDetail.TicketId,
+		CountryCode: ticketDetail.Country.Code,
+		Field:       constants.DiscrepancyTotalRemaining,
+		Expected:    count.Unused,
+		Actual:      ticketDetail.TotalRemaining,
+	}
+	// seats not generated yet are remaining without a bank ticket, so only a complete generation is fixed
+	if !fix || count.Total != ticketDetail.TotalQuota {
+		return append(discrepancies, remaining), nil
+	}
+
+	resp := <-c.workerRepositoryCommand.UpdateTicketDetailRemaining(ctx, ticketDetail.TicketId, ticketDetail.TotalRemaining, count.Unused)
+	if resp.Error != nil {
+		return append(discrepancies, remaining), resp.Error
+	}
+	if resp.Data != nil {
+		remaining.Fixed = true
+		c.logger.Info(ctx, fmt.Sprintf("Fixed totalRemaining of ticket detail %s from %d to %d", ticketDetail.TicketId, ticketDetail.TotalRemaining, count.Unused), remaining)
+		c.adjustInventory(ctx, ticketDetail.TicketId, ticketDetail.Country.Code, count.Unused-ticketDetail.TotalRemaining, ticketDetail.TotalQuota, count.Unused)
+	}
+	return append(discrepancies, remaining), nil
+}
@@ -0,0 +1,28 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"worker-service/internal/modules/worker/models/entity"
+
+	"go.elastic.co/apm"
+)
+
+func (c commandUsecase) UpsertCronJob(origCtx context.Context, job entity.CronJob) error {
+	domain := "workerUsecase-UpsertCronJob"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	job.UpdatedAt = time.Now()
+	resp := <-c.workerRepositoryCommand.UpsertCronJob(ctx, job)
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	c.logger.Info(ctx, fmt.Sprintf("Cron job %s set to %q, enabled %t", job.Name, job.Spec, job.Enabled), job)
+	return nil
+}
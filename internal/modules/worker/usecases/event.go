@@ -0,0 +1,95 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"worker-service/configs"
+	"worker-service/internal/modules/worker/models/dto"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/events"
+)
+
+// saveEvents saves evs in the outbox, which the outbox relay publishes from.
+// Given the transaction context of the change they describe, the events are committed or rolled back with it.
+func (c commandUsecase) saveEvents(ctx context.Context, evs ...events.Event) error {
+	now := time.Now()
+	outboxEvents := make([]entity.OutboxEvent, 0, len(evs))
+	for _, ev := range evs {
+		message, err := events.NewMessage(configs.GetConfig().ServiceName, ev)
+		if err != nil {
+			return errors.InternalServerError(fmt.Sprintf("cannot encode %s event: %v", ev.Type, err))
+		}
+		outboxEvents = append(outboxEvents, entity.OutboxEvent{
+			Id:            message.Id,
+			Topic:         message.Topic,
+			Key:           message.Key,
+			Headers:       message.Headers,
+			Value:         string(message.Value),
+			Status:        constants.OutboxPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+
+	resp := <-c.workerRepositoryCommand.InsertManyOutboxEvent(ctx, outboxEvents)
+	return resp.Error
+}
+
+// ticketReleasedEvent is keyed by ticket id, so the inventory changes of a ticket are consumed in order
+func ticketReleasedEvent(ticket entity.Ticket, reason string) events.Event {
+	return events.Event{
+		Type:    constants.EventTicketReleased,
+		Version: constants.EventTicketReleasedVersion,
+		Key:     ticket.TicketId,
+		Data: dto.TicketReleased{
+			TicketNumber: ticket.TicketNumber,
+			TicketId:     ticket.TicketId,
+			EventId:      ticket.EventId,
+			CountryCode:  ticket.CountryCode,
+			TicketType:   ticket.TicketType,
+			SeatNumber:   ticket.SeatNumber,
+			Reason:       reason,
+			ReleasedAt:   time.Now(),
+		},
+	}
+}
+
+func paymentExpiredEvent(p entity.PaymentHistory) events.Event {
+	return events.Event{
+		Type:    constants.EventPaymentExpired,
+		Version: constants.EventPaymentExpiredVersion,
+		Key:     p.PaymentId,
+		Data: dto.PaymentExpired{
+			PaymentId:    p.PaymentId,
+			UserId:       p.UserId,
+			TicketNumber: p.Ticket.TicketNumber,
+			TicketId:     p.Ticket.TicketId,
+			EventId:      p.Ticket.EventId,
+			ExpiryTime:   p.ExpiryTime,
+			ExpiredAt:    time.Now(),
+		},
+	}
+}
+
+// bankTicketGeneratedEvent is the seat range of tickets, which are consecutive seats of one ticket
+func bankTicketGeneratedEvent(tickets []entity.BankTicket) events.Event {
+	first, last := tickets[0], tickets[len(tickets)-1]
+	return events.Event{
+		Type:    constants.EventBankTicketGenerated,
+		Version: constants.EventBankTicketGeneratedVersion,
+		Key:     first.TicketId,
+		Data: dto.BankTicketGenerated{
+			TicketId:        first.TicketId,
+			EventId:         first.EventId,
+			CountryCode:     first.CountryCode,
+			TicketType:      first.TicketType,
+			FirstSeatNumber: first.SeatNumber,
+			LastSeatNumber:  last.SeatNumber,
+			Total:           len(tickets),
+			GeneratedAt:     time.Now(),
+		},
+	}
+}
@@ -0,0 +1,117 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
+
+	"go.elastic.co/apm"
+)
+
+const expiryPolicyCacheTTL = time.Minute
+
+// expiryPolicyCache keeps the expiry policies between expiry runs, it is shared by every copy of the usecase
+type expiryPolicyCache struct {
+	mu       sync.Mutex
+	policies []entity.ExpiryPolicy
+	loadedAt time.Time
+}
+
+// expiryPolicyId is the id of the policy of an event, ticket type and payment type, * stands for any value
+func expiryPolicyId(eventId, ticketType, paymentType string) string {
+	return fmt.Sprintf("%s:%s:%s",
+		helpers.CustomIfEmpty(eventId, "*"),
+		helpers.CustomIfEmpty(ticketType, "*"),
+		helpers.CustomIfEmpty(paymentType, "*"))
+}
+
+// findExpiryPolicies returns the cached expiry policies, reading them again once the cache is older than its TTL
+func (c commandUsecase) findExpiryPolicies(ctx context.Context) ([]entity.ExpiryPolicy, error) {
+	c.expiryPolicies.mu.Lock()
+	defer c.expiryPolicies.mu.Unlock()
+
+	if c.expiryPolicies.policies != nil && time.Since(c.expiryPolicies.loadedAt) < expiryPolicyCacheTTL {
+		return c.expiryPolicies.policies, nil
+	}
+
+	policyData := <-c.workerRepositoryQuery.FindAllExpiryPolicy(ctx)
+	if policyData.Error != nil {
+		return nil, policyData.Error
+	}
+
+	policies := make([]entity.ExpiryPolicy, 0)
+	if policyData.Data != nil {
+		data, ok := policyData.Data.(*[]entity.ExpiryPolicy)
+		if !ok {
+			return nil, errors.InternalServerError("cannot parsing data expiry policy")
+		}
+		policies = append(policies, *data...)
+	}
+
+	c.expiryPolicies.policies = policies
+	c.expiryPolicies.loadedAt = time.Now()
+	return policies, nil
+}
+
+// resetExpiryPolicies drops the cached policies so the next expiry run reads the changed ones
+func (c commandUsecase) resetExpiryPolicies() {
+	c.expiryPolicies.mu.Lock()
+	defer c.expiryPolicies.mu.Unlock()
+	c.expiryPolicies.policies = nil
+}
+
+func (c commandUsecase) UpsertExpiryPolicy(origCtx context.Context, payload request.UpsertExpiryPolicyReq) (*entity.ExpiryPolicy, error) {
+	domain := "workerUsecase-UpsertExpiryPolicy"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	if payload.WindowMinutes <= 0 {
+		return nil, errors.BadRequest("window minutes must be positive")
+	}
+
+	policy := entity.ExpiryPolicy{
+		Id:            expiryPolicyId(payload.EventId, payload.TicketType, payload.PaymentType),
+		EventId:       payload.EventId,
+		TicketType:    payload.TicketType,
+		PaymentType:   payload.PaymentType,
+		WindowMinutes: payload.WindowMinutes,
+		UpdatedAt:     time.Now(),
+	}
+	resp := <-c.workerRepositoryCommand.UpsertExpiryPolicy(ctx, policy)
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	c.resetExpiryPolicies()
+	c.logger.Info(ctx, fmt.Sprintf("Expiry policy %s set to %d minutes", policy.Id, policy.WindowMinutes), policy)
+	return &policy, nil
+}
+
+func (c commandUsecase) DeleteExpiryPolicy(origCtx context.Context, id string) error {
+	domain := "workerUsecase-DeleteExpiryPolicy"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	resp := <-c.workerRepositoryCommand.DeleteOneExpiryPolicy(ctx, id)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.Count == 0 {
+		return errors.NotFound("expiry policy not found")
+	}
+
+	c.resetExpiryPolicies()
+	c.logger.Info(ctx, fmt.Sprintf("Expiry policy %s deleted", id), id)
+	return nil
+}
@@ -0,0 +1,196 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+	"worker-service/configs"
+	"worker-service/internal/modules/worker/models/dto"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/redis"
+
+	goRedis "github.com/go-redis/redis/v8"
+	"go.elastic.co/apm"
+)
+
+const defaultInventoryBatchSize = 500
+
+// inventoryKey is the redis counter of the remaining tickets of a ticket detail
+func inventoryKey(ticketId, countryCode string) string {
+	return fmt.Sprintf("%s:%s:%s", constants.RedisKeyInventory, ticketId, countryCode)
+}
+
+func inventoryBatchSize() int64 {
+	batchSize, err := strconv.ParseInt(configs.GetConfig().Worker.InventoryBatchSize, 10, 64)
+	if err != nil || batchSize <= 0 {
+		return defaultInventoryBatchSize
+	}
+	return batchSize
+}
+
+// seedInventory creates the counter of a ticket detail from its remaining tickets in mongo when it has none yet,
+// an existing counter already follows the sales since it was seeded and is kept.
+// Mongo stays the source of truth, a counter which cannot be set is left to the reconciliation.
+func (c commandUsecase) seedInventory(ctx context.Context, ticketId, countryCode string, totalRemaining int) {
+	if err := c.redisClient.SetNX(ctx, inventoryKey(ticketId, countryCode), totalRemaining, 0).Err(); err != nil {
+		c.logger.Error(ctx, fmt.Sprintf("Failed seed inventory %s %s", ticketId, countryCode), err.Error())
+	}
+}
+
+// adjustInventory moves the counter of a ticket detail by the change of its remaining tickets in mongo,
+// a counter not seeded yet is seeded from the remaining tickets saved there
+func (c commandUsecase) adjustInventory(ctx context.Context, ticketId, countryCode string, delta, totalQuota, totalRemaining int) {
+	_, err := redis.IncrementCounter(ctx, c.redisClient, inventoryKey(ticketId, countryCode), int64(delta), int64(totalQuota))
+	if err == redis.ErrCounterNotFound {
+		c.seedInventory(ctx, ticketId, countryCode, totalRemaining)
+		return
+	}
+	if err != nil {
+		c.logger.Error(ctx, fmt.Sprintf("Failed adjust inventory %s %s", ticketId, countryCode), err.Error())
+	}
+}
+
+// releaseInventory gives one seat back to the counter of a ticket detail once it is released in mongo,
+// a counter not seeded yet or already full is left to the reconciliation
+func (c commandUsecase) releaseInventory(ctx context.Context, ticketDetail *entity.TicketDetail) {
+	key := inventoryKey(ticketDetail.TicketId, ticketDetail.Country.Code)
+	_, err := redis.IncrementCounter(ctx, c.redisClient, key, 1, int64(ticketDetail.TotalQuota))
+	if err == redis.ErrCounterNotFound {
+		return
+	}
+	if err != nil {
+		c.logger.Error(ctx, fmt.Sprintf("Failed release inventory %s %s", ticketDetail.TicketId, ticketDetail.Country.Code), err.Error())
+	}
+}
+
+// ReconcileInventory sets every counter which drifted from the remaining tickets of its ticket detail in mongo,
+// a counter set again is counted as fixed and one already in sync as skipped. Ticket details are read from the primary,
+// a lagging secondary would set counters back to seats already taken.
+func (c commandUsecase) ReconcileInventory(origCtx context.Context) (*entity.WorkerJobRun, error) {
+	domain := "workerUsecase-ReconcileInventory"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	run := newRun(constants.JobTypeReconcileInventory)
+	page := request.KeysetPageReq{Size: inventoryBatchSize()}
+	for {
+		ticketDetailData := <-c.workerRepositoryPrimary.FindAllTicketDetail(ctx, page)
+		if ticketDetailData.Error != nil {
+			c.finishRun(ctx, run, ticketDetailData.Error)
+			return nil, ticketDetailData.Error
+		}
+		if ticketDetailData.Data == nil {
+			break
+		}
+
+		ticketDetails, ok := ticketDetailData.Data.(*[]entity.TicketDetail)
+		if !ok {
+			err := errors.InternalServerError("cannot parsing data ticket detail")
+			c.finishRun(ctx, run, err)
+			return nil, err
+		}
+
+		run.Scanned += len(*ticketDetails)
+		for _, ticketDetail := range *ticketDetails {
+			reconciled, err := c.reconcileInventory(ctx, ticketDetail)
+			if err != nil {
+				failRun(run, ticketDetail.TicketId, err)
+				continue
+			}
+			if !reconciled {
+				run.Skipped++
+				continue
+			}
+			run.Fixed++
+		}
+
+		if int64(len(*ticketDetails)) < page.Size {
+			break
+		}
+		if ctx.Err() != nil {
+			run.TimedOut = true
+			break
+		}
+		last := (*ticketDetails)[len(*ticketDetails)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterId = last.Id
+	}
+
+	c.finishRun(ctx, run, nil)
+	return run, nil
+}
+
+// reconcileInventory sets the counter of a ticket detail when it is missing or differs from mongo.
+// The counter is only set while it still holds what was read, a seat taken or released in between is left to the next run,
+// as is a seat released in mongo but not yet in redis.
+func (c commandUsecase) reconcileInventory(ctx context.Context, ticketDetail entity.TicketDetail) (bool, error) {
+	key := inventoryKey(ticketDetail.TicketId, ticketDetail.Country.Code)
+	remaining, err := c.redisClient.Get(ctx, key).Result()
+	if err != nil && err != goRedis.Nil {
+		return false, err
+	}
+	if err == nil && remaining == strconv.Itoa(ticketDetail.TotalRemaining) {
+		return false, nil
+	}
+
+	err = redis.SetCounter(ctx, c.redisClient, key, remaining, int64(ticketDetail.TotalRemaining))
+	if err == redis.ErrCounterChanged {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	c.logger.Info(ctx, fmt.Sprintf("Reconciled inventory %s %s to %d", ticketDetail.TicketId, ticketDetail.Country.Code, ticketDetail.TotalRemaining), remaining)
+	return true, nil
+}
+
+// FindInventory reads the remaining tickets of a ticket detail from its counter, or from mongo when it is not seeded yet
+func (q queryUsecase) FindInventory(origCtx context.Context, ticketId, countryCode string) (*dto.Inventory, error) {
+	domain := "workerUsecase-FindInventory"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	inventory := &dto.Inventory{
+		TicketId:    ticketId,
+		CountryCode: countryCode,
+	}
+	remaining, err := q.redisClient.Get(ctx, inventoryKey(ticketId, countryCode)).Int()
+	if err == nil {
+		inventory.TotalRemaining = remaining
+		inventory.Source = constants.InventorySourceRedis
+		return inventory, nil
+	}
+	if err != goRedis.Nil {
+		q.logger.Error(ctx, fmt.Sprintf("Failed get inventory %s %s", ticketId, countryCode), err.Error())
+	}
+
+	ticketDetailData := <-q.workerRepositoryQuery.FindOneTicketDetailById(ctx, ticketId)
+	if ticketDetailData.Error != nil {
+		return nil, ticketDetailData.Error
+	}
+	if ticketDetailData.Data == nil {
+		return nil, errors.NotFound("ticket detail not found")
+	}
+
+	ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data ticket detail")
+	}
+	if ticketDetail.Country.Code != countryCode {
+		return nil, errors.NotFound("ticket detail not found")
+	}
+
+	inventory.TotalRemaining = ticketDetail.TotalRemaining
+	inventory.Source = constants.InventorySourceMongo
+	return inventory, nil
+}
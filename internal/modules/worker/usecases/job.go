@@ -0,0 +1,123 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"worker-service/configs"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/constants"
+
+	"github.com/google/uuid"
+	"go.elastic.co/apm"
+)
+
+const defaultJobStaleAfter = 30 * time.Minute
+
+// progressFunc reports the progress of a running job
+type progressFunc func(generated, total int)
+
+// jobFunc is the work tracked by a job
+type jobFunc func(ctx context.Context, report progressFunc) (*string, error)
+
+func (c commandUsecase) newJob(jobType, source string, payload map[string]string) entity.WorkerJob {
+	now := time.Now()
+	return entity.WorkerJob{
+		Id:        uuid.NewString(),
+		Type:      jobType,
+		Source:    source,
+		State:     constants.JobQueued,
+		Instance:  c.instance,
+		Payload:   payload,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// runJob runs fn and saves the job state, progress and result along the way
+func (c commandUsecase) runJob(ctx context.Context, job *entity.WorkerJob, fn jobFunc) (*string, error) {
+	startedAt := time.Now()
+	job.State = constants.JobRunning
+	job.StartedAt = &startedAt
+	job.UpdatedAt = startedAt
+	c.saveJob(job)
+
+	resp, err := fn(ctx, func(generated, total int) {
+		job.Progress = entity.JobProgress{
+			Generated: generated,
+			Total:     total,
+		}
+		job.UpdatedAt = time.Now()
+		c.saveJob(job)
+	})
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	job.UpdatedAt = finishedAt
+	if err != nil {
+		job.State = constants.JobFailed
+		job.Error = err.Error()
+	} else {
+		job.State = constants.JobSucceeded
+		if resp != nil {
+			job.Result = *resp
+		}
+	}
+	c.saveJob(job)
+
+	return resp, err
+}
+
+// saveJob saves the job state, a job which cannot be saved does not fail the work it tracks
+func (c commandUsecase) saveJob(job *entity.WorkerJob) {
+	// the job context may be the one which was canceled
+	ctx := context.Background()
+	if resp := <-c.workerRepositoryCommand.UpdateOneWorkerJob(ctx, *job); resp.Error != nil {
+		c.logger.Error(ctx, fmt.Sprintf("Failed save worker job %s", job.Id), resp.Error.Error())
+	}
+}
+
+// FailAbandonedWorkerJob fails the jobs this instance left queued or running when it stopped, and those of other
+// instances which were not saved for the stale period, a job saves its progress after every chunk
+func (c commandUsecase) FailAbandonedWorkerJob(origCtx context.Context) (int64, error) {
+	domain := "workerUsecase-FailAbandonedWorkerJob"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	before := time.Now().Add(-jobStaleAfter())
+	resp := <-c.workerRepositoryCommand.FailAllAbandonedWorkerJob(ctx, c.instance, before, "job abandoned, the worker stopped before it finished")
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	failed, _ := resp.Data.(int64)
+	return failed, nil
+}
+
+func jobStaleAfter() time.Duration {
+	staleAfter, err := time.ParseDuration(configs.GetConfig().Worker.JobStaleAfter)
+	if err != nil || staleAfter <= 0 {
+		return defaultJobStaleAfter
+	}
+	return staleAfter
+}
+
+// Close cancels the background jobs and waits for them to stop
+func (c commandUsecase) Close(ctx context.Context) error {
+	c.cancelJobs()
+
+	done := make(chan struct{})
+	go func() {
+		c.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,483 @@
+package usecases
+
+import (
+	"context"
+	"time"
+	"worker-service/configs"
+	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+
+	"github.com/google/uuid"
+	"go.elastic.co/apm"
+)
+
+const (
+	defaultOrphanGracePeriod     = time.Hour
+	defaultOrphanPaymentLookback = 7 * 24 * time.Hour
+)
+
+// errOrphanChanged aborts the fix of an orphan whose seat was paid, reserved again or released since it was checked
+var errOrphanChanged = errors.Conflict("orphan changed since it was checked")
+
+// orphanRules are how each kind of orphan is resolved, a record younger than the grace period may still be in flight
+// and a payment created before the lookback is no longer scanned
+type orphanRules struct {
+	gracePeriod     time.Duration
+	paymentLookback time.Duration
+	order           string
+	bankTicket      string
+	payment         string
+}
+
+func newOrphanRules() orphanRules {
+	worker := configs.GetConfig().Worker
+	gracePeriod, err := time.ParseDuration(worker.OrphanGracePeriod)
+	if err != nil || gracePeriod <= 0 {
+		gracePeriod = defaultOrphanGracePeriod
+	}
+	paymentLookback, err := time.ParseDuration(worker.OrphanPaymentLookback)
+	if err != nil || paymentLookback <= 0 {
+		paymentLookback = defaultOrphanPaymentLookback
+	}
+	return orphanRules{
+		gracePeriod:     gracePeriod,
+		paymentLookback: paymentLookback,
+		order:           orphanAction(worker.OrphanOrderAction, constants.OrphanActionDelete),
+		bankTicket:      orphanAction(worker.OrphanBankTicketAction, constants.OrphanActionRelease),
+		payment:         orphanAction(worker.OrphanPaymentAction, constants.OrphanActionRelease),
+	}
+}
+
+// orphanAction is the configured action when it is the fix of the orphan, anything else only reports it
+func orphanAction(action, fix string) string {
+	if action == fix {
+		return fix
+	}
+	return constants.OrphanActionReport
+}
+
+// recordOrphan adds the outcome of a record to the run, orphan is nil when the links of the record are consistent.
+// Orphans are listed up to maxRunRecords, the ones past it are only counted.
+func recordOrphan(run *entity.WorkerJobRun, id string, orphan *entity.Orphan, err error) {
+	if orphan != nil {
+		if len(run.Orphans) < maxRunRecords {
+			run.Orphans = append(run.Orphans, *orphan)
+		} else {
+			run.OrphansOmitted++
+		}
+	}
+	switch {
+	case err != nil:
+		failRun(run, id, err)
+	case orphan == nil:
+		run.Skipped++
+	case orphan.Fixed:
+		run.Reclaimed++
+	}
+}
+
+// orphanAudit is the audit record of a fixed orphan
+func orphanAudit(runId string, orphan entity.Orphan, ticketId, userId string) entity.OrphanAudit {
+	orphan.Fixed = true
+	return entity.OrphanAudit{
+		Id:        uuid.NewString(),
+		RunId:     runId,
+		Orphan:    orphan,
+		TicketId:  ticketId,
+		UserId:    userId,
+		CreatedAt: time.Now(),
+	}
+}
+
+// CleanupOrphan finds the orders, payments and bank tickets whose links by ticketNumber are broken and resolves each
+// with the configured rule. Every orphan is reported on the run, a fixed one is counted as reclaimed and saves an
+// audit record with its fix, a consistent record is counted as skipped.
+func (c commandUsecase) CleanupOrphan(origCtx context.Context) (*entity.WorkerJobRun, error) {
+	domain := "workerUsecase-CleanupOrphan"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	run := newRun(constants.JobTypeCleanupOrphan)
+	rules := newOrphanRules()
+	before := time.Now().Add(-rules.gracePeriod)
+
+	// payments go first since releasing their seats deletes their orders, which are then not seen as orphans
+	cleanups := []func(context.Context, *entity.WorkerJobRun, orphanRules, time.Time) error{
+		c.cleanupOrphanPayments,
+		c.cleanupOrphanBankTickets,
+		c.cleanupOrphanOrders,
+	}
+	for _, cleanup := range cleanups {
+		if err := cleanup(ctx, run, rules, before); err != nil {
+			c.finishRun(ctx, run, err)
+			return nil, err
+		}
+		if run.TimedOut {
+			break
+		}
+	}
+
+	c.finishRun(ctx, run, nil)
+	return run, nil
+}
+
+// cleanupOrphanPayments only scans the invalid payments created within the lookback, so a run does not grow with every
+// payment ever invalidated. The seat of an older one still held is found as an orphan bank ticket.
+func (c commandUsecase) cleanupOrphanPayments(ctx context.Context, run *entity.WorkerJobRun, rules orphanRules, before time.Time) error {
+	from := before.Add(-rules.paymentLookback)
+	page := request.KeysetPageReq{Size: expiryBatchSize()}
+	for {
+		paymentData := <-c.workerRepositoryQuery.FindAllInvalidPayment(ctx, page, from, before)
+		if paymentData.Error != nil {
+			return paymentData.Error
+		}
+		if paymentData.Data == nil {
+			return nil
+		}
+
+		payments, ok := paymentData.Data.(*[]entity.PaymentHistory)
+		if !ok {
+			return errors.InternalServerError("cannot parsing data payment")
+		}
+
+		run.Scanned += len(*payments)
+		for _, p := range *payments {
+			orphan, err := c.cleanupOrphanPayment(ctx, run.Id, rules.payment, p)
+			recordOrphan(run, p.PaymentId, orphan, err)
+		}
+
+		if int64(len(*payments)) < page.Size {
+			return nil
+		}
+		if ctx.Err() != nil {
+			run.TimedOut = true
+			return nil
+		}
+		last := (*payments)[len(*payments)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterId = last.Id
+	}
+}
+
+// cleanupOrphanPayment resolves an invalidated payment whose seat is still held by its user without a valid payment,
+// its release deletes the order and gives the seat back
+func (c commandUsecase) cleanupOrphanPayment(ctx context.Context, runId, action string, p entity.PaymentHistory) (*entity.Orphan, error) {
+	if p.Ticket == nil {
+		return nil, nil
+	}
+
+	ticketNumber := p.Ticket.TicketNumber
+	bankTicket, err := c.findBankTicket(ctx, c.workerRepositoryQuery, ticketNumber)
+	if err != nil {
+		return nil, err
+	}
+	// the seat was released, or reserved again by another user
+	if bankTicket == nil || !bankTicket.IsUsed || bankTicket.UserId != p.UserId {
+		return nil, nil
+	}
+
+	paymentData := <-c.workerRepositoryQuery.FindPaymentByTicketNumber(ctx, ticketNumber)
+	if paymentData.Error != nil {
+		return nil, paymentData.Error
+	}
+	if paymentData.Data != nil {
+		return nil, nil
+	}
+
+	orphan := &entity.Orphan{
+		Kind:         constants.OrphanPayment,
+		TicketNumber: ticketNumber,
+		PaymentId:    p.PaymentId,
+		Reason:       "payment invalidated while its seat is held",
+		Action:       action,
+	}
+	c.logger.Info(ctx, "Orphan Payment", orphan)
+	if action != constants.OrphanActionRelease {
+		return orphan, nil
+	}
+
+	ticketDetail, err := c.findTicketDetail(ctx, bankTicket.TicketId)
+	if err != nil {
+		return orphan, err
+	}
+
+	// the order, the seat and the inventory are released together with their event and audit or not at all
+	err = c.workerRepositoryCommand.WithTransaction(ctx, func(txCtx context.Context) error {
+		if err := c.releaseOrphanSeat(txCtx, *bankTicket, ticketDetail, constants.ReleaseOrphanPayment); err != nil {
+			return err
+		}
+
+		deleteOrderResp := <-c.workerRepositoryCommand.DeleteOneOrder(txCtx, ticketNumber)
+		if deleteOrderResp.Error != nil {
+			return deleteOrderResp.Error
+		}
+
+		auditResp := <-c.workerRepositoryCommand.InsertOneOrphanAudit(txCtx, orphanAudit(runId, *orphan, bankTicket.TicketId, p.UserId))
+		return auditResp.Error
+	})
+	if err == errOrphanChanged {
+		c.logger.Info(ctx, "Skip Orphan Payment changed meanwhile", orphan)
+		return nil, nil
+	}
+	if err != nil {
+		return orphan, err
+	}
+
+	orphan.Fixed = true
+	c.releaseInventory(ctx, ticketDetail)
+	c.pushSeatPool(ctx, ticketDetail.TicketId, ticketNumber)
+	return orphan, nil
+}
+
+func (c commandUsecase) cleanupOrphanBankTickets(ctx context.Context, run *entity.WorkerJobRun, rules orphanRules, before time.Time) error {
+	page := request.KeysetPageReq{Size: expiryBatchSize()}
+	for {
+		bankTicketData := <-c.workerRepositoryQuery.FindAllUsedBankTicket(ctx, page, before)
+		if bankTicketData.Error != nil {
+			return bankTicketData.Error
+		}
+		if bankTicketData.Data == nil {
+			return nil
+		}
+
+		bankTickets, ok := bankTicketData.Data.(*[]entity.BankTicket)
+		if !ok {
+			return errors.InternalServerError("cannot parsing data bank ticket")
+		}
+
+		run.Scanned += len(*bankTickets)
+		for _, b := range *bankTickets {
+			orphan, err := c.cleanupOrphanBankTicket(ctx, run.Id, rules.bankTicket, b)
+			recordOrphan(run, b.TicketNumber, orphan, err)
+		}
+
+		if int64(len(*bankTickets)) < page.Size {
+			return nil
+		}
+		if ctx.Err() != nil {
+			run.TimedOut = true
+			return nil
+		}
+		last := (*bankTickets)[len(*bankTickets)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterId = last.Id
+	}
+}
+
+// cleanupOrphanBankTicket resolves a used bank ticket which neither an order nor a valid payment holds,
+// its release gives the seat back
+func (c commandUsecase) cleanupOrphanBankTicket(ctx context.Context, runId, action string, b entity.BankTicket) (*entity.Orphan, error) {
+	orderData := <-c.workerRepositoryQuery.FindOrderByTicketNumber(ctx, b.TicketNumber)
+	if orderData.Error != nil {
+		return nil, orderData.Error
+	}
+	if orderData.Data != nil {
+		return nil, nil
+	}
+
+	paymentData := <-c.workerRepositoryQuery.FindPaymentByTicketNumber(ctx, b.TicketNumber)
+	if paymentData.Error != nil {
+		return nil, paymentData.Error
+	}
+	if paymentData.Data != nil {
+		return nil, nil
+	}
+
+	orphan := &entity.Orphan{
+		Kind:         constants.OrphanBankTicket,
+		TicketNumber: b.TicketNumber,
+		Reason:       "bank ticket used without an order or a valid payment",
+		Action:       action,
+	}
+	c.logger.Info(ctx, "Orphan Bank Ticket", orphan)
+	if action != constants.OrphanActionRelease {
+		return orphan, nil
+	}
+
+	ticketDetail, err := c.findTicketDetail(ctx, b.TicketId)
+	if err != nil {
+		return orphan, err
+	}
+
+	// the seat and the inventory are released together with their event and audit or not at all
+	err = c.workerRepositoryCommand.WithTransaction(ctx, func(txCtx context.Context) error {
+		if err := c.releaseOrphanSeat(txCtx, b, ticketDetail, constants.ReleaseOrphanBankTicket); err != nil {
+			return err
+		}
+
+		auditResp := <-c.workerRepositoryCommand.InsertOneOrphanAudit(txCtx, orphanAudit(runId, *orphan, b.TicketId, b.UserId))
+		return auditResp.Error
+	})
+	if err == errOrphanChanged {
+		c.logger.Info(ctx, "Skip Orphan Bank Ticket changed meanwhile", orphan)
+		return nil, nil
+	}
+	if err != nil {
+		return orphan, err
+	}
+
+	orphan.Fixed = true
+	c.releaseInventory(ctx, ticketDetail)
+	c.pushSeatPool(ctx, ticketDetail.TicketId, b.TicketNumber)
+	return orphan, nil
+}
+
+func (c commandUsecase) cleanupOrphanOrders(ctx context.Context, run *entity.WorkerJobRun, rules orphanRules, before time.Time) error {
+	page := request.OrderPageReq{Size: expiryBatchSize()}
+	for {
+		orderData := <-c.workerRepositoryQuery.FindAllOrder(ctx, page, before)
+		if orderData.Error != nil {
+			return orderData.Error
+		}
+		if orderData.Data == nil {
+			return nil
+		}
+
+		orders, ok := orderData.Data.(*[]entity.Order)
+		if !ok {
+			return errors.InternalServerError("cannot parsing data order")
+		}
+
+		run.Scanned += len(*orders)
+		for _, o := range *orders {
+			orphan, err := c.cleanupOrphanOrder(ctx, run.Id, rules.order, o)
+			recordOrphan(run, o.TicketNumber, orphan, err)
+		}
+
+		if int64(len(*orders)) < page.Size {
+			return nil
+		}
+		if ctx.Err() != nil {
+			run.TimedOut = true
+			return nil
+		}
+		last := (*orders)[len(*orders)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterTicketNumber = last.TicketNumber
+	}
+}
+
+// cleanupOrphanOrder resolves an order whose bank ticket is missing or not reserved, its fix deletes the order.
+// The bank ticket is read again from the primary in the transaction of the fix, which is aborted with errOrphanChanged
+// once the seat is reserved.
+func (c commandUsecase) cleanupOrphanOrder(ctx context.Context, runId, action string, o entity.Order) (*entity.Orphan, error) {
+	bankTicket, err := c.findBankTicket(ctx, c.workerRepositoryQuery, o.TicketNumber)
+	if err != nil {
+		return nil, err
+	}
+	if bankTicket != nil && bankTicket.IsUsed {
+		return nil, nil
+	}
+
+	orphan := &entity.Orphan{
+		Kind:         constants.OrphanOrder,
+		TicketNumber: o.TicketNumber,
+		Reason:       "order without a reserved bank ticket",
+		Action:       action,
+	}
+	c.logger.Info(ctx, "Orphan Order", orphan)
+	if action != constants.OrphanActionDelete {
+		return orphan, nil
+	}
+
+	ticketId := ""
+	if bankTicket != nil {
+		ticketId = bankTicket.TicketId
+	}
+	err = c.workerRepositoryCommand.WithTransaction(ctx, func(txCtx context.Context) error {
+		current, err := c.findBankTicket(txCtx, c.workerRepositoryPrimary, o.TicketNumber)
+		if err != nil {
+			return err
+		}
+		if current != nil && current.IsUsed {
+			return errOrphanChanged
+		}
+
+		deleteOrderResp := <-c.workerRepositoryCommand.DeleteOneOrder(txCtx, o.TicketNumber)
+		if deleteOrderResp.Error != nil {
+			return deleteOrderResp.Error
+		}
+
+		auditResp := <-c.workerRepositoryCommand.InsertOneOrphanAudit(txCtx, orphanAudit(runId, *orphan, ticketId, o.UserId))
+		return auditResp.Error
+	})
+	if err == errOrphanChanged {
+		c.logger.Info(ctx, "Skip Orphan Order changed meanwhile", orphan)
+		return nil, nil
+	}
+	if err != nil {
+		return orphan, err
+	}
+
+	orphan.Fixed = true
+	return orphan, nil
+}
+
+// releaseOrphanSeat gives the seat of an orphan back to its ticket detail with its event, in the transaction of the fix.
+// The seat is only released while it is held as it was checked, otherwise the fix is aborted with errOrphanChanged.
+func (c commandUsecase) releaseOrphanSeat(txCtx context.Context, b entity.BankTicket, ticketDetail *entity.TicketDetail, reason string) error {
+	bankTicketResp := <-c.workerRepositoryCommand.ReleaseOneBankTicket(txCtx, request.ReleaseBankTicketReq{
+		TicketNumber: b.TicketNumber,
+		UserId:       b.UserId,
+		UpdatedAt:    b.UpdatedAt,
+		Price:        ticketDetail.TicketPrice,
+	})
+	if bankTicketResp.Error != nil {
+		return bankTicketResp.Error
+	}
+	if bankTicketResp.Data == nil {
+		return errOrphanChanged
+	}
+
+	if err := c.releaseTicketDetail(txCtx, ticketDetail.TicketId); err != nil {
+		return err
+	}
+
+	return c.saveEvents(txCtx, ticketReleasedEvent(entity.Ticket{
+		TicketNumber: b.TicketNumber,
+		EventId:      b.EventId,
+		TicketType:   b.TicketType,
+		SeatNumber:   b.SeatNumber,
+		CountryCode:  b.CountryCode,
+		TicketId:     b.TicketId,
+	}, reason))
+}
+
+// findBankTicket reads the bank ticket of a ticket number from repository, nil when it does not exist
+func (c commandUsecase) findBankTicket(ctx context.Context, repository worker.MongodbRepositoryQuery, ticketNumber string) (*entity.BankTicket, error) {
+	bankTicketData := <-repository.FindBankTicketByTicketNumber(ctx, ticketNumber)
+	if bankTicketData.Error != nil {
+		return nil, bankTicketData.Error
+	}
+	if bankTicketData.Data == nil {
+		return nil, nil
+	}
+
+	bankTicket, ok := bankTicketData.Data.(*entity.BankTicket)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data bank ticket")
+	}
+	return bankTicket, nil
+}
+
+func (c commandUsecase) findTicketDetail(ctx context.Context, ticketId string) (*entity.TicketDetail, error) {
+	ticketDetailData := <-c.workerRepositoryQuery.FindOneTicketDetailById(ctx, ticketId)
+	if ticketDetailData.Error != nil {
+		return nil, ticketDetailData.Error
+	}
+	if ticketDetailData.Data == nil {
+		return nil, errors.BadRequest("ticket not found")
+	}
+
+	ticketDetail, ok := ticketDetailData.Data.(*entity.TicketDetail)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data ticket")
+	}
+	return ticketDetail, nil
+}
@@ -0,0 +1,58 @@
+package usecases
+
+import (
+	"context"
+	"time"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/errors"
+
+	"go.elastic.co/apm"
+)
+
+// ClaimOutboxEvent holds a due pending outbox event for the outbox relay until the given time, false when it is not due anymore
+func (c commandUsecase) ClaimOutboxEvent(origCtx context.Context, id string, until time.Time) (bool, error) {
+	domain := "workerUsecase-ClaimOutboxEvent"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	resp := <-c.workerRepositoryCommand.ClaimOutboxEvent(ctx, id, until)
+	if resp.Error != nil {
+		return false, resp.Error
+	}
+	if resp.Data == nil {
+		return false, nil
+	}
+
+	if _, ok := resp.Data.(*entity.OutboxEvent); !ok {
+		return false, errors.InternalServerError("cannot parsing data outbox event")
+	}
+	return true, nil
+}
+
+func (c commandUsecase) UpdateOutboxEventSent(origCtx context.Context, id string) error {
+	domain := "workerUsecase-UpdateOutboxEventSent"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	resp := <-c.workerRepositoryCommand.UpdateOneOutboxEventSent(ctx, id)
+	return resp.Error
+}
+
+// UpdateOutboxEventRetry makes a pending outbox event due again at nextAttemptAt, reason is why its publish failed
+func (c commandUsecase) UpdateOutboxEventRetry(origCtx context.Context, id string, nextAttemptAt time.Time, reason string) error {
+	domain := "workerUsecase-UpdateOutboxEventRetry"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	resp := <-c.workerRepositoryCommand.UpdateOneOutboxEventRetry(ctx, id, nextAttemptAt, reason)
+	return resp.Error
+}
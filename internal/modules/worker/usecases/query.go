@@ -0,0 +1,166 @@
+package usecases
+
+import (
+	"context"
+	"time"
+	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/modules/worker/models/response"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
+	"worker-service/internal/pkg/log"
+	"worker-service/internal/pkg/redis"
+
+	"go.elastic.co/apm"
+)
+
+// defaultCronRunSize is the page size of the cron runs when none is asked
+const defaultCronRunSize = 20
+
+type queryUsecase struct {
+	workerRepositoryQuery worker.MongodbRepositoryQuery
+	redisClient           redis.Collections
+	logger                log.Logger
+}
+
+func NewQueryUsecase(wrq worker.MongodbRepositoryQuery, rc redis.Collections, log log.Logger) worker.UsecaseQuery {
+	return queryUsecase{
+		workerRepositoryQuery: wrq,
+		redisClient:           rc,
+		logger:                log,
+	}
+}
+
+func (q queryUsecase) FindWorkerJob(origCtx context.Context, id string) (*entity.WorkerJob, error) {
+	domain := "workerUsecase-FindWorkerJob"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	jobData := <-q.workerRepositoryQuery.FindOneWorkerJob(ctx, id)
+	if jobData.Error != nil {
+		return nil, jobData.Error
+	}
+	if jobData.Data == nil {
+		return nil, errors.NotFound("job not found")
+	}
+
+	job, ok := jobData.Data.(*entity.WorkerJob)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data job")
+	}
+	return job, nil
+}
+
+func (q queryUsecase) FindAllExpiryPolicy(origCtx context.Context) ([]entity.ExpiryPolicy, error) {
+	domain := "workerUsecase-FindAllExpiryPolicy"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	policyData := <-q.workerRepositoryQuery.FindAllExpiryPolicy(ctx)
+	if policyData.Error != nil {
+		return nil, policyData.Error
+	}
+
+	policies := make([]entity.ExpiryPolicy, 0)
+	if policyData.Data == nil {
+		return policies, nil
+	}
+
+	data, ok := policyData.Data.(*[]entity.ExpiryPolicy)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data expiry policy")
+	}
+	return append(policies, *data...), nil
+}
+
+func (q queryUsecase) FindAllCronJob(origCtx context.Context) ([]entity.CronJob, error) {
+	domain := "workerUsecase-FindAllCronJob"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	jobData := <-q.workerRepositoryQuery.FindAllCronJob(ctx)
+	if jobData.Error != nil {
+		return nil, jobData.Error
+	}
+
+	jobs := make([]entity.CronJob, 0)
+	if jobData.Data == nil {
+		return jobs, nil
+	}
+
+	data, ok := jobData.Data.(*[]entity.CronJob)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data cron job")
+	}
+	return append(jobs, *data...), nil
+}
+
+func (q queryUsecase) FindAllCronRun(origCtx context.Context, payload request.CronRunReq) (*response.CronRunResp, error) {
+	domain := "workerUsecase-FindAllCronRun"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	if payload.Page == 0 {
+		payload.Page = 1
+	}
+	if payload.Size == 0 {
+		payload.Size = defaultCronRunSize
+	}
+
+	runData := <-q.workerRepositoryQuery.FindAllWorkerJobRun(ctx, payload)
+	if runData.Error != nil {
+		return nil, runData.Error
+	}
+
+	runs := make([]entity.WorkerJobRun, 0)
+	if runData.Data != nil {
+		data, ok := runData.Data.(*[]entity.WorkerJobRun)
+		if !ok {
+			return nil, errors.InternalServerError("cannot parsing data cron run")
+		}
+		runs = append(runs, *data...)
+	}
+
+	return &response.CronRunResp{
+		CollectionData: runs,
+		MetaData:       helpers.GenerateMetaData(runData.Count, int64(len(runs)), payload.Page, payload.Size),
+	}, nil
+}
+
+func (q queryUsecase) FindAllPendingOutboxEvent(origCtx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	domain := "workerUsecase-FindAllPendingOutboxEvent"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	outboxData := <-q.workerRepositoryQuery.FindAllPendingOutboxEvent(ctx, int64(limit))
+	if outboxData.Error != nil {
+		return nil, outboxData.Error
+	}
+
+	outboxEvents := make([]entity.OutboxEvent, 0)
+	if outboxData.Data == nil {
+		return outboxEvents, nil
+	}
+
+	data, ok := outboxData.Data.(*[]entity.OutboxEvent)
+	if !ok {
+		return nil, errors.InternalServerError("cannot parsing data outbox event")
+	}
+	return append(outboxEvents, *data...), nil
+}
@@ -0,0 +1,210 @@
+package usecases_test
+
+import (
+	"context"
+	"testing"
+	"worker-service/internal/modules/worker"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	uc "worker-service/internal/modules/worker/usecases"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/helpers"
+	mockcert "worker-service/mocks/modules/worker"
+	mocklog "worker-service/mocks/pkg/log"
+	mockredis "worker-service/mocks/pkg/redis"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueryUsecaseTestSuite struct {
+	suite.Suite
+	mockWorkerRepositoryQuery *mockcert.MongodbRepositoryQuery
+	mockRedis                 *mockredis.Collections
+	mockLogger                *mocklog.Logger
+	usecase                   worker.UsecaseQuery
+	ctx                       context.Context
+}
+
+func (suite *QueryUsecaseTestSuite) SetupTest() {
+	suite.mockWorkerRepositoryQuery = &mockcert.MongodbRepositoryQuery{}
+	suite.mockRedis = &mockredis.Collections{}
+	suite.mockLogger = &mocklog.Logger{}
+	suite.ctx = context.Background()
+	suite.usecase = uc.NewQueryUsecase(
+		suite.mockWorkerRepositoryQuery,
+		suite.mockRedis,
+		suite.mockLogger,
+	)
+}
+
+func TestQueryUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(QueryUsecaseTestSuite))
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindWorkerJob() {
+	mockJob := helpers.Result{
+		Data: &entity.WorkerJob{
+			Id: "id",
+		},
+		Error: nil,
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindOneWorkerJob", mock.Anything, "id").Return(mockChannel(mockJob))
+	job, err := suite.usecase.FindWorkerJob(suite.ctx, "id")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "id", job.Id)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindWorkerJobErr() {
+	mockJob := helpers.Result{
+		Data:  nil,
+		Error: errors.InternalServerError("error"),
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindOneWorkerJob", mock.Anything, "id").Return(mockChannel(mockJob))
+	_, err := suite.usecase.FindWorkerJob(suite.ctx, "id")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindWorkerJobErrNil() {
+	suite.mockWorkerRepositoryQuery.On("FindOneWorkerJob", mock.Anything, "id").Return(mockChannel(helpers.Result{}))
+	_, err := suite.usecase.FindWorkerJob(suite.ctx, "id")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindWorkerJobErrParse() {
+	mockJob := helpers.Result{
+		Data:  &entity.Country{},
+		Error: nil,
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindOneWorkerJob", mock.Anything, "id").Return(mockChannel(mockJob))
+	_, err := suite.usecase.FindWorkerJob(suite.ctx, "id")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllExpiryPolicy() {
+	mockPolicies := helpers.Result{
+		Data: &[]entity.ExpiryPolicy{{Id: "*:*:*", WindowMinutes: 15}},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindAllExpiryPolicy", mock.Anything).Return(mockChannel(mockPolicies))
+	policies, err := suite.usecase.FindAllExpiryPolicy(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), policies, 1)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllExpiryPolicyErr() {
+	suite.mockWorkerRepositoryQuery.On("FindAllExpiryPolicy", mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	_, err := suite.usecase.FindAllExpiryPolicy(suite.ctx)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllCronJob() {
+	mockJobs := helpers.Result{
+		Data: &[]entity.CronJob{{Name: "expiry-payment", Spec: "*/5 * * * *"}},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindAllCronJob", mock.Anything).Return(mockChannel(mockJobs))
+	jobs, err := suite.usecase.FindAllCronJob(suite.ctx)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), jobs, 1)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllCronJobErr() {
+	suite.mockWorkerRepositoryQuery.On("FindAllCronJob", mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	_, err := suite.usecase.FindAllCronJob(suite.ctx)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllCronRun() {
+	mockRuns := helpers.Result{
+		Data:  &[]entity.WorkerJobRun{{Id: "run", Job: "expiry-payment"}},
+		Count: 41,
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindAllWorkerJobRun", mock.Anything, request.CronRunReq{Job: "expiry-payment", Page: 1, Size: 20}).Return(mockChannel(mockRuns))
+	resp, err := suite.usecase.FindAllCronRun(suite.ctx, request.CronRunReq{Job: "expiry-payment"})
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), resp.CollectionData, 1)
+	assert.Equal(suite.T(), int64(3), resp.MetaData.TotalPage)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllCronRunErr() {
+	suite.mockWorkerRepositoryQuery.On("FindAllWorkerJobRun", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	_, err := suite.usecase.FindAllCronRun(suite.ctx, request.CronRunReq{Job: "expiry-payment"})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllPendingOutboxEvent() {
+	mockOutboxEvents := helpers.Result{
+		Data: &[]entity.OutboxEvent{{Id: "event", Topic: "concert-ticket-released"}},
+	}
+
+	suite.mockWorkerRepositoryQuery.On("FindAllPendingOutboxEvent", mock.Anything, int64(100)).Return(mockChannel(mockOutboxEvents))
+	outboxEvents, err := suite.usecase.FindAllPendingOutboxEvent(suite.ctx, 100)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), outboxEvents, 1)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindAllPendingOutboxEventErr() {
+	suite.mockWorkerRepositoryQuery.On("FindAllPendingOutboxEvent", mock.Anything, mock.Anything).Return(mockChannel(helpers.Result{Error: errors.InternalServerError("error")}))
+	_, err := suite.usecase.FindAllPendingOutboxEvent(suite.ctx, 100)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindInventory() {
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:ID").Return(redis.NewStringResult("12", nil))
+
+	resp, err := suite.usecase.FindInventory(suite.ctx, "id", "ID")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 12, resp.TotalRemaining)
+	assert.Equal(suite.T(), constants.InventorySourceRedis, resp.Source)
+	suite.mockWorkerRepositoryQuery.AssertNotCalled(suite.T(), "FindOneTicketDetailById", mock.Anything, mock.Anything)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindInventoryNotSeeded() {
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:ID").Return(redis.NewStringResult("", redis.Nil))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalRemaining: 8, Country: entity.Country{Code: "ID"}},
+	}))
+
+	resp, err := suite.usecase.FindInventory(suite.ctx, "id", "ID")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 8, resp.TotalRemaining)
+	assert.Equal(suite.T(), constants.InventorySourceMongo, resp.Source)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindInventoryErrRedis() {
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:ID").Return(redis.NewStringResult("", errors.InternalServerError("redis down")))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", TotalRemaining: 8, Country: entity.Country{Code: "ID"}},
+	}))
+
+	resp, err := suite.usecase.FindInventory(suite.ctx, "id", "ID")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), constants.InventorySourceMongo, resp.Source)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindInventoryErrCountry() {
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:SG").Return(redis.NewStringResult("", redis.Nil))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(mockChannel(helpers.Result{
+		Data: &entity.TicketDetail{TicketId: "id", Country: entity.Country{Code: "ID"}},
+	}))
+
+	_, err := suite.usecase.FindInventory(suite.ctx, "id", "SG")
+	assert.Equal(suite.T(), errors.NotFound("ticket detail not found"), err)
+}
+
+func (suite *QueryUsecaseTestSuite) TestFindInventoryErrNotFound() {
+	suite.mockRedis.On("Get", mock.Anything, "INVENTORY:id:ID").Return(redis.NewStringResult("", redis.Nil))
+	suite.mockWorkerRepositoryQuery.On("FindOneTicketDetailById", mock.Anything, "id").Return(mockChannel(helpers.Result{}))
+
+	_, err := suite.usecase.FindInventory(suite.ctx, "id", "ID")
+	assert.Equal(suite.T(), errors.NotFound("ticket detail not found"), err)
+}
@@ -0,0 +1,88 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/scheduler"
+
+	"github.com/google/uuid"
+)
+
+// maxRunRecords caps the records listed on a run, its report is one mongo document which must stay under 16MB
+const maxRunRecords = 1000
+
+// newRun starts the report of a cron job run
+func newRun(job string) *entity.WorkerJobRun {
+	return &entity.WorkerJobRun{
+		Id:        uuid.NewString(),
+		Job:       job,
+		Failures:  make([]entity.RunFailure, 0),
+		StartedAt: time.Now(),
+	}
+}
+
+// failRun records a record the run could not process, the failures past maxRunRecords are only counted
+func failRun(run *entity.WorkerJobRun, id string, err error) {
+	run.Failed++
+	if len(run.Failures) >= maxRunRecords {
+		run.FailuresOmitted++
+		return
+	}
+	run.Failures = append(run.Failures, entity.RunFailure{
+		Id:     id,
+		Reason: err.Error(),
+	})
+}
+
+// addDiscrepancies lists discrepancies on the run up to maxRunRecords, the ones past it are only counted
+func addDiscrepancies(run *entity.WorkerJobRun, discrepancies ...entity.Discrepancy) {
+	for _, discrepancy := range discrepancies {
+		if len(run.Discrepancies) >= maxRunRecords {
+			run.DiscrepanciesOmitted++
+			continue
+		}
+		run.Discrepancies = append(run.Discrepancies, discrepancy)
+	}
+}
+
+// runStatus is the outcome of a run, a run which timed out or failed some records still processed the others
+func runStatus(run *entity.WorkerJobRun, err error) string {
+	switch {
+	case err != nil:
+		return constants.RunFailed
+	case run.TimedOut:
+		return constants.RunTimedOut
+	case run.Failed > 0:
+		return constants.RunPartial
+	default:
+		return constants.RunSucceeded
+	}
+}
+
+// finishRun logs and saves the report of a run, err is the error which stopped the whole run
+func (c commandUsecase) finishRun(ctx context.Context, run *entity.WorkerJobRun, err error) {
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Fence = scheduler.FenceFromContext(ctx)
+	run.Instance = scheduler.InstanceFromContext(ctx)
+	run.Status = runStatus(run, err)
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	msg := fmt.Sprintf("Run %s %s in %dms: scanned %d, reclaimed %d, fixed %d, skipped %d, failed %d",
+		run.Job, run.Status, run.DurationMs, run.Scanned, run.Reclaimed, run.Fixed, run.Skipped, run.Failed)
+	if err != nil || run.Failed > 0 {
+		c.logger.Error(ctx, msg, run)
+	} else {
+		c.logger.Info(ctx, msg, run)
+	}
+
+	// a report which cannot be saved does not fail the run
+	if resp := <-c.workerRepositoryCommand.InsertOneWorkerJobRun(ctx, *run); resp.Error != nil {
+		c.logger.Error(ctx, fmt.Sprintf("Failed save worker job run %s", run.Id), resp.Error.Error())
+	}
+}
@@ -0,0 +1,143 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"worker-service/internal/modules/worker/models/dto"
+	"worker-service/internal/modules/worker/models/entity"
+	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/redis"
+
+	"go.elastic.co/apm"
+)
+
+const (
+	seatPoolLockTTL   = 5 * time.Minute
+	seatPoolBatchSize = 1000
+	// seatPoolRebuildMarker keeps the rebuild of a pool existing from its start, so seats pushed meanwhile are added to it
+	seatPoolRebuildMarker = "REBUILDING"
+)
+
+// seatPoolKey is the redis set of the ticket numbers of the free seats of a ticket,
+// its rebuild key shares its hash tag so it can be renamed over it on a redis cluster
+func seatPoolKey(ticketId string) string {
+	return fmt.Sprintf("%s:{%s}", constants.RedisKeySeatPool, ticketId)
+}
+
+func seatPoolRebuildKey(ticketId string) string {
+	return fmt.Sprintf("%s:{%s}", constants.RedisKeySeatPoolRebuild, ticketId)
+}
+
+// seatMembers are the ticket numbers of tickets as members of a seat pool
+func seatMembers(tickets []entity.BankTicket) []interface{} {
+	members := make([]interface{}, 0, len(tickets))
+	for _, ticket := range tickets {
+		members = append(members, ticket.TicketNumber)
+	}
+	return members
+}
+
+// pushSeatPool adds free seats to the pool of a ticket once they are saved in mongo, and to the rebuild of the pool
+// while there is one. Seats which cannot be added are picked up by the next rebuild of the pool.
+func (c commandUsecase) pushSeatPool(ctx context.Context, ticketId string, ticketNumbers ...interface{}) {
+	if len(ticketNumbers) == 0 {
+		return
+	}
+	if _, err := redis.AddSet(ctx, c.redisClient, seatPoolKey(ticketId), seatPoolRebuildKey(ticketId), ticketNumbers...); err != nil {
+		c.logger.Error(ctx, fmt.Sprintf("Failed push seat pool %s", ticketId), err.Error())
+	}
+}
+
+// RebuildSeatPool replaces the seat pool of a ticket with its bank tickets which are not used.
+// The pool is built aside from the primary and replaces the live pool at once, so it is never read half built and
+// the seats used before the rebuild are dropped. Seats pushed while it is rebuilt are added to the rebuild as well.
+// A seat taken while it is rebuilt, after it was read or pushed, may still be in it, whoever pops a seat checks
+// it is not used in mongo before assigning it.
+func (c commandUsecase) RebuildSeatPool(origCtx context.Context, ticketId string) (*dto.SeatPool, error) {
+	domain := "workerUsecase-RebuildSeatPool"
+	span, ctx := apm.StartSpanOptions(origCtx, domain, "function", apm.SpanOptions{
+		Start:  time.Now(),
+		Parent: apm.TraceContext{},
+	})
+	defer span.End()
+
+	lockKey := fmt.Sprintf("%s:%s", constants.RedisKeySeatPoolLock, ticketId)
+	lock, err := redis.AcquireLock(ctx, c.redisClient, lockKey, seatPoolLockTTL)
+	if err == redis.ErrLockNotAcquired {
+		return nil, errors.Conflict("rebuild seat pool already in progress")
+	}
+	if err != nil {
+		return nil, errors.InternalServerError(fmt.Sprintf("cannot acquire seat pool lock: %v", err))
+	}
+	defer func() {
+		if err := lock.Release(context.Background()); err != nil {
+			c.logger.Error(ctx, "Failed release seat pool lock", err.Error())
+		}
+	}()
+
+	// a rebuild which crashed may have left its seats behind, the marker is set before the seats are read
+	// so a seat saved after the read started is pushed to the rebuild
+	rebuildKey := seatPoolRebuildKey(ticketId)
+	if err := c.redisClient.Del(ctx, rebuildKey).Err(); err != nil {
+		return nil, err
+	}
+	if err := c.redisClient.SAdd(ctx, rebuildKey, seatPoolRebuildMarker).Err(); err != nil {
+		return nil, err
+	}
+	replaced := false
+	defer func() {
+		if replaced {
+			return
+		}
+		// seats are no longer pushed to a rebuild which failed
+		if err := c.redisClient.Del(context.Background(), rebuildKey).Err(); err != nil {
+			c.logger.Error(ctx, "Failed remove seat pool rebuild", err.Error())
+		}
+	}()
+
+	unused := 0
+	page := request.KeysetPageReq{Size: seatPoolBatchSize}
+	for {
+		bankTicketData := <-c.workerRepositoryPrimary.FindAllUnusedBankTicket(ctx, ticketId, page)
+		if bankTicketData.Error != nil {
+			return nil, bankTicketData.Error
+		}
+		if bankTicketData.Data == nil {
+			break
+		}
+
+		bankTickets, ok := bankTicketData.Data.(*[]entity.BankTicket)
+		if !ok {
+			return nil, errors.InternalServerError("cannot parsing data bank ticket")
+		}
+		if len(*bankTickets) > 0 {
+			if err := c.redisClient.SAdd(ctx, rebuildKey, seatMembers(*bankTickets)...).Err(); err != nil {
+				return nil, err
+			}
+			unused += len(*bankTickets)
+		}
+
+		if int64(len(*bankTickets)) < page.Size {
+			break
+		}
+		if err := lock.Refresh(ctx, seatPoolLockTTL); err != nil {
+			return nil, errors.Conflict(fmt.Sprintf("seat pool lock lost: %v", err))
+		}
+		last := (*bankTickets)[len(*bankTickets)-1]
+		page.AfterCreatedAt = last.CreatedAt
+		page.AfterId = last.Id
+	}
+
+	size, err := redis.ReplaceSet(ctx, c.redisClient, rebuildKey, seatPoolKey(ticketId), seatPoolRebuildMarker)
+	if err != nil {
+		return nil, err
+	}
+	replaced = true
+
+	pool := &dto.SeatPool{TicketId: ticketId, Size: int(size)}
+	c.logger.Info(ctx, fmt.Sprintf("Rebuilt seat pool %s from %d unused seats", ticketId, unused), pool)
+	return pool, nil
+}
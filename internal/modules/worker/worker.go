@@ -2,37 +2,104 @@ package worker
 
 import (
 	"context"
+	"time"
+	"worker-service/internal/modules/worker/models/dto"
 	"worker-service/internal/modules/worker/models/entity"
 	"worker-service/internal/modules/worker/models/request"
+	"worker-service/internal/modules/worker/models/response"
 	wrapper "worker-service/internal/pkg/helpers"
 )
 
 type UsecaseCommand interface {
 	CreateBankTicket(origCtx context.Context, payload request.CreateTicketReq) (*string, error)
-	UpdateAllExpiryPayment(origCtx context.Context) (*string, error)
+	EnqueueBankTicketJob(origCtx context.Context, payload request.CreateTicketReq) (*entity.WorkerJob, error)
+	UpdateAllExpiryPayment(origCtx context.Context) (*entity.WorkerJobRun, error)
 	CreateOnlineBankTicket(origCtx context.Context, payload request.CreateOnlineTicketReq) (*string, error)
-	UpdateAllExpiryBankTicket(origCtx context.Context) (*string, error)
+	SimulateOnlineBankTicket(origCtx context.Context, payload request.SimulateOnlineTicketReq) (*dto.OnlineTicketSimulation, error)
+	UpdateAllExpiryBankTicket(origCtx context.Context) (*entity.WorkerJobRun, error)
+	UpsertExpiryPolicy(origCtx context.Context, payload request.UpsertExpiryPolicyReq) (*entity.ExpiryPolicy, error)
+	DeleteExpiryPolicy(origCtx context.Context, id string) error
+	UpsertCronJob(origCtx context.Context, job entity.CronJob) error
+	ClaimOutboxEvent(origCtx context.Context, id string, until time.Time) (bool, error)
+	UpdateOutboxEventSent(origCtx context.Context, id string) error
+	UpdateOutboxEventRetry(origCtx context.Context, id string, nextAttemptAt time.Time, reason string) error
+	ReconcileInventory(origCtx context.Context) (*entity.WorkerJobRun, error)
+	RebuildSeatPool(origCtx context.Context, ticketId string) (*dto.SeatPool, error)
+	ReconcileTicketDetail(origCtx context.Context, fix bool) (*entity.WorkerJobRun, error)
+	CleanupOrphan(origCtx context.Context) (*entity.WorkerJobRun, error)
+	FailAbandonedWorkerJob(origCtx context.Context) (int64, error)
+	Close(ctx context.Context) error
+}
+
+type UsecaseQuery interface {
+	FindWorkerJob(origCtx context.Context, id string) (*entity.WorkerJob, error)
+	FindAllExpiryPolicy(origCtx context.Context) ([]entity.ExpiryPolicy, error)
+	FindAllCronJob(origCtx context.Context) ([]entity.CronJob, error)
+	FindAllCronRun(origCtx context.Context, payload request.CronRunReq) (*response.CronRunResp, error)
+	FindAllPendingOutboxEvent(origCtx context.Context, limit int) ([]entity.OutboxEvent, error)
+	FindInventory(origCtx context.Context, ticketId, countryCode string) (*dto.Inventory, error)
 }
 
 type MongodbRepositoryQuery interface {
 	FindOneTicketDetail(ctx context.Context, payload request.CreateTicketReq) <-chan wrapper.Result
 	FindOneLastTicket(ctx context.Context, countryCode string, ticketType string, eventId string, collectionName string) <-chan wrapper.Result
-	FindAllExpirePayment(ctx context.Context) <-chan wrapper.Result
-	FindAllExpireBankTicket(ctx context.Context) <-chan wrapper.Result
+	FindAllExpirePayment(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan wrapper.Result
+	FindAllExpireBankTicket(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan wrapper.Result
 	FindBankTicketByTicketNumber(ctx context.Context, ticketNumber string) <-chan wrapper.Result
 	FindOneTicketDetailById(ctx context.Context, id string) <-chan wrapper.Result
 	FindOnlineTicketConfigByTag(ctx context.Context, tag string) <-chan wrapper.Result
 	FindTotalAvalailableTicket(ctx context.Context, tag string) <-chan wrapper.Result
 	FindOneTicketDetailByTag(ctx context.Context, payload request.TicketDetailByTagReq) <-chan wrapper.Result
 	FindPaymentByTicketNumber(ctx context.Context, ticketNumber string) <-chan wrapper.Result
+	FindOneBankTicketProgress(ctx context.Context, id string) <-chan wrapper.Result
+	FindOneWorkerJob(ctx context.Context, id string) <-chan wrapper.Result
+	FindAllExpiryPolicy(ctx context.Context) <-chan wrapper.Result
+	FindAllCronJob(ctx context.Context) <-chan wrapper.Result
+	FindAllWorkerJobRun(ctx context.Context, payload request.CronRunReq) <-chan wrapper.Result
+	FindAllPendingOutboxEvent(ctx context.Context, limit int64) <-chan wrapper.Result
+	FindAllTicketDetail(ctx context.Context, page request.KeysetPageReq) <-chan wrapper.Result
+	FindAllUnusedBankTicket(ctx context.Context, ticketId string, page request.KeysetPageReq) <-chan wrapper.Result
+	AggregateBankTicketByTicketId(ctx context.Context, ticketIds []string) <-chan wrapper.Result
+	FindAllOrder(ctx context.Context, page request.OrderPageReq, before time.Time) <-chan wrapper.Result
+	FindOrderByTicketNumber(ctx context.Context, ticketNumber string) <-chan wrapper.Result
+	FindAllUsedBankTicket(ctx context.Context, page request.KeysetPageReq, before time.Time) <-chan wrapper.Result
+	FindAllInvalidPayment(ctx context.Context, page request.KeysetPageReq, from, before time.Time) <-chan wrapper.Result
 }
 
 type MongodbRepositoryCommand interface {
 	InsertManyTicketCollection(ctx context.Context, collection string, ticket []entity.BankTicket) <-chan wrapper.Result
 	DeleteOneOrder(ctx context.Context, ticketNumber string) <-chan wrapper.Result
 	UpdateOneBankTicket(ctx context.Context, payload request.UpdateBankTicketRequest) <-chan wrapper.Result
+	ReleaseOneBankTicket(ctx context.Context, payload request.ReleaseBankTicketReq) <-chan wrapper.Result
 	UpdateOnePayment(ctx context.Context, paymentId string) <-chan wrapper.Result
 	UpdateOnlineTicketConfig(ctx context.Context, payload request.UpdateOnlineTicketConfigReq) <-chan wrapper.Result
 	UpdateTicketDetailByTag(ctx context.Context, payload request.UpdateTicketDetailReq) <-chan wrapper.Result
-	UpdateTicketDetailById(ctx context.Context, payload request.UpdateTicketDetailByIdReq) <-chan wrapper.Result
+	IncrementTicketDetailRemaining(ctx context.Context, ticketId string, delta int) <-chan wrapper.Result
+	UpdateTicketDetailRemaining(ctx context.Context, ticketId string, from, to int) <-chan wrapper.Result
+	CreateBankTicketIndex(ctx context.Context) <-chan wrapper.Result
+	CreatePaymentHistoryIndex(ctx context.Context) <-chan wrapper.Result
+	CreateOrderIndex(ctx context.Context) <-chan wrapper.Result
+	CreateOutboxEventIndex(ctx context.Context) <-chan wrapper.Result
+	CreateOutboxEventSentIndex(ctx context.Context, retention time.Duration) <-chan wrapper.Result
+	BulkInsertBankTicket(ctx context.Context, ticket []entity.BankTicket) <-chan wrapper.Result
+	UpsertBankTicketProgress(ctx context.Context, progress entity.BankTicketProgress) <-chan wrapper.Result
+	InsertOneWorkerJob(ctx context.Context, job entity.WorkerJob) <-chan wrapper.Result
+	UpdateOneWorkerJob(ctx context.Context, job entity.WorkerJob) <-chan wrapper.Result
+	FailAllAbandonedWorkerJob(ctx context.Context, instance string, before time.Time, reason string) <-chan wrapper.Result
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	InsertOneWorkerJobRun(ctx context.Context, run entity.WorkerJobRun) <-chan wrapper.Result
+	UpsertExpiryPolicy(ctx context.Context, policy entity.ExpiryPolicy) <-chan wrapper.Result
+	DeleteOneExpiryPolicy(ctx context.Context, id string) <-chan wrapper.Result
+	UpsertCronJob(ctx context.Context, job entity.CronJob) <-chan wrapper.Result
+	InsertManyOutboxEvent(ctx context.Context, outboxEvents []entity.OutboxEvent) <-chan wrapper.Result
+	ClaimOutboxEvent(ctx context.Context, id string, until time.Time) <-chan wrapper.Result
+	UpdateOneOutboxEventSent(ctx context.Context, id string) <-chan wrapper.Result
+	UpdateOneOutboxEventRetry(ctx context.Context, id string, nextAttemptAt time.Time, reason string) <-chan wrapper.Result
+	InsertOneOrphanAudit(ctx context.Context, audit entity.OrphanAudit) <-chan wrapper.Result
+}
+
+// AllocationStrategy splits the online quota of a tag between countries
+type AllocationStrategy interface {
+	Name() string
+	Allocate(input dto.AllocationInput) ([]dto.CountryQuota, error)
 }
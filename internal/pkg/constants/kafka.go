@@ -0,0 +1,29 @@
+package constants
+
+// kafka topic
+const (
+	TopicCreateBankTicket       = `concert-create-bank-ticket`
+	TopicUpdateOnlineBankTicket = `concert-update-online-bank-ticket`
+)
+
+// domain event topic, each event is published on the topic named after its type
+const (
+	EventTicketReleased      = `concert-ticket-released`
+	EventPaymentExpired      = `concert-payment-expired`
+	EventBankTicketGenerated = `concert-bank-ticket-generated`
+)
+
+// version of the domain event payloads, bumped when a payload changes incompatibly
+const (
+	EventTicketReleasedVersion      = 1
+	EventPaymentExpiredVersion      = 1
+	EventBankTicketGeneratedVersion = 1
+)
+
+// reason a seat is released
+const (
+	ReleaseExpiredPayment    = `expired-payment`
+	ReleaseExpiredBankTicket = `expired-bank-ticket`
+	ReleaseOrphanBankTicket  = `orphan-bank-ticket`
+	ReleaseOrphanPayment     = `orphan-payment`
+)
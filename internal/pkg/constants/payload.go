@@ -10,3 +10,86 @@ type MetaData struct {
 var (
 	Online = "Online"
 )
+
+// state of a bank ticket generation
+const (
+	GenerationRunning   = `running`
+	GenerationCompleted = `completed`
+	GenerationFailed    = `failed`
+)
+
+// state of a worker job
+const (
+	JobQueued    = `queued`
+	JobRunning   = `running`
+	JobSucceeded = `succeeded`
+	JobFailed    = `failed`
+)
+
+// outcome of a cron job run
+const (
+	RunSucceeded = `succeeded`
+	RunPartial   = `partial`
+	RunTimedOut  = `timed-out`
+	RunFailed    = `failed`
+)
+
+// state of an outbox event
+const (
+	OutboxPending = `pending`
+	OutboxSent    = `sent`
+)
+
+// type of a worker job
+const (
+	JobTypeCreateBankTicket       = `create-bank-ticket`
+	JobTypeCreateOnlineBankTicket = `create-online-bank-ticket`
+	JobTypeExpiryPayment          = `expiry-payment`
+	JobTypeExpiryBankTicket       = `expiry-bank-ticket`
+	JobTypeReconcileInventory     = `reconcile-inventory`
+	JobTypeReconcileTicketDetail  = `reconcile-ticket-detail`
+	JobTypeCleanupOrphan          = `cleanup-orphan`
+)
+
+// source of a worker job
+const (
+	JobSourceHttp  = `http`
+	JobSourceKafka = `kafka`
+)
+
+// strategy splitting the online quota between countries
+const (
+	AllocationRankBySellout = `rank-by-sellout`
+	AllocationOfflineDemand = `offline-demand`
+	AllocationFixedQuota    = `fixed-quota`
+	AllocationWaitingQueue  = `waiting-queue`
+)
+
+// ticket detail field checked against its bank tickets
+const (
+	DiscrepancyTotalRemaining = `totalRemaining`
+	DiscrepancyTotalQuota     = `totalQuota`
+)
+
+// record whose link by ticketNumber is broken
+const (
+	OrphanOrder      = `order`
+	OrphanBankTicket = `bank-ticket`
+	OrphanPayment    = `payment`
+)
+
+// rule resolving an orphan, report only records it on the run
+const (
+	OrphanActionReport  = `report`
+	OrphanActionDelete  = `delete`
+	OrphanActionRelease = `release`
+)
+
+// source of the inventory read of a ticket detail
+const (
+	InventorySourceRedis = `redis`
+	InventorySourceMongo = `mongo`
+)
+
+// expiry window of pending payments and bank tickets when no global expiry policy is stored
+const DefaultExpiryWindowMinutes = 15
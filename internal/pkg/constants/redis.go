@@ -9,4 +9,16 @@ const (
 	RedisKeyLoginAttempt        = `LOGIN-ATTEMPT`
 	RedisKeyOtpRegister         = `OTP-REGISTER`
 	RedisKeyOtpLogin            = `OTP-LOGIN`
+	RedisKeyBankTicketLock      = `BANK-TICKET-LOCK`
+	RedisKeyIdempotency         = `IDEMPOTENCY`
+	RedisKeyWaitingQueue        = `WAITING-QUEUE`
+	RedisKeyCronLease           = `CRON-LEASE`
+	RedisKeyCronFence           = `CRON-FENCE`
+	RedisKeyCronLastRun         = `CRON-LAST-RUN`
+	RedisKeyCronQueue           = `CRON-QUEUE`
+	RedisKeyCronSlot            = `CRON-SLOT`
+	RedisKeyInventory           = `INVENTORY`
+	RedisKeySeatPool            = `SEAT-POOL`
+	RedisKeySeatPoolRebuild     = `SEAT-POOL-REBUILD`
+	RedisKeySeatPoolLock        = `SEAT-POOL-LOCK`
 )
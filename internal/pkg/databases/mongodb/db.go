@@ -56,8 +56,10 @@ type FindAllData struct {
 	CollectionName string
 	Filter         interface{}
 	Sort           *Sort
-	Page           int64
-	Size           int64
+	// ThenSort breaks the ties of Sort, in order
+	ThenSort []Sort
+	Page     int64
+	Size     int64
 }
 
 func (f FindAllData) generateOptionSkip() *int64 {
@@ -78,7 +80,11 @@ func (m MongoDBLogger) FindAllData(payload FindAllData, ctx context.Context) <-c
 		findOption := options.Find()
 
 		if payload.Sort != nil {
-			findOption.SetSort(bson.D{{Key: payload.Sort.FieldName, Value: payload.Sort.buildSortBy()}})
+			sort := bson.D{{Key: payload.Sort.FieldName, Value: payload.Sort.buildSortBy()}}
+			for _, s := range payload.ThenSort {
+				sort = append(sort, bson.E{Key: s.FieldName, Value: s.buildSortBy()})
+			}
+			findOption.SetSort(sort)
 		}
 
 		findOption.Limit = &payload.Size
@@ -330,10 +336,6 @@ func (m MongoDBLogger) UpsertOne(payload UpdateOne, ctx context.Context) <-chan
 		defer close(output)
 		start := time.Now()
 
-		wc := writeconcern.Majority()
-		rc := readconcern.Snapshot()
-		txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
-
 		collection := m.mongoClient.Database(m.dbName).Collection(payload.CollectionName)
 		pByte, err := bson.Marshal(payload.Document)
 		if err != nil {
@@ -357,10 +359,10 @@ func (m MongoDBLogger) UpsertOne(payload UpdateOne, ctx context.Context) <-chan
 		doc := bson.D{{Key: "$set", Value: update}}
 		opts := options.Update().SetUpsert(true)
 
-		callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		_, err = m.inTransaction(ctx, func(sessCtx context.Context) (interface{}, error) {
 			// Important: You must pass sessCtx as the Context parameter to the operations for them to be executed in the
 			// transaction.
-			_, err = collection.UpdateOne(sessCtx, payload.Filter, doc, opts)
+			_, err := collection.UpdateOne(sessCtx, payload.Filter, doc, opts)
 
 			if err != nil {
 				msg := fmt.Sprintf("Error Mongodb Connection : %s", err.Error())
@@ -368,19 +370,7 @@ func (m MongoDBLogger) UpsertOne(payload UpdateOne, ctx context.Context) <-chan
 				return nil, errors.InternalServerError("Error mongodb connection")
 			}
 			return nil, nil
-		}
-
-		session, err := m.mongoClient.StartSession()
-		if err != nil {
-			msg := fmt.Sprintf("Error Mongodb Session : %s", err.Error())
-			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
-			output <- wrapper.Result{
-				Error: errors.InternalServerError("Error mongodb session"),
-			}
-		}
-		defer session.EndSession(context.Background())
-
-		_, err = session.WithTransaction(ctx, callback, txnOpts)
+		})
 		if err != nil {
 			msg := fmt.Sprintf("Error Mongodb Transaction : %s", err.Error())
 			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
@@ -456,13 +446,9 @@ func (m MongoDBLogger) InsertMany(payload InsertMany, ctx context.Context) <-cha
 		defer close(output)
 		start := time.Now()
 
-		wc := writeconcern.Majority()
-		rc := readconcern.Snapshot()
-		txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
-
 		collection := m.mongoClient.Database(m.dbName).Collection(payload.CollectionName)
 
-		callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		insertDoc, err := m.inTransaction(ctx, func(sessCtx context.Context) (interface{}, error) {
 			// Important: You must pass sessCtx as the Context parameter to the operations for them to be executed in the
 			// transaction.
 			insertDoc, err := collection.InsertMany(sessCtx, payload.Documents)
@@ -473,25 +459,14 @@ func (m MongoDBLogger) InsertMany(payload InsertMany, ctx context.Context) <-cha
 				return nil, errors.InternalServerError("Error mongodb connection")
 			}
 			return insertDoc, nil
-		}
-
-		session, err := m.mongoClient.StartSession()
-		if err != nil {
-			msg := fmt.Sprintf("Error Mongodb Session : %s", err.Error())
-			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
-			output <- wrapper.Result{
-				Error: errors.InternalServerError("Error mongodb session"),
-			}
-		}
-		defer session.EndSession(context.Background())
-
-		insertDoc, err := session.WithTransaction(ctx, callback, txnOpts)
+		})
 		if err != nil {
 			msg := fmt.Sprintf("Error Mongodb Transaction : %s", err.Error())
 			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
 			output <- wrapper.Result{
 				Error: errors.InternalServerError("Error mongodb transaction"),
 			}
+			return
 		}
 
 		finish := time.Now()
@@ -510,6 +485,82 @@ func (m MongoDBLogger) InsertMany(payload InsertMany, ctx context.Context) <-cha
 	return output
 }
 
+// inTransaction runs fn in the transaction of ctx when it is the session context of WithTransaction, so its writes
+// commit or abort with the caller's, otherwise fn runs in a transaction of its own
+func (m MongoDBLogger) inTransaction(ctx context.Context, fn func(sessCtx context.Context) (interface{}, error)) (interface{}, error) {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := m.mongoClient.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(context.Background())
+
+	txnOpts := options.Transaction().SetWriteConcern(writeconcern.Majority()).SetReadConcern(readconcern.Snapshot())
+	return session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return fn(sessCtx)
+	}, txnOpts)
+}
+
+type BulkInsert struct {
+	CollectionName string
+	Documents      []interface{}
+}
+
+// BulkInsert inserts documents unordered, outside of a transaction documents rejected by a unique index are skipped
+// so a partially inserted batch can be sent again. In a transaction a rejected document aborts the transaction,
+// so it is reported as an error and callers only send documents not inserted yet. Count holds the number of inserted documents.
+func (m MongoDBLogger) BulkInsert(payload BulkInsert, ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		defer close(output)
+		start := time.Now()
+
+		collection := m.mongoClient.Database(m.dbName).Collection(payload.CollectionName)
+
+		inserted := int64(len(payload.Documents))
+		_, err := collection.InsertMany(ctx, payload.Documents, options.InsertMany().SetOrdered(false))
+		if err != nil {
+			bulkErr, ok := err.(mongo.BulkWriteException)
+			inTransaction := mongo.SessionFromContext(ctx) != nil
+			if !ok || inTransaction || bulkErr.WriteConcernError != nil || !onlyDuplicateKey(bulkErr.WriteErrors) {
+				msg := fmt.Sprintf("Error Mongodb Bulk Insert : %s", err.Error())
+				m.logger.Error(ctx, msg, payload.CollectionName)
+				output <- wrapper.Result{
+					Error: errors.InternalServerError(msg),
+				}
+				return
+			}
+			inserted -= int64(len(bulkErr.WriteErrors))
+		}
+
+		finish := time.Now()
+		if finish.Sub(start).Seconds() > 10 {
+			msg := fmt.Sprintf("slow query: %v second, bulk insert: %d documents", finish.Sub(start).Seconds(), len(payload.Documents))
+			m.logger.Error(ctx, msg, payload.CollectionName)
+		}
+
+		output <- wrapper.Result{
+			Data:  inserted,
+			Count: inserted,
+		}
+	}()
+
+	return output
+}
+
+func onlyDuplicateKey(writeErrors []mongo.BulkWriteError) bool {
+	for _, writeErr := range writeErrors {
+		if !mongo.IsDuplicateKeyError(writeErr.WriteError) {
+			return false
+		}
+	}
+	return true
+}
+
 func (m MongoDBLogger) UpdateOne(payload UpdateOne, ctx context.Context) <-chan wrapper.Result {
 	output := make(chan wrapper.Result)
 
@@ -565,6 +616,101 @@ func (m MongoDBLogger) UpdateOne(payload UpdateOne, ctx context.Context) <-chan
 	return output
 }
 
+// UpdateMany applies $set of Document to every document matching the filter, Data is the number of documents updated
+func (m MongoDBLogger) UpdateMany(payload UpdateOne, ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		defer close(output)
+		start := time.Now()
+
+		collection := m.mongoClient.Database(m.dbName).Collection(payload.CollectionName)
+
+		doc := bson.D{{Key: "$set", Value: payload.Document}}
+		res, err := collection.UpdateMany(ctx, payload.Filter, doc)
+		if err != nil {
+			msg := fmt.Sprintf("Error Mongodb Connection : %s", err.Error())
+			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
+			output <- wrapper.Result{
+				Error: errors.InternalServerError("Error mongodb connection"),
+			}
+			return
+		}
+
+		finish := time.Now()
+
+		if finish.Sub(start).Seconds() > 10 {
+			j, _ := json.Marshal(payload.Filter)
+			msg := fmt.Sprintf("slow query: %v second, query: %s", finish.Sub(start).Seconds(), string(j))
+			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
+		}
+
+		output <- wrapper.Result{
+			Data: res.ModifiedCount,
+		}
+	}()
+
+	return output
+}
+
+type IncrementOne struct {
+	CollectionName string
+	Filter         interface{}
+	Increment      bson.M
+	Set            bson.M
+	Result         interface{}
+}
+
+// IncrementOne atomically applies $inc and $set to the first document matching the filter and decodes the updated document
+// into Result, either may be empty. Data is nil when no document matches, so guard conditions in the filter reject the
+// update without an error.
+func (m MongoDBLogger) IncrementOne(payload IncrementOne, ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		defer close(output)
+		start := time.Now()
+
+		collection := m.mongoClient.Database(m.dbName).Collection(payload.CollectionName)
+
+		update := bson.M{}
+		if len(payload.Increment) > 0 {
+			update["$inc"] = payload.Increment
+		}
+		if len(payload.Set) > 0 {
+			update["$set"] = payload.Set
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err := collection.FindOneAndUpdate(ctx, payload.Filter, update, opts).Decode(payload.Result)
+		if err == mongo.ErrNoDocuments {
+			output <- wrapper.Result{}
+			return
+		}
+		if err != nil {
+			msg := fmt.Sprintf("Error Mongodb Connection : %s", err.Error())
+			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
+			output <- wrapper.Result{
+				Error: errors.InternalServerError("Error mongodb connection"),
+			}
+			return
+		}
+
+		finish := time.Now()
+
+		if finish.Sub(start).Seconds() > 10 {
+			j, _ := json.Marshal(payload.Filter)
+			msg := fmt.Sprintf("slow query: %v second, query: %s", finish.Sub(start).Seconds(), string(j))
+			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
+		}
+
+		output <- wrapper.Result{
+			Data: payload.Result,
+		}
+	}()
+
+	return output
+}
+
 type Aggregate struct {
 	Result         interface{}
 	CollectionName string
@@ -651,6 +797,85 @@ func (m MongoDBLogger) DeleteOne(payload DeleteOne, ctx context.Context) <-chan
 	return output
 }
 
+// CreateIndex creates an index, a positive ExpireAfter makes it a TTL index removing the documents that long after
+// the date of its single key
+type CreateIndex struct {
+	CollectionName string
+	Name           string
+	Keys           bson.D
+	Unique         bool
+	ExpireAfter    time.Duration
+}
+
+func (m MongoDBLogger) CreateIndex(payload CreateIndex, ctx context.Context) <-chan wrapper.Result {
+	output := make(chan wrapper.Result)
+
+	go func() {
+		defer close(output)
+
+		collection := m.mongoClient.Database(m.dbName).Collection(payload.CollectionName)
+
+		indexOption := options.Index().SetUnique(payload.Unique)
+		if payload.Name != "" {
+			indexOption.SetName(payload.Name)
+		}
+		if payload.ExpireAfter > 0 {
+			indexOption.SetExpireAfterSeconds(int32(payload.ExpireAfter.Seconds()))
+		}
+
+		name, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    payload.Keys,
+			Options: indexOption,
+		})
+		if err != nil {
+			msg := fmt.Sprintf("Error Mongodb Create Index : %s", err.Error())
+			m.logger.Error(ctx, msg, fmt.Sprintf("%+v", payload))
+			output <- wrapper.Result{
+				Error: errors.InternalServerError(msg),
+			}
+			return
+		}
+
+		output <- wrapper.Result{
+			Data: name,
+		}
+	}()
+
+	return output
+}
+
+// WithTransaction runs fn in a multi-document transaction, the operations of fn must use the session context it receives.
+// The transaction is committed when fn returns nil and aborted otherwise, the error of fn is returned as is.
+func (m MongoDBLogger) WithTransaction(ctx context.Context, fn func(sessCtx context.Context) error) error {
+	wc := writeconcern.Majority()
+	rc := readconcern.Snapshot()
+	txnOpts := options.Transaction().SetWriteConcern(wc).SetReadConcern(rc)
+
+	session, err := m.mongoClient.StartSession()
+	if err != nil {
+		msg := fmt.Sprintf("Error Mongodb Session : %s", err.Error())
+		m.logger.Error(ctx, msg, "")
+		return errors.InternalServerError("Error mongodb session")
+	}
+	defer session.EndSession(context.Background())
+
+	var fnErr error
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		fnErr = fn(sessCtx)
+		return nil, fnErr
+	}, txnOpts)
+	if err != nil {
+		if fnErr != nil {
+			return fnErr
+		}
+		msg := fmt.Sprintf("Error Mongodb Transaction : %s", err.Error())
+		m.logger.Error(ctx, msg, "")
+		return errors.InternalServerError("Error mongodb transaction")
+	}
+
+	return nil
+}
+
 // Collections is mongodb's collection of function
 type Collections interface {
 	FindAllData(payload FindAllData, ctx context.Context) <-chan wrapper.Result
@@ -661,7 +886,12 @@ type Collections interface {
 	InsertOne(payload InsertOne, ctx context.Context) <-chan wrapper.Result
 	InsertMany(payload InsertMany, ctx context.Context) <-chan wrapper.Result
 	UpdateOne(payload UpdateOne, ctx context.Context) <-chan wrapper.Result
+	UpdateMany(payload UpdateOne, ctx context.Context) <-chan wrapper.Result
 	Aggregate(payload Aggregate, ctx context.Context) <-chan wrapper.Result
 	DeleteOne(payload DeleteOne, ctx context.Context) <-chan wrapper.Result
+	CreateIndex(payload CreateIndex, ctx context.Context) <-chan wrapper.Result
+	BulkInsert(payload BulkInsert, ctx context.Context) <-chan wrapper.Result
+	IncrementOne(payload IncrementOne, ctx context.Context) <-chan wrapper.Result
+	WithTransaction(ctx context.Context, fn func(sessCtx context.Context) error) error
 	Close(ctx context.Context) error
 }
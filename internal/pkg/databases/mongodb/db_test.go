@@ -0,0 +1,89 @@
+package mongodb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"worker-service/internal/pkg/databases/mongodb"
+	mocklog "worker-service/mocks/pkg/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestWithTransactionAbortsNestedWrites checks the writes of fn are sent in the session of the transaction
+// and aborted with it, so nothing of a failed fn is committed
+func TestWithTransactionAbortsNestedWrites(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("abort", func(mt *mtest.T) {
+		logger := &mocklog.Logger{}
+		logger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+		db := mongodb.NewMongoDBLogger(mt.Client, "worker", logger)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		err := db.WithTransaction(context.Background(), func(sessCtx context.Context) error {
+			if resp := <-db.InsertMany(mongodb.InsertMany{
+				CollectionName: "outbox-events",
+				Documents:      []interface{}{bson.M{"_id": "event"}},
+			}, sessCtx); resp.Error != nil {
+				return resp.Error
+			}
+			<-db.UpsertOne(mongodb.UpdateOne{
+				CollectionName: "bank-ticket-progress",
+				Filter:         bson.M{"_id": "progress"},
+				Document:       bson.M{"lastSeatNumber": 10},
+			}, sessCtx)
+			return fmt.Errorf("chunk failed")
+		})
+		assert.EqualError(mt, err, "chunk failed")
+
+		events := mt.GetAllStartedEvents()
+		commands := make([]string, 0, len(events))
+		for _, event := range events {
+			commands = append(commands, event.CommandName)
+		}
+		assert.Equal(mt, []string{"insert", "update", "abortTransaction"}, commands)
+
+		lsid := events[2].Command.Lookup("lsid")
+		for _, event := range events[:2] {
+			assert.True(mt, lsid.Equal(event.Command.Lookup("lsid")), "%s not in the session of the transaction", event.CommandName)
+			_, inTransaction := event.Command.Lookup("txnNumber").Int64OK()
+			assert.True(mt, inTransaction, "%s not in the transaction", event.CommandName)
+		}
+	})
+}
+
+// TestBulkInsertInTransactionRejectsDuplicate checks a duplicate seat is skipped outside of a transaction
+// but reported in one, where it aborts the transaction
+func TestBulkInsertInTransactionRejectsDuplicate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("duplicate", func(mt *mtest.T) {
+		logger := &mocklog.Logger{}
+		logger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+		db := mongodb.NewMongoDBLogger(mt.Client, "worker", logger)
+		duplicate := mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}
+		payload := mongodb.BulkInsert{
+			CollectionName: "bank-ticket",
+			Documents:      []interface{}{bson.M{"seatNumber": 1}, bson.M{"seatNumber": 2}},
+		}
+
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(duplicate))
+		resp := <-db.BulkInsert(payload, context.Background())
+		assert.NoError(mt, resp.Error)
+		assert.Equal(mt, int64(1), resp.Count)
+
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(duplicate), mtest.CreateSuccessResponse())
+		err := db.WithTransaction(context.Background(), func(sessCtx context.Context) error {
+			return (<-db.BulkInsert(payload, sessCtx)).Error
+		})
+		assert.Error(mt, err)
+	})
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// headers set on every event message, so consumers can route without parsing the value
+const (
+	HeaderEventType    = "event-type"
+	HeaderEventVersion = "event-version"
+)
+
+// Envelope is the JSON wrapper of every domain event published by the worker.
+// Consumers switch on Type and Version, Data is the payload of that version of the event.
+type Envelope struct {
+	Id         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	Source     string          `json:"source"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Event is a domain event to publish on the topic named after its type.
+// Key keeps the events of one aggregate on one partition, so they are consumed in order.
+type Event struct {
+	Type    string
+	Version int
+	Key     string
+	Data    interface{}
+}
+
+// Message is an event encoded as the kafka message it is published as, Attempts counts the publishes tried
+type Message struct {
+	Id       string
+	Topic    string
+	Key      string
+	Headers  map[string]string
+	Value    []byte
+	Attempts int
+}
+
+// NewEnvelope wraps event into a new envelope from source
+func NewEnvelope(source string, event Event) (Envelope, error) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		Id:         uuid.NewString(),
+		Type:       event.Type,
+		Version:    event.Version,
+		Source:     source,
+		OccurredAt: time.Now(),
+		Data:       data,
+	}, nil
+}
+
+// NewMessage encodes event from source as the message published on the topic named after its type
+func NewMessage(source string, event Event) (Message, error) {
+	envelope, err := NewEnvelope(source, event)
+	if err != nil {
+		return Message{}, err
+	}
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Id:    envelope.Id,
+		Topic: event.Type,
+		Key:   event.Key,
+		Headers: map[string]string{
+			HeaderEventType:    event.Type,
+			HeaderEventVersion: strconv.Itoa(event.Version),
+		},
+		Value: value,
+	}, nil
+}
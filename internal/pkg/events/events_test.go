@@ -0,0 +1,40 @@
+package events_test
+
+import (
+	"encoding/json"
+	"testing"
+	"worker-service/internal/pkg/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMessage(t *testing.T) {
+	message, err := events.NewMessage("worker-service", events.Event{
+		Type:    "concert-ticket-released",
+		Version: 2,
+		Key:     "ticket",
+		Data:    map[string]string{"ticketNumber": "1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "concert-ticket-released", message.Topic)
+	assert.Equal(t, "ticket", message.Key)
+	assert.Equal(t, map[string]string{
+		events.HeaderEventType:    "concert-ticket-released",
+		events.HeaderEventVersion: "2",
+	}, message.Headers)
+
+	var envelope events.Envelope
+	assert.NoError(t, json.Unmarshal(message.Value, &envelope))
+	assert.Equal(t, message.Id, envelope.Id)
+	assert.NotEmpty(t, envelope.Id)
+	assert.Equal(t, "concert-ticket-released", envelope.Type)
+	assert.Equal(t, 2, envelope.Version)
+	assert.Equal(t, "worker-service", envelope.Source)
+	assert.False(t, envelope.OccurredAt.IsZero())
+	assert.JSONEq(t, `{"ticketNumber":"1"}`, string(envelope.Data))
+}
+
+func TestNewMessageErrData(t *testing.T) {
+	_, err := events.NewMessage("worker-service", events.Event{Type: "concert-ticket-released", Data: make(chan int)})
+	assert.Error(t, err)
+}
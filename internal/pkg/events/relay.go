@@ -0,0 +1,203 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+	"worker-service/internal/pkg/log"
+
+	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// storeTimeout bounds the store updates made on a delivery report
+const storeTimeout = 10 * time.Second
+
+// report is the delivery report of a produced message
+type report struct {
+	message Message
+	err     error
+}
+
+// Store keeps the outbox, the messages saved with the changes they describe
+type Store interface {
+	// FindPending returns at most limit pending messages which are due
+	FindPending(ctx context.Context, limit int) ([]Message, error)
+	// Claim holds the pending message id until the given time and counts an attempt, false when it was claimed elsewhere
+	Claim(ctx context.Context, id string, until time.Time) (bool, error)
+	MarkSent(ctx context.Context, id string) error
+	// Retry makes the message due again at next, reason is why its publish failed
+	Retry(ctx context.Context, id string, next time.Time, reason string) error
+}
+
+// RelayConfig configures a relay. ClaimTimeout must exceed the kafka message timeout,
+// else a message still waiting for its delivery report is published again.
+type RelayConfig struct {
+	Interval     time.Duration
+	BatchSize    int
+	ClaimTimeout time.Duration
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+}
+
+// Relay publishes the outbox messages, each is published at least once
+type Relay interface {
+	Start()
+	Relay(ctx context.Context) (int, error)
+	Close(ctx context.Context) error
+}
+
+type relay struct {
+	producer kafkaConfluent.Producer
+	store    Store
+	config   RelayConfig
+	logger   log.Logger
+
+	// running tracks the relay loop and the messages waiting for their delivery report to be recorded
+	running *sync.WaitGroup
+	stop    chan struct{}
+	stopped sync.Once
+	// reports hands the delivery reports from the producer to the recorder, so the store is never written on the
+	// goroutine of the producer serving the delivery reports
+	reports chan report
+	drained sync.Once
+}
+
+// NewRelay returns a relay publishing the messages of store with producer every config.Interval,
+// the delivery reports are recorded until it is closed
+func NewRelay(producer kafkaConfluent.Producer, store Store, config RelayConfig, log log.Logger) Relay {
+	r := &relay{
+		producer: producer,
+		store:    store,
+		config:   config,
+		logger:   log,
+		running:  new(sync.WaitGroup),
+		stop:     make(chan struct{}),
+		reports:  make(chan report, config.BatchSize),
+	}
+	go r.record()
+	return r
+}
+
+func (r *relay) Start() {
+	r.running.Add(1)
+	go r.loop()
+}
+
+func (r *relay) loop() {
+	defer r.running.Done()
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if _, err := r.Relay(context.Background()); err != nil {
+				r.logger.Error(context.Background(), "Failed relay outbox events", err.Error())
+			}
+		}
+	}
+}
+
+// Relay publishes the pending messages which are due and returns how many were produced.
+// A message is claimed first, so the relays of other replicas skip it, and is marked sent on its delivery report.
+func (r *relay) Relay(ctx context.Context) (int, error) {
+	messages, err := r.store.FindPending(ctx, r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	produced := 0
+	for _, m := range messages {
+		claimed, err := r.store.Claim(ctx, m.Id, time.Now().Add(r.config.ClaimTimeout))
+		if err != nil {
+			return produced, err
+		}
+		if !claimed {
+			continue
+		}
+
+		m.Attempts++
+		r.running.Add(1)
+		if err := r.producer.PublishAsync(m.Topic, m.Key, m.Headers, m.Value, r.onDelivery(m)); err != nil {
+			r.delivered(report{message: m, err: err})
+			continue
+		}
+		produced++
+	}
+	return produced, nil
+}
+
+// onDelivery queues the delivery report of m for the recorder, it only blocks the producer while a full batch of
+// reports is waiting to be recorded
+func (r *relay) onDelivery(m Message) kafkaConfluent.DeliveryFunc {
+	return func(_ *k.Message, err error) {
+		r.reports <- report{message: m, err: err}
+	}
+}
+
+// record records the queued delivery reports one at a time until the relay is closed
+func (r *relay) record() {
+	for rep := range r.reports {
+		r.delivered(rep)
+	}
+}
+
+// delivered records a delivery report, a failed message is retried after a backoff growing with its attempts.
+// A message whose sent mark fails is published again once its claim expires.
+func (r *relay) delivered(rep report) {
+	defer r.running.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	m, deliveryErr := rep.message, rep.err
+	if deliveryErr == nil {
+		if err := r.store.MarkSent(ctx, m.Id); err != nil {
+			r.logger.Error(ctx, fmt.Sprintf("Failed mark outbox event %s sent", m.Id), err.Error())
+		}
+		return
+	}
+
+	next := time.Now().Add(r.backoff(m.Attempts))
+	r.logger.Error(ctx, fmt.Sprintf("Failed publish outbox event %s on attempt %d, retry at %s", m.Id, m.Attempts, next.Format(time.RFC3339)), deliveryErr.Error())
+	if err := r.store.Retry(ctx, m.Id, next, deliveryErr.Error()); err != nil {
+		r.logger.Error(ctx, fmt.Sprintf("Failed retry outbox event %s", m.Id), err.Error())
+	}
+}
+
+// backoff doubles from MinBackoff on every attempt up to MaxBackoff
+func (r *relay) backoff(attempts int) time.Duration {
+	backoff := r.config.MinBackoff
+	for i := 1; i < attempts && backoff < r.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.config.MaxBackoff {
+		return r.config.MaxBackoff
+	}
+	return backoff
+}
+
+// Close stops the relay and waits for the delivery reports of the messages it produced to be recorded
+func (r *relay) Close(ctx context.Context) error {
+	r.stopped.Do(func() {
+		close(r.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		r.drained.Do(func() {
+			close(r.reports)
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,229 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"worker-service/internal/pkg/events"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+	mockevents "worker-service/mocks/pkg/events"
+	mockkafka "worker-service/mocks/pkg/kafka"
+	mocklog "worker-service/mocks/pkg/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+type RelayTestSuite struct {
+	suite.Suite
+	mockProducer *mockkafka.Producer
+	mockStore    *mockevents.Store
+	mockLogger   *mocklog.Logger
+	relay        events.Relay
+	produced     []events.Message
+	reports      []kafkaConfluent.DeliveryFunc
+}
+
+func (suite *RelayTestSuite) SetupTest() {
+	suite.mockProducer = new(mockkafka.Producer)
+	suite.mockStore = new(mockevents.Store)
+	suite.mockLogger = new(mocklog.Logger)
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+	suite.relay = events.NewRelay(suite.mockProducer, suite.mockStore, events.RelayConfig{
+		Interval:     time.Millisecond,
+		BatchSize:    10,
+		ClaimTimeout: time.Minute,
+		MinBackoff:   time.Second,
+		MaxBackoff:   5 * time.Second,
+	}, suite.mockLogger)
+	suite.produced = nil
+	suite.reports = nil
+}
+
+func TestRelayTestSuite(t *testing.T) {
+	suite.Run(t, new(RelayTestSuite))
+}
+
+func (suite *RelayTestSuite) capture(err error) {
+	suite.mockProducer.On("PublishAsync", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		suite.produced = append(suite.produced, events.Message{
+			Topic:   args.String(0),
+			Key:     args.String(1),
+			Headers: args.Get(2).(map[string]string),
+			Value:   args.Get(3).([]byte),
+		})
+		suite.reports = append(suite.reports, args.Get(4).(kafkaConfluent.DeliveryFunc))
+	}).Return(err)
+}
+
+// after matches a time d after a moment between start and now
+func after(start time.Time, d time.Duration) interface{} {
+	return mock.MatchedBy(func(at time.Time) bool {
+		return !at.Before(start.Add(d)) && !at.After(time.Now().Add(d))
+	})
+}
+
+func (suite *RelayTestSuite) TestRelay() {
+	start := time.Now()
+	suite.capture(nil)
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return([]events.Message{
+		{
+			Id:      "a",
+			Topic:   "concert-ticket-released",
+			Key:     "ticket",
+			Headers: map[string]string{events.HeaderEventVersion: "1", events.HeaderEventType: "concert-ticket-released"},
+			Value:   []byte(`{"id":"a"}`),
+		},
+		{Id: "b", Topic: "concert-payment-expired"},
+	}, nil)
+	suite.mockStore.On("Claim", mock.Anything, "a", after(start, time.Minute)).Return(true, nil)
+	// claimed by the relay of another replica
+	suite.mockStore.On("Claim", mock.Anything, "b", mock.Anything).Return(false, nil)
+	suite.mockStore.On("MarkSent", mock.Anything, "a").Return(nil)
+
+	produced, err := suite.relay.Relay(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, produced)
+	assert.Len(suite.T(), suite.produced, 1)
+
+	assert.Equal(suite.T(), events.Message{
+		Topic:   "concert-ticket-released",
+		Key:     "ticket",
+		Headers: map[string]string{events.HeaderEventVersion: "1", events.HeaderEventType: "concert-ticket-released"},
+		Value:   []byte(`{"id":"a"}`),
+	}, suite.produced[0])
+
+	// the message stays pending until its delivery report
+	suite.mockStore.AssertNotCalled(suite.T(), "MarkSent", mock.Anything, mock.Anything)
+	suite.reports[0](nil, nil)
+	assert.NoError(suite.T(), suite.relay.Close(context.Background()))
+	suite.mockStore.AssertCalled(suite.T(), "MarkSent", mock.Anything, "a")
+}
+
+func (suite *RelayTestSuite) TestRelayDeliveryNotBlocked() {
+	suite.capture(nil)
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return([]events.Message{{Id: "a"}}, nil)
+	suite.mockStore.On("Claim", mock.Anything, "a", mock.Anything).Return(true, nil)
+	marking := make(chan struct{})
+	suite.mockStore.On("MarkSent", mock.Anything, "a").Run(func(args mock.Arguments) {
+		<-marking
+	}).Return(nil)
+
+	_, err := suite.relay.Relay(context.Background())
+	assert.NoError(suite.T(), err)
+
+	// the producer gets its delivery report goroutine back while the store is still being written
+	reported := make(chan struct{})
+	go func() {
+		suite.reports[0](nil, nil)
+		close(reported)
+	}()
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		suite.T().Fatal("delivery report blocked on the store")
+	}
+
+	close(marking)
+	assert.NoError(suite.T(), suite.relay.Close(context.Background()))
+	suite.mockStore.AssertCalled(suite.T(), "MarkSent", mock.Anything, "a")
+}
+
+func (suite *RelayTestSuite) TestRelayErrDelivery() {
+	start := time.Now()
+	suite.capture(nil)
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return([]events.Message{{Id: "a", Attempts: 2}}, nil)
+	suite.mockStore.On("Claim", mock.Anything, "a", mock.Anything).Return(true, nil)
+	suite.mockStore.On("Retry", mock.Anything, "a", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := suite.relay.Relay(context.Background())
+	assert.NoError(suite.T(), err)
+	suite.reports[0](nil, kafka.NewError(kafka.ErrMsgTimedOut, "Local: Message timed out", false))
+	assert.NoError(suite.T(), suite.relay.Close(context.Background()))
+
+	// the backoff doubles on every attempt, the third one waits four times the minimum
+	suite.mockStore.AssertCalled(suite.T(), "Retry", mock.Anything, "a", after(start, 4*time.Second), "Local: Message timed out")
+	suite.mockStore.AssertNotCalled(suite.T(), "MarkSent", mock.Anything, mock.Anything)
+}
+
+func (suite *RelayTestSuite) TestRelayErrDeliveryMaxBackoff() {
+	start := time.Now()
+	suite.capture(nil)
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return([]events.Message{{Id: "a", Attempts: 30}}, nil)
+	suite.mockStore.On("Claim", mock.Anything, "a", mock.Anything).Return(true, nil)
+	suite.mockStore.On("Retry", mock.Anything, "a", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := suite.relay.Relay(context.Background())
+	assert.NoError(suite.T(), err)
+	suite.reports[0](nil, errors.New("broker down"))
+	assert.NoError(suite.T(), suite.relay.Close(context.Background()))
+
+	suite.mockStore.AssertCalled(suite.T(), "Retry", mock.Anything, "a", after(start, 5*time.Second), "broker down")
+}
+
+func (suite *RelayTestSuite) TestRelayErrPublish() {
+	start := time.Now()
+	suite.capture(errors.New("queue full"))
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return([]events.Message{{Id: "a"}}, nil)
+	suite.mockStore.On("Claim", mock.Anything, "a", mock.Anything).Return(true, nil)
+	suite.mockStore.On("Retry", mock.Anything, "a", mock.Anything, mock.Anything).Return(nil)
+
+	produced, err := suite.relay.Relay(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, produced)
+	suite.mockStore.AssertCalled(suite.T(), "Retry", mock.Anything, "a", after(start, time.Second), "queue full")
+
+	// nothing is left waiting for a delivery report
+	assert.NoError(suite.T(), suite.relay.Close(context.Background()))
+}
+
+func (suite *RelayTestSuite) TestRelayErrFind() {
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return(nil, errors.New("error"))
+
+	_, err := suite.relay.Relay(context.Background())
+	assert.Error(suite.T(), err)
+	suite.mockProducer.AssertNotCalled(suite.T(), "PublishAsync", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *RelayTestSuite) TestRelayErrClaim() {
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return([]events.Message{{Id: "a"}}, nil)
+	suite.mockStore.On("Claim", mock.Anything, "a", mock.Anything).Return(false, errors.New("error"))
+
+	_, err := suite.relay.Relay(context.Background())
+	assert.Error(suite.T(), err)
+	suite.mockProducer.AssertNotCalled(suite.T(), "PublishAsync", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *RelayTestSuite) TestStart() {
+	relayed := make(chan struct{})
+	suite.mockStore.On("FindPending", mock.Anything, 10).Run(func(args mock.Arguments) {
+		select {
+		case relayed <- struct{}{}:
+		default:
+		}
+	}).Return([]events.Message{}, nil)
+
+	suite.relay.Start()
+	<-relayed
+	assert.NoError(suite.T(), suite.relay.Close(context.Background()))
+}
+
+func (suite *RelayTestSuite) TestCloseWaitsDelivery() {
+	suite.capture(nil)
+	suite.mockStore.On("FindPending", mock.Anything, 10).Return([]events.Message{{Id: "a"}}, nil)
+	suite.mockStore.On("Claim", mock.Anything, "a", mock.Anything).Return(true, nil)
+	suite.mockStore.On("MarkSent", mock.Anything, "a").Return(nil)
+
+	_, err := suite.relay.Relay(context.Background())
+	assert.NoError(suite.T(), err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(suite.T(), suite.relay.Close(ctx), context.DeadlineExceeded)
+
+	suite.reports[0](nil, nil)
+	assert.NoError(suite.T(), suite.relay.Close(context.Background()))
+}
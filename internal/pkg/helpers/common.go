@@ -11,6 +11,7 @@ import (
 	"math/rand"
 	"os"
 	"regexp"
+	"sort"
 	"worker-service/configs"
 	"worker-service/internal/pkg/constants"
 )
@@ -206,6 +207,18 @@ func generateRandomPart(charset string, length int) (string, error) {
 	return string(randomPart), nil
 }
 
+// InstanceId names this replica, the hostname is the pod name on kubernetes
+func InstanceId() string {
+	if instance := configs.GetConfig().Worker.InstanceId; instance != "" {
+		return instance
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
 func CustomIfEmpty(param1 string, param2 string) string {
 	if param1 != "" {
 		return param1
@@ -223,3 +236,38 @@ func GenerateMetaData(totalData, count int64, page, limit int64) constants.MetaD
 
 	return metaData
 }
+
+// Apportion splits total by weights with the largest remainder method, so the parts always sum to total.
+// Leftover units go to the largest remainders first, ties go to the lowest index.
+func Apportion(total int, weights []int) []int {
+	parts := make([]int, len(weights))
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	if sum <= 0 || total <= 0 {
+		return parts
+	}
+
+	remainders := make([]int, len(weights))
+	allocated := 0
+	for i, w := range weights {
+		parts[i] = total * w / sum
+		remainders[i] = total * w % sum
+		allocated += parts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; allocated < total; i++ {
+		parts[order[i]]++
+		allocated++
+	}
+
+	return parts
+}
@@ -0,0 +1,31 @@
+package helpers_test
+
+import (
+	"testing"
+	"worker-service/internal/pkg/helpers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApportion(t *testing.T) {
+	assert.Equal(t, []int{4, 3, 3}, helpers.Apportion(10, []int{34, 33, 33}))
+	assert.Equal(t, []int{34, 33, 33}, helpers.Apportion(100, []int{34, 33, 33}))
+	assert.Equal(t, []int{2, 1, 1, 1, 1, 1}, helpers.Apportion(7, []int{20, 16, 16, 16, 16, 16}))
+	assert.Equal(t, []int{500, 300, 200}, helpers.Apportion(1000, []int{50, 30, 20}))
+}
+
+func TestApportionSum(t *testing.T) {
+	weights := []int{7, 13, 19, 23, 38}
+	for total := 0; total < 200; total++ {
+		sum := 0
+		for _, part := range helpers.Apportion(total, weights) {
+			sum += part
+		}
+		assert.Equal(t, total, sum)
+	}
+}
+
+func TestApportionEmpty(t *testing.T) {
+	assert.Equal(t, []int{0, 0}, helpers.Apportion(10, []int{0, 0}))
+	assert.Equal(t, []int{}, helpers.Apportion(10, []int{}))
+}
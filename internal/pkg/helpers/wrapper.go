@@ -81,6 +81,33 @@ func RespSuccess(c *fiber.Ctx, log log.Logger, data interface{}, message string)
 	})
 }
 
+// RespAccepted responds 202 to a request whose work continues in background
+func RespAccepted(c *fiber.Ctx, log log.Logger, data interface{}, message string) error {
+	ip := c.Get("X-Forwarded-For")
+	if ip == "" {
+		// If X-Forwarded-For is not present, use the default IP
+		ip = c.IP()
+	}
+	meta := Meta{
+		Date:          time.Now(),
+		Url:           c.Path(),
+		Method:        c.Method(),
+		Code:          fmt.Sprintf("%v", fiber.StatusAccepted),
+		ContentLength: int64(c.Request().Header.ContentLength()),
+		Ip:            ip,
+	}
+
+	log.Info(c.Context(), "audit-log", fmt.Sprintf("%+v", meta))
+
+	return c.Status(fiber.StatusAccepted).JSON(response{
+		Meta: MetaResponse{
+			Code:    fiber.StatusAccepted,
+			Message: message,
+		},
+		Data: data,
+	})
+}
+
 func RespError(c *fiber.Ctx, log log.Logger, err error) error {
 	ip := c.Get("X-Forwarded-For")
 	if ip == "" {
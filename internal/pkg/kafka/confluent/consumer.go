@@ -2,70 +2,384 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"worker-service/internal/pkg/log"
 
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 )
 
+const (
+	defaultMaxInFlight  = 100
+	defaultMaxAttempts  = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+	pollTimeout         = 100 * time.Millisecond
+)
+
+// ProcessingConfig controls how consumed messages are handled before their offset is committed
+type ProcessingConfig struct {
+	// MaxInFlight is the number of messages buffered per partition before polling blocks
+	MaxInFlight int
+	// MaxAttempts is the number of handler attempts before the failure handler takes over
+	MaxAttempts int
+	// RetryBackoff is the wait before the second attempt, doubled on every following attempt
+	RetryBackoff time.Duration
+}
+
+func (p ProcessingConfig) withDefaults() ProcessingConfig {
+	if p.MaxInFlight <= 0 {
+		p.MaxInFlight = defaultMaxInFlight
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.RetryBackoff <= 0 {
+		p.RetryBackoff = defaultRetryBackoff
+	}
+	return p
+}
+
+type partitionWorker struct {
+	partition kafka.TopicPartition
+	messages  chan *kafka.Message
+	done      chan struct{}
+	// paused is only read and written by the poll goroutine, which also pauses and resumes the partition,
+	// so no message can be fetched between the partition being resumed and paused being cleared
+	paused bool
+	// hold is the message a handler reported not due, guarded by mu, the poll goroutine rewinds to it
+	// and keeps the partition paused until holdUntil
+	hold      *kafka.TopicPartition
+	holdUntil time.Time
+}
+
 type consumer struct {
-	handler  ConsumerHandler
-	consumer *kafka.Consumer
-	logger   log.Logger
+	router         Router
+	failureHandler FailureHandlerFunc
+	processing     ProcessingConfig
+	consumer       ConsumerClient
+	logger         log.Logger
+
+	mu         sync.Mutex
+	workers    map[string]*partitionWorker
+	subscribed bool
+	ctx        context.Context
+	cancel     context.CancelFunc
+	stop       chan struct{}
+	stopped    chan struct{}
+	stopOnce   sync.Once
 }
 
-// NewConsumer is a constructor of kafka consumer
-func NewConsumer(cfg *kafka.ConfigMap, log log.Logger) (Consumer, error) {
+// NewConsumer is a constructor of kafka consumer, offsets are committed only after the routed handler succeeds
+func NewConsumer(cfg *kafka.ConfigMap, processing ProcessingConfig, log log.Logger) (Consumer, error) {
 	c, err := kafka.NewConsumer(cfg)
 	if err != nil {
 		return nil, err
 	}
+	return NewConsumerWithClient(c, processing, log), nil
+}
 
+// NewConsumerWithClient is a constructor of kafka consumer fetching its messages with client
+func NewConsumerWithClient(client ConsumerClient, processing ProcessingConfig, log log.Logger) Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &consumer{
-		logger:   log,
-		consumer: c,
-	}, nil
+		logger:     log,
+		consumer:   client,
+		processing: processing.withDefaults(),
+		workers:    make(map[string]*partitionWorker),
+		ctx:        ctx,
+		cancel:     cancel,
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+func (c *consumer) SetRouter(router Router) {
+	c.router = router
 }
 
-func (c *consumer) SetHandler(handler ConsumerHandler) {
-	c.handler = handler
+func (c *consumer) SetFailureHandler(handler FailureHandlerFunc) {
+	c.failureHandler = handler
 }
 
+// Subscribe starts consuming topics, when no topic is given every topic registered on the router is subscribed
 func (c *consumer) Subscribe(topics ...string) {
-	if c.handler == nil {
+	if c.router == nil {
 		joinTopic := strings.Join(topics, ", ")
-		msg := fmt.Sprintf("Kafka Consumer Error: Topics: [%s] There is no consumer handlers to handle message from incoming event", joinTopic)
+		msg := fmt.Sprintf("Kafka Consumer Error: Topics: [%s] There is no router to handle message from incoming event", joinTopic)
 		c.logger.Error(context.Background(), msg, fmt.Sprintf("%+v", topics))
 		return
 	}
+	if len(topics) == 0 {
+		topics = c.router.Topics()
+	}
 
-	c.consumer.SubscribeTopics(topics, nil)
+	c.consumer.SubscribeTopics(topics, c.rebalance)
+	c.subscribed = true
 	go func() {
+		defer close(c.stopped)
 		for {
-			msg, err := c.consumer.ReadMessage(-1)
+			if c.closing() {
+				return
+			}
+
+			c.holdPartitions()
+			c.resume()
+			msg, err := c.consumer.ReadMessage(pollTimeout)
 			if err != nil {
+				if kerr, ok := err.(kafka.Error); ok && kerr.Code() == kafka.ErrTimedOut {
+					continue
+				}
 				msg := fmt.Sprintf("Kafka Consumer Error: %v (%v)\n", err, msg)
 				c.logger.Error(context.Background(), msg, fmt.Sprintf("%+v", topics))
 				continue
 			}
-			switch topics[0] {
-			case "concert-create-bank-ticket":
-				go c.handler.CreateBankTicket(msg, topics[0])
-				c.consumer.CommitMessage(msg)
-			case "concert-update-online-bank-ticket":
-				go c.handler.UpdateOnlineBankTicket(msg, topics[0])
-				c.consumer.CommitMessage(msg)
-			default:
-				c.consumer.CommitMessage(msg)
-			}
+			c.enqueue(msg)
 		}
 	}()
+}
+
+// enqueue hands message to its partition worker, a partition with MaxInFlight messages pending is paused
+// and rewound to message so polling never blocks on a slow handler
+func (c *consumer) enqueue(msg *kafka.Message) {
+	key := partitionKey(msg.TopicPartition)
+
+	c.mu.Lock()
+	worker, ok := c.workers[key]
+	if !ok {
+		worker = &partitionWorker{
+			partition: kafka.TopicPartition{Topic: msg.TopicPartition.Topic, Partition: msg.TopicPartition.Partition},
+			messages:  make(chan *kafka.Message, c.processing.MaxInFlight),
+			done:      make(chan struct{}),
+		}
+		c.workers[key] = worker
+		go c.work(worker)
+	}
+	c.mu.Unlock()
+
+	// messages fetched before the pause took effect are refetched after the rewind
+	if worker.paused {
+		return
+	}
+
+	select {
+	case worker.messages <- msg:
+	default:
+		worker.paused = true
+		if err := c.consumer.Pause([]kafka.TopicPartition{worker.partition}); err != nil {
+			c.logger.Error(c.ctx, fmt.Sprintf("Kafka Consumer Error: pause failed %v", err), key)
+		}
+		if err := c.consumer.Seek(msg.TopicPartition, 0); err != nil {
+			c.logger.Error(c.ctx, fmt.Sprintf("Kafka Consumer Error: seek failed %v", err), key)
+		}
+	}
+}
+
+// holdPartitions pauses and rewinds the partitions whose worker holds a message that is not due yet,
+// it runs on the poll goroutine like enqueue
+func (c *consumer) holdPartitions() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, worker := range c.workers {
+		if worker.hold == nil {
+			continue
+		}
+		if !worker.paused {
+			worker.paused = true
+			if err := c.consumer.Pause([]kafka.TopicPartition{worker.partition}); err != nil {
+				c.logger.Error(c.ctx, fmt.Sprintf("Kafka Consumer Error: pause failed %v", err), key)
+			}
+		}
+		if err := c.consumer.Seek(*worker.hold, 0); err != nil {
+			c.logger.Error(c.ctx, fmt.Sprintf("Kafka Consumer Error: seek failed %v", err), key)
+		}
+		worker.hold = nil
+	}
+}
+
+// resume restarts fetching of the paused partitions whose worker caught up and whose held message is due,
+// it runs on the poll goroutine between two polls so the rewound message is only fetched once its partition
+// is no longer marked paused
+func (c *consumer) resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, worker := range c.workers {
+		if !worker.paused || len(worker.messages) > 0 || time.Now().Before(worker.holdUntil) {
+			continue
+		}
+		if err := c.consumer.Resume([]kafka.TopicPartition{worker.partition}); err != nil {
+			c.logger.Error(c.ctx, fmt.Sprintf("Kafka Consumer Error: resume failed %v", err), key)
+			continue
+		}
+		worker.paused = false
+	}
+}
+
+// work processes messages of a single partition in offset order
+func (c *consumer) work(worker *partitionWorker) {
+	defer close(worker.done)
+	var held *kafka.TopicPartition
+	for msg := range worker.messages {
+		// messages after a held one are skipped until the rewind fetches the held one again
+		if held != nil {
+			if msg.TopicPartition.Offset != held.Offset {
+				continue
+			}
+			held = nil
+		}
+
+		// once closing, pending messages are left uncommitted to be redelivered
+		if c.closing() {
+			continue
+		}
+		commit, notDue := c.process(msg)
+		if notDue != nil {
+			held = &msg.TopicPartition
+			c.mu.Lock()
+			worker.hold = held
+			worker.holdUntil = notDue.Until
+			c.mu.Unlock()
+			continue
+		}
+		if !commit {
+			continue
+		}
+		if _, err := c.consumer.CommitMessage(msg); err != nil {
+			c.logger.Error(c.ctx, fmt.Sprintf("Kafka Consumer Error: commit failed %v", err), fmt.Sprintf("%+v", msg.TopicPartition))
+		}
+	}
+}
+
+// process runs the routed handler with backoff and reports whether the offset may be committed,
+// or the NotDueError of a message to be held
+func (c *consumer) process(msg *kafka.Message) (bool, *NotDueError) {
+	ctx := c.ctx
+	backoff := c.processing.RetryBackoff
+
+	var err error
+	for attempt := 1; attempt <= c.processing.MaxAttempts; attempt++ {
+		if err = c.router.Dispatch(ctx, msg); err == nil {
+			return true, nil
+		}
+		var notDue *NotDueError
+		if errors.As(err, &notDue) {
+			return false, notDue
+		}
+		c.logger.Error(ctx, fmt.Sprintf("Kafka Consumer Error: attempt %d/%d failed: %v", attempt, c.processing.MaxAttempts, err), string(msg.Value))
+		if attempt == c.processing.MaxAttempts {
+			break
+		}
+		if !c.sleep(backoff) {
+			return false, nil
+		}
+		backoff *= 2
+	}
+
+	if c.failureHandler == nil {
+		c.logger.Error(ctx, "Kafka Consumer Error: message dropped, no failure handler", string(msg.Value))
+		return true, nil
+	}
+
+	// the offset is only committed once the failure handler has taken over the message
+	for {
+		failErr := c.failureHandler(ctx, msg, err)
+		if failErr == nil {
+			return true, nil
+		}
+		c.logger.Error(ctx, fmt.Sprintf("Kafka Consumer Error: failure handler failed: %v", failErr), string(msg.Value))
+		if !c.sleep(backoff) {
+			return false, nil
+		}
+	}
+}
+
+func (c *consumer) closing() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d and returns false when the consumer is closing
+func (c *consumer) sleep(d time.Duration) bool {
+	select {
+	case <-c.stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// rebalance drains the workers of revoked partitions so their offsets are committed before ownership moves
+func (c *consumer) rebalance(kc *kafka.Consumer, ev kafka.Event) error {
+	revoked, ok := ev.(kafka.RevokedPartitions)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(revoked.Partitions))
+	for _, tp := range revoked.Partitions {
+		keys = append(keys, partitionKey(tp))
+	}
+	c.drain(keys...)
+	return nil
+}
+
+// drain closes and waits for the given partition workers, every worker when no key is given
+func (c *consumer) drain(keys ...string) {
+	c.mu.Lock()
+	if len(keys) == 0 {
+		for key := range c.workers {
+			keys = append(keys, key)
+		}
+	}
+	workers := make([]*partitionWorker, 0, len(keys))
+	for _, key := range keys {
+		if worker, ok := c.workers[key]; ok {
+			close(worker.messages)
+			workers = append(workers, worker)
+			delete(c.workers, key)
+		}
+	}
+	c.mu.Unlock()
 
-	return
+	for _, worker := range workers {
+		<-worker.done
+	}
 }
 
 func (c *consumer) Close(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.cancel()
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		if c.subscribed {
+			<-c.stopped
+		}
+		c.drain()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		c.logger.Error(ctx, "Kafka Consumer Error: close timeout, in-flight messages will be redelivered", ctx.Err().Error())
+	}
+
 	return c.consumer.Close()
 }
+
+func partitionKey(tp kafka.TopicPartition) string {
+	topic := ""
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return fmt.Sprintf("%s-%d", topic, tp.Partition)
+}
@@ -0,0 +1,257 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+	mockkafka "worker-service/mocks/pkg/kafka"
+	mocklog "worker-service/mocks/pkg/log"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// ConsumerTestSuite runs a consumer on a mocked client, the messages it fetches are fed through a channel
+// and every dispatch and client call is recorded in order
+type ConsumerTestSuite struct {
+	suite.Suite
+	mockClient *mockkafka.ConsumerClient
+	mockRouter *mockkafka.Router
+	mockLogger *mocklog.Logger
+	consumer   kafkaConfluent.Consumer
+	rebalance  kafka.RebalanceCb
+	feed       chan *kafka.Message
+	topic      string
+
+	mu       sync.Mutex
+	events   []string
+	resumeAt time.Time
+}
+
+func (suite *ConsumerTestSuite) SetupTest() {
+	suite.topic = "topic"
+	suite.feed = make(chan *kafka.Message, 10)
+	suite.events = nil
+	suite.consumer = nil
+
+	suite.mockClient = &mockkafka.ConsumerClient{}
+	suite.mockClient.On("SubscribeTopics", []string{"topic"}, mock.Anything).Run(func(args mock.Arguments) {
+		suite.rebalance = args.Get(1).(kafka.RebalanceCb)
+	}).Return(nil)
+	suite.mockClient.On("ReadMessage", mock.Anything).Return(func(timeout time.Duration) (*kafka.Message, error) {
+		select {
+		case msg := <-suite.feed:
+			return msg, nil
+		case <-time.After(timeout):
+			return nil, kafka.NewError(kafka.ErrTimedOut, "timed out", false)
+		}
+	})
+	suite.mockClient.On("CommitMessage", mock.Anything).Run(func(args mock.Arguments) {
+		suite.record("commit %d", args.Get(0).(*kafka.Message).TopicPartition.Offset)
+	}).Return(nil, nil)
+	suite.mockClient.On("Pause", mock.Anything).Run(func(args mock.Arguments) {
+		suite.record("pause")
+	}).Return(nil)
+	suite.mockClient.On("Resume", mock.Anything).Run(func(args mock.Arguments) {
+		suite.mu.Lock()
+		suite.resumeAt = time.Now()
+		suite.mu.Unlock()
+		suite.record("resume")
+	}).Return(nil)
+	suite.mockClient.On("Seek", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		suite.record("seek %d", args.Get(0).(kafka.TopicPartition).Offset)
+	}).Return(nil)
+	suite.mockClient.On("Close").Return(nil)
+
+	suite.mockRouter = &mockkafka.Router{}
+	suite.mockLogger = &mocklog.Logger{}
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *ConsumerTestSuite) TearDownTest() {
+	if suite.consumer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	suite.NoError(suite.consumer.Close(ctx))
+}
+
+func TestConsumerTestSuite(t *testing.T) {
+	suite.Run(t, new(ConsumerTestSuite))
+}
+
+// start subscribes a consumer buffering maxInFlight messages per partition
+func (suite *ConsumerTestSuite) start(maxInFlight int, failureHandler kafkaConfluent.FailureHandlerFunc) {
+	suite.consumer = kafkaConfluent.NewConsumerWithClient(suite.mockClient, kafkaConfluent.ProcessingConfig{
+		MaxInFlight:  maxInFlight,
+		MaxAttempts:  2,
+		RetryBackoff: time.Millisecond,
+	}, suite.mockLogger)
+	suite.consumer.SetRouter(suite.mockRouter)
+	suite.consumer.SetFailureHandler(failureHandler)
+	suite.consumer.Subscribe("topic")
+}
+
+// fetched is the message at offset of partition 0
+func (suite *ConsumerTestSuite) fetched(offset int) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &suite.topic, Partition: 0, Offset: kafka.Offset(offset)},
+		Value:          []byte(fmt.Sprint(offset)),
+	}
+}
+
+// dispatches records every dispatch of the router and returns err
+func (suite *ConsumerTestSuite) dispatches(err error) *mock.Call {
+	return suite.mockRouter.On("Dispatch", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		suite.record("dispatch %d", args.Get(1).(*kafka.Message).TopicPartition.Offset)
+	}).Return(err)
+}
+
+func (suite *ConsumerTestSuite) record(format string, args ...interface{}) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	suite.events = append(suite.events, fmt.Sprintf(format, args...))
+}
+
+func (suite *ConsumerTestSuite) recorded() []string {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	return append([]string{}, suite.events...)
+}
+
+// waitFor waits until event is recorded
+func (suite *ConsumerTestSuite) waitFor(event string) {
+	suite.Require().Eventually(func() bool {
+		for _, recorded := range suite.recorded() {
+			if recorded == event {
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond, "%s not recorded in %v", event, suite.recorded())
+}
+
+func (suite *ConsumerTestSuite) indexOf(event string) int {
+	for i, recorded := range suite.recorded() {
+		if recorded == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func (suite *ConsumerTestSuite) TestCommitsAfterDispatchInOffsetOrder() {
+	suite.dispatches(nil)
+	suite.start(10, nil)
+
+	suite.feed <- suite.fetched(0)
+	suite.feed <- suite.fetched(1)
+	suite.feed <- suite.fetched(2)
+	suite.waitFor("commit 2")
+
+	suite.Equal([]string{"dispatch 0", "commit 0", "dispatch 1", "commit 1", "dispatch 2", "commit 2"}, suite.recorded())
+}
+
+func (suite *ConsumerTestSuite) TestCommitsOnceFailureHandled() {
+	suite.dispatches(errors.New("handler failed"))
+	failures := 0
+	suite.start(10, func(ctx context.Context, message *kafka.Message, err error) error {
+		failures++
+		suite.record("fail %d", message.TopicPartition.Offset)
+		if failures == 1 {
+			return errors.New("dead letter failed")
+		}
+		return nil
+	})
+
+	suite.feed <- suite.fetched(0)
+	suite.waitFor("commit 0")
+
+	suite.Equal([]string{"dispatch 0", "dispatch 0", "fail 0", "fail 0", "commit 0"}, suite.recorded())
+}
+
+func (suite *ConsumerTestSuite) TestPausesFullPartitionUntilCaughtUp() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	suite.dispatches(nil).Once().Run(func(args mock.Arguments) {
+		suite.record("dispatch 0")
+		close(started)
+		<-release
+	})
+	suite.dispatches(nil)
+	suite.start(1, nil)
+
+	suite.feed <- suite.fetched(0)
+	<-started
+	// 1 fills the buffer, 2 pauses the partition and rewinds to it
+	suite.feed <- suite.fetched(1)
+	suite.feed <- suite.fetched(2)
+	suite.waitFor("seek 2")
+	// 3 was fetched before the pause took effect, it is dropped to be fetched again after the rewind
+	suite.feed <- suite.fetched(3)
+	suite.Require().Eventually(func() bool { return len(suite.feed) == 0 }, time.Second, time.Millisecond)
+	suite.Equal(-1, suite.indexOf("resume"))
+
+	close(release)
+	suite.waitFor("resume")
+	suite.feed <- suite.fetched(2)
+	suite.feed <- suite.fetched(3)
+	suite.waitFor("commit 3")
+
+	events := suite.recorded()
+	suite.Less(suite.indexOf("pause"), suite.indexOf("seek 2"))
+	suite.Less(suite.indexOf("commit 0"), suite.indexOf("resume"))
+	suite.Equal([]string{"dispatch 2", "commit 2", "dispatch 3", "commit 3"}, events[len(events)-4:])
+	suite.mockRouter.AssertNumberOfCalls(suite.T(), "Dispatch", 4)
+}
+
+func (suite *ConsumerTestSuite) TestHoldsNotDueMessage() {
+	until := time.Now().Add(100 * time.Millisecond)
+	suite.dispatches(&kafkaConfluent.NotDueError{Until: until}).Once()
+	suite.dispatches(nil)
+	suite.start(10, nil)
+
+	// 1 is fetched behind the held message and skipped until 0 is fetched again
+	suite.feed <- suite.fetched(0)
+	suite.feed <- suite.fetched(1)
+	suite.waitFor("resume")
+	suite.mu.Lock()
+	resumeAt := suite.resumeAt
+	suite.mu.Unlock()
+	suite.False(resumeAt.Before(until), "resumed at %s before %s", resumeAt, until)
+
+	suite.feed <- suite.fetched(0)
+	suite.feed <- suite.fetched(1)
+	suite.waitFor("commit 1")
+
+	suite.Equal([]string{"dispatch 0", "pause", "seek 0", "resume", "dispatch 0", "commit 0", "dispatch 1", "commit 1"}, suite.recorded())
+}
+
+func (suite *ConsumerTestSuite) TestRevokeDrainsPartition() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	suite.dispatches(nil).Run(func(args mock.Arguments) {
+		suite.record("dispatch 0")
+		close(started)
+		<-release
+	})
+	suite.start(10, nil)
+
+	suite.feed <- suite.fetched(0)
+	<-started
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	// the revoked partition is handed over only once its in-flight message is committed
+	err := suite.rebalance(nil, kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{suite.fetched(0).TopicPartition}})
+	suite.NoError(err)
+	suite.Equal([]string{"dispatch 0", "commit 0"}, suite.recorded())
+}
@@ -2,6 +2,8 @@ package kafka
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 )
@@ -9,39 +11,85 @@ import (
 // Producer is collection of function of kafka producer
 type Producer interface {
 	Publish(topic string, message []byte, kafkaPartition *int32)
+	PublishSync(ctx context.Context, topic string, key string, headers map[string]string, value []byte) error
+	PublishAsync(topic string, key string, headers map[string]string, value []byte, onDelivery DeliveryFunc) error
 
 	Close(ctx context.Context) error
 }
 
+// DeliveryFunc receives the delivery report of a message published asynchronously, err is nil once it is delivered
+type DeliveryFunc func(message *k.Message, err error)
+
 // Consumer is collection of function of kafka consumer
 type Consumer interface {
-	SetHandler(handler ConsumerHandler)
+	SetRouter(router Router)
+	SetFailureHandler(handler FailureHandlerFunc)
 	Subscribe(topics ...string)
 
 	Close(ctx context.Context) error
 }
 
-// ConsumerHandler is a collection of function for handling kafka message
-type ConsumerHandler interface {
-	CreateBankTicket(message *k.Message, topic string)
-	UpdateOnlineBankTicket(message *k.Message, topic string)
+// ConsumerClient is the part of the confluent kafka consumer used to fetch, pause and commit messages
+type ConsumerClient interface {
+	SubscribeTopics(topics []string, rebalanceCb k.RebalanceCb) error
+	ReadMessage(timeout time.Duration) (*k.Message, error)
+	Pause(partitions []k.TopicPartition) error
+	Resume(partitions []k.TopicPartition) error
+	Seek(partition k.TopicPartition, timeoutMs int) error
+	CommitMessage(message *k.Message) ([]k.TopicPartition, error)
+	Close() error
+}
+
+// HandlerFunc is a function for handling kafka message of a routed topic
+type HandlerFunc func(ctx context.Context, message *k.Message) error
+
+// NotDueError is returned by a handler whose message must not be handled before Until,
+// the consumer pauses the partition and fetches the message again once it is due
+type NotDueError struct {
+	Until time.Time
+}
+
+func (e *NotDueError) Error() string {
+	return fmt.Sprintf("kafka message not due before %s", e.Until.Format(time.RFC3339Nano))
+}
+
+// FailureHandlerFunc takes over a message whose handler kept failing, the offset is committed once it returns nil
+type FailureHandlerFunc func(ctx context.Context, message *k.Message, err error) error
+
+// Router is a registry of kafka topic handlers
+type Router interface {
+	Handle(topic string, handler HandlerFunc)
+	Topics() []string
+	Match(topic string) (HandlerFunc, bool)
+	Dispatch(ctx context.Context, message *k.Message) error
+}
+
+// Retrier is a collection of function for retrying and dead lettering kafka message
+type Retrier interface {
+	Handle(router Router, topic string, handler HandlerFunc)
+	Fail(ctx context.Context, message *k.Message, err error) error
+	Replay(ctx context.Context, topic string, limit int) (int, error)
 }
 
 ///
 
 type KafkaConfig struct {
-	username string
-	password string
-	address  string
+	username    string
+	password    string
+	address     string
+	partitioner string
 }
 
 var kafkaConfig KafkaConfig
 
-func InitKafkaConfig(kafkaUrl string, username string, password string) {
+// InitKafkaConfig sets the broker config, partitioner is the librdkafka partitioner of the producer,
+// empty keeps the librdkafka default consistent_random
+func InitKafkaConfig(kafkaUrl string, username string, password string, partitioner string) {
 	kafkaConfig = KafkaConfig{
-		address:  kafkaUrl,
-		username: username,
-		password: password,
+		address:     kafkaUrl,
+		username:    username,
+		password:    password,
+		partitioner: partitioner,
 	}
 }
 
@@ -87,3 +135,13 @@ func (kc KafkaConfig) GetKafkaConfig(groupId string, commit bool) *k.ConfigMap {
 
 	return &kafkaCfg
 }
+
+// GetProducerConfig is the config of the producer of groupId with the configured partitioner
+func (kc KafkaConfig) GetProducerConfig(groupId string) *k.ConfigMap {
+	kafkaCfg := kc.GetKafkaConfig(groupId, true)
+	if kc.partitioner != "" {
+		kafkaCfg.SetKey("partitioner", kc.partitioner)
+	}
+
+	return kafkaCfg
+}
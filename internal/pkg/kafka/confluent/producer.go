@@ -3,19 +3,27 @@ package kafka
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"worker-service/internal/pkg/log"
 
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 )
 
+// flushTimeoutMs is how long one flush on close waits before the close context is checked again
+const flushTimeoutMs = 100
+
 // Producer struct
 type producer struct {
 	producer *kafka.Producer
 	logger   log.Logger
+
+	closed   sync.Once
+	closeErr error
 }
 
-// NewProducer constructor
+// NewProducer constructor, keyed messages are partitioned by the partitioner of cfg
 func NewProducer(cfg *kafka.ConfigMap, log log.Logger) (Producer, error) {
 	p, err := kafka.NewProducer(cfg)
 	if err != nil {
@@ -40,10 +48,15 @@ func (p *producer) errReporter() {
 				msg := fmt.Sprintf("Delivery failed: %v\n", ev.TopicPartition)
 				p.logger.Error(context.Background(), msg, fmt.Sprintf("%+v", ev.TopicPartition.Error))
 			}
+			if onDelivery, ok := ev.Opaque.(DeliveryFunc); ok {
+				onDelivery(ev, ev.TopicPartition.Error)
+			}
 		}
 	}
 }
 
+// Publish produces message on partition of topic, or on any partition when kafkaPartition is nil.
+// It does not wait for the delivery, a failed delivery is only logged.
 func (p *producer) Publish(topic string, message []byte, kafkaPartition *int32) {
 	partition := kafka.PartitionAny
 
@@ -61,7 +74,70 @@ func (p *producer) Publish(topic string, message []byte, kafkaPartition *int32)
 	}
 }
 
+// PublishSync produces value on topic and waits for its delivery report, see newMessage for key and headers
+func (p *producer) PublishSync(ctx context.Context, topic string, key string, headers map[string]string, value []byte) error {
+	deliveryChan := make(chan kafka.Event, 1)
+	if err := p.producer.Produce(newMessage(topic, key, headers, value), deliveryChan); err != nil {
+		return err
+	}
+
+	select {
+	case e := <-deliveryChan:
+		if m, ok := e.(*kafka.Message); ok && m.TopicPartition.Error != nil {
+			return m.TopicPartition.Error
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PublishAsync produces value on topic without waiting, its delivery report is passed to onDelivery by the error reporter.
+// See newMessage for key and headers.
+func (p *producer) PublishAsync(topic string, key string, headers map[string]string, value []byte, onDelivery DeliveryFunc) error {
+	message := newMessage(topic, key, headers, value)
+	message.Opaque = onDelivery
+	return p.producer.Produce(message, nil)
+}
+
+// Close flushes the queued messages until ctx is done, the messages still queued then are dropped.
+// Closing again returns the error of the first close.
 func (p *producer) Close(ctx context.Context) error {
-	p.producer.Close()
-	return nil
+	p.closed.Do(func() {
+		defer p.producer.Close()
+
+		for p.producer.Flush(flushTimeoutMs) > 0 {
+			if ctx.Err() != nil {
+				p.closeErr = fmt.Errorf("kafka producer closed with %d messages not delivered: %w", p.producer.Len(), ctx.Err())
+				return
+			}
+		}
+	})
+	return p.closeErr
+}
+
+// newMessage is the message of value on topic. The messages of one key are produced on one partition,
+// the messages without key are spread over the partitions. Headers are sorted by key.
+func newMessage(topic string, key string, headers map[string]string, value []byte) *kafka.Message {
+	message := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Value: value,
+	}
+	if key != "" {
+		message.Key = []byte(key)
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		message.Headers = append(message.Headers, kafka.Header{Key: k, Value: []byte(headers[k])})
+	}
+
+	return message
 }
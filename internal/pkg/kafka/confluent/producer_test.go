@@ -0,0 +1,149 @@
+package kafka_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+	mocklog "worker-service/mocks/pkg/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// ProducerTestSuite runs a producer without broker, so every message fails once its timeout is reached
+type ProducerTestSuite struct {
+	suite.Suite
+	mockLogger *mocklog.Logger
+	config     *kafka.ConfigMap
+	producer   kafkaConfluent.Producer
+}
+
+func (suite *ProducerTestSuite) SetupTest() {
+	suite.mockLogger = &mocklog.Logger{}
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+	suite.config = &kafka.ConfigMap{
+		"bootstrap.servers":         "127.0.0.1:1",
+		"message.timeout.ms":        200,
+		"go.delivery.report.fields": "key,value,headers",
+		"log_level":                 0,
+	}
+
+	producer, err := kafkaConfluent.NewProducer(suite.config, suite.mockLogger)
+	suite.Require().NoError(err)
+	suite.producer = producer
+}
+
+func (suite *ProducerTestSuite) TearDownTest() {
+	_ = suite.producer.Close(context.Background())
+}
+
+func TestProducerTestSuite(t *testing.T) {
+	suite.Run(t, new(ProducerTestSuite))
+}
+
+func (suite *ProducerTestSuite) TestNewProducerKeepsConfig() {
+	partitioner, err := suite.config.Get("partitioner", nil)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), partitioner)
+}
+
+func (suite *ProducerTestSuite) TestGetProducerConfig() {
+	kafkaConfluent.InitKafkaConfig("127.0.0.1:1", "", "", "murmur2_random")
+	defer kafkaConfluent.InitKafkaConfig("", "", "", "")
+
+	partitioner, err := kafkaConfluent.GetConfig().GetProducerConfig("worker").Get("partitioner", nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "murmur2_random", partitioner)
+
+	kafkaConfluent.InitKafkaConfig("127.0.0.1:1", "", "", "")
+	partitioner, err = kafkaConfluent.GetConfig().GetProducerConfig("worker").Get("partitioner", nil)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), partitioner)
+}
+
+func (suite *ProducerTestSuite) TestPublishSyncErrDelivery() {
+	err := suite.producer.PublishSync(context.Background(), "concert-ticket-released", "ticket", nil, []byte("value"))
+
+	var kafkaErr kafka.Error
+	assert.ErrorAs(suite.T(), err, &kafkaErr)
+	assert.Equal(suite.T(), kafka.ErrMsgTimedOut, kafkaErr.Code())
+}
+
+func (suite *ProducerTestSuite) TestPublishSyncErrContext() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := suite.producer.PublishSync(ctx, "concert-ticket-released", "ticket", nil, []byte("value"))
+	assert.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+}
+
+func (suite *ProducerTestSuite) TestPublishAsync() {
+	reports := make(chan *kafka.Message, 1)
+	errs := make(chan error, 1)
+	err := suite.producer.PublishAsync("concert-ticket-released", "ticket", map[string]string{
+		"event-version": "1",
+		"event-type":    "concert-ticket-released",
+	}, []byte("value"), func(message *kafka.Message, err error) {
+		reports <- message
+		errs <- err
+	})
+	assert.NoError(suite.T(), err)
+
+	select {
+	case message := <-reports:
+		assert.Equal(suite.T(), "concert-ticket-released", *message.TopicPartition.Topic)
+		assert.Equal(suite.T(), "ticket", string(message.Key))
+		assert.Equal(suite.T(), "value", string(message.Value))
+		assert.Equal(suite.T(), []kafka.Header{
+			{Key: "event-type", Value: []byte("concert-ticket-released")},
+			{Key: "event-version", Value: []byte("1")},
+		}, message.Headers)
+		assert.Error(suite.T(), <-errs)
+	case <-time.After(5 * time.Second):
+		suite.T().Fatal("no delivery report")
+	}
+	suite.mockLogger.AssertCalled(suite.T(), "Error", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *ProducerTestSuite) TestPublishAsyncWithoutKey() {
+	reports := make(chan *kafka.Message, 1)
+	err := suite.producer.PublishAsync("concert-ticket-released", "", nil, []byte("value"), func(message *kafka.Message, err error) {
+		reports <- message
+	})
+	assert.NoError(suite.T(), err)
+
+	message := <-reports
+	assert.Nil(suite.T(), message.Key)
+	assert.Empty(suite.T(), message.Headers)
+}
+
+func (suite *ProducerTestSuite) TestCloseFlush() {
+	delivered := make(chan error, 1)
+	err := suite.producer.PublishAsync("concert-ticket-released", "ticket", nil, []byte("value"), func(message *kafka.Message, err error) {
+		delivered <- err
+	})
+	assert.NoError(suite.T(), err)
+
+	// close waits until the queued message has its delivery report
+	assert.NoError(suite.T(), suite.producer.Close(context.Background()))
+	select {
+	case err := <-delivered:
+		assert.Error(suite.T(), err)
+	default:
+		suite.T().Fatal("message dropped on close")
+	}
+}
+
+func (suite *ProducerTestSuite) TestCloseErrContext() {
+	err := suite.producer.PublishAsync("concert-ticket-released", "ticket", nil, []byte("value"), nil)
+	assert.NoError(suite.T(), err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = suite.producer.Close(ctx)
+	assert.ErrorIs(suite.T(), err, context.DeadlineExceeded)
+	assert.ErrorContains(suite.T(), err, "1 messages not delivered")
+}
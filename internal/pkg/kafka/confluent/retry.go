@@ -0,0 +1,246 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/log"
+
+	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// kafka header of retried and dead lettered message
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderAttempt           = "x-attempt"
+	HeaderError             = "x-error"
+	HeaderFirstFailedAt     = "x-first-failed-at"
+	HeaderLastFailedAt      = "x-last-failed-at"
+	HeaderRetryAt           = "x-retry-at"
+	HeaderReplayedAt        = "x-replayed-at"
+)
+
+const (
+	defaultRetryTiers = "1m,10m"
+	replayIdleTimeout = 10 * time.Second
+)
+
+var retryHeaders = map[string]bool{
+	HeaderOriginalTopic:     true,
+	HeaderOriginalPartition: true,
+	HeaderOriginalOffset:    true,
+	HeaderAttempt:           true,
+	HeaderError:             true,
+	HeaderFirstFailedAt:     true,
+	HeaderLastFailedAt:      true,
+	HeaderRetryAt:           true,
+	HeaderReplayedAt:        true,
+}
+
+// RetryTier is a delayed retry step, messages are retried on <topic>.retry.<Name> after Delay
+type RetryTier struct {
+	Name  string
+	Delay time.Duration
+}
+
+// ParseRetryTiers parses a comma separated list of durations such as "1m,10m"
+func ParseRetryTiers(tiers string) ([]RetryTier, error) {
+	if strings.TrimSpace(tiers) == "" {
+		tiers = defaultRetryTiers
+	}
+
+	results := make([]RetryTier, 0)
+	for _, tier := range strings.Split(tiers, ",") {
+		name := strings.TrimSpace(tier)
+		delay, err := time.ParseDuration(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry tier %q: %v", name, err)
+		}
+		results = append(results, RetryTier{
+			Name:  name,
+			Delay: delay,
+		})
+	}
+
+	return results, nil
+}
+
+// RetryTopic returns the topic of a retry tier
+func RetryTopic(topic string, tier RetryTier) string {
+	return fmt.Sprintf("%s.retry.%s", topic, tier.Name)
+}
+
+// DeadLetterTopic returns the topic of messages which exhausted every retry tier
+func DeadLetterTopic(topic string) string {
+	return fmt.Sprintf("%s.dlq", topic)
+}
+
+type retrier struct {
+	producer     Producer
+	tiers        []RetryTier
+	replayConfig *k.ConfigMap
+	logger       log.Logger
+
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+// NewRetrier is a constructor of kafka retrier, replayConfig is the consumer config used to read dead letter topics
+func NewRetrier(producer Producer, tiers []RetryTier, replayConfig *k.ConfigMap, log log.Logger) Retrier {
+	return &retrier{
+		producer:     producer,
+		tiers:        tiers,
+		replayConfig: replayConfig,
+		logger:       log,
+		topics:       make(map[string]bool),
+	}
+}
+
+// Handle registers handler for topic and for every retry tier of topic
+func (r *retrier) Handle(router Router, topic string, handler HandlerFunc) {
+	r.mu.Lock()
+	r.topics[topic] = true
+	r.mu.Unlock()
+
+	router.Handle(topic, handler)
+	for _, tier := range r.tiers {
+		router.Handle(RetryTopic(topic, tier), r.delayed(handler))
+	}
+}
+
+// delayed rejects a retried message with NotDueError until its retry time, so the consumer holds its partition
+// instead of blocking the handler
+func (r *retrier) delayed(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, message *k.Message) error {
+		if retryAt, err := time.Parse(time.RFC3339Nano, headerValue(message, HeaderRetryAt)); err == nil && time.Now().Before(retryAt) {
+			return &NotDueError{Until: retryAt}
+		}
+		return handler(ctx, message)
+	}
+}
+
+// Fail publishes message to its next retry tier, or to the dead letter topic once every tier is exhausted
+func (r *retrier) Fail(ctx context.Context, message *k.Message, cause error) error {
+	now := time.Now().UTC()
+
+	originalTopic := headerValue(message, HeaderOriginalTopic)
+	if originalTopic == "" && message.TopicPartition.Topic != nil {
+		originalTopic = *message.TopicPartition.Topic
+	}
+	attempt, _ := strconv.Atoi(headerValue(message, HeaderAttempt))
+	attempt++
+
+	headers := map[string]string{
+		HeaderOriginalTopic:     originalTopic,
+		HeaderOriginalPartition: headerValue(message, HeaderOriginalPartition),
+		HeaderOriginalOffset:    headerValue(message, HeaderOriginalOffset),
+		HeaderAttempt:           strconv.Itoa(attempt),
+		HeaderError:             cause.Error(),
+		HeaderFirstFailedAt:     headerValue(message, HeaderFirstFailedAt),
+		HeaderLastFailedAt:      now.Format(time.RFC3339Nano),
+	}
+	if headers[HeaderOriginalPartition] == "" {
+		headers[HeaderOriginalPartition] = strconv.Itoa(int(message.TopicPartition.Partition))
+		headers[HeaderOriginalOffset] = message.TopicPartition.Offset.String()
+	}
+	if headers[HeaderFirstFailedAt] == "" {
+		headers[HeaderFirstFailedAt] = headers[HeaderLastFailedAt]
+	}
+
+	topic := DeadLetterTopic(originalTopic)
+	if attempt <= len(r.tiers) {
+		tier := r.tiers[attempt-1]
+		topic = RetryTopic(originalTopic, tier)
+		headers[HeaderRetryAt] = now.Add(tier.Delay).Format(time.RFC3339Nano)
+	}
+
+	if err := r.producer.PublishSync(ctx, topic, string(message.Key), withHeaders(message.Headers, headers), message.Value); err != nil {
+		return err
+	}
+
+	r.logger.Info(ctx, fmt.Sprintf("Kafka message moved to %s", topic), fmt.Sprintf("%+v", headers))
+	return nil
+}
+
+// Replay moves up to limit messages from the dead letter topic of topic back onto topic
+func (r *retrier) Replay(ctx context.Context, topic string, limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.topics[topic] {
+		return 0, errors.BadRequest(fmt.Sprintf("topic %s has no dead letter queue", topic))
+	}
+
+	c, err := k.NewConsumer(r.replayConfig)
+	if err != nil {
+		return 0, errors.InternalServerError(fmt.Sprintf("cannot create replay consumer: %v", err))
+	}
+	defer c.Close()
+
+	if err := c.Subscribe(DeadLetterTopic(topic), nil); err != nil {
+		return 0, errors.InternalServerError(fmt.Sprintf("cannot subscribe dead letter queue: %v", err))
+	}
+
+	replayed := 0
+	idleSince := time.Now()
+	for replayed < limit && time.Since(idleSince) < replayIdleTimeout {
+		if ctx.Err() != nil {
+			return replayed, ctx.Err()
+		}
+
+		msg, err := c.ReadMessage(pollTimeout)
+		if err != nil {
+			if kerr, ok := err.(k.Error); ok && kerr.Code() == k.ErrTimedOut {
+				continue
+			}
+			return replayed, errors.InternalServerError(fmt.Sprintf("cannot read dead letter queue: %v", err))
+		}
+
+		if err := r.producer.PublishSync(ctx, topic, string(msg.Key), withHeaders(msg.Headers, map[string]string{
+			HeaderReplayedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		}), msg.Value); err != nil {
+			return replayed, errors.InternalServerError(fmt.Sprintf("cannot replay message: %v", err))
+		}
+		if _, err := c.CommitMessage(msg); err != nil {
+			return replayed, errors.InternalServerError(fmt.Sprintf("cannot commit dead letter queue: %v", err))
+		}
+
+		replayed++
+		idleSince = time.Now()
+	}
+
+	r.logger.Info(ctx, fmt.Sprintf("Replayed %d message from %s", replayed, DeadLetterTopic(topic)), topic)
+	return replayed, nil
+}
+
+func headerValue(message *k.Message, key string) string {
+	for _, h := range message.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// withHeaders keeps the non retry headers of headers and adds the non empty values
+func withHeaders(headers []k.Header, values map[string]string) map[string]string {
+	results := make(map[string]string, len(headers)+len(values))
+	for _, h := range headers {
+		if !retryHeaders[h.Key] {
+			results[h.Key] = string(h.Value)
+		}
+	}
+	for key, value := range values {
+		if value != "" {
+			results[key] = value
+		}
+	}
+	return results
+}
@@ -0,0 +1,165 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+	mockkafka "worker-service/mocks/pkg/kafka"
+	mocklog "worker-service/mocks/pkg/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+type RetryTestSuite struct {
+	suite.Suite
+	mockProducer *mockkafka.Producer
+	mockLogger   *mocklog.Logger
+	retrier      kafkaConfluent.Retrier
+	published    published
+}
+
+func (suite *RetryTestSuite) SetupTest() {
+	suite.mockProducer = new(mockkafka.Producer)
+	suite.mockLogger = &mocklog.Logger{}
+	suite.mockLogger.On("Info", mock.Anything, mock.Anything, mock.Anything)
+	suite.published = published{}
+
+	tiers, _ := kafkaConfluent.ParseRetryTiers("1m,10m")
+	suite.retrier = kafkaConfluent.NewRetrier(suite.mockProducer, tiers, nil, suite.mockLogger)
+}
+
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
+
+func (suite *RetryTestSuite) capture(err error) {
+	suite.mockProducer.On("PublishSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		suite.published = published{
+			topic:   args.String(1),
+			key:     args.String(2),
+			headers: args.Get(3).(map[string]string),
+			value:   args.Get(4).([]byte),
+		}
+	}).Return(err)
+}
+
+// published is the message passed to PublishSync
+type published struct {
+	topic   string
+	key     string
+	headers map[string]string
+	value   []byte
+}
+
+func (suite *RetryTestSuite) TestParseRetryTiers() {
+	tiers, err := kafkaConfluent.ParseRetryTiers(" 30s, 5m ")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []kafkaConfluent.RetryTier{
+		{Name: "30s", Delay: 30 * time.Second},
+		{Name: "5m", Delay: 5 * time.Minute},
+	}, tiers)
+
+	tiers, err = kafkaConfluent.ParseRetryTiers("")
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), tiers, 2)
+
+	_, err = kafkaConfluent.ParseRetryTiers("soon")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *RetryTestSuite) TestFailFirstTier() {
+	suite.capture(nil)
+	topic := "concert-create-bank-ticket"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 2, Offset: 10},
+		Key:            []byte("key"),
+		Value:          []byte(`{"ticketId":"id"}`),
+		Headers:        []kafka.Header{{Key: "trace", Value: []byte("abc")}},
+	}
+
+	err := suite.retrier.Fail(context.Background(), msg, errors.New("mongo down"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "concert-create-bank-ticket.retry.1m", suite.published.topic)
+	assert.Equal(suite.T(), msg.Value, suite.published.value)
+	assert.Equal(suite.T(), string(msg.Key), suite.published.key)
+	assert.Equal(suite.T(), "abc", suite.published.headers["trace"])
+	assert.Equal(suite.T(), topic, suite.published.headers[kafkaConfluent.HeaderOriginalTopic])
+	assert.Equal(suite.T(), "2", suite.published.headers[kafkaConfluent.HeaderOriginalPartition])
+	assert.Equal(suite.T(), "10", suite.published.headers[kafkaConfluent.HeaderOriginalOffset])
+	assert.Equal(suite.T(), "1", suite.published.headers[kafkaConfluent.HeaderAttempt])
+	assert.Equal(suite.T(), "mongo down", suite.published.headers[kafkaConfluent.HeaderError])
+	assert.NotEmpty(suite.T(), suite.published.headers[kafkaConfluent.HeaderRetryAt])
+}
+
+func (suite *RetryTestSuite) TestFailDeadLetter() {
+	suite.capture(nil)
+	topic := "concert-create-bank-ticket.retry.10m"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Value:          []byte(`{"ticketId":"id"}`),
+		Headers: []kafka.Header{
+			{Key: kafkaConfluent.HeaderOriginalTopic, Value: []byte("concert-create-bank-ticket")},
+			{Key: kafkaConfluent.HeaderAttempt, Value: []byte("2")},
+			{Key: kafkaConfluent.HeaderFirstFailedAt, Value: []byte("2024-01-01T00:00:00Z")},
+			{Key: kafkaConfluent.HeaderRetryAt, Value: []byte("2024-01-01T00:10:00Z")},
+		},
+	}
+
+	err := suite.retrier.Fail(context.Background(), msg, errors.New("still failing"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "concert-create-bank-ticket.dlq", suite.published.topic)
+	assert.Equal(suite.T(), "3", suite.published.headers[kafkaConfluent.HeaderAttempt])
+	assert.Equal(suite.T(), "2024-01-01T00:00:00Z", suite.published.headers[kafkaConfluent.HeaderFirstFailedAt])
+	assert.Equal(suite.T(), "still failing", suite.published.headers[kafkaConfluent.HeaderError])
+	assert.Empty(suite.T(), suite.published.headers[kafkaConfluent.HeaderRetryAt])
+}
+
+func (suite *RetryTestSuite) TestFailPublishErr() {
+	suite.capture(errors.New("broker down"))
+	topic := "concert-create-bank-ticket"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+	}
+
+	err := suite.retrier.Fail(context.Background(), msg, errors.New("mongo down"))
+	assert.EqualError(suite.T(), err, "broker down")
+}
+
+func (suite *RetryTestSuite) TestHandleDelayedRetry() {
+	router := kafkaConfluent.NewRouter()
+	called := 0
+	suite.retrier.Handle(router, "topic", func(ctx context.Context, message *kafka.Message) error {
+		called++
+		return nil
+	})
+
+	topic := "topic.retry.1m"
+	retryAt := time.Now().Add(time.Hour).UTC()
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Headers: []kafka.Header{
+			{Key: kafkaConfluent.HeaderRetryAt, Value: []byte(retryAt.Format(time.RFC3339Nano))},
+		},
+	}
+
+	err := router.Dispatch(context.Background(), msg)
+	var notDue *kafkaConfluent.NotDueError
+	assert.ErrorAs(suite.T(), err, &notDue)
+	assert.True(suite.T(), retryAt.Equal(notDue.Until))
+	assert.Equal(suite.T(), 0, called)
+
+	msg.Headers = nil
+	err = router.Dispatch(context.Background(), msg)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, called)
+}
+
+func (suite *RetryTestSuite) TestReplayUnknownTopic() {
+	_, err := suite.retrier.Replay(context.Background(), "unknown", 10)
+	assert.Error(suite.T(), err)
+}
@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	k "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+// wildcard is the suffix used to register a handler for every topic sharing a prefix
+const wildcard = "*"
+
+type router struct {
+	mu       sync.RWMutex
+	exact    map[string]HandlerFunc
+	prefixes map[string]HandlerFunc
+}
+
+// NewRouter is a constructor of kafka topic router
+func NewRouter() Router {
+	return &router{
+		exact:    make(map[string]HandlerFunc),
+		prefixes: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers handler for topic, a topic ending with "*" matches every topic with that prefix
+func (r *router) Handle(topic string, handler HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if strings.HasSuffix(topic, wildcard) {
+		r.prefixes[strings.TrimSuffix(topic, wildcard)] = handler
+		return
+	}
+	r.exact[topic] = handler
+}
+
+// Topics returns the subscription list, prefix routes are converted to kafka regex subscriptions
+func (r *router) Topics() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	topics := make([]string, 0, len(r.exact)+len(r.prefixes))
+	for topic := range r.exact {
+		topics = append(topics, topic)
+	}
+	for prefix := range r.prefixes {
+		topics = append(topics, fmt.Sprintf("^%s.*", regexp.QuoteMeta(prefix)))
+	}
+	sort.Strings(topics)
+
+	return topics
+}
+
+// Match returns the handler of topic, exact routes win over the longest matching prefix
+func (r *router) Match(topic string) (HandlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if handler, ok := r.exact[topic]; ok {
+		return handler, true
+	}
+
+	var (
+		matched HandlerFunc
+		longest = -1
+	)
+	for prefix, handler := range r.prefixes {
+		if strings.HasPrefix(topic, prefix) && len(prefix) > longest {
+			matched = handler
+			longest = len(prefix)
+		}
+	}
+
+	return matched, matched != nil
+}
+
+// Dispatch sends message to the handler registered for its topic
+func (r *router) Dispatch(ctx context.Context, message *k.Message) error {
+	if message.TopicPartition.Topic == nil {
+		return fmt.Errorf("kafka message has no topic")
+	}
+	topic := *message.TopicPartition.Topic
+
+	handler, ok := r.Match(topic)
+	if !ok {
+		return fmt.Errorf("no handler registered for topic %s", topic)
+	}
+
+	return handler(ctx, message)
+}
@@ -0,0 +1,87 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	kafkaConfluent "worker-service/internal/pkg/kafka/confluent"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+type RouterTestSuite struct {
+	suite.Suite
+	router kafkaConfluent.Router
+	called string
+}
+
+func (suite *RouterTestSuite) SetupTest() {
+	suite.router = kafkaConfluent.NewRouter()
+	suite.called = ""
+}
+
+func TestRouterTestSuite(t *testing.T) {
+	suite.Run(t, new(RouterTestSuite))
+}
+
+func (suite *RouterTestSuite) handler(name string) kafkaConfluent.HandlerFunc {
+	return func(ctx context.Context, message *kafka.Message) error {
+		suite.called = name
+		return nil
+	}
+}
+
+func message(topic string) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+	}
+}
+
+func (suite *RouterTestSuite) TestTopics() {
+	suite.router.Handle("concert-create-bank-ticket", suite.handler("exact"))
+	suite.router.Handle("concert.retry.*", suite.handler("prefix"))
+
+	assert.Equal(suite.T(), []string{"^concert\\.retry\\..*", "concert-create-bank-ticket"}, suite.router.Topics())
+}
+
+func (suite *RouterTestSuite) TestDispatchExact() {
+	suite.router.Handle("concert-create-bank-ticket", suite.handler("exact"))
+	suite.router.Handle("concert-*", suite.handler("prefix"))
+
+	err := suite.router.Dispatch(context.Background(), message("concert-create-bank-ticket"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "exact", suite.called)
+}
+
+func (suite *RouterTestSuite) TestDispatchLongestPrefix() {
+	suite.router.Handle("concert-*", suite.handler("short"))
+	suite.router.Handle("concert-create-*", suite.handler("long"))
+
+	err := suite.router.Dispatch(context.Background(), message("concert-create-bank-ticket"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "long", suite.called)
+}
+
+func (suite *RouterTestSuite) TestDispatchNotFound() {
+	suite.router.Handle("concert-create-bank-ticket", suite.handler("exact"))
+
+	err := suite.router.Dispatch(context.Background(), message("other"))
+	assert.Error(suite.T(), err)
+	assert.Empty(suite.T(), suite.called)
+}
+
+func (suite *RouterTestSuite) TestDispatchNoTopic() {
+	err := suite.router.Dispatch(context.Background(), &kafka.Message{})
+	assert.Error(suite.T(), err)
+}
+
+func (suite *RouterTestSuite) TestDispatchHandlerError() {
+	suite.router.Handle("concert-create-bank-ticket", func(ctx context.Context, message *kafka.Message) error {
+		return errors.New("failed")
+	})
+
+	err := suite.router.Dispatch(context.Background(), message("concert-create-bank-ticket"))
+	assert.EqualError(suite.T(), err, "failed")
+}
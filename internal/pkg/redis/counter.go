@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"context"
+	"errors"
+)
+
+// incrementCounterScript adds ARGV[1] to the counter only while it stays between 0 and ARGV[2],
+// -1 is returned when there is no counter and -2 when it would leave its bounds
+const incrementCounterScript = `local value = redis.call("get", KEYS[1])
+if not value then return -1 end
+local next = tonumber(value) + tonumber(ARGV[1])
+if next < 0 or next > tonumber(ARGV[2]) then return -2 end
+return redis.call("incrby", KEYS[1], ARGV[1])`
+
+// setCounterScript sets the counter to ARGV[2] only while it still holds ARGV[1], an empty ARGV[1] expects no counter,
+// 0 is returned when the counter changed in between
+const setCounterScript = `local value = redis.call("get", KEYS[1])
+if (value or "") ~= ARGV[1] then return 0 end
+redis.call("set", KEYS[1], ARGV[2])
+return 1`
+
+var (
+	incrementCounterScriptSha = scriptSha(incrementCounterScript)
+	setCounterScriptSha       = scriptSha(setCounterScript)
+)
+
+var (
+	// ErrCounterNotFound is returned when the counter was never set
+	ErrCounterNotFound = errors.New("redis counter not found")
+	// ErrCounterOutOfRange is returned when the counter would go below 0 or above its max
+	ErrCounterOutOfRange = errors.New("redis counter out of range")
+	// ErrCounterChanged is returned when the counter no longer holds the value it was expected to
+	ErrCounterChanged = errors.New("redis counter changed")
+)
+
+// IncrementCounter atomically adds delta to the counter of key while it stays between 0 and max, and returns its value.
+// A missing counter is not created, it is left to whoever knows its real value.
+func IncrementCounter(ctx context.Context, client Collections, key string, delta, max int64) (int64, error) {
+	value, err := evalScript(ctx, client, incrementCounterScript, incrementCounterScriptSha, []string{key}, delta, max)
+	if err != nil {
+		return 0, err
+	}
+	switch value {
+	case -1:
+		return 0, ErrCounterNotFound
+	case -2:
+		return 0, ErrCounterOutOfRange
+	}
+	return value, nil
+}
+
+// SetCounter atomically sets the counter of key to value while it still holds expected, the value last read from it,
+// an empty expected means there was no counter. ErrCounterChanged is returned when it changed in between.
+func SetCounter(ctx context.Context, client Collections, key, expected string, value int64) error {
+	set, err := evalScript(ctx, client, setCounterScript, setCounterScriptSha, []string{key}, expected, value)
+	if err != nil {
+		return err
+	}
+	if set == 0 {
+		return ErrCounterChanged
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// acquireLeaseScript takes a free lease and stamps it with the next fencing token kept in the second key
+const acquireLeaseScript = `if redis.call("exists", KEYS[1]) == 1 then return 0 end
+local fence = redis.call("incr", KEYS[2])
+redis.call("set", KEYS[1], ARGV[1] .. "|" .. fence, "PX", ARGV[2])
+return fence`
+
+// queueLeaseScript takes a free lease like acquireLeaseScript, a held lease is instead marked in the third key
+// with its fencing token so its holder takes it once more when done
+const queueLeaseScript = `local holder = redis.call("get", KEYS[1])
+if holder then
+	redis.call("set", KEYS[3], string.match(holder, "|(%d+)$") or "0")
+	return 0
+end
+local fence = redis.call("incr", KEYS[2])
+redis.call("set", KEYS[1], ARGV[1] .. "|" .. fence, "PX", ARGV[2])
+return fence`
+
+// dequeueLeaseScript releases the lease held with ARGV[1], unless the third key marks it queued:
+// the lease then stays with the holder under the next fencing token. A mark left by an earlier lease is dropped.
+const dequeueLeaseScript = `if redis.call("get", KEYS[1]) ~= ARGV[1] then return -1 end
+local queued = redis.call("get", KEYS[3])
+redis.call("del", KEYS[3])
+if queued == ARGV[3] then
+	local fence = redis.call("incr", KEYS[2])
+	redis.call("set", KEYS[1], ARGV[2] .. "|" .. fence, "PX", ARGV[4])
+	return fence
+end
+redis.call("del", KEYS[1])
+return 0`
+
+var (
+	acquireLeaseScriptSha = scriptSha(acquireLeaseScript)
+	queueLeaseScriptSha   = scriptSha(queueLeaseScript)
+	dequeueLeaseScriptSha = scriptSha(dequeueLeaseScript)
+)
+
+// ErrLeaseQueued is returned when the lease is held and the acquisition is queued behind its holder
+var ErrLeaseQueued = errors.New("redis lease queued")
+
+// Lease is a lock renewed by its holder while it works. Every acquisition gets a larger fencing token, which tells
+// the work of a holder which lost its lease apart from the work of the next one in what they report. The token is not
+// checked by their writes, so the lease is a best-effort exclusion: a holder only learns it lost the lease when it
+// next renews it, and may still write until then.
+type Lease struct {
+	client   Collections
+	key      string
+	fenceKey string
+	queueKey string
+	holder   string
+	fence    int64
+}
+
+// AcquireLease takes the lease of key for holder, fenceKey keeps the last fencing token given out
+func AcquireLease(ctx context.Context, client Collections, key, fenceKey, holder string, ttl time.Duration) (*Lease, error) {
+	fence, err := evalScript(ctx, client, acquireLeaseScript, acquireLeaseScriptSha, []string{key, fenceKey}, holder, ttl.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrLockNotAcquired
+	}
+
+	return &Lease{
+		client:   client,
+		key:      key,
+		fenceKey: fenceKey,
+		holder:   holder,
+		fence:    fence,
+	}, nil
+}
+
+// QueueLease takes the lease of key like AcquireLease. When the lease is held, ErrLeaseQueued is returned and the
+// acquisition is queued in queueKey, so the holder takes the lease once more on Dequeue. At most one acquisition is queued.
+func QueueLease(ctx context.Context, client Collections, key, fenceKey, queueKey, holder string, ttl time.Duration) (*Lease, error) {
+	fence, err := evalScript(ctx, client, queueLeaseScript, queueLeaseScriptSha, []string{key, fenceKey, queueKey}, holder, ttl.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrLeaseQueued
+	}
+
+	return &Lease{
+		client:   client,
+		key:      key,
+		fenceKey: fenceKey,
+		queueKey: queueKey,
+		holder:   holder,
+		fence:    fence,
+	}, nil
+}
+
+// Fence returns the fencing token of the lease
+func (l *Lease) Fence() int64 {
+	return l.fence
+}
+
+// Renew extends the lease to ttl, ErrLockLost is returned when the lease expired or was taken by someone else
+func (l *Lease) Renew(ctx context.Context, ttl time.Duration) error {
+	renewed, err := evalScript(ctx, l.client, refreshScript, refreshScriptSha, []string{l.key}, leaseValue(l.holder, l.fence), ttl.Milliseconds())
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release frees the lease if it is still held by this lease
+func (l *Lease) Release(ctx context.Context) error {
+	_, err := evalScript(ctx, l.client, releaseScript, releaseScriptSha, []string{l.key}, leaseValue(l.holder, l.fence))
+	return err
+}
+
+// Dequeue releases the lease, unless an acquisition was queued while it was held: the lease is then kept for that
+// acquisition under the next fencing token and true is returned. A lease not taken through QueueLease is released.
+func (l *Lease) Dequeue(ctx context.Context, ttl time.Duration) (bool, error) {
+	if l.queueKey == "" {
+		return false, l.Release(ctx)
+	}
+
+	fence, err := evalScript(ctx, l.client, dequeueLeaseScript, dequeueLeaseScriptSha, []string{l.key, l.fenceKey, l.queueKey},
+		leaseValue(l.holder, l.fence), l.holder, l.fence, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	if fence < 0 {
+		return false, ErrLockLost
+	}
+	if fence == 0 {
+		return false, nil
+	}
+	l.fence = fence
+	return true, nil
+}
+
+// FindLease returns the holder and fencing token of the lease of key, the holder is empty when the lease is free
+func FindLease(ctx context.Context, client Collections, key string) (string, int64, error) {
+	value, err := client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	i := strings.LastIndex(value, "|")
+	if i < 0 {
+		return "", 0, fmt.Errorf("invalid lease value %q", value)
+	}
+	fence, err := strconv.ParseInt(value[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid lease fence %q", value)
+	}
+	return value[:i], fence, nil
+}
+
+func leaseValue(holder string, fence int64) string {
+	return fmt.Sprintf("%s|%d", holder, fence)
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// releaseScript deletes the lock only when it is still held by the caller token
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// refreshScript extends the lock only when it is still held by the caller token
+const refreshScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
+var (
+	releaseScriptSha = scriptSha(releaseScript)
+	refreshScriptSha = scriptSha(refreshScript)
+)
+
+var (
+	// ErrLockNotAcquired is returned when the lock is held by someone else
+	ErrLockNotAcquired = errors.New("redis lock not acquired")
+	// ErrLockLost is returned when the lock expired or was taken by someone else
+	ErrLockLost = errors.New("redis lock lost")
+)
+
+// Lock is a redis lock owned by a random token
+type Lock struct {
+	client Collections
+	key    string
+	token  string
+}
+
+// AcquireLock takes the lock of key with SETNX, the lock expires after ttl if never released
+func AcquireLock(ctx context.Context, client Collections, key string, ttl time.Duration) (*Lock, error) {
+	token := uuid.NewString()
+	ok, err := client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotAcquired
+	}
+
+	return &Lock{
+		client: client,
+		key:    key,
+		token:  token,
+	}, nil
+}
+
+// Token returns the token of the lock owner
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// Release deletes the lock if it is still owned by this lock
+func (l *Lock) Release(ctx context.Context) error {
+	_, err := l.eval(ctx, releaseScript, releaseScriptSha, l.token)
+	return err
+}
+
+// Refresh extends the lock to ttl, ErrLockLost is returned when the lock is no longer owned by this lock
+func (l *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	refreshed, err := l.eval(ctx, refreshScript, refreshScriptSha, l.token, ttl.Milliseconds())
+	if err != nil {
+		return err
+	}
+	if refreshed == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// eval runs a cached script on the lock key
+func (l *Lock) eval(ctx context.Context, script, sha string, args ...interface{}) (int64, error) {
+	return evalScript(ctx, l.client, script, sha, []string{l.key}, args...)
+}
+
+// evalScript runs a cached script, the script is loaded when redis does not know it yet
+func evalScript(ctx context.Context, client Collections, script, sha string, keys []string, args ...interface{}) (int64, error) {
+	result, err := client.EvalSha(ctx, sha, keys, args...).Int64()
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		if err = client.ScriptLoad(ctx, script).Err(); err != nil {
+			return 0, err
+		}
+		result, err = client.EvalSha(ctx, sha, keys, args...).Int64()
+	}
+	return result, err
+}
+
+func scriptSha(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
@@ -13,107 +13,117 @@ import (
 	redistrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/go-redis/redis.v8"
 )
 
-// var redisClient *redis.Client
+// modes of RedisAppConfig, any other value is a standalone redis
+const (
+	ModeCluster  = "cluster"
+	ModeSentinel = "sentinel"
+)
 
+// RedisClient runs the commands on a standalone, a sentinel monitored or a cluster redis
 type RedisClient struct {
-	Client interface{}
+	Client redis.UniversalClient
 }
 
-func InitConnection(redisDB, redisHost, redisPort, redisPassword string, appConfig string) Collections {
-	var client interface{}
+// InitConnection connects the redis of cfg and panics when it cannot be reached.
+// RedisHost lists the comma separated host:port of the cluster nodes or of the sentinels in those modes,
+// RedisDB is ignored by a cluster.
+func InitConnection(cfg configs.RedisConfig) Collections {
+	client := NewUniversalClient(cfg)
 
-	if appConfig != "cluster" {
-		// Create Redis Client
-		db := 0
-		parseRedisDb, err := strconv.ParseInt(redisDB, 10, 32)
+	if configs.GetConfig().Datadog.DatadogEnabled == "true" {
+		redistrace.WrapClient(client)
+	}
 
-		if err == nil {
-			db = int(parseRedisDb)
-		}
+	if err := ping(context.Background(), client); err != nil {
+		panic(fmt.Sprintf("cannot connect redis: %v", err))
+	}
+	return &RedisClient{Client: client}
+}
 
-		c := redis.NewClient(&redis.Options{
-			Addr:     fmt.Sprintf("%v:%v", redisHost, redisPort),
-			Password: redisPassword,
-			DB:       db,
-		})
+// NewUniversalClient returns the client of the mode of cfg, it does not connect yet
+func NewUniversalClient(cfg configs.RedisConfig) redis.UniversalClient {
+	db := 0
+	if parseRedisDb, err := strconv.ParseInt(cfg.RedisDB, 10, 32); err == nil {
+		db = int(parseRedisDb)
+	}
 
-		if configs.GetConfig().Datadog.DatadogEnabled == "true" {
-			redistrace.WrapClient(c)
-		}
-
-		if c.Ping(context.Background()).Err() != nil {
-			panic("cannot connect redis")
-		}
-		client = c
-	} else {
-		// Create Redis Cluster Client
-		hostArray := strings.Split(redisHost, ",")
-		c := redis.NewClusterClient(&redis.ClusterOptions{
-			Addrs:    hostArray,
-			Password: redisPassword,
-		})
+	options := &redis.UniversalOptions{
+		Addrs:            strings.Split(cfg.RedisHost, ","),
+		DB:               db,
+		Password:         cfg.RedisPassword,
+		MasterName:       cfg.RedisSentinelMasterName,
+		SentinelPassword: cfg.RedisSentinelPassword,
+	}
 
-		// Test Connection
-		for _, addr := range hostArray {
-			nodeClient := redis.NewClient(&redis.Options{
-				Addr:     addr,
-				Password: redisPassword,
-			})
-
-			_, err := nodeClient.Ping(context.Background()).Result()
-			if err != nil {
-				fmt.Println("REDIS CLUSTER ERROR:", err.Error())
-				panic("Cannot connect to Redis Cluster")
-			}
-			nodeClient.Close()
-		}
-		client = c
+	switch cfg.RedisAppConfig {
+	case ModeCluster:
+		return redis.NewClusterClient(options.Cluster())
+	case ModeSentinel:
+		return redis.NewFailoverClient(options.Failover())
+	default:
+		options.Addrs = []string{fmt.Sprintf("%v:%v", cfg.RedisHost, cfg.RedisPort)}
+		return redis.NewClient(options.Simple())
 	}
-	return &RedisClient{Client: client}
 }
 
+// ping checks every master of a cluster, and the one redis of the other modes
+func ping(ctx context.Context, client redis.UniversalClient) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return master.Ping(ctx).Err()
+		})
+	}
+	return client.Ping(ctx).Err()
+}
+
+// Collections is the collection of redis commands the service runs.
+// The keys of a command with many keys, or of a script, must share a hash tag to work on a cluster.
 type Collections interface {
 	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
 	EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd
+	ScriptLoad(ctx context.Context, script string) *redis.StringCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
-	Conn(ctx context.Context) *redis.Conn
 	Get(ctx context.Context, key string) *redis.StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	Rename(ctx context.Context, key, newkey string) *redis.StatusCmd
 
 	Close() error
 }
 
 func (r *RedisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
-	return r.Client.(*redis.Client).SetNX(ctx, key, value, expiration)
+	return r.Client.SetNX(ctx, key, value, expiration)
 }
 
 func (r *RedisClient) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
-	return r.Client.(*redis.Client).EvalSha(ctx, sha1, keys, args...)
+	return r.Client.EvalSha(ctx, sha1, keys, args...)
 }
 
-func (r *RedisClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
-	return r.Client.(*redis.Client).Del(ctx, keys...)
+// ScriptLoad loads script on every master of a cluster, so it can run on the one owning its keys
+func (r *RedisClient) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	return r.Client.ScriptLoad(ctx, script)
 }
 
-func (r *RedisClient) Conn(ctx context.Context) *redis.Conn {
-	return r.Client.(*redis.Client).Conn(ctx)
+func (r *RedisClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	return r.Client.Del(ctx, keys...)
 }
 
 func (r *RedisClient) Get(ctx context.Context, key string) *redis.StringCmd {
-	return r.Client.(*redis.Client).Get(ctx, key)
+	return r.Client.Get(ctx, key)
 }
 
 func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
-	return r.Client.(*redis.Client).Set(ctx, key, value, expiration)
+	return r.Client.Set(ctx, key, value, expiration)
+}
+
+func (r *RedisClient) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return r.Client.SAdd(ctx, key, members...)
+}
+
+func (r *RedisClient) Rename(ctx context.Context, key, newkey string) *redis.StatusCmd {
+	return r.Client.Rename(ctx, key, newkey)
 }
 
 func (r *RedisClient) Close() error {
-	switch c := r.Client.(type) {
-	case *redis.Client:
-		return c.Close()
-	case *redis.ClusterClient:
-		return c.Close()
-	default:
-		return fmt.Errorf("unsupported Redis client type")
-	}
+	return r.Client.Close()
 }
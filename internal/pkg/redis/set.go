@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"context"
+)
+
+// addSetScript adds ARGV to the set KEYS[1], and to the set KEYS[2] as well while it exists, and returns the number
+// of members added to KEYS[1]. KEYS[2] is the rebuild of KEYS[1], members added meanwhile are kept when it replaces KEYS[1].
+const addSetScript = `local rebuilding = redis.call("exists", KEYS[2]) == 1
+local added = 0
+for i = 1, #ARGV do
+	added = added + redis.call("sadd", KEYS[1], ARGV[i])
+	if rebuilding then redis.call("sadd", KEYS[2], ARGV[i]) end
+end
+return added`
+
+// replaceSetScript replaces KEYS[2] with KEYS[1] without ARGV[1], the member keeping KEYS[1] existing while it was
+// rebuilt, and returns the size of KEYS[2]. KEYS[2] is removed when the rebuild is empty.
+const replaceSetScript = `redis.call("srem", KEYS[1], ARGV[1])
+local size = redis.call("scard", KEYS[1])
+if size > 0 then redis.call("rename", KEYS[1], KEYS[2]) else redis.call("del", KEYS[2]) end
+return size`
+
+var (
+	addSetScriptSha     = scriptSha(addSetScript)
+	replaceSetScriptSha = scriptSha(replaceSetScript)
+)
+
+// AddSet atomically adds members to the set key and to its rebuild while there is one, and returns the number of
+// members added to key. Both keys must share a hash tag on a redis cluster.
+func AddSet(ctx context.Context, client Collections, key, rebuild string, members ...interface{}) (int64, error) {
+	return evalScript(ctx, client, addSetScript, addSetScriptSha, []string{key, rebuild}, members...)
+}
+
+// ReplaceSet atomically replaces the set dst with its rebuild src, which is started with marker as its only member so
+// AddSet adds to it, and returns the size of dst. Both keys must share a hash tag on a redis cluster.
+func ReplaceSet(ctx context.Context, client Collections, src, dst, marker string) (int64, error) {
+	return evalScript(ctx, client, replaceSetScript, replaceSetScriptSha, []string{src, dst}, marker)
+}
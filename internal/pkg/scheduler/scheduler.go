@@ -0,0 +1,574 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"worker-service/internal/pkg/constants"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/log"
+	"worker-service/internal/pkg/redis"
+
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/robfig/cron/v3"
+)
+
+// refreshInterval is how often the job configuration is read again from the store,
+// so a job paused on one replica stops on the others within that time
+const refreshInterval = time.Minute
+
+// overlap policy of a job, applied to a tick which fires while an earlier run still holds the job lease
+const (
+	// OverlapSkip drops the tick, it is the policy of a job without one
+	OverlapSkip = "skip"
+	// OverlapQueue runs the job once more right after the earlier run, at most one run waits
+	OverlapQueue = "queue"
+)
+
+// JobConfig is the runtime configuration of a job
+type JobConfig struct {
+	Name     string `json:"name"`
+	Spec     string `json:"spec"`
+	Timezone string `json:"timezone"`
+	Enabled  bool   `json:"enabled"`
+	// Timeout cancels a run which takes longer, 0 never cancels it
+	Timeout time.Duration `json:"timeout"`
+	Overlap string        `json:"overlap"`
+}
+
+// Job is a cron job, each run is held by a single instance through a redis lease. The lease is a best-effort
+// exclusion, a run whose lease was lost is only canceled at its next renewal, so two runs may overlap until then.
+// Its config is the default used until the store has one for the job.
+type Job struct {
+	JobConfig
+	Run func(ctx context.Context)
+}
+
+// JobStatus is a job with its next run on this instance and its last run on any instance
+type JobStatus struct {
+	JobConfig
+	NextRun *time.Time `json:"nextRun"`
+	LastRun *time.Time `json:"lastRun"`
+}
+
+// Leader is the instance holding the lease of a job, Holder is empty when no run is in progress
+type Leader struct {
+	Job    string `json:"job"`
+	Holder string `json:"holder"`
+	Fence  int64  `json:"fence"`
+	// Self is set when the holder is this instance
+	Self bool `json:"self"`
+}
+
+// Store keeps the job configuration changed at runtime, shared by every instance
+type Store interface {
+	FindJobs(ctx context.Context) ([]JobConfig, error)
+	SaveJob(ctx context.Context, config JobConfig) error
+}
+
+type Scheduler interface {
+	Register(job Job) error
+	Start()
+	Jobs(ctx context.Context) ([]JobStatus, error)
+	Config(name string) (JobConfig, error)
+	Update(ctx context.Context, config JobConfig) error
+	Pause(ctx context.Context, name string) error
+	Resume(ctx context.Context, name string) error
+	Trigger(ctx context.Context, name string) error
+	Leaders(ctx context.Context) ([]Leader, error)
+	Close(ctx context.Context) error
+}
+
+type (
+	fenceContextKey    struct{}
+	instanceContextKey struct{}
+)
+
+// WithFence returns ctx carrying the fencing token of the lease a job runs under, it identifies the run in its report
+// and is not checked by the writes of the job
+func WithFence(ctx context.Context, fence int64) context.Context {
+	return context.WithValue(ctx, fenceContextKey{}, fence)
+}
+
+// FenceFromContext returns the fencing token of the running job, 0 outside a scheduled run
+func FenceFromContext(ctx context.Context) int64 {
+	fence, _ := ctx.Value(fenceContextKey{}).(int64)
+	return fence
+}
+
+// WithInstance returns ctx carrying the instance a job runs on
+func WithInstance(ctx context.Context, instance string) context.Context {
+	return context.WithValue(ctx, instanceContextKey{}, instance)
+}
+
+// InstanceFromContext returns the instance running the job, empty outside a scheduled run
+func InstanceFromContext(ctx context.Context) string {
+	instance, _ := ctx.Value(instanceContextKey{}).(string)
+	return instance
+}
+
+// entry is a registered job, id is 0 while the job is paused
+type entry struct {
+	job Job
+	id  cron.EntryID
+}
+
+type scheduler struct {
+	cron     *cron.Cron
+	client   redis.Collections
+	store    Store
+	logger   log.Logger
+	instance string
+	leaseTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+
+	// running tracks the triggered runs, the cron tracks the scheduled ones
+	running *sync.WaitGroup
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// NewScheduler returns a scheduler whose jobs run on the instance taking their lease, the lease is renewed every third of leaseTTL
+func NewScheduler(client redis.Collections, store Store, instance string, leaseTTL time.Duration, log log.Logger) Scheduler {
+	return &scheduler{
+		cron:     cron.New(),
+		client:   client,
+		store:    store,
+		logger:   log,
+		instance: instance,
+		leaseTTL: leaseTTL,
+		entries:  make(map[string]*entry),
+		running:  new(sync.WaitGroup),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *scheduler) Register(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[job.Name]; ok {
+		return fmt.Errorf("cron job %s is already registered", job.Name)
+	}
+	e := &entry{job: job}
+	if err := s.schedule(e, job.JobConfig); err != nil {
+		return err
+	}
+	s.entries[job.Name] = e
+	return nil
+}
+
+// Start applies the stored configuration and starts scheduling, the configuration is then refreshed in the background
+func (s *scheduler) Start() {
+	s.refresh()
+	s.cron.Start()
+	go s.refreshLoop()
+}
+
+func (s *scheduler) Jobs(ctx context.Context) ([]JobStatus, error) {
+	s.mu.Lock()
+	jobs := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		status := JobStatus{JobConfig: e.job.JobConfig}
+		if e.id != 0 {
+			if next := s.cron.Entry(e.id).Next; !next.IsZero() {
+				status.NextRun = &next
+			}
+		}
+		jobs = append(jobs, status)
+	}
+	s.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+
+	for i := range jobs {
+		lastRun, err := s.lastRun(ctx, jobs[i].Name)
+		if err != nil {
+			s.logger.Error(ctx, fmt.Sprintf("Failed get last run of cron job %s", jobs[i].Name), err.Error())
+			return nil, errors.InternalServerError("cannot get cron job last run")
+		}
+		jobs[i].LastRun = lastRun
+	}
+	return jobs, nil
+}
+
+// Config returns the configuration a registered job currently runs with
+func (s *scheduler) Config(name string) (JobConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[name]
+	if !ok {
+		return JobConfig{}, errors.NotFound(fmt.Sprintf("cron job %s not found", name))
+	}
+	return e.job.JobConfig, nil
+}
+
+// Update validates and saves the configuration of a registered job, then reschedules it on this instance
+func (s *scheduler) Update(ctx context.Context, config JobConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[config.Name]
+	if !ok {
+		return errors.NotFound(fmt.Sprintf("cron job %s not found", config.Name))
+	}
+	if _, err := parseSpec(config); err != nil {
+		return err
+	}
+	if err := s.store.SaveJob(ctx, config); err != nil {
+		return err
+	}
+	return s.schedule(e, config)
+}
+
+func (s *scheduler) Pause(ctx context.Context, name string) error {
+	return s.setEnabled(ctx, name, false)
+}
+
+func (s *scheduler) Resume(ctx context.Context, name string) error {
+	return s.setEnabled(ctx, name, true)
+}
+
+func (s *scheduler) setEnabled(ctx context.Context, name string, enabled bool) error {
+	config, err := s.Config(name)
+	if err != nil {
+		return err
+	}
+	config.Enabled = enabled
+	return s.Update(ctx, config)
+}
+
+// Trigger runs a job now whether it is paused or not. When another run holds the job lease it fails,
+// unless the job queues overlapping runs: the run is then queued behind the other one.
+func (s *scheduler) Trigger(ctx context.Context, name string) error {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	var job Job
+	if ok {
+		job = e.job
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return errors.NotFound(fmt.Sprintf("cron job %s not found", name))
+	}
+
+	lease, err := s.acquire(ctx, job)
+	if err == redis.ErrLockNotAcquired {
+		return errors.Conflict(fmt.Sprintf("cron job %s is already running", name))
+	}
+	if err == redis.ErrLeaseQueued {
+		s.logger.Info(ctx, fmt.Sprintf("Cron job %s is still running, triggered run queued", name), name)
+		return nil
+	}
+	if err != nil {
+		s.logger.Error(ctx, fmt.Sprintf("Failed acquire lease of cron job %s", name), err.Error())
+		return errors.InternalServerError("cannot acquire cron lease")
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.runHeld(job, lease)
+	}()
+	return nil
+}
+
+func (s *scheduler) Leaders(ctx context.Context) ([]Leader, error) {
+	leaders := make([]Leader, 0)
+	for _, job := range s.names() {
+		holder, fence, err := redis.FindLease(ctx, s.client, leaseKey(job))
+		if err != nil {
+			s.logger.Error(ctx, fmt.Sprintf("Failed get lease of cron job %s", job), err.Error())
+			return nil, errors.InternalServerError("cannot get cron lease")
+		}
+		leaders = append(leaders, Leader{
+			Job:    job,
+			Holder: holder,
+			Fence:  fence,
+			Self:   holder != "" && holder == s.instance,
+		})
+	}
+	return leaders, nil
+}
+
+// Close stops scheduling and waits for the scheduled and the triggered runs
+func (s *scheduler) Close(ctx context.Context) error {
+	s.stopped.Do(func() {
+		close(s.stop)
+	})
+	scheduled := s.cron.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		<-scheduled.Done()
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// schedule replaces the cron entry of e by config, the caller holds mu
+func (s *scheduler) schedule(e *entry, config JobConfig) error {
+	schedule, err := parseSpec(config)
+	if err != nil {
+		return err
+	}
+
+	if e.id != 0 {
+		s.cron.Remove(e.id)
+		e.id = 0
+	}
+	e.job.JobConfig = config
+	if config.Enabled {
+		job := e.job
+		e.id = s.cron.Schedule(schedule, cron.FuncJob(func() {
+			s.run(job, schedule, s.slot(e))
+		}))
+	}
+	return nil
+}
+
+// refresh applies the stored configuration of the registered jobs
+func (s *scheduler) refresh() {
+	ctx := context.Background()
+	configs, err := s.store.FindJobs(ctx)
+	if err != nil {
+		s.logger.Error(ctx, "Failed get cron job config", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, config := range configs {
+		e, ok := s.entries[config.Name]
+		if !ok || e.job.JobConfig == config {
+			continue
+		}
+		if err := s.schedule(e, config); err != nil {
+			s.logger.Error(ctx, fmt.Sprintf("Failed apply config of cron job %s", config.Name), err.Error())
+		}
+	}
+}
+
+func (s *scheduler) refreshLoop() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.refresh()
+		}
+	}
+}
+
+// run runs job for slot when this instance claims the slot and takes the lease of job,
+// a tick overlapping an earlier run follows the overlap policy of job
+func (s *scheduler) run(job Job, schedule cron.Schedule, slot time.Time) {
+	ctx := context.Background()
+	claimed, err := s.claim(ctx, job.Name, schedule, slot)
+	if err != nil {
+		s.logger.Error(ctx, fmt.Sprintf("Failed claim slot of cron job %s", job.Name), err.Error())
+		return
+	}
+	if !claimed {
+		s.logger.Info(ctx, fmt.Sprintf("Cron job %s already ran for %s, run skipped", job.Name, slot.Format(time.RFC3339)), job.Name)
+		return
+	}
+
+	lease, err := s.acquire(ctx, job)
+	if err == redis.ErrLockNotAcquired {
+		s.logger.Info(ctx, fmt.Sprintf("Cron job %s is still running, run skipped", job.Name), job.Name)
+		return
+	}
+	if err == redis.ErrLeaseQueued {
+		s.logger.Info(ctx, fmt.Sprintf("Cron job %s is still running, run queued", job.Name), job.Name)
+		return
+	}
+	if err != nil {
+		s.logger.Error(ctx, fmt.Sprintf("Failed acquire lease of cron job %s", job.Name), err.Error())
+		return
+	}
+	s.runHeld(job, lease)
+}
+
+// slot returns the activation time of the tick firing e, the current second when e was rescheduled meanwhile
+func (s *scheduler) slot(e *entry) time.Time {
+	s.mu.Lock()
+	id := e.id
+	s.mu.Unlock()
+
+	if prev := s.cron.Entry(id).Prev; !prev.IsZero() {
+		return prev
+	}
+	return time.Now().Truncate(time.Second)
+}
+
+// claim marks slot of job as run until the next slot of schedule. The lease is released after each run,
+// so without the claim a tick of the same slot firing late on another instance would run the job again.
+func (s *scheduler) claim(ctx context.Context, name string, schedule cron.Schedule, slot time.Time) (bool, error) {
+	ttl := schedule.Next(slot).Sub(slot)
+	if ttl < s.leaseTTL {
+		ttl = s.leaseTTL
+	}
+	return s.client.SetNX(ctx, slotKey(name, slot), s.instance, ttl).Result()
+}
+
+// acquire takes the lease of job, queueing the run behind the holder when the job queues overlapping runs
+func (s *scheduler) acquire(ctx context.Context, job Job) (*redis.Lease, error) {
+	if job.Overlap == OverlapQueue {
+		return redis.QueueLease(ctx, s.client, leaseKey(job.Name), fenceKey(job.Name), queueKey(job.Name), s.instance, s.leaseTTL)
+	}
+	return redis.AcquireLease(ctx, s.client, leaseKey(job.Name), fenceKey(job.Name), s.instance, s.leaseTTL)
+}
+
+// runHeld runs job under lease, then the run queued meanwhile if any, before releasing the lease
+func (s *scheduler) runHeld(job Job, lease *redis.Lease) {
+	ctx := context.Background()
+	for {
+		s.runOnce(job, lease)
+
+		queued, err := lease.Dequeue(ctx, s.leaseTTL)
+		if err != nil {
+			s.logger.Error(ctx, fmt.Sprintf("Failed release lease of cron job %s", job.Name), err.Error())
+			return
+		}
+		if !queued {
+			return
+		}
+		s.logger.Info(ctx, fmt.Sprintf("Running queued run of cron job %s, fence %d", job.Name, lease.Fence()), job.Name)
+	}
+}
+
+// runOnce runs job once, the job context is canceled once a renewal finds the lease lost or the job times out
+func (s *scheduler) runOnce(job Job, lease *redis.Lease) {
+	ctx := context.Background()
+	jobCtx := WithInstance(WithFence(ctx, lease.Fence()), s.instance)
+	var cancel context.CancelFunc
+	if job.Timeout > 0 {
+		jobCtx, cancel = context.WithTimeout(jobCtx, job.Timeout)
+	} else {
+		jobCtx, cancel = context.WithCancel(jobCtx)
+	}
+	done := make(chan struct{})
+	go s.renew(cancel, job.Name, lease, done)
+
+	job.Run(jobCtx)
+	close(done)
+	cancel()
+
+	if err := s.client.Set(ctx, lastRunKey(job.Name), time.Now().Format(time.RFC3339Nano), 0).Err(); err != nil {
+		s.logger.Error(ctx, fmt.Sprintf("Failed save last run of cron job %s", job.Name), err.Error())
+	}
+}
+
+// renew keeps the lease until done is closed, a lost lease cancels the job
+func (s *scheduler) renew(cancel context.CancelFunc, name string, lease *redis.Lease, done <-chan struct{}) {
+	ticker := time.NewTicker(s.leaseTTL / 3)
+	defer ticker.Stop()
+
+	ctx := context.Background()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := lease.Renew(ctx, s.leaseTTL)
+			if err == redis.ErrLockLost {
+				s.logger.Error(ctx, fmt.Sprintf("Lost lease of cron job %s, fence %d", name, lease.Fence()), name)
+				cancel()
+				return
+			}
+			// the lease is tried again on the next tick, it only expires after a whole ttl
+			if err != nil {
+				s.logger.Error(ctx, fmt.Sprintf("Failed renew lease of cron job %s", name), err.Error())
+			}
+		}
+	}
+}
+
+func (s *scheduler) lastRun(ctx context.Context, name string) (*time.Time, error) {
+	value, err := s.client.Get(ctx, lastRunKey(name)).Result()
+	if err == goRedis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lastRun, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &lastRun, nil
+}
+
+func (s *scheduler) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSpec validates config and parses its spec in its timezone, an empty timezone is UTC
+func parseSpec(config JobConfig) (cron.Schedule, error) {
+	if strings.HasPrefix(config.Spec, "TZ=") || strings.HasPrefix(config.Spec, "CRON_TZ=") {
+		return nil, errors.BadRequest(fmt.Sprintf("spec of cron job %s must not set a timezone, use the timezone field", config.Name))
+	}
+	if config.Timeout < 0 {
+		return nil, errors.BadRequest(fmt.Sprintf("timeout of cron job %s is negative", config.Name))
+	}
+	if config.Overlap != "" && config.Overlap != OverlapSkip && config.Overlap != OverlapQueue {
+		return nil, errors.BadRequest(fmt.Sprintf("overlap %q of cron job %s is unknown", config.Overlap, config.Name))
+	}
+
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, errors.BadRequest(fmt.Sprintf("timezone %s of cron job %s is unknown", config.Timezone, config.Name))
+	}
+	schedule, err := cron.ParseStandard(fmt.Sprintf("CRON_TZ=%s %s", location.String(), config.Spec))
+	if err != nil {
+		return nil, errors.BadRequest(fmt.Sprintf("spec %q of cron job %s is invalid: %v", config.Spec, config.Name, err))
+	}
+	return schedule, nil
+}
+
+// leaseKey, fenceKey and queueKey share the hash tag of the job, so the lease scripts using them run on a redis cluster
+func leaseKey(job string) string {
+	return fmt.Sprintf("%s:{%s}", constants.RedisKeyCronLease, job)
+}
+
+func fenceKey(job string) string {
+	return fmt.Sprintf("%s:{%s}", constants.RedisKeyCronFence, job)
+}
+
+func queueKey(job string) string {
+	return fmt.Sprintf("%s:{%s}", constants.RedisKeyCronQueue, job)
+}
+
+func slotKey(job string, slot time.Time) string {
+	return fmt.Sprintf("%s:{%s}:%d", constants.RedisKeyCronSlot, job, slot.Unix())
+}
+
+func lastRunKey(job string) string {
+	return fmt.Sprintf("%s:%s", constants.RedisKeyCronLastRun, job)
+}
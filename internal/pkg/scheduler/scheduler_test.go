@@ -0,0 +1,383 @@
+package scheduler_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"worker-service/internal/pkg/errors"
+	"worker-service/internal/pkg/scheduler"
+	mocklog "worker-service/mocks/pkg/log"
+	mockredis "worker-service/mocks/pkg/redis"
+	mockscheduler "worker-service/mocks/pkg/scheduler"
+
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchedulerTestSuite struct {
+	suite.Suite
+	mockRedis  *mockredis.Collections
+	mockStore  *mockscheduler.Store
+	mockLogger *mocklog.Logger
+	scheduler  scheduler.Scheduler
+}
+
+func (suite *SchedulerTestSuite) SetupTest() {
+	suite.mockRedis = new(mockredis.Collections)
+	suite.mockStore = new(mockscheduler.Store)
+	suite.mockLogger = &mocklog.Logger{}
+	suite.scheduler = scheduler.NewScheduler(suite.mockRedis, suite.mockStore, "pod-1", time.Minute, suite.mockLogger)
+}
+
+func (suite *SchedulerTestSuite) TearDownTest() {
+	suite.scheduler.Close(context.Background())
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
+
+func job(name, spec string, run func(ctx context.Context)) scheduler.Job {
+	return scheduler.Job{
+		JobConfig: scheduler.JobConfig{Name: name, Spec: spec, Timezone: "Asia/Jakarta", Enabled: true},
+		Run:       run,
+	}
+}
+
+func noop(ctx context.Context) {}
+
+// lease mocks redis so the lease of name is taken with fence, and the last run is saved
+func (suite *SchedulerTestSuite) lease(name string, fence int64) {
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"CRON-LEASE:{" + name + "}", "CRON-FENCE:{" + name + "}"}, "pod-1", int64(60000)).Return(goRedis.NewCmdResult(fence, nil))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"CRON-LEASE:{" + name + "}"}, mock.Anything).Return(goRedis.NewCmdResult(int64(1), nil))
+	suite.mockRedis.On("Set", mock.Anything, "CRON-LAST-RUN:"+name, mock.Anything, time.Duration(0)).Return(goRedis.NewStatusResult("OK", nil))
+}
+
+// claim mocks redis so every slot of name is claimed by this instance, or found claimed already
+func (suite *SchedulerTestSuite) claim(name string, claimed bool) {
+	suite.mockRedis.On("SetNX", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "CRON-SLOT:{"+name+"}:")
+	}), "pod-1", mock.Anything).Return(goRedis.NewBoolResult(claimed, nil))
+}
+
+func (suite *SchedulerTestSuite) TestRegisterErrSpec() {
+	err := suite.scheduler.Register(job("job", "every minute", noop))
+	assert.Error(suite.T(), err)
+}
+
+func (suite *SchedulerTestSuite) TestRegisterErrTimezone() {
+	j := job("job", "*/5 * * * *", noop)
+	j.Timezone = "Mars/Olympus"
+	err := suite.scheduler.Register(j)
+	assert.EqualError(suite.T(), err, "timezone Mars/Olympus of cron job job is unknown")
+}
+
+func (suite *SchedulerTestSuite) TestRegisterErrSpecTimezone() {
+	err := suite.scheduler.Register(job("job", "CRON_TZ=UTC */5 * * * *", noop))
+	assert.Error(suite.T(), err)
+}
+
+func (suite *SchedulerTestSuite) TestRegisterErrOverlap() {
+	j := job("job", "*/5 * * * *", noop)
+	j.Overlap = "wait"
+	err := suite.scheduler.Register(j)
+	assert.EqualError(suite.T(), err, `overlap "wait" of cron job job is unknown`)
+}
+
+func (suite *SchedulerTestSuite) TestRegisterErrDuplicate() {
+	assert.NoError(suite.T(), suite.scheduler.Register(job("job", "*/5 * * * *", noop)))
+	assert.Error(suite.T(), suite.scheduler.Register(job("job", "*/5 * * * *", noop)))
+}
+
+func (suite *SchedulerTestSuite) TestJobs() {
+	suite.scheduler.Register(job("b-job", "*/5 * * * *", noop))
+	suite.scheduler.Register(job("a-job", "*/5 * * * *", noop))
+	// the store pauses b-job
+	suite.mockStore.On("FindJobs", mock.Anything).Return([]scheduler.JobConfig{
+		{Name: "b-job", Spec: "*/10 * * * *", Timezone: "Asia/Jakarta"},
+		{Name: "unknown", Spec: "*/10 * * * *"},
+	}, nil)
+	lastRun := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	suite.mockRedis.On("Get", mock.Anything, "CRON-LAST-RUN:a-job").Return(goRedis.NewStringResult(lastRun.Format(time.RFC3339Nano), nil))
+	suite.mockRedis.On("Get", mock.Anything, "CRON-LAST-RUN:b-job").Return(goRedis.NewStringResult("", goRedis.Nil))
+	suite.scheduler.Start()
+
+	jobs, err := suite.scheduler.Jobs(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), jobs, 2)
+	assert.Equal(suite.T(), "a-job", jobs[0].Name)
+	assert.True(suite.T(), jobs[0].Enabled)
+	assert.NotNil(suite.T(), jobs[0].NextRun)
+	assert.True(suite.T(), lastRun.Equal(*jobs[0].LastRun))
+	assert.Equal(suite.T(), "*/10 * * * *", jobs[1].Spec)
+	assert.False(suite.T(), jobs[1].Enabled)
+	assert.Nil(suite.T(), jobs[1].NextRun)
+	assert.Nil(suite.T(), jobs[1].LastRun)
+}
+
+func (suite *SchedulerTestSuite) TestStartErrStore() {
+	suite.scheduler.Register(job("job", "*/5 * * * *", noop))
+	suite.mockStore.On("FindJobs", mock.Anything).Return(nil, errors.InternalServerError("error"))
+	suite.mockLogger.On("Error", mock.Anything, "Failed get cron job config", mock.Anything)
+
+	suite.scheduler.Start()
+	suite.mockLogger.AssertExpectations(suite.T())
+}
+
+func (suite *SchedulerTestSuite) TestUpdate() {
+	suite.scheduler.Register(job("job", "*/5 * * * *", noop))
+	config := scheduler.JobConfig{Name: "job", Spec: "0 * * * *", Timezone: "UTC", Enabled: true, Timeout: time.Minute}
+	suite.mockStore.On("SaveJob", mock.Anything, config).Return(nil)
+	suite.mockRedis.On("Get", mock.Anything, mock.Anything).Return(goRedis.NewStringResult("", goRedis.Nil))
+
+	err := suite.scheduler.Update(context.Background(), config)
+	assert.NoError(suite.T(), err)
+	jobs, _ := suite.scheduler.Jobs(context.Background())
+	assert.Equal(suite.T(), config, jobs[0].JobConfig)
+}
+
+func (suite *SchedulerTestSuite) TestUpdateErrTimezone() {
+	suite.scheduler.Register(job("job", "*/5 * * * *", noop))
+
+	err := suite.scheduler.Update(context.Background(), scheduler.JobConfig{Name: "job", Spec: "0 * * * *", Timezone: "Jakarta"})
+	assert.Error(suite.T(), err)
+	suite.mockStore.AssertNotCalled(suite.T(), "SaveJob", mock.Anything, mock.Anything)
+}
+
+func (suite *SchedulerTestSuite) TestUpdateErrNotFound() {
+	err := suite.scheduler.Update(context.Background(), scheduler.JobConfig{Name: "job", Spec: "0 * * * *"})
+	assert.EqualError(suite.T(), err, "cron job job not found")
+}
+
+func (suite *SchedulerTestSuite) TestPauseResume() {
+	suite.scheduler.Register(job("job", "*/5 * * * *", noop))
+	suite.mockStore.On("SaveJob", mock.Anything, mock.MatchedBy(func(config scheduler.JobConfig) bool {
+		return config.Name == "job" && config.Spec == "*/5 * * * *"
+	})).Return(nil)
+
+	assert.NoError(suite.T(), suite.scheduler.Pause(context.Background(), "job"))
+	assert.NoError(suite.T(), suite.scheduler.Resume(context.Background(), "job"))
+	saved := suite.mockStore.Calls
+	assert.False(suite.T(), saved[0].Arguments.Get(1).(scheduler.JobConfig).Enabled)
+	assert.True(suite.T(), saved[1].Arguments.Get(1).(scheduler.JobConfig).Enabled)
+}
+
+func (suite *SchedulerTestSuite) TestTrigger() {
+	suite.lease("job", 3)
+	fences := make(chan int64, 1)
+	j := job("job", "*/5 * * * *", func(ctx context.Context) {
+		fences <- scheduler.FenceFromContext(ctx)
+	})
+	j.Enabled = false
+	suite.scheduler.Register(j)
+
+	err := suite.scheduler.Trigger(context.Background(), "job")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(3), <-fences)
+	suite.scheduler.Close(context.Background())
+	suite.mockRedis.AssertCalled(suite.T(), "Set", mock.Anything, "CRON-LAST-RUN:job", mock.Anything, time.Duration(0))
+}
+
+func (suite *SchedulerTestSuite) TestTriggerTimeout() {
+	suite.lease("job", 1)
+	errs := make(chan error, 1)
+	j := job("job", "*/5 * * * *", func(ctx context.Context) {
+		<-ctx.Done()
+		errs <- ctx.Err()
+	})
+	j.Timeout = 10 * time.Millisecond
+	suite.scheduler.Register(j)
+
+	assert.NoError(suite.T(), suite.scheduler.Trigger(context.Background(), "job"))
+	assert.Equal(suite.T(), context.DeadlineExceeded, <-errs)
+}
+
+func (suite *SchedulerTestSuite) TestTriggerErrRunning() {
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(goRedis.NewCmdResult(int64(0), nil))
+	suite.scheduler.Register(job("job", "*/5 * * * *", noop))
+
+	err := suite.scheduler.Trigger(context.Background(), "job")
+	assert.EqualError(suite.T(), err, "cron job job is already running")
+}
+
+func (suite *SchedulerTestSuite) TestTriggerErrNotFound() {
+	err := suite.scheduler.Trigger(context.Background(), "job")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *SchedulerTestSuite) TestLeaders() {
+	suite.scheduler.Register(job("b-job", "*/5 * * * *", noop))
+	suite.scheduler.Register(job("a-job", "*/5 * * * *", noop))
+	suite.mockRedis.On("Get", mock.Anything, "CRON-LEASE:{a-job}").Return(goRedis.NewStringResult("pod-1|4", nil))
+	suite.mockRedis.On("Get", mock.Anything, "CRON-LEASE:{b-job}").Return(goRedis.NewStringResult("", goRedis.Nil))
+
+	leaders, err := suite.scheduler.Leaders(context.Background())
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []scheduler.Leader{
+		{Job: "a-job", Holder: "pod-1", Fence: 4, Self: true},
+		{Job: "b-job"},
+	}, leaders)
+}
+
+func (suite *SchedulerTestSuite) TestLeadersErr() {
+	suite.scheduler.Register(job("job", "*/5 * * * *", noop))
+	suite.mockRedis.On("Get", mock.Anything, "CRON-LEASE:{job}").Return(goRedis.NewStringResult("", goRedis.ErrClosed))
+	suite.mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err := suite.scheduler.Leaders(context.Background())
+	assert.Error(suite.T(), err)
+}
+
+func (suite *SchedulerTestSuite) TestRunHoldsLease() {
+	suite.claim("job", true)
+	suite.lease("job", 7)
+	suite.mockStore.On("FindJobs", mock.Anything).Return([]scheduler.JobConfig{}, nil)
+
+	fences := make(chan int64, 1)
+	suite.scheduler.Register(job("job", "@every 1s", func(ctx context.Context) {
+		select {
+		case fences <- scheduler.FenceFromContext(ctx):
+		default:
+		}
+	}))
+	suite.scheduler.Start()
+
+	select {
+	case fence := <-fences:
+		assert.Equal(suite.T(), int64(7), fence)
+	case <-time.After(3 * time.Second):
+		suite.T().Fatal("job did not run")
+	}
+}
+
+func (suite *SchedulerTestSuite) TestRunSkippedWhenHeld() {
+	suite.claim("job", true)
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(goRedis.NewCmdResult(int64(0), nil))
+	suite.mockStore.On("FindJobs", mock.Anything).Return([]scheduler.JobConfig{}, nil)
+	skipped := make(chan struct{}, 1)
+	suite.mockLogger.On("Info", mock.Anything, "Cron job job is still running, run skipped", "job").Run(func(args mock.Arguments) {
+		select {
+		case skipped <- struct{}{}:
+		default:
+		}
+	})
+
+	ran := false
+	suite.scheduler.Register(job("job", "@every 1s", func(ctx context.Context) {
+		ran = true
+	}))
+	suite.scheduler.Start()
+
+	select {
+	case <-skipped:
+	case <-time.After(3 * time.Second):
+		suite.T().Fatal("job was not skipped")
+	}
+	suite.scheduler.Close(context.Background())
+	assert.False(suite.T(), ran)
+}
+
+func (suite *SchedulerTestSuite) TestRunSkippedWhenSlotClaimed() {
+	suite.claim("job", false)
+	suite.mockStore.On("FindJobs", mock.Anything).Return([]scheduler.JobConfig{}, nil)
+	skipped := make(chan struct{}, 1)
+	suite.mockLogger.On("Info", mock.Anything, mock.MatchedBy(func(msg string) bool {
+		return strings.HasPrefix(msg, "Cron job job already ran for ")
+	}), "job").Run(func(args mock.Arguments) {
+		select {
+		case skipped <- struct{}{}:
+		default:
+		}
+	})
+
+	ran := false
+	suite.scheduler.Register(job("job", "@every 1s", func(ctx context.Context) {
+		ran = true
+	}))
+	suite.scheduler.Start()
+
+	select {
+	case <-skipped:
+	case <-time.After(3 * time.Second):
+		suite.T().Fatal("job was not skipped")
+	}
+	suite.scheduler.Close(context.Background())
+	assert.False(suite.T(), ran)
+	// the lease is not even tried for a slot run by another instance
+	suite.mockRedis.AssertNotCalled(suite.T(), "EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// queueLease mocks redis so the lease of name is taken with fence through the queue script,
+// then dequeued with the next fence once per queued run
+func (suite *SchedulerTestSuite) queueLease(name string, fence int64, queued int) {
+	keys := []string{"CRON-LEASE:{" + name + "}", "CRON-FENCE:{" + name + "}", "CRON-QUEUE:{" + name + "}"}
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, keys, "pod-1", int64(60000)).Return(goRedis.NewCmdResult(fence, nil))
+	for i := 0; i < queued; i++ {
+		value := fmt.Sprintf("pod-1|%d", fence+int64(i))
+		suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, keys, value, "pod-1", fence+int64(i), int64(60000)).Return(goRedis.NewCmdResult(fence+int64(i)+1, nil)).Once()
+	}
+	value := fmt.Sprintf("pod-1|%d", fence+int64(queued))
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, keys, value, "pod-1", fence+int64(queued), int64(60000)).Return(goRedis.NewCmdResult(int64(0), nil))
+	suite.mockRedis.On("Set", mock.Anything, "CRON-LAST-RUN:"+name, mock.Anything, time.Duration(0)).Return(goRedis.NewStatusResult("OK", nil))
+}
+
+func (suite *SchedulerTestSuite) TestTriggerQueued() {
+	suite.queueLease("job", 3, 1)
+	fences := make(chan int64, 2)
+	instances := make(chan string, 2)
+	j := job("job", "*/5 * * * *", func(ctx context.Context) {
+		fences <- scheduler.FenceFromContext(ctx)
+		instances <- scheduler.InstanceFromContext(ctx)
+	})
+	j.Overlap = scheduler.OverlapQueue
+	suite.scheduler.Register(j)
+	suite.mockLogger.On("Info", mock.Anything, "Running queued run of cron job job, fence 4", "job")
+
+	assert.NoError(suite.T(), suite.scheduler.Trigger(context.Background(), "job"))
+	suite.scheduler.Close(context.Background())
+	// the run queued meanwhile runs right after under the next fence
+	assert.Equal(suite.T(), int64(3), <-fences)
+	assert.Equal(suite.T(), int64(4), <-fences)
+	assert.Equal(suite.T(), "pod-1", <-instances)
+	suite.mockLogger.AssertExpectations(suite.T())
+}
+
+func (suite *SchedulerTestSuite) TestTriggerQueuedBehindRunning() {
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(goRedis.NewCmdResult(int64(0), nil))
+	suite.mockLogger.On("Info", mock.Anything, "Cron job job is still running, triggered run queued", "job")
+	j := job("job", "*/5 * * * *", noop)
+	j.Overlap = scheduler.OverlapQueue
+	suite.scheduler.Register(j)
+
+	err := suite.scheduler.Trigger(context.Background(), "job")
+	assert.NoError(suite.T(), err)
+	suite.mockLogger.AssertExpectations(suite.T())
+}
+
+func (suite *SchedulerTestSuite) TestRunQueuedWhenHeld() {
+	suite.claim("job", true)
+	suite.mockRedis.On("EvalSha", mock.Anything, mock.Anything, []string{"CRON-LEASE:{job}", "CRON-FENCE:{job}", "CRON-QUEUE:{job}"}, mock.Anything, mock.Anything).Return(goRedis.NewCmdResult(int64(0), nil))
+	suite.mockStore.On("FindJobs", mock.Anything).Return([]scheduler.JobConfig{}, nil)
+	queued := make(chan struct{}, 1)
+	suite.mockLogger.On("Info", mock.Anything, "Cron job job is still running, run queued", "job").Run(func(args mock.Arguments) {
+		select {
+		case queued <- struct{}{}:
+		default:
+		}
+	})
+
+	j := job("job", "@every 1s", noop)
+	j.Overlap = scheduler.OverlapQueue
+	suite.scheduler.Register(j)
+	suite.scheduler.Start()
+
+	select {
+	case <-queued:
+	case <-time.After(3 * time.Second):
+		suite.T().Fatal("job was not queued")
+	}
+}
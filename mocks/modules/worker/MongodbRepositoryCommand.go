@@ -10,6 +10,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	request "worker-service/internal/modules/worker/models/request"
+
+	time "time"
 )
 
 // MongodbRepositoryCommand is an autogenerated mock type for the MongodbRepositoryCommand type
@@ -17,6 +19,166 @@ type MongodbRepositoryCommand struct {
 	mock.Mock
 }
 
+// BulkInsertBankTicket provides a mock function with given fields: ctx, ticket
+func (_m *MongodbRepositoryCommand) BulkInsertBankTicket(ctx context.Context, ticket []entity.BankTicket) <-chan helpers.Result {
+	ret := _m.Called(ctx, ticket)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BulkInsertBankTicket")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.BankTicket) <-chan helpers.Result); ok {
+		r0 = rf(ctx, ticket)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// ClaimOutboxEvent provides a mock function with given fields: ctx, id, until
+func (_m *MongodbRepositoryCommand) ClaimOutboxEvent(ctx context.Context, id string, until time.Time) <-chan helpers.Result {
+	ret := _m.Called(ctx, id, until)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimOutboxEvent")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) <-chan helpers.Result); ok {
+		r0 = rf(ctx, id, until)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// CreateBankTicketIndex provides a mock function with given fields: ctx
+func (_m *MongodbRepositoryCommand) CreateBankTicketIndex(ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateBankTicketIndex")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context) <-chan helpers.Result); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// CreateOrderIndex provides a mock function with given fields: ctx
+func (_m *MongodbRepositoryCommand) CreateOrderIndex(ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOrderIndex")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context) <-chan helpers.Result); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// CreateOutboxEventIndex provides a mock function with given fields: ctx
+func (_m *MongodbRepositoryCommand) CreateOutboxEventIndex(ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOutboxEventIndex")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context) <-chan helpers.Result); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// CreateOutboxEventSentIndex provides a mock function with given fields: ctx, retention
+func (_m *MongodbRepositoryCommand) CreateOutboxEventSentIndex(ctx context.Context, retention time.Duration) <-chan helpers.Result {
+	ret := _m.Called(ctx, retention)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOutboxEventSentIndex")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) <-chan helpers.Result); ok {
+		r0 = rf(ctx, retention)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// CreatePaymentHistoryIndex provides a mock function with given fields: ctx
+func (_m *MongodbRepositoryCommand) CreatePaymentHistoryIndex(ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreatePaymentHistoryIndex")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context) <-chan helpers.Result); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// DeleteOneExpiryPolicy provides a mock function with given fields: ctx, id
+func (_m *MongodbRepositoryCommand) DeleteOneExpiryPolicy(ctx context.Context, id string) <-chan helpers.Result {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteOneExpiryPolicy")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // DeleteOneOrder provides a mock function with given fields: ctx, ticketNumber
 func (_m *MongodbRepositoryCommand) DeleteOneOrder(ctx context.Context, ticketNumber string) <-chan helpers.Result {
 	ret := _m.Called(ctx, ticketNumber)
@@ -37,6 +199,66 @@ func (_m *MongodbRepositoryCommand) DeleteOneOrder(ctx context.Context, ticketNu
 	return r0
 }
 
+// FailAllAbandonedWorkerJob provides a mock function with given fields: ctx, instance, before, reason
+func (_m *MongodbRepositoryCommand) FailAllAbandonedWorkerJob(ctx context.Context, instance string, before time.Time, reason string) <-chan helpers.Result {
+	ret := _m.Called(ctx, instance, before, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FailAllAbandonedWorkerJob")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, instance, before, reason)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// IncrementTicketDetailRemaining provides a mock function with given fields: ctx, ticketId, delta
+func (_m *MongodbRepositoryCommand) IncrementTicketDetailRemaining(ctx context.Context, ticketId string, delta int) <-chan helpers.Result {
+	ret := _m.Called(ctx, ticketId, delta)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementTicketDetailRemaining")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) <-chan helpers.Result); ok {
+		r0 = rf(ctx, ticketId, delta)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// InsertManyOutboxEvent provides a mock function with given fields: ctx, outboxEvents
+func (_m *MongodbRepositoryCommand) InsertManyOutboxEvent(ctx context.Context, outboxEvents []entity.OutboxEvent) <-chan helpers.Result {
+	ret := _m.Called(ctx, outboxEvents)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertManyOutboxEvent")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, []entity.OutboxEvent) <-chan helpers.Result); ok {
+		r0 = rf(ctx, outboxEvents)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // InsertManyTicketCollection provides a mock function with given fields: ctx, collection, ticket
 func (_m *MongodbRepositoryCommand) InsertManyTicketCollection(ctx context.Context, collection string, ticket []entity.BankTicket) <-chan helpers.Result {
 	ret := _m.Called(ctx, collection, ticket)
@@ -57,6 +279,86 @@ func (_m *MongodbRepositoryCommand) InsertManyTicketCollection(ctx context.Conte
 	return r0
 }
 
+// InsertOneOrphanAudit provides a mock function with given fields: ctx, audit
+func (_m *MongodbRepositoryCommand) InsertOneOrphanAudit(ctx context.Context, audit entity.OrphanAudit) <-chan helpers.Result {
+	ret := _m.Called(ctx, audit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertOneOrphanAudit")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, entity.OrphanAudit) <-chan helpers.Result); ok {
+		r0 = rf(ctx, audit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// InsertOneWorkerJob provides a mock function with given fields: ctx, job
+func (_m *MongodbRepositoryCommand) InsertOneWorkerJob(ctx context.Context, job entity.WorkerJob) <-chan helpers.Result {
+	ret := _m.Called(ctx, job)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertOneWorkerJob")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WorkerJob) <-chan helpers.Result); ok {
+		r0 = rf(ctx, job)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// InsertOneWorkerJobRun provides a mock function with given fields: ctx, run
+func (_m *MongodbRepositoryCommand) InsertOneWorkerJobRun(ctx context.Context, run entity.WorkerJobRun) <-chan helpers.Result {
+	ret := _m.Called(ctx, run)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InsertOneWorkerJobRun")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WorkerJobRun) <-chan helpers.Result); ok {
+		r0 = rf(ctx, run)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// ReleaseOneBankTicket provides a mock function with given fields: ctx, payload
+func (_m *MongodbRepositoryCommand) ReleaseOneBankTicket(ctx context.Context, payload request.ReleaseBankTicketReq) <-chan helpers.Result {
+	ret := _m.Called(ctx, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseOneBankTicket")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.ReleaseBankTicketReq) <-chan helpers.Result); ok {
+		r0 = rf(ctx, payload)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // UpdateOneBankTicket provides a mock function with given fields: ctx, payload
 func (_m *MongodbRepositoryCommand) UpdateOneBankTicket(ctx context.Context, payload request.UpdateBankTicketRequest) <-chan helpers.Result {
 	ret := _m.Called(ctx, payload)
@@ -77,6 +379,46 @@ func (_m *MongodbRepositoryCommand) UpdateOneBankTicket(ctx context.Context, pay
 	return r0
 }
 
+// UpdateOneOutboxEventRetry provides a mock function with given fields: ctx, id, nextAttemptAt, reason
+func (_m *MongodbRepositoryCommand) UpdateOneOutboxEventRetry(ctx context.Context, id string, nextAttemptAt time.Time, reason string) <-chan helpers.Result {
+	ret := _m.Called(ctx, id, nextAttemptAt, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOneOutboxEventRetry")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, id, nextAttemptAt, reason)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// UpdateOneOutboxEventSent provides a mock function with given fields: ctx, id
+func (_m *MongodbRepositoryCommand) UpdateOneOutboxEventSent(ctx context.Context, id string) <-chan helpers.Result {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOneOutboxEventSent")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // UpdateOnePayment provides a mock function with given fields: ctx, paymentId
 func (_m *MongodbRepositoryCommand) UpdateOnePayment(ctx context.Context, paymentId string) <-chan helpers.Result {
 	ret := _m.Called(ctx, paymentId)
@@ -97,17 +439,17 @@ func (_m *MongodbRepositoryCommand) UpdateOnePayment(ctx context.Context, paymen
 	return r0
 }
 
-// UpdateOnlineTicketConfig provides a mock function with given fields: ctx, payload
-func (_m *MongodbRepositoryCommand) UpdateOnlineTicketConfig(ctx context.Context, payload request.UpdateOnlineTicketConfigReq) <-chan helpers.Result {
-	ret := _m.Called(ctx, payload)
+// UpdateOneWorkerJob provides a mock function with given fields: ctx, job
+func (_m *MongodbRepositoryCommand) UpdateOneWorkerJob(ctx context.Context, job entity.WorkerJob) <-chan helpers.Result {
+	ret := _m.Called(ctx, job)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateOnlineTicketConfig")
+		panic("no return value specified for UpdateOneWorkerJob")
 	}
 
 	var r0 <-chan helpers.Result
-	if rf, ok := ret.Get(0).(func(context.Context, request.UpdateOnlineTicketConfigReq) <-chan helpers.Result); ok {
-		r0 = rf(ctx, payload)
+	if rf, ok := ret.Get(0).(func(context.Context, entity.WorkerJob) <-chan helpers.Result); ok {
+		r0 = rf(ctx, job)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(<-chan helpers.Result)
@@ -117,16 +459,16 @@ func (_m *MongodbRepositoryCommand) UpdateOnlineTicketConfig(ctx context.Context
 	return r0
 }
 
-// UpdateTicketDetailById provides a mock function with given fields: ctx, payload
-func (_m *MongodbRepositoryCommand) UpdateTicketDetailById(ctx context.Context, payload request.UpdateTicketDetailByIdReq) <-chan helpers.Result {
+// UpdateOnlineTicketConfig provides a mock function with given fields: ctx, payload
+func (_m *MongodbRepositoryCommand) UpdateOnlineTicketConfig(ctx context.Context, payload request.UpdateOnlineTicketConfigReq) <-chan helpers.Result {
 	ret := _m.Called(ctx, payload)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateTicketDetailById")
+		panic("no return value specified for UpdateOnlineTicketConfig")
 	}
 
 	var r0 <-chan helpers.Result
-	if rf, ok := ret.Get(0).(func(context.Context, request.UpdateTicketDetailByIdReq) <-chan helpers.Result); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, request.UpdateOnlineTicketConfigReq) <-chan helpers.Result); ok {
 		r0 = rf(ctx, payload)
 	} else {
 		if ret.Get(0) != nil {
@@ -157,6 +499,104 @@ func (_m *MongodbRepositoryCommand) UpdateTicketDetailByTag(ctx context.Context,
 	return r0
 }
 
+// UpdateTicketDetailRemaining provides a mock function with given fields: ctx, ticketId, from, to
+func (_m *MongodbRepositoryCommand) UpdateTicketDetailRemaining(ctx context.Context, ticketId string, from int, to int) <-chan helpers.Result {
+	ret := _m.Called(ctx, ticketId, from, to)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateTicketDetailRemaining")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) <-chan helpers.Result); ok {
+		r0 = rf(ctx, ticketId, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// UpsertBankTicketProgress provides a mock function with given fields: ctx, progress
+func (_m *MongodbRepositoryCommand) UpsertBankTicketProgress(ctx context.Context, progress entity.BankTicketProgress) <-chan helpers.Result {
+	ret := _m.Called(ctx, progress)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertBankTicketProgress")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, entity.BankTicketProgress) <-chan helpers.Result); ok {
+		r0 = rf(ctx, progress)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// UpsertCronJob provides a mock function with given fields: ctx, job
+func (_m *MongodbRepositoryCommand) UpsertCronJob(ctx context.Context, job entity.CronJob) <-chan helpers.Result {
+	ret := _m.Called(ctx, job)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertCronJob")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, entity.CronJob) <-chan helpers.Result); ok {
+		r0 = rf(ctx, job)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// UpsertExpiryPolicy provides a mock function with given fields: ctx, policy
+func (_m *MongodbRepositoryCommand) UpsertExpiryPolicy(ctx context.Context, policy entity.ExpiryPolicy) <-chan helpers.Result {
+	ret := _m.Called(ctx, policy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertExpiryPolicy")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, entity.ExpiryPolicy) <-chan helpers.Result); ok {
+		r0 = rf(ctx, policy)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// WithTransaction provides a mock function with given fields: ctx, fn
+func (_m *MongodbRepositoryCommand) WithTransaction(ctx context.Context, fn func(context.Context) error) error {
+	ret := _m.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithTransaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(context.Context) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewMongodbRepositoryCommand creates a new instance of MongodbRepositoryCommand. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMongodbRepositoryCommand(t interface {
@@ -4,11 +4,14 @@ package mocks
 
 import (
 	context "context"
+	entity "worker-service/internal/modules/worker/models/entity"
 	helpers "worker-service/internal/pkg/helpers"
 
 	mock "github.com/stretchr/testify/mock"
 
 	request "worker-service/internal/modules/worker/models/request"
+
+	time "time"
 )
 
 // MongodbRepositoryQuery is an autogenerated mock type for the MongodbRepositoryQuery type
@@ -16,12 +19,32 @@ type MongodbRepositoryQuery struct {
 	mock.Mock
 }
 
-// FindAllExpireBankTicket provides a mock function with given fields: ctx
-func (_m *MongodbRepositoryQuery) FindAllExpireBankTicket(ctx context.Context) <-chan helpers.Result {
+// AggregateBankTicketByTicketId provides a mock function with given fields: ctx, ticketIds
+func (_m *MongodbRepositoryQuery) AggregateBankTicketByTicketId(ctx context.Context, ticketIds []string) <-chan helpers.Result {
+	ret := _m.Called(ctx, ticketIds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AggregateBankTicketByTicketId")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, []string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, ticketIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllCronJob provides a mock function with given fields: ctx
+func (_m *MongodbRepositoryQuery) FindAllCronJob(ctx context.Context) <-chan helpers.Result {
 	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
-		panic("no return value specified for FindAllExpireBankTicket")
+		panic("no return value specified for FindAllCronJob")
 	}
 
 	var r0 <-chan helpers.Result
@@ -36,14 +59,54 @@ func (_m *MongodbRepositoryQuery) FindAllExpireBankTicket(ctx context.Context) <
 	return r0
 }
 
-// FindAllExpirePayment provides a mock function with given fields: ctx
-func (_m *MongodbRepositoryQuery) FindAllExpirePayment(ctx context.Context) <-chan helpers.Result {
-	ret := _m.Called(ctx)
+// FindAllExpireBankTicket provides a mock function with given fields: ctx, page, policies
+func (_m *MongodbRepositoryQuery) FindAllExpireBankTicket(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan helpers.Result {
+	ret := _m.Called(ctx, page, policies)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllExpireBankTicket")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.KeysetPageReq, []entity.ExpiryPolicy) <-chan helpers.Result); ok {
+		r0 = rf(ctx, page, policies)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllExpirePayment provides a mock function with given fields: ctx, page, policies
+func (_m *MongodbRepositoryQuery) FindAllExpirePayment(ctx context.Context, page request.KeysetPageReq, policies []entity.ExpiryPolicy) <-chan helpers.Result {
+	ret := _m.Called(ctx, page, policies)
 
 	if len(ret) == 0 {
 		panic("no return value specified for FindAllExpirePayment")
 	}
 
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.KeysetPageReq, []entity.ExpiryPolicy) <-chan helpers.Result); ok {
+		r0 = rf(ctx, page, policies)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllExpiryPolicy provides a mock function with given fields: ctx
+func (_m *MongodbRepositoryQuery) FindAllExpiryPolicy(ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllExpiryPolicy")
+	}
+
 	var r0 <-chan helpers.Result
 	if rf, ok := ret.Get(0).(func(context.Context) <-chan helpers.Result); ok {
 		r0 = rf(ctx)
@@ -56,6 +119,146 @@ func (_m *MongodbRepositoryQuery) FindAllExpirePayment(ctx context.Context) <-ch
 	return r0
 }
 
+// FindAllInvalidPayment provides a mock function with given fields: ctx, page, from, before
+func (_m *MongodbRepositoryQuery) FindAllInvalidPayment(ctx context.Context, page request.KeysetPageReq, from time.Time, before time.Time) <-chan helpers.Result {
+	ret := _m.Called(ctx, page, from, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllInvalidPayment")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.KeysetPageReq, time.Time, time.Time) <-chan helpers.Result); ok {
+		r0 = rf(ctx, page, from, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllOrder provides a mock function with given fields: ctx, page, before
+func (_m *MongodbRepositoryQuery) FindAllOrder(ctx context.Context, page request.OrderPageReq, before time.Time) <-chan helpers.Result {
+	ret := _m.Called(ctx, page, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllOrder")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.OrderPageReq, time.Time) <-chan helpers.Result); ok {
+		r0 = rf(ctx, page, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllPendingOutboxEvent provides a mock function with given fields: ctx, limit
+func (_m *MongodbRepositoryQuery) FindAllPendingOutboxEvent(ctx context.Context, limit int64) <-chan helpers.Result {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllPendingOutboxEvent")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, int64) <-chan helpers.Result); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllTicketDetail provides a mock function with given fields: ctx, page
+func (_m *MongodbRepositoryQuery) FindAllTicketDetail(ctx context.Context, page request.KeysetPageReq) <-chan helpers.Result {
+	ret := _m.Called(ctx, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllTicketDetail")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.KeysetPageReq) <-chan helpers.Result); ok {
+		r0 = rf(ctx, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllUnusedBankTicket provides a mock function with given fields: ctx, ticketId, page
+func (_m *MongodbRepositoryQuery) FindAllUnusedBankTicket(ctx context.Context, ticketId string, page request.KeysetPageReq) <-chan helpers.Result {
+	ret := _m.Called(ctx, ticketId, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllUnusedBankTicket")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string, request.KeysetPageReq) <-chan helpers.Result); ok {
+		r0 = rf(ctx, ticketId, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllUsedBankTicket provides a mock function with given fields: ctx, page, before
+func (_m *MongodbRepositoryQuery) FindAllUsedBankTicket(ctx context.Context, page request.KeysetPageReq, before time.Time) <-chan helpers.Result {
+	ret := _m.Called(ctx, page, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllUsedBankTicket")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.KeysetPageReq, time.Time) <-chan helpers.Result); ok {
+		r0 = rf(ctx, page, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
+// FindAllWorkerJobRun provides a mock function with given fields: ctx, payload
+func (_m *MongodbRepositoryQuery) FindAllWorkerJobRun(ctx context.Context, payload request.CronRunReq) <-chan helpers.Result {
+	ret := _m.Called(ctx, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllWorkerJobRun")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, request.CronRunReq) <-chan helpers.Result); ok {
+		r0 = rf(ctx, payload)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // FindBankTicketByTicketNumber provides a mock function with given fields: ctx, ticketNumber
 func (_m *MongodbRepositoryQuery) FindBankTicketByTicketNumber(ctx context.Context, ticketNumber string) <-chan helpers.Result {
 	ret := _m.Called(ctx, ticketNumber)
@@ -76,6 +279,26 @@ func (_m *MongodbRepositoryQuery) FindBankTicketByTicketNumber(ctx context.Conte
 	return r0
 }
 
+// FindOneBankTicketProgress provides a mock function with given fields: ctx, id
+func (_m *MongodbRepositoryQuery) FindOneBankTicketProgress(ctx context.Context, id string) <-chan helpers.Result {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindOneBankTicketProgress")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // FindOneLastTicket provides a mock function with given fields: ctx, countryCode, ticketType, eventId, collectionName
 func (_m *MongodbRepositoryQuery) FindOneLastTicket(ctx context.Context, countryCode string, ticketType string, eventId string, collectionName string) <-chan helpers.Result {
 	ret := _m.Called(ctx, countryCode, ticketType, eventId, collectionName)
@@ -156,6 +379,26 @@ func (_m *MongodbRepositoryQuery) FindOneTicketDetailByTag(ctx context.Context,
 	return r0
 }
 
+// FindOneWorkerJob provides a mock function with given fields: ctx, id
+func (_m *MongodbRepositoryQuery) FindOneWorkerJob(ctx context.Context, id string) <-chan helpers.Result {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindOneWorkerJob")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // FindOnlineTicketConfigByTag provides a mock function with given fields: ctx, tag
 func (_m *MongodbRepositoryQuery) FindOnlineTicketConfigByTag(ctx context.Context, tag string) <-chan helpers.Result {
 	ret := _m.Called(ctx, tag)
@@ -176,6 +419,26 @@ func (_m *MongodbRepositoryQuery) FindOnlineTicketConfigByTag(ctx context.Contex
 	return r0
 }
 
+// FindOrderByTicketNumber provides a mock function with given fields: ctx, ticketNumber
+func (_m *MongodbRepositoryQuery) FindOrderByTicketNumber(ctx context.Context, ticketNumber string) <-chan helpers.Result {
+	ret := _m.Called(ctx, ticketNumber)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindOrderByTicketNumber")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(context.Context, string) <-chan helpers.Result); ok {
+		r0 = rf(ctx, ticketNumber)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // FindPaymentByTicketNumber provides a mock function with given fields: ctx, ticketNumber
 func (_m *MongodbRepositoryQuery) FindPaymentByTicketNumber(ctx context.Context, ticketNumber string) <-chan helpers.Result {
 	ret := _m.Called(ctx, ticketNumber)
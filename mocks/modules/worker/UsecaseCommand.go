@@ -4,9 +4,14 @@ package mocks
 
 import (
 	context "context"
-	request "worker-service/internal/modules/worker/models/request"
+	dto "worker-service/internal/modules/worker/models/dto"
+	entity "worker-service/internal/modules/worker/models/entity"
 
 	mock "github.com/stretchr/testify/mock"
+
+	request "worker-service/internal/modules/worker/models/request"
+
+	time "time"
 )
 
 // UsecaseCommand is an autogenerated mock type for the UsecaseCommand type
@@ -14,6 +19,82 @@ type UsecaseCommand struct {
 	mock.Mock
 }
 
+// ClaimOutboxEvent provides a mock function with given fields: origCtx, id, until
+func (_m *UsecaseCommand) ClaimOutboxEvent(origCtx context.Context, id string, until time.Time) (bool, error) {
+	ret := _m.Called(origCtx, id, until)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimOutboxEvent")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) (bool, error)); ok {
+		return rf(origCtx, id, until)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) bool); ok {
+		r0 = rf(origCtx, id, until)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(origCtx, id, until)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CleanupOrphan provides a mock function with given fields: origCtx
+func (_m *UsecaseCommand) CleanupOrphan(origCtx context.Context) (*entity.WorkerJobRun, error) {
+	ret := _m.Called(origCtx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CleanupOrphan")
+	}
+
+	var r0 *entity.WorkerJobRun
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*entity.WorkerJobRun, error)); ok {
+		return rf(origCtx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *entity.WorkerJobRun); ok {
+		r0 = rf(origCtx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.WorkerJobRun)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(origCtx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Close provides a mock function with given fields: ctx
+func (_m *UsecaseCommand) Close(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateBankTicket provides a mock function with given fields: origCtx, payload
 func (_m *UsecaseCommand) CreateBankTicket(origCtx context.Context, payload request.CreateTicketReq) (*string, error) {
 	ret := _m.Called(origCtx, payload)
@@ -74,24 +155,220 @@ func (_m *UsecaseCommand) CreateOnlineBankTicket(origCtx context.Context, payloa
 	return r0, r1
 }
 
+// DeleteExpiryPolicy provides a mock function with given fields: origCtx, id
+func (_m *UsecaseCommand) DeleteExpiryPolicy(origCtx context.Context, id string) error {
+	ret := _m.Called(origCtx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpiryPolicy")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(origCtx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnqueueBankTicketJob provides a mock function with given fields: origCtx, payload
+func (_m *UsecaseCommand) EnqueueBankTicketJob(origCtx context.Context, payload request.CreateTicketReq) (*entity.WorkerJob, error) {
+	ret := _m.Called(origCtx, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnqueueBankTicketJob")
+	}
+
+	var r0 *entity.WorkerJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, request.CreateTicketReq) (*entity.WorkerJob, error)); ok {
+		return rf(origCtx, payload)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, request.CreateTicketReq) *entity.WorkerJob); ok {
+		r0 = rf(origCtx, payload)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.WorkerJob)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, request.CreateTicketReq) error); ok {
+		r1 = rf(origCtx, payload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FailAbandonedWorkerJob provides a mock function with given fields: origCtx
+func (_m *UsecaseCommand) FailAbandonedWorkerJob(origCtx context.Context) (int64, error) {
+	ret := _m.Called(origCtx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FailAbandonedWorkerJob")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int64, error)); ok {
+		return rf(origCtx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int64); ok {
+		r0 = rf(origCtx)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(origCtx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RebuildSeatPool provides a mock function with given fields: origCtx, ticketId
+func (_m *UsecaseCommand) RebuildSeatPool(origCtx context.Context, ticketId string) (*dto.SeatPool, error) {
+	ret := _m.Called(origCtx, ticketId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RebuildSeatPool")
+	}
+
+	var r0 *dto.SeatPool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*dto.SeatPool, error)); ok {
+		return rf(origCtx, ticketId)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *dto.SeatPool); ok {
+		r0 = rf(origCtx, ticketId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.SeatPool)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(origCtx, ticketId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReconcileInventory provides a mock function with given fields: origCtx
+func (_m *UsecaseCommand) ReconcileInventory(origCtx context.Context) (*entity.WorkerJobRun, error) {
+	ret := _m.Called(origCtx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReconcileInventory")
+	}
+
+	var r0 *entity.WorkerJobRun
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*entity.WorkerJobRun, error)); ok {
+		return rf(origCtx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *entity.WorkerJobRun); ok {
+		r0 = rf(origCtx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.WorkerJobRun)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(origCtx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReconcileTicketDetail provides a mock function with given fields: origCtx, fix
+func (_m *UsecaseCommand) ReconcileTicketDetail(origCtx context.Context, fix bool) (*entity.WorkerJobRun, error) {
+	ret := _m.Called(origCtx, fix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReconcileTicketDetail")
+	}
+
+	var r0 *entity.WorkerJobRun
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, bool) (*entity.WorkerJobRun, error)); ok {
+		return rf(origCtx, fix)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, bool) *entity.WorkerJobRun); ok {
+		r0 = rf(origCtx, fix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.WorkerJobRun)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, bool) error); ok {
+		r1 = rf(origCtx, fix)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SimulateOnlineBankTicket provides a mock function with given fields: origCtx, payload
+func (_m *UsecaseCommand) SimulateOnlineBankTicket(origCtx context.Context, payload request.SimulateOnlineTicketReq) (*dto.OnlineTicketSimulation, error) {
+	ret := _m.Called(origCtx, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SimulateOnlineBankTicket")
+	}
+
+	var r0 *dto.OnlineTicketSimulation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, request.SimulateOnlineTicketReq) (*dto.OnlineTicketSimulation, error)); ok {
+		return rf(origCtx, payload)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, request.SimulateOnlineTicketReq) *dto.OnlineTicketSimulation); ok {
+		r0 = rf(origCtx, payload)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.OnlineTicketSimulation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, request.SimulateOnlineTicketReq) error); ok {
+		r1 = rf(origCtx, payload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateAllExpiryBankTicket provides a mock function with given fields: origCtx
-func (_m *UsecaseCommand) UpdateAllExpiryBankTicket(origCtx context.Context) (*string, error) {
+func (_m *UsecaseCommand) UpdateAllExpiryBankTicket(origCtx context.Context) (*entity.WorkerJobRun, error) {
 	ret := _m.Called(origCtx)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateAllExpiryBankTicket")
 	}
 
-	var r0 *string
+	var r0 *entity.WorkerJobRun
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) (*string, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) (*entity.WorkerJobRun, error)); ok {
 		return rf(origCtx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) *string); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) *entity.WorkerJobRun); ok {
 		r0 = rf(origCtx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*string)
+			r0 = ret.Get(0).(*entity.WorkerJobRun)
 		}
 	}
 
@@ -105,23 +382,23 @@ func (_m *UsecaseCommand) UpdateAllExpiryBankTicket(origCtx context.Context) (*s
 }
 
 // UpdateAllExpiryPayment provides a mock function with given fields: origCtx
-func (_m *UsecaseCommand) UpdateAllExpiryPayment(origCtx context.Context) (*string, error) {
+func (_m *UsecaseCommand) UpdateAllExpiryPayment(origCtx context.Context) (*entity.WorkerJobRun, error) {
 	ret := _m.Called(origCtx)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateAllExpiryPayment")
 	}
 
-	var r0 *string
+	var r0 *entity.WorkerJobRun
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) (*string, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) (*entity.WorkerJobRun, error)); ok {
 		return rf(origCtx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) *string); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) *entity.WorkerJobRun); ok {
 		r0 = rf(origCtx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*string)
+			r0 = ret.Get(0).(*entity.WorkerJobRun)
 		}
 	}
 
@@ -134,6 +411,90 @@ func (_m *UsecaseCommand) UpdateAllExpiryPayment(origCtx context.Context) (*stri
 	return r0, r1
 }
 
+// UpdateOutboxEventRetry provides a mock function with given fields: origCtx, id, nextAttemptAt, reason
+func (_m *UsecaseCommand) UpdateOutboxEventRetry(origCtx context.Context, id string, nextAttemptAt time.Time, reason string) error {
+	ret := _m.Called(origCtx, id, nextAttemptAt, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOutboxEventRetry")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, string) error); ok {
+		r0 = rf(origCtx, id, nextAttemptAt, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateOutboxEventSent provides a mock function with given fields: origCtx, id
+func (_m *UsecaseCommand) UpdateOutboxEventSent(origCtx context.Context, id string) error {
+	ret := _m.Called(origCtx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOutboxEventSent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(origCtx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertCronJob provides a mock function with given fields: origCtx, job
+func (_m *UsecaseCommand) UpsertCronJob(origCtx context.Context, job entity.CronJob) error {
+	ret := _m.Called(origCtx, job)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertCronJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, entity.CronJob) error); ok {
+		r0 = rf(origCtx, job)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpsertExpiryPolicy provides a mock function with given fields: origCtx, payload
+func (_m *UsecaseCommand) UpsertExpiryPolicy(origCtx context.Context, payload request.UpsertExpiryPolicyReq) (*entity.ExpiryPolicy, error) {
+	ret := _m.Called(origCtx, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertExpiryPolicy")
+	}
+
+	var r0 *entity.ExpiryPolicy
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, request.UpsertExpiryPolicyReq) (*entity.ExpiryPolicy, error)); ok {
+		return rf(origCtx, payload)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, request.UpsertExpiryPolicyReq) *entity.ExpiryPolicy); ok {
+		r0 = rf(origCtx, payload)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.ExpiryPolicy)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, request.UpsertExpiryPolicyReq) error); ok {
+		r1 = rf(origCtx, payload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewUsecaseCommand creates a new instance of UsecaseCommand. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUsecaseCommand(t interface {
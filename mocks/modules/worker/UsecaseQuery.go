@@ -0,0 +1,214 @@
+// Code generated by mockery v2.40.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	dto "worker-service/internal/modules/worker/models/dto"
+	entity "worker-service/internal/modules/worker/models/entity"
+
+	mock "github.com/stretchr/testify/mock"
+
+	request "worker-service/internal/modules/worker/models/request"
+
+	response "worker-service/internal/modules/worker/models/response"
+)
+
+// UsecaseQuery is an autogenerated mock type for the UsecaseQuery type
+type UsecaseQuery struct {
+	mock.Mock
+}
+
+// FindAllCronJob provides a mock function with given fields: origCtx
+func (_m *UsecaseQuery) FindAllCronJob(origCtx context.Context) ([]entity.CronJob, error) {
+	ret := _m.Called(origCtx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllCronJob")
+	}
+
+	var r0 []entity.CronJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]entity.CronJob, error)); ok {
+		return rf(origCtx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []entity.CronJob); ok {
+		r0 = rf(origCtx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.CronJob)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(origCtx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindAllCronRun provides a mock function with given fields: origCtx, payload
+func (_m *UsecaseQuery) FindAllCronRun(origCtx context.Context, payload request.CronRunReq) (*response.CronRunResp, error) {
+	ret := _m.Called(origCtx, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllCronRun")
+	}
+
+	var r0 *response.CronRunResp
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, request.CronRunReq) (*response.CronRunResp, error)); ok {
+		return rf(origCtx, payload)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, request.CronRunReq) *response.CronRunResp); ok {
+		r0 = rf(origCtx, payload)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*response.CronRunResp)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, request.CronRunReq) error); ok {
+		r1 = rf(origCtx, payload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindAllExpiryPolicy provides a mock function with given fields: origCtx
+func (_m *UsecaseQuery) FindAllExpiryPolicy(origCtx context.Context) ([]entity.ExpiryPolicy, error) {
+	ret := _m.Called(origCtx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllExpiryPolicy")
+	}
+
+	var r0 []entity.ExpiryPolicy
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]entity.ExpiryPolicy, error)); ok {
+		return rf(origCtx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []entity.ExpiryPolicy); ok {
+		r0 = rf(origCtx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.ExpiryPolicy)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(origCtx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindAllPendingOutboxEvent provides a mock function with given fields: origCtx, limit
+func (_m *UsecaseQuery) FindAllPendingOutboxEvent(origCtx context.Context, limit int) ([]entity.OutboxEvent, error) {
+	ret := _m.Called(origCtx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindAllPendingOutboxEvent")
+	}
+
+	var r0 []entity.OutboxEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]entity.OutboxEvent, error)); ok {
+		return rf(origCtx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []entity.OutboxEvent); ok {
+		r0 = rf(origCtx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entity.OutboxEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(origCtx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindInventory provides a mock function with given fields: origCtx, ticketId, countryCode
+func (_m *UsecaseQuery) FindInventory(origCtx context.Context, ticketId string, countryCode string) (*dto.Inventory, error) {
+	ret := _m.Called(origCtx, ticketId, countryCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindInventory")
+	}
+
+	var r0 *dto.Inventory
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*dto.Inventory, error)); ok {
+		return rf(origCtx, ticketId, countryCode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *dto.Inventory); ok {
+		r0 = rf(origCtx, ticketId, countryCode)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.Inventory)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(origCtx, ticketId, countryCode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindWorkerJob provides a mock function with given fields: origCtx, id
+func (_m *UsecaseQuery) FindWorkerJob(origCtx context.Context, id string) (*entity.WorkerJob, error) {
+	ret := _m.Called(origCtx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindWorkerJob")
+	}
+
+	var r0 *entity.WorkerJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entity.WorkerJob, error)); ok {
+		return rf(origCtx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entity.WorkerJob); ok {
+		r0 = rf(origCtx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entity.WorkerJob)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(origCtx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewUsecaseQuery creates a new instance of UsecaseQuery. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewUsecaseQuery(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *UsecaseQuery {
+	mock := &UsecaseQuery{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
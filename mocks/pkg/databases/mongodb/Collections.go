@@ -38,6 +38,26 @@ func (_m *Collections) Aggregate(payload mongodb.Aggregate, ctx context.Context)
 	return r0
 }
 
+// BulkInsert provides a mock function with given fields: payload, ctx
+func (_m *Collections) BulkInsert(payload mongodb.BulkInsert, ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(payload, ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BulkInsert")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(mongodb.BulkInsert, context.Context) <-chan helpers.Result); ok {
+		r0 = rf(payload, ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // Close provides a mock function with given fields: ctx
 func (_m *Collections) Close(ctx context.Context) error {
 	ret := _m.Called(ctx)
@@ -76,6 +96,26 @@ func (_m *Collections) CountData(payload mongodb.CountData, ctx context.Context)
 	return r0
 }
 
+// CreateIndex provides a mock function with given fields: payload, ctx
+func (_m *Collections) CreateIndex(payload mongodb.CreateIndex, ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(payload, ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateIndex")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(mongodb.CreateIndex, context.Context) <-chan helpers.Result); ok {
+		r0 = rf(payload, ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // DeleteOne provides a mock function with given fields: payload, ctx
 func (_m *Collections) DeleteOne(payload mongodb.DeleteOne, ctx context.Context) <-chan helpers.Result {
 	ret := _m.Called(payload, ctx)
@@ -156,6 +196,26 @@ func (_m *Collections) FindOneAndUpdate(payload mongodb.FindOneAndUpdate, rd opt
 	return r0
 }
 
+// IncrementOne provides a mock function with given fields: payload, ctx
+func (_m *Collections) IncrementOne(payload mongodb.IncrementOne, ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(payload, ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IncrementOne")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(mongodb.IncrementOne, context.Context) <-chan helpers.Result); ok {
+		r0 = rf(payload, ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // InsertMany provides a mock function with given fields: payload, ctx
 func (_m *Collections) InsertMany(payload mongodb.InsertMany, ctx context.Context) <-chan helpers.Result {
 	ret := _m.Called(payload, ctx)
@@ -196,6 +256,26 @@ func (_m *Collections) InsertOne(payload mongodb.InsertOne, ctx context.Context)
 	return r0
 }
 
+// UpdateMany provides a mock function with given fields: payload, ctx
+func (_m *Collections) UpdateMany(payload mongodb.UpdateOne, ctx context.Context) <-chan helpers.Result {
+	ret := _m.Called(payload, ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateMany")
+	}
+
+	var r0 <-chan helpers.Result
+	if rf, ok := ret.Get(0).(func(mongodb.UpdateOne, context.Context) <-chan helpers.Result); ok {
+		r0 = rf(payload, ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan helpers.Result)
+		}
+	}
+
+	return r0
+}
+
 // UpdateOne provides a mock function with given fields: payload, ctx
 func (_m *Collections) UpdateOne(payload mongodb.UpdateOne, ctx context.Context) <-chan helpers.Result {
 	ret := _m.Called(payload, ctx)
@@ -236,6 +316,24 @@ func (_m *Collections) UpsertOne(payload mongodb.UpdateOne, ctx context.Context)
 	return r0
 }
 
+// WithTransaction provides a mock function with given fields: ctx, fn
+func (_m *Collections) WithTransaction(ctx context.Context, fn func(context.Context) error) error {
+	ret := _m.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithTransaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(context.Context) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewCollections creates a new instance of Collections. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewCollections(t interface {
@@ -1,4 +1,4 @@
-// Code generated by mockery v2.39.1. DO NOT EDIT.
+// Code generated by mockery v2.40.1. DO NOT EDIT.
 
 package mocks
 
@@ -32,11 +32,16 @@ func (_m *Consumer) Close(ctx context.Context) error {
 	return r0
 }
 
-// SetHandler provides a mock function with given fields: handler
-func (_m *Consumer) SetHandler(handler kafka.ConsumerHandler) {
+// SetFailureHandler provides a mock function with given fields: handler
+func (_m *Consumer) SetFailureHandler(handler kafka.FailureHandlerFunc) {
 	_m.Called(handler)
 }
 
+// SetRouter provides a mock function with given fields: router
+func (_m *Consumer) SetRouter(router kafka.Router) {
+	_m.Called(router)
+}
+
 // Subscribe provides a mock function with given fields: topics
 func (_m *Consumer) Subscribe(topics ...string) {
 	_va := make([]interface{}, len(topics))
@@ -0,0 +1,180 @@
+// Code generated by mockery v2.40.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	confluent_kafka_go_v1kafka "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// ConsumerClient is an autogenerated mock type for the ConsumerClient type
+type ConsumerClient struct {
+	mock.Mock
+}
+
+// Close provides a mock function with given fields:
+func (_m *ConsumerClient) Close() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CommitMessage provides a mock function with given fields: message
+func (_m *ConsumerClient) CommitMessage(message *confluent_kafka_go_v1kafka.Message) ([]confluent_kafka_go_v1kafka.TopicPartition, error) {
+	ret := _m.Called(message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CommitMessage")
+	}
+
+	var r0 []confluent_kafka_go_v1kafka.TopicPartition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*confluent_kafka_go_v1kafka.Message) ([]confluent_kafka_go_v1kafka.TopicPartition, error)); ok {
+		return rf(message)
+	}
+	if rf, ok := ret.Get(0).(func(*confluent_kafka_go_v1kafka.Message) []confluent_kafka_go_v1kafka.TopicPartition); ok {
+		r0 = rf(message)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]confluent_kafka_go_v1kafka.TopicPartition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*confluent_kafka_go_v1kafka.Message) error); ok {
+		r1 = rf(message)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Pause provides a mock function with given fields: partitions
+func (_m *ConsumerClient) Pause(partitions []confluent_kafka_go_v1kafka.TopicPartition) error {
+	ret := _m.Called(partitions)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Pause")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]confluent_kafka_go_v1kafka.TopicPartition) error); ok {
+		r0 = rf(partitions)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReadMessage provides a mock function with given fields: timeout
+func (_m *ConsumerClient) ReadMessage(timeout time.Duration) (*confluent_kafka_go_v1kafka.Message, error) {
+	ret := _m.Called(timeout)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadMessage")
+	}
+
+	var r0 *confluent_kafka_go_v1kafka.Message
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Duration) (*confluent_kafka_go_v1kafka.Message, error)); ok {
+		return rf(timeout)
+	}
+	if rf, ok := ret.Get(0).(func(time.Duration) *confluent_kafka_go_v1kafka.Message); ok {
+		r0 = rf(timeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*confluent_kafka_go_v1kafka.Message)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Duration) error); ok {
+		r1 = rf(timeout)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Resume provides a mock function with given fields: partitions
+func (_m *ConsumerClient) Resume(partitions []confluent_kafka_go_v1kafka.TopicPartition) error {
+	ret := _m.Called(partitions)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Resume")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]confluent_kafka_go_v1kafka.TopicPartition) error); ok {
+		r0 = rf(partitions)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Seek provides a mock function with given fields: partition, timeoutMs
+func (_m *ConsumerClient) Seek(partition confluent_kafka_go_v1kafka.TopicPartition, timeoutMs int) error {
+	ret := _m.Called(partition, timeoutMs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Seek")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(confluent_kafka_go_v1kafka.TopicPartition, int) error); ok {
+		r0 = rf(partition, timeoutMs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SubscribeTopics provides a mock function with given fields: topics, rebalanceCb
+func (_m *ConsumerClient) SubscribeTopics(topics []string, rebalanceCb confluent_kafka_go_v1kafka.RebalanceCb) error {
+	ret := _m.Called(topics, rebalanceCb)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SubscribeTopics")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]string, confluent_kafka_go_v1kafka.RebalanceCb) error); ok {
+		r0 = rf(topics, rebalanceCb)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewConsumerClient creates a new instance of ConsumerClient. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewConsumerClient(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *ConsumerClient {
+	mock := &ConsumerClient{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
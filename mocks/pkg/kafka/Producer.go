@@ -1,9 +1,10 @@
-// Code generated by mockery v2.39.1. DO NOT EDIT.
+// Code generated by mockery v2.40.1. DO NOT EDIT.
 
 package mocks
 
 import (
 	context "context"
+	kafka "worker-service/internal/pkg/kafka/confluent"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -36,6 +37,42 @@ func (_m *Producer) Publish(topic string, message []byte, kafkaPartition *int32)
 	_m.Called(topic, message, kafkaPartition)
 }
 
+// PublishAsync provides a mock function with given fields: topic, key, headers, value, onDelivery
+func (_m *Producer) PublishAsync(topic string, key string, headers map[string]string, value []byte, onDelivery kafka.DeliveryFunc) error {
+	ret := _m.Called(topic, key, headers, value, onDelivery)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishAsync")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, map[string]string, []byte, kafka.DeliveryFunc) error); ok {
+		r0 = rf(topic, key, headers, value, onDelivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PublishSync provides a mock function with given fields: ctx, topic, key, headers, value
+func (_m *Producer) PublishSync(ctx context.Context, topic string, key string, headers map[string]string, value []byte) error {
+	ret := _m.Called(ctx, topic, key, headers, value)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishSync")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, map[string]string, []byte) error); ok {
+		r0 = rf(ctx, topic, key, headers, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewProducer creates a new instance of Producer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewProducer(t interface {
@@ -0,0 +1,82 @@
+// Code generated by mockery v2.40.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	confluent "worker-service/internal/pkg/kafka/confluent"
+
+	kafka "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Retrier is an autogenerated mock type for the Retrier type
+type Retrier struct {
+	mock.Mock
+}
+
+// Fail provides a mock function with given fields: ctx, message, err
+func (_m *Retrier) Fail(ctx context.Context, message *kafka.Message, err error) error {
+	ret := _m.Called(ctx, message, err)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Fail")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *kafka.Message, error) error); ok {
+		r0 = rf(ctx, message, err)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Handle provides a mock function with given fields: router, topic, handler
+func (_m *Retrier) Handle(router confluent.Router, topic string, handler confluent.HandlerFunc) {
+	_m.Called(router, topic, handler)
+}
+
+// Replay provides a mock function with given fields: ctx, topic, limit
+func (_m *Retrier) Replay(ctx context.Context, topic string, limit int) (int, error) {
+	ret := _m.Called(ctx, topic, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Replay")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) (int, error)); ok {
+		return rf(ctx, topic, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) int); ok {
+		r0 = rf(ctx, topic, limit)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, topic, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewRetrier creates a new instance of Retrier. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRetrier(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Retrier {
+	mock := &Retrier{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
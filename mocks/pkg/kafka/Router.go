@@ -0,0 +1,104 @@
+// Code generated by mockery v2.40.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	confluent "worker-service/internal/pkg/kafka/confluent"
+
+	kafka "gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// Router is an autogenerated mock type for the Router type
+type Router struct {
+	mock.Mock
+}
+
+// Dispatch provides a mock function with given fields: ctx, message
+func (_m *Router) Dispatch(ctx context.Context, message *kafka.Message) error {
+	ret := _m.Called(ctx, message)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Dispatch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *kafka.Message) error); ok {
+		r0 = rf(ctx, message)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Handle provides a mock function with given fields: topic, handler
+func (_m *Router) Handle(topic string, handler confluent.HandlerFunc) {
+	_m.Called(topic, handler)
+}
+
+// Match provides a mock function with given fields: topic
+func (_m *Router) Match(topic string) (confluent.HandlerFunc, bool) {
+	ret := _m.Called(topic)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Match")
+	}
+
+	var r0 confluent.HandlerFunc
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(string) (confluent.HandlerFunc, bool)); ok {
+		return rf(topic)
+	}
+	if rf, ok := ret.Get(0).(func(string) confluent.HandlerFunc); ok {
+		r0 = rf(topic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(confluent.HandlerFunc)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(topic)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// Topics provides a mock function with given fields:
+func (_m *Router) Topics() []string {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Topics")
+	}
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// NewRouter creates a new instance of Router. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRouter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Router {
+	mock := &Router{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}